@@ -98,6 +98,11 @@ func (a *App) GetDashboardCounts(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{out})
 }
 
+// GetManagerMetrics returns the campaign manager's queue and throughput metrics.
+func (a *App) GetManagerMetrics(c echo.Context) error {
+	return c.JSON(http.StatusOK, okResp{a.manager.Metrics()})
+}
+
 // ReloadApp sends a reload signal to the app, causing a full restart.
 func (a *App) ReloadApp(c echo.Context) error {
 	go func() {
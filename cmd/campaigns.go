@@ -358,8 +358,14 @@ func (a *App) UpdateCampaignStatus(c echo.Context) error {
 	}
 
 	// If the campaign is being stopped, send the signal to the manager to stop it in flight.
-	if req.Status == models.CampaignStatusPaused || req.Status == models.CampaignStatusCancelled {
+	// Cancelled campaigns have their queued messages dropped and checkpoints discarded.
+	switch req.Status {
+	case models.CampaignStatusPaused:
 		a.manager.StopCampaign(id)
+	case models.CampaignStatusCancelled:
+		if err := a.manager.CancelCampaign(id); err != nil {
+			a.log.Printf("error cancelling campaign %d: %v", id, err)
+		}
 	}
 
 	return c.JSON(http.StatusOK, okResp{out})
@@ -451,6 +457,12 @@ func (a *App) GetRunningCampaignStats(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{out})
 }
 
+// GetRunningCampaigns returns the campaigns that the campaign manager is
+// currently processing with their live stats.
+func (a *App) GetRunningCampaigns(c echo.Context) error {
+	return c.JSON(http.StatusOK, okResp{a.manager.RunningCampaigns()})
+}
+
 // TestCampaign handles the sending of a campaign message to
 // arbitrary subscribers for testing.
 func (a *App) TestCampaign(c echo.Context) error {
@@ -150,6 +150,7 @@ type Config struct {
 		Strategy          string `koanf:"strategy"`          // subdomain, domain, header
 		DefaultTenantID   int    `koanf:"default_tenant_id"`
 		CreateDefaultTenant bool `koanf:"create_default_tenant"`
+		ExposeTenantHeaders bool `koanf:"expose_tenant_headers"`
 	} `koanf:"tenant"`
 }
 
@@ -174,6 +175,7 @@ func initFlags(ko *koanf.Koanf) {
 	f.String("i18n-dir", "", "(optional) path to directory with i18n language files")
 	f.Bool("yes", false, "assume 'yes' to prompts during --install/upgrade")
 	f.Bool("passive", false, "run in passive mode where campaigns are not processed")
+	f.Bool("dry-run", false, "render and process campaigns without sending out any messages")
 	if err := f.Parse(os.Args[1:]); err != nil {
 		lo.Fatalf("error loading flags: %v", err)
 	}
@@ -514,6 +516,10 @@ func initTenantMiddleware(db *sqlx.DB, queries *models.Queries, cfg *Config) *mi
 	lo.Println("tenant mode enabled")
 	
 	tm := middleware.NewTenantMiddleware(db, queries)
+	if ko.Exists("tenant.cache_ttl") {
+		tm.SetCacheTTL(ko.Duration("tenant.cache_ttl"))
+	}
+	tm.SetExposeTenantHeaders(cfg.Tenant.ExposeTenantHeaders)
 	
 	// Create default tenant if configured
 	if cfg.Tenant.CreateDefaultTenant {
@@ -586,10 +592,15 @@ func initI18n(lang string, fs stuffbin.FileSystem) *i18n.I18n {
 
 // initCore initializes the CRUD DB core .
 func initCore(fnNotify func(sub models.Subscriber, listIDs []int) (int, error), queries *models.Queries, db *sqlx.DB, i *i18n.I18n, ko *koanf.Koanf) *core.Core {
+	if ko.String("app.secret_key") == "" {
+		lo.Println("WARNING: app.secret_key is not set. Secrets such as tenant SMTP passwords and DKIM keys will be stored unencrypted in the database.")
+	}
+
 	opt := &core.Opt{
 		Constants: core.Constants{
 			SendOptinConfirmation: ko.Bool("app.send_optin_confirmation"),
 			CacheSlowQueries:      ko.Bool("app.cache_slow_queries"),
+			SecretKey:             ko.String("app.secret_key"),
 		},
 		Queries: queries,
 		DB:      db,
@@ -608,33 +619,58 @@ func initCore(fnNotify func(sub models.Subscriber, listIDs []int) (int, error),
 	})
 }
 
-// initCampaignManager initializes the campaign manager.
+// campaignManagerConfig returns the config of the campaign managers.
+func campaignManagerConfig(u *UrlConfig, ko *koanf.Koanf) manager.Config {
+	return manager.Config{
+		BatchSize:               ko.Int("app.batch_size"),
+		Concurrency:             ko.Int("app.concurrency"),
+		MessageRate:             ko.Int("app.message_rate"),
+		MaxSendErrors:           ko.Int("app.max_send_errors"),
+		FromEmail:               ko.String("app.from_email"),
+		IndividualTracking:      ko.Bool("privacy.individual_tracking"),
+		UnsubURL:                u.UnsubURL,
+		OptinURL:                u.OptinURL,
+		LinkTrackURL:            u.LinkTrackURL,
+		ViewTrackURL:            u.ViewTrackURL,
+		MessageURL:              u.MessageURL,
+		ArchiveURL:              u.ArchiveURL,
+		RootURL:                 u.RootURL,
+		UnsubHeader:             ko.Bool("privacy.unsubscribe_header"),
+		UnsubMailto:             ko.String("privacy.unsubscribe_mailto"),
+		SlidingWindow:           ko.Bool("app.message_sliding_window"),
+		SlidingWindowDuration:   ko.Duration("app.message_sliding_window_duration"),
+		SlidingWindowRate:       ko.Int("app.message_sliding_window_rate"),
+		ScanInterval:            time.Second * 5,
+		ScanCampaigns:           !ko.Bool("passive"),
+		DryRun:                  ko.Bool("dry-run"),
+		MaxLinkCache:            ko.Int("app.max_link_cache"),
+		WarmLinkCache:           ko.Bool("app.warm_link_cache"),
+		SendWindowStart:         ko.String("app.send_window_start"),
+		SendWindowEnd:           ko.String("app.send_window_end"),
+		SendWindowTimezone:      ko.String("app.send_window_timezone"),
+		AutoPlainText:           ko.Bool("app.auto_plain_text"),
+		StreamAttachments:       ko.Bool("app.stream_attachments"),
+		AdaptiveBatch:           ko.Bool("app.adaptive_batch"),
+		EnforceUnsubFooter:      ko.Bool("app.enforce_unsub_footer"),
+		MaxAttachmentBytes:      ko.Int64("app.max_attachment_bytes"),
+		MaxConcurrencyPerTenant: ko.Int("app.max_concurrency_per_tenant"),
+	}
+}
+
+// initCampaignManager initializes the campaign manager. In tenant mode, the
+// campaigns are processed by the tenant manager (initTenantManager()) instead.
 func initCampaignManager(msgrs []manager.Messenger, q *models.Queries, u *UrlConfig, co *core.Core, md media.Store, i *i18n.I18n, ko *koanf.Koanf) *manager.Manager {
 	if ko.Bool("passive") {
 		lo.Println("running in passive mode. won't process campaigns.")
 	}
 
-	mgr := manager.New(manager.Config{
-		BatchSize:             ko.Int("app.batch_size"),
-		Concurrency:           ko.Int("app.concurrency"),
-		MessageRate:           ko.Int("app.message_rate"),
-		MaxSendErrors:         ko.Int("app.max_send_errors"),
-		FromEmail:             ko.String("app.from_email"),
-		IndividualTracking:    ko.Bool("privacy.individual_tracking"),
-		UnsubURL:              u.UnsubURL,
-		OptinURL:              u.OptinURL,
-		LinkTrackURL:          u.LinkTrackURL,
-		ViewTrackURL:          u.ViewTrackURL,
-		MessageURL:            u.MessageURL,
-		ArchiveURL:            u.ArchiveURL,
-		RootURL:               u.RootURL,
-		UnsubHeader:           ko.Bool("privacy.unsubscribe_header"),
-		SlidingWindow:         ko.Bool("app.message_sliding_window"),
-		SlidingWindowDuration: ko.Duration("app.message_sliding_window_duration"),
-		SlidingWindowRate:     ko.Int("app.message_sliding_window_rate"),
-		ScanInterval:          time.Second * 5,
-		ScanCampaigns:         !ko.Bool("passive"),
-	}, newManagerStore(q, co, md), i, lo)
+	cfg := campaignManagerConfig(u, ko)
+	cfg.ScanCampaigns = cfg.ScanCampaigns && !ko.Bool("tenant.enabled")
+
+	mgr, err := manager.New(cfg, newManagerStore(q, co, md), i, lo)
+	if err != nil {
+		lo.Fatalf("error initializing campaign manager: %v", err)
+	}
 
 	// Attach all messengers to the campaign manager.
 	for _, m := range msgrs {
@@ -644,6 +680,23 @@ func initCampaignManager(msgrs []manager.Messenger, q *models.Queries, u *UrlCon
 	return mgr
 }
 
+// initTenantManager initializes the campaign manager that processes every
+// tenant's campaigns in isolated instances if tenant mode is enabled.
+func initTenantManager(msgrs []manager.Messenger, q *models.Queries, u *UrlConfig, co *core.Core, md media.Store, db *sqlx.DB, i *i18n.I18n, cfg *Config, ko *koanf.Koanf) *manager.TenantManager {
+	if !cfg.Tenant.Enabled {
+		return nil
+	}
+
+	tm := manager.NewTenantManager(campaignManagerConfig(u, ko), newManagerStore(q, co, md, db), i, lo)
+
+	// Attach all messengers to the tenant manager.
+	for _, m := range msgrs {
+		tm.AddMessenger(m)
+	}
+
+	return tm
+}
+
 // initTxTemplates initializes and compiles the transactional templates and caches them in-memory.
 func initTxTemplates(m *manager.Manager, co *core.Core) {
 	tpls, err := co.GetTemplates(models.TemplateTypeTx, false)
@@ -944,6 +997,11 @@ func initHTTPServer(cfg *Config, urlCfg *UrlConfig, i *i18n.I18n, fs stuffbin.Fi
 	if app.tenantMiddleware != nil {
 		srv.Use(app.tenantMiddleware.Middleware())
 		lo.Println("tenant middleware registered")
+
+		// Per-tenant API rate limits. Tenants' own limits in their features
+		// take precedence over the default. They're applied to the
+		// authenticated API routes in initHTTPHandlers().
+		app.tenantRateLimit = middleware.RateLimitPerTenant(ko.Int("tenant.api_rate_limit"), ko.Duration("tenant.api_rate_limit_window"))
 	}
 
 	tpl, err := stuffbin.ParseTemplatesGlob(initTplFuncs(i, urlCfg), fs, "/public/templates/*.html")
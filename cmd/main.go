@@ -30,6 +30,7 @@ import (
 	"github.com/knadh/listmonk/models"
 	"github.com/knadh/paginator"
 	"github.com/knadh/stuffbin"
+	"github.com/labstack/echo/v4"
 )
 
 // App contains the "global" shared components, controllers and fields.
@@ -57,6 +58,15 @@ type App struct {
 	// Tenant middleware for multi-tenancy support
 	tenantMiddleware *middleware.TenantMiddleware
 
+	// Per-tenant API rate limiter for the authenticated API routes.
+	tenantRateLimit echo.MiddlewareFunc
+
+	// Per-tenant SMTP e-mailers loaded from tenant_settings.
+	tenantEmailer *email.TenantEmailer
+
+	// Campaign manager that processes every tenant's campaigns in tenant mode.
+	tenantManager *manager.TenantManager
+
 	about         about
 	fnOptinNotify func(models.Subscriber, []int) (int, error)
 
@@ -196,6 +206,9 @@ func main() {
 		// Campaign manager.
 		mgr = initCampaignManager(msgrs, queries, urlCfg, core, media, i18n, ko)
 
+		// Campaign manager for the tenants in tenant mode.
+		tenantMgr = initTenantManager(msgrs, queries, urlCfg, core, media, db, i18n, cfg, ko)
+
 		// Bulk importer.
 		importer = initImporter(queries, db, core, i18n, ko)
 
@@ -246,6 +259,9 @@ func main() {
 	// Start the campaign manager workers. The campaign batches (fetch from DB, push out
 	// messages) get processed at the specified interval.
 	go mgr.Run()
+	if tenantMgr != nil {
+		go tenantMgr.Run()
+	}
 
 	// =========================================================================
 	// Initialize the App{} with all the global shared components, controllers and fields.
@@ -271,6 +287,8 @@ func main() {
 
 		// Tenant middleware
 		tenantMiddleware: tenantMW,
+		tenantEmailer:    email.NewTenantEmailer(db, emailMsgr, ko.String("app.secret_key"), lo),
+		tenantManager:    tenantMgr,
 
 		pg: paginator.New(paginator.Opt{
 			DefaultPerPage: 20,
@@ -311,7 +329,14 @@ func main() {
 		srv.Shutdown(ctx)
 
 		// Close the campaign manager.
-		mgr.Close()
+		if err := mgr.Close(); err != nil {
+			lo.Printf("error closing campaign manager: %v", err)
+		}
+		if tenantMgr != nil {
+			if err := tenantMgr.Close(); err != nil {
+				lo.Printf("error closing tenant campaign manager: %v", err)
+			}
+		}
 
 		// Close the DB pool.
 		db.Close()
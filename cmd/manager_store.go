@@ -3,10 +3,12 @@ package main
 import (
 	"context"
 	"database/sql"
-	"fmt"
+	"encoding/json"
+	"strings"
 
 	"github.com/gofrs/uuid/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/jmoiron/sqlx/types"
 	"github.com/knadh/listmonk/internal/core"
 	"github.com/knadh/listmonk/internal/manager"
 	"github.com/knadh/listmonk/internal/media"
@@ -14,6 +16,10 @@ import (
 	"github.com/lib/pq"
 )
 
+// legacyTenantID is the tenant that the single-tenant Store methods operate
+// on. It's the default tenant that the manager's legacy mode also uses.
+const legacyTenantID = 1
+
 // store implements DataSource over the primary
 // database.
 type store struct {
@@ -28,9 +34,25 @@ type runningCamp struct {
 	CampaignType     string `db:"campaign_type"`
 	LastSubscriberID int    `db:"last_subscriber_id"`
 	MaxSubscriberID  int    `db:"max_subscriber_id"`
+	SubscriberQuery  string `db:"subscriber_query"`
 	ListID           int    `db:"list_id"`
 }
 
+// campSubscriberIDs returns the IDs of the next batch of a campaign's
+// subscribers that match its subscriber query expression. The expression is
+// validated when the campaign is saved, but as it's arbitrary SQL, tx should
+// be read-only. The IDs are passed to next-campaign-subscribers so that the
+// query that updates the campaign never has the expression in it.
+func (s *store) campSubscriberIDs(ctx context.Context, tx *sqlx.Tx, exp string, args []any) (any, error) {
+	ids := []int64{}
+	stmt := strings.ReplaceAll(s.queries.NextCampaignSubscriberIDs, "%query%", exp)
+	if err := tx.SelectContext(ctx, &ids, stmt, args...); err != nil {
+		return nil, err
+	}
+
+	return pq.Array(ids), nil
+}
+
 func newManagerStore(q *models.Queries, c *core.Core, m media.Store, db *sqlx.DB) *store {
 	return &store{
 		queries: q,
@@ -49,28 +71,13 @@ func (s *store) NextCampaigns(currentIDs []int64, sentCounts []int64) ([]*models
 	return out, err
 }
 
-// NextSubscribers retrieves a subset of subscribers of a given campaign.
+// NextSubscribers retrieves a subset of subscribers of a given campaign of the
+// default tenant.
 // Since batches are processed sequentially, the retrieval is ordered by ID,
 // and every batch takes the last ID of the last batch and fetches the next
 // batch above that.
-func (s *store) NextSubscribers(campID, limit int) ([]models.Subscriber, error) {
-	var camps []runningCamp
-	if err := s.queries.GetRunningCampaign.Select(&camps, campID); err != nil {
-		return nil, err
-	}
-
-	var listIDs []int
-	for _, c := range camps {
-		listIDs = append(listIDs, c.ListID)
-	}
-
-	if len(listIDs) == 0 {
-		return nil, nil
-	}
-
-	var out []models.Subscriber
-	err := s.queries.NextCampaignSubscribers.Select(&out, camps[0].CampaignID, camps[0].CampaignType, camps[0].LastSubscriberID, camps[0].MaxSubscriberID, pq.Array(listIDs), limit)
-	return out, err
+func (s *store) NextSubscribers(ctx context.Context, campID, limit int) ([]models.Subscriber, error) {
+	return s.NextTenantSubscribers(ctx, legacyTenantID, campID, limit)
 }
 
 // GetCampaign fetches a campaign from the database.
@@ -80,10 +87,16 @@ func (s *store) GetCampaign(campID int) (*models.Campaign, error) {
 	return out, err
 }
 
-// UpdateCampaignStatus updates a campaign's status.
+// UpdateCampaignStatus updates the status of a campaign of the default tenant.
 func (s *store) UpdateCampaignStatus(campID int, status string) error {
-	_, err := s.queries.UpdateCampaignStatus.Exec(campID, status)
-	return err
+	return s.UpdateTenantCampaignStatus(legacyTenantID, campID, status)
+}
+
+// UpdateCampaignStatusFrom updates the status of a campaign of the default
+// tenant only if its current status is one of from so that a concurrent
+// status change isn't overwritten.
+func (s *store) UpdateCampaignStatusFrom(campID int, from []string, status string) (bool, error) {
+	return s.UpdateTenantCampaignStatusFrom(legacyTenantID, campID, from, status)
 }
 
 // UpdateCampaignCounts updates a campaign's status.
@@ -92,6 +105,36 @@ func (s *store) UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID
 	return err
 }
 
+// SaveArchive saves the rendered content of a campaign of the default
+// tenant for the archive.
+func (s *store) SaveArchive(campID int, body []byte) error {
+	return s.SaveTenantArchive(legacyTenantID, campID, body)
+}
+
+// GetLinks returns the URL to UUID mappings of the default tenant's tracked
+// links that appear in the given campaigns.
+func (s *store) GetLinks(campUUIDs []string) (map[string]string, error) {
+	return s.GetTenantLinks(legacyTenantID, campUUIDs)
+}
+
+// GetTenantLinks returns the URL to UUID mappings of a tenant's tracked links
+// that appear in the given campaigns.
+func (s *store) GetTenantLinks(tenantID int, campUUIDs []string) (map[string]string, error) {
+	var res []struct {
+		URL  string `db:"url"`
+		UUID string `db:"uuid"`
+	}
+	if err := s.queries.GetCampaignLinks.Select(&res, tenantID, pq.Array(campUUIDs)); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]string, len(res))
+	for _, l := range res {
+		out[l.URL] = l.UUID
+	}
+	return out, nil
+}
+
 // GetAttachment fetches a media attachment blob.
 func (s *store) GetAttachment(mediaID int) (models.Attachment, error) {
 	m, err := s.core.GetMedia(mediaID, "", "", s.media)
@@ -163,26 +206,22 @@ func (s *store) DeleteSubscriber(id int64) error {
 // NextTenantCampaigns retrieves active campaigns for a specific tenant
 func (s *store) NextTenantCampaigns(tenantID int, currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error) {
 	var out []*models.Campaign
-	
-	// Set tenant context for RLS
-	if err := s.setTenantContext(tenantID); err != nil {
-		return nil, fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
+
 	// This would need a tenant-aware query - for now using existing query with RLS
-	err := s.queries.NextCampaigns.Select(&out, pq.Int64Array(currentIDs), pq.Int64Array(sentCounts))
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.NextCampaigns).Select(&out, pq.Int64Array(currentIDs), pq.Int64Array(sentCounts))
+	})
 	return out, err
 }
 
 // NextTenantSubscribers retrieves subscribers for a campaign within a tenant
-func (s *store) NextTenantSubscribers(tenantID, campID, limit int) ([]models.Subscriber, error) {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return nil, fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
+func (s *store) NextTenantSubscribers(ctx context.Context, tenantID, campID, limit int) ([]models.Subscriber, error) {
 	// Get running campaign info with tenant context
 	var camps []runningCamp
-	if err := s.queries.GetRunningCampaign.Select(&camps, campID); err != nil {
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.GetRunningCampaign).SelectContext(ctx, &camps, tenantID, campID)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -195,19 +234,42 @@ func (s *store) NextTenantSubscribers(tenantID, campID, limit int) ([]models.Sub
 		return nil, nil
 	}
 
+	var (
+		c      = camps[0]
+		args   = []any{tenantID, c.CampaignID, c.CampaignType, c.LastSubscriberID, c.MaxSubscriberID, pq.Array(listIDs), limit}
+		subIDs any
+	)
+
+	// Restrict the batch to the subscribers that match the campaign's
+	// subscriber query expression, if there's one.
+	if c.SubscriberQuery != "" {
+		err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+			if _, err := tx.ExecContext(ctx, `SET TRANSACTION READ ONLY`); err != nil {
+				return err
+			}
+
+			var err error
+			subIDs, err = s.campSubscriberIDs(ctx, tx, c.SubscriberQuery, args)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var out []models.Subscriber
-	err := s.queries.NextCampaignSubscribers.Select(&out, camps[0].CampaignID, camps[0].CampaignType, camps[0].LastSubscriberID, camps[0].MaxSubscriberID, pq.Array(listIDs), limit)
+	err = s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.NextCampaignSubscribers).SelectContext(ctx, &out, append(args, subIDs)...)
+	})
 	return out, err
 }
 
 // GetTenantCampaign fetches a campaign from a specific tenant
 func (s *store) GetTenantCampaign(tenantID, campID int) (*models.Campaign, error) {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return nil, fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
 	var out = &models.Campaign{}
-	err := s.queries.GetCampaign.Get(out, campID, nil, nil, "default")
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.GetCampaign).Get(out, campID, nil, nil, "default")
+	})
 	return out, err
 }
 
@@ -261,39 +323,133 @@ func (s *store) GetTenantSettings(tenantID int) (map[string]interface{}, error)
 	return settings, nil
 }
 
+// GetActiveTenantIDs returns the IDs of active tenants that currently have
+// running or scheduled campaigns to process.
+func (s *store) GetActiveTenantIDs() ([]int, error) {
+	var out []int
+	err := s.queries.GetActiveTenantIDs.Select(&out)
+	return out, err
+}
+
+// GetTenantFeatures retrieves the features and limits of a tenant's plan.
+func (s *store) GetTenantFeatures(tenantID int) (models.TenantFeatures, error) {
+	var (
+		out models.TenantFeatures
+		raw types.JSONText
+	)
+	if err := s.queries.GetTenantFeatures.Get(&raw, tenantID); err != nil {
+		return out, err
+	}
+
+	if err := raw.Unmarshal(&out); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// GetTenantUsage retrieves a tenant's current usage counted against its plan's limits.
+func (s *store) GetTenantUsage(tenantID int) (manager.TenantUsage, error) {
+	var out manager.TenantUsage
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.GetTenantUsage).QueryRow(tenantID).Scan(&out.CampaignsThisMonth, &out.Subscribers)
+	})
+	return out, err
+}
+
+// RecordTenantBounce records a bounce for a tenant's subscriber and returns the
+// number of bounces of the same type recorded for the subscriber.
+func (s *store) RecordTenantBounce(tenantID int, b models.Bounce) (int, error) {
+	meta := b.Meta
+	if len(meta) == 0 {
+		meta = json.RawMessage("{}")
+	}
+
+	// Nothing is returned, ie: sql.ErrNoRows, if the subscriber isn't in the tenant.
+	var num int
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.RecordTenantBounce).Get(&num, tenantID, b.SubscriberID, b.CampaignUUID, b.Type, b.Source, meta)
+	})
+
+	return num, err
+}
+
+// GetTenantSuppressions retrieves the e-mails on a tenant's suppression list.
+func (s *store) GetTenantSuppressions(tenantID int) ([]string, error) {
+	out := []string{}
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.GetTenantSuppressions).Select(&out, tenantID)
+	})
+	return out, err
+}
+
+// GetTenantAdminEmails retrieves the e-mails of a tenant's enabled owners and admins.
+func (s *store) GetTenantAdminEmails(tenantID int) ([]string, error) {
+	out := []string{}
+	err := s.queries.GetTenantAdminEmails.Select(&out, tenantID)
+	return out, err
+}
+
+// PauseTenantCampaigns pauses all of a tenant's running campaigns and returns their IDs.
+func (s *store) PauseTenantCampaigns(tenantID int) ([]int, error) {
+	out := []int{}
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.PauseTenantCampaigns).Select(&out, tenantID)
+	})
+	return out, err
+}
+
 // UpdateTenantCampaignStatus updates a campaign status within a tenant
 func (s *store) UpdateTenantCampaignStatus(tenantID, campID int, status string) error {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
-	_, err := s.queries.UpdateCampaignStatus.Exec(campID, status)
-	return err
+	return s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		_, err := tx.Stmtx(s.queries.UpdateCampaignStatus).Exec(tenantID, campID, status, nil)
+		return err
+	})
+}
+
+// UpdateTenantCampaignStatusFrom updates a tenant campaign's status only if
+// its current status is one of from
+func (s *store) UpdateTenantCampaignStatusFrom(tenantID, campID int, from []string, status string) (bool, error) {
+	var n int64
+	err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		res, err := tx.Stmtx(s.queries.UpdateCampaignStatus).Exec(tenantID, campID, status, pq.StringArray(from))
+		if err != nil {
+			return err
+		}
+
+		n, err = res.RowsAffected()
+		return err
+	})
+
+	return n > 0, err
 }
 
 // UpdateTenantCampaignCounts updates campaign counts for a tenant-specific campaign
 func (s *store) UpdateTenantCampaignCounts(tenantID, campID int, toSend int, sent int, lastSubID int) error {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
-	_, err := s.queries.UpdateCampaignCounts.Exec(campID, toSend, sent, lastSubID)
-	return err
+	return s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		_, err := tx.Stmtx(s.queries.UpdateCampaignCounts).Exec(campID, toSend, sent, lastSubID)
+		return err
+	})
+}
+
+// SaveTenantArchive saves the rendered content of a tenant's campaign for the archive
+func (s *store) SaveTenantArchive(tenantID, campID int, body []byte) error {
+	return s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		_, err := tx.Stmtx(s.queries.SaveCampaignArchive).Exec(tenantID, campID, string(body))
+		return err
+	})
 }
 
 // CreateTenantLink creates a tracking link for a tenant
 func (s *store) CreateTenantLink(tenantID int, url string) (string, error) {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return "", fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
 	uu, err := uuid.NewV4()
 	if err != nil {
 		return "", err
 	}
 
 	var out string
-	if err := s.queries.CreateLink.Get(&out, uu, url); err != nil {
+	if err := s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		return tx.Stmtx(s.queries.CreateLink).Get(&out, uu, url)
+	}); err != nil {
 		return "", err
 	}
 
@@ -302,26 +458,24 @@ func (s *store) CreateTenantLink(tenantID int, url string) (string, error) {
 
 // BlocklistTenantSubscriber blocklists a subscriber within a tenant
 func (s *store) BlocklistTenantSubscriber(tenantID int, id int64) error {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
-	_, err := s.queries.BlocklistSubscribers.Exec(pq.Int64Array{id})
-	return err
+	return s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		_, err := tx.Stmtx(s.queries.BlocklistSubscribers).Exec(pq.Int64Array{id})
+		return err
+	})
 }
 
 // DeleteTenantSubscriber deletes a subscriber within a tenant
 func (s *store) DeleteTenantSubscriber(tenantID int, id int64) error {
-	if err := s.setTenantContext(tenantID); err != nil {
-		return fmt.Errorf("failed to set tenant context: %v", err)
-	}
-	
-	_, err := s.queries.DeleteSubscribers.Exec(pq.Int64Array{id})
-	return err
+	defer s.core.InvalidateTenantUsage(tenantID)
+
+	return s.inTenantTx(tenantID, func(tx *sqlx.Tx) error {
+		_, err := tx.Stmtx(s.queries.DeleteSubscribers).Exec(pq.Int64Array{id})
+		return err
+	})
 }
 
-// setTenantContext sets the PostgreSQL session variable for row-level security
-func (s *store) setTenantContext(tenantID int) error {
-	_, err := s.db.Exec("SELECT set_config('app.current_tenant', $1, false)", fmt.Sprintf("%d", tenantID))
-	return err
+// inTenantTx runs fn in a transaction with the tenant context for row-level
+// security set transaction-locally on the connection running the queries.
+func (s *store) inTenantTx(tenantID int, fn func(tx *sqlx.Tx) error) error {
+	return core.WithTenantTx(s.db, tenantID, fn)
 }
@@ -0,0 +1,469 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/goyesql/v2"
+	goyesqlx "github.com/knadh/goyesql/v2/sqlx"
+	"github.com/knadh/listmonk/models"
+)
+
+// fakeDB is a database connector that records the queries run on it and
+// answers them with the results returned by fn.
+type fakeDB struct {
+	names map[string]string
+	fn    func(c fakeCall) fakeResult
+
+	calls []fakeCall
+	mut   sync.Mutex
+}
+
+// fakeCall is a query run on a fakeDB. name is the query's name in
+// queries.sql, if it's one.
+type fakeCall struct {
+	name  string
+	query string
+	args  []driver.Value
+}
+
+// fakeResult is the result of a query. n is the number of rows affected
+// by an Exec.
+type fakeResult struct {
+	cols []string
+	rows [][]driver.Value
+	n    int64
+	err  error
+}
+
+// named returns the calls of the named query.
+func (f *fakeDB) named(name string) []fakeCall {
+	f.mut.Lock()
+	defer f.mut.Unlock()
+
+	var out []fakeCall
+	for _, c := range f.calls {
+		if c.name == name {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func (f *fakeDB) run(query string, args []driver.Value) fakeResult {
+	c := fakeCall{name: f.names[query], query: query, args: args}
+
+	f.mut.Lock()
+	f.calls = append(f.calls, c)
+	f.mut.Unlock()
+
+	if f.fn == nil {
+		return fakeResult{}
+	}
+	return f.fn(c)
+}
+
+func (f *fakeDB) Connect(context.Context) (driver.Conn, error) { return &fakeConn{f}, nil }
+func (f *fakeDB) Driver() driver.Driver                        { return nil }
+
+type fakeConn struct{ db *fakeDB }
+
+func (c *fakeConn) Prepare(q string) (driver.Stmt, error) { return &fakeStmt{c.db, q}, nil }
+func (c *fakeConn) Close() error                          { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error)             { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeStmt struct {
+	db    *fakeDB
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	res := s.db.run(s.query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return driver.RowsAffected(res.n), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	res := s.db.run(s.query, args)
+	if res.err != nil {
+		return nil, res.err
+	}
+	return &fakeRows{cols: res.cols, rows: res.rows}, nil
+}
+
+type fakeRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.cols }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newFakeStore returns a manager store with the queries in queries.sql
+// prepared on a fakeDB that answers them with fn.
+func newFakeStore(t *testing.T, fn func(c fakeCall) fakeResult) (*store, *fakeDB) {
+	t.Helper()
+
+	qMap, err := goyesql.ParseFile("../queries.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Queries that are derived from others on boot by prepareQueries().
+	for _, name := range []string{"get-campaign-view-counts", "get-campaign-click-counts"} {
+		qMap[name] = &goyesql.Query{Query: qMap["get-campaign-analytics-counts"].Query}
+	}
+
+	f := &fakeDB{names: make(map[string]string), fn: fn}
+	for name, q := range qMap {
+		f.names[q.Query] = name
+	}
+
+	db := sqlx.NewDb(sql.OpenDB(f), "postgres")
+	t.Cleanup(func() { db.Close() })
+
+	var q models.Queries
+	if err := goyesqlx.ScanToStruct(&q, qMap, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the calls made by the tests are of interest.
+	f.calls = nil
+
+	return newManagerStore(&q, nil, nil, db), f
+}
+
+func TestStoreGetActiveTenantIDs(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "get-active-tenant-ids" {
+			return fakeResult{cols: []string{"id"}, rows: [][]driver.Value{{int64(1)}, {int64(3)}}}
+		}
+		return fakeResult{}
+	})
+
+	ids, err := s.GetActiveTenantIDs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 3]" {
+		t.Errorf("unexpected tenant IDs: %v", ids)
+	}
+	if n := len(f.named("get-active-tenant-ids")); n != 1 {
+		t.Errorf("expected the named query to be run once, got %d", n)
+	}
+}
+
+func TestStoreGetTenantFeatures(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "get-tenant-features" && c.args[0] == int64(2) {
+			return fakeResult{cols: []string{"features"}, rows: [][]driver.Value{{[]byte(`{"max_subscribers": 500}`)}}}
+		}
+		return fakeResult{cols: []string{"features"}}
+	})
+
+	out, err := s.GetTenantFeatures(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.MaxSubscribers != 500 {
+		t.Errorf("expected 500 max subscribers, got %d", out.MaxSubscribers)
+	}
+
+	if _, err := s.GetTenantFeatures(3); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing tenant, got %v", err)
+	}
+	if n := len(f.named("get-tenant-features")); n != 2 {
+		t.Errorf("expected the named query to be run twice, got %d", n)
+	}
+}
+
+// argsOf formats the arguments of a call for comparison.
+func argsOf(c fakeCall) string {
+	return fmt.Sprint(c.args)
+}
+
+func TestStoreSaveArchive(t *testing.T) {
+	s, f := newFakeStore(t, nil)
+
+	if err := s.SaveTenantArchive(2, 10, []byte("<p>hi</p>")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveArchive(11, []byte("<p>hello</p>")); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.named("save-campaign-archive")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 archive saves, got %d", len(calls))
+	}
+	if got := argsOf(calls[0]); got != "[2 10 <p>hi</p>]" {
+		t.Errorf("unexpected tenant archive args: %s", got)
+	}
+	if got := argsOf(calls[1]); got != fmt.Sprintf("[%d 11 <p>hello</p>]", legacyTenantID) {
+		t.Errorf("unexpected archive args: %s", got)
+	}
+}
+
+func TestStoreGetTenantUsage(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "get-tenant-usage" {
+			return fakeResult{
+				cols: []string{"campaigns_this_month", "subscribers"},
+				rows: [][]driver.Value{{int64(3), int64(250)}},
+			}
+		}
+		return fakeResult{}
+	})
+
+	out, err := s.GetTenantUsage(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.CampaignsThisMonth != 3 || out.Subscribers != 250 {
+		t.Errorf("unexpected usage: %+v", out)
+	}
+
+	// The query runs in the tenant's context.
+	f.mut.Lock()
+	calls := f.calls
+	f.mut.Unlock()
+	if len(calls) != 2 || argsOf(calls[0]) != "[app.current_tenant 2]" || calls[1].name != "get-tenant-usage" {
+		t.Errorf("expected the usage query in the tenant's transaction, got %v", calls)
+	}
+}
+
+func TestStoreRecordTenantBounce(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "record-tenant-bounce" && c.args[1] == int64(7) {
+			return fakeResult{cols: []string{"num"}, rows: [][]driver.Value{{int64(2)}}}
+		}
+		return fakeResult{cols: []string{"num"}}
+	})
+
+	num, err := s.RecordTenantBounce(2, models.Bounce{SubscriberID: 7, Type: models.BounceTypeSoft, Source: "api"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if num != 2 {
+		t.Errorf("expected 2 bounces, got %d", num)
+	}
+
+	calls := f.named("record-tenant-bounce")
+	if len(calls) != 1 || argsOf(calls[0]) != "[2 7  soft api [123 125]]" {
+		t.Errorf("unexpected bounce args: %v", calls)
+	}
+
+	// Subscribers that aren't in the tenant have no bounces recorded.
+	if _, err := s.RecordTenantBounce(2, models.Bounce{SubscriberID: 8, Type: models.BounceTypeHard}); err != sql.ErrNoRows {
+		t.Errorf("expected sql.ErrNoRows for a missing subscriber, got %v", err)
+	}
+}
+
+func TestStoreGetTenantSuppressions(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "get-tenant-suppressions" {
+			return fakeResult{cols: []string{"email"}, rows: [][]driver.Value{{"a@example.com"}, {"b@example.com"}}}
+		}
+		return fakeResult{}
+	})
+
+	out, err := s.GetTenantSuppressions(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(out) != "[a@example.com b@example.com]" {
+		t.Errorf("unexpected suppressions: %v", out)
+	}
+
+	calls := f.named("get-tenant-suppressions")
+	if len(calls) != 1 || argsOf(calls[0]) != "[2]" {
+		t.Errorf("unexpected suppression args: %v", calls)
+	}
+}
+
+func TestStoreGetTenantAdminEmails(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "get-tenant-admin-emails" && c.args[0] == int64(2) {
+			return fakeResult{cols: []string{"email"}, rows: [][]driver.Value{{"owner@tenant.test"}}}
+		}
+		return fakeResult{cols: []string{"email"}}
+	})
+
+	out, err := s.GetTenantAdminEmails(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(out) != "[owner@tenant.test]" {
+		t.Errorf("unexpected admin e-mails: %v", out)
+	}
+
+	// Tenants without admins get an empty list.
+	if out, err := s.GetTenantAdminEmails(3); err != nil || out == nil || len(out) != 0 {
+		t.Errorf("expected no admin e-mails, got %v (%v)", out, err)
+	}
+	if n := len(f.named("get-tenant-admin-emails")); n != 2 {
+		t.Errorf("expected the named query to be run twice, got %d", n)
+	}
+}
+
+func TestStoreGetLinks(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "get-campaign-links" {
+			return fakeResult{cols: []string{"url", "uuid"}, rows: [][]driver.Value{{"https://listmonk.app", "link-1"}}}
+		}
+		return fakeResult{}
+	})
+
+	out, err := s.GetTenantLinks(2, []string{"camp-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out["https://listmonk.app"] != "link-1" || len(out) != 1 {
+		t.Errorf("unexpected links: %v", out)
+	}
+	if _, err := s.GetLinks([]string{"camp-2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := f.named("get-campaign-links")
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 link lookups, got %d", len(calls))
+	}
+	if got := argsOf(calls[0]); got != "[2 {\"camp-1\"}]" {
+		t.Errorf("unexpected tenant link args: %s", got)
+	}
+	if got := argsOf(calls[1]); got != fmt.Sprintf("[%d {\"camp-2\"}]", legacyTenantID) {
+		t.Errorf("unexpected link args: %s", got)
+	}
+}
+
+func TestStoreNextSubscribersQuery(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		switch {
+		case c.name == "get-running-campaign":
+			return fakeResult{
+				cols: []string{"campaign_id", "campaign_type", "last_subscriber_id", "max_subscriber_id", "subscriber_query", "list_id"},
+				rows: [][]driver.Value{{int64(10), "regular", int64(0), int64(5), "subscribers.attribs->>'city' = 'Bengaluru'", int64(3)}},
+			}
+
+		// The campaign's segment excludes subscribers 1, 3 and 5.
+		case strings.Contains(c.query, "subscribers.attribs->>'city' = 'Bengaluru'"):
+			return fakeResult{cols: []string{"id"}, rows: [][]driver.Value{{int64(2)}, {int64(4)}}}
+
+		case c.name == "next-campaign-subscribers":
+			return fakeResult{cols: []string{"id", "email"}, rows: [][]driver.Value{{int64(2), "b@tenant.test"}, {int64(4), "d@tenant.test"}}}
+		}
+		return fakeResult{}
+	})
+
+	out, err := s.NextSubscribers(context.Background(), 10, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 2 || out[0].ID != 2 || out[1].ID != 4 {
+		t.Errorf("unexpected subscribers: %+v", out)
+	}
+
+	// Every query is the default tenant's, which is passed first.
+	if c := f.named("get-running-campaign"); len(c) != 1 || argsOf(c[0]) != fmt.Sprintf("[%d 10]", legacyTenantID) {
+		t.Errorf("unexpected running campaign args: %v", c)
+	}
+	c := f.named("next-campaign-subscribers")
+	if len(c) != 1 {
+		t.Fatalf("expected the subscribers to be fetched once, got %d", len(c))
+	}
+	if got, want := argsOf(c[0]), fmt.Sprintf("[%d 10 regular 0 5 {3} 100 {2,4}]", legacyTenantID); got != want {
+		t.Errorf("expected the segment's subscribers in the args %s, got %s", want, got)
+	}
+}
+
+func TestStoreUpdateCampaignStatus(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		// Campaign 11 is no longer running.
+		if c.name == "update-campaign-status" && c.args[1] == int64(11) && c.args[3] != nil {
+			return fakeResult{n: 0}
+		}
+		return fakeResult{n: 1}
+	})
+
+	if err := s.UpdateTenantCampaignStatus(2, 10, models.CampaignStatusPaused); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCampaignStatus(10, models.CampaignStatusCancelled); err != nil {
+		t.Fatal(err)
+	}
+
+	from := []string{models.CampaignStatusRunning, models.CampaignStatusScheduled}
+	if ok, err := s.UpdateTenantCampaignStatusFrom(2, 10, from, models.CampaignStatusFinished); err != nil || !ok {
+		t.Errorf("expected the campaign to be finished, got %v (%v)", ok, err)
+	}
+	if ok, err := s.UpdateCampaignStatusFrom(11, from, models.CampaignStatusFinished); err != nil || ok {
+		t.Errorf("expected the campaign not to be finished, got %v (%v)", ok, err)
+	}
+
+	calls := f.named("update-campaign-status")
+	if len(calls) != 4 {
+		t.Fatalf("expected 4 status updates, got %d", len(calls))
+	}
+	for n, want := range []string{
+		"[2 10 paused <nil>]",
+		fmt.Sprintf("[%d 10 cancelled <nil>]", legacyTenantID),
+		"[2 10 finished {\"running\",\"scheduled\"}]",
+		fmt.Sprintf("[%d 11 finished {\"running\",\"scheduled\"}]", legacyTenantID),
+	} {
+		if got := argsOf(calls[n]); got != want {
+			t.Errorf("expected status update args %s, got %s", want, got)
+		}
+	}
+}
+
+func TestStorePauseTenantCampaigns(t *testing.T) {
+	s, f := newFakeStore(t, func(c fakeCall) fakeResult {
+		if c.name == "pause-tenant-campaigns" {
+			return fakeResult{cols: []string{"id"}, rows: [][]driver.Value{{int64(1)}, {int64(2)}}}
+		}
+		return fakeResult{}
+	})
+
+	ids, err := s.PauseTenantCampaigns(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ids) != "[1 2]" {
+		t.Errorf("unexpected paused campaigns: %v", ids)
+	}
+
+	// The campaigns are paused in the tenant's context.
+	f.mut.Lock()
+	calls := f.calls
+	f.mut.Unlock()
+	if len(calls) != 2 || argsOf(calls[0]) != "[app.current_tenant 2]" || calls[1].name != "pause-tenant-campaigns" || argsOf(calls[1]) != "[2]" {
+		t.Errorf("expected the campaigns to be paused in the tenant's transaction, got %v", calls)
+	}
+}
@@ -120,13 +120,11 @@ func (a *App) UploadMedia(c echo.Context) error {
 		thumbfName = fName
 	}
 
-	// Images have metadata.
-	meta := models.JSON{}
+	// Record the size for storage quotas. Images have additional metadata.
+	meta := models.JSON{"size": file.Size}
 	if isImage {
-		meta = models.JSON{
-			"width":  width,
-			"height": height,
-		}
+		meta["width"] = width
+		meta["height"] = height
 	}
 
 	// Insert the media into the DB.
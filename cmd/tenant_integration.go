@@ -6,6 +6,9 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/knadh/koanf/v2"
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/manager"
 	"github.com/knadh/listmonk/internal/middleware"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
@@ -20,6 +23,37 @@ type TenantConfig struct {
 	RequireTenant   bool   `koanf:"require_tenant"`  // strict tenant enforcement
 }
 
+// loadTenantFeatureDefaults returns the default features of new tenants,
+// overriding the built-in defaults with the tenant.default_features and
+// per plan tenant.plans.<plan> config. Only the keys that are set in the
+// config override the defaults.
+func loadTenantFeatureDefaults() manager.MultiTenantConfig {
+	cfg := manager.DefaultMultiTenantConfig()
+	if ko == nil {
+		return cfg
+	}
+
+	if ko.Exists("tenant.default_features") {
+		if err := ko.UnmarshalWithConf("tenant.default_features", &cfg.DefaultTenantLimits, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+			lo.Printf("error reading tenant.default_features config: %v", err)
+		}
+	}
+
+	for _, plan := range ko.MapKeys("tenant.plans") {
+		f, ok := cfg.PlanLimits[plan]
+		if !ok {
+			f = cfg.DefaultTenantLimits
+		}
+		if err := ko.UnmarshalWithConf("tenant.plans."+plan, &f, koanf.UnmarshalConf{Tag: "json"}); err != nil {
+			lo.Printf("error reading tenant.plans.%s config: %v", plan, err)
+			continue
+		}
+		cfg.PlanLimits[plan] = f
+	}
+
+	return cfg
+}
+
 // initTenantMiddleware initializes and configures tenant middleware
 func initTenantMiddleware(app *App) echo.MiddlewareFunc {
 	// Load tenant configuration from environment/config
@@ -130,7 +164,7 @@ func ensureDefaultTenant(app *App, defaultTenantID int) error {
 	}
 
 	// Create default tenant settings by copying from global settings
-	err = createDefaultTenantSettings(app, defaultTenantID)
+	err = createDefaultTenantSettings(app.db, defaultTenantID)
 	if err != nil {
 		app.log.Printf("Warning: Could not create default tenant settings: %v", err)
 	}
@@ -146,7 +180,8 @@ func configureTenantStrategy(tm *middleware.TenantMiddleware, config TenantConfi
 	// 2. Custom domain resolution  
 	// 3. Header-based resolution
 	// 4. Query parameter resolution (dev only)
-	// 5. User default tenant from session
+	// 5. Tenant switched to in the session
+	// 6. User default tenant from session
 
 	// The middleware will automatically try all strategies in order
 	// No additional configuration needed as the middleware handles this
@@ -172,21 +207,57 @@ func initTenantRoutes(e *echo.Echo, app *App) {
 	adminGroup := e.Group("/api/tenants")
 	adminGroup.Use(authRequired(app))     // Existing auth middleware
 	adminGroup.Use(adminRequired(app))    // Existing admin middleware if available
-	adminGroup.GET("", handleGetTenants)
-	adminGroup.POST("", handleCreateTenant)
+	adminGroup.GET("", handleGetTenants, requireSuperAdmin(app))
+	adminGroup.POST("", handleCreateTenant, requireSuperAdmin(app))
+	adminGroup.POST("/migration", handleStartMediaMigration, requireSuperAdmin(app))
+	adminGroup.GET("/migration/status", handleGetMediaMigrationStatus, requireSuperAdmin(app))
+	adminGroup.GET("/load", handleGetTenantsLoad, requireSuperAdmin(app))
 	adminGroup.GET("/:id", handleGetTenant)
 	adminGroup.PUT("/:id", handleUpdateTenant)
-	adminGroup.DELETE("/:id", handleDeleteTenant)
+	adminGroup.DELETE("/:id", handleDeleteTenant, requireSuperAdmin(app))
 	adminGroup.GET("/:id/stats", handleGetTenantStats)
+	adminGroup.POST("/:id/impersonate", handleImpersonateTenant, requireSuperAdmin(app))
+	adminGroup.DELETE("/:id/impersonate", handleStopImpersonatingTenant, requireSuperAdmin(app))
+	adminGroup.GET("/:id/campaigns/:cid/analytics", handleGetTenantCampaignAnalytics)
 	adminGroup.GET("/:id/settings", handleGetTenantSettings)
 	adminGroup.PUT("/:id/settings", handleUpdateTenantSettings)
+	adminGroup.POST("/:id/smtp/test", handleTestTenantSMTP)
+	adminGroup.POST("/:id/smtp/reload", handleReloadTenantSMTP)
+	adminGroup.POST("/:id/templates/:tid/preview", handlePreviewTenantTemplate)
+	adminGroup.GET("/:id/export", handleExportTenant)
 	adminGroup.POST("/:id/users", handleAddUserToTenant)
 	adminGroup.DELETE("/:id/users/:userId", handleRemoveUserFromTenant)
 
+	// Bounces reported by tenants' external ESPs. These aren't behind auth
+	// as they're authenticated by the tenant's webhook signature.
+	e.POST("/api/tenants/:id/bounces", handleTenantBounceWebhook)
+
 	// Health check endpoint that validates tenant context
 	e.GET("/api/health/tenants", handleTenantHealthCheck)
 }
 
+// requireSuperAdmin returns a middleware that only allows super admin users,
+// who can manage all tenants, through.
+func requireSuperAdmin(app *App) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !isSuperAdmin(c) {
+				return echo.NewHTTPError(http.StatusForbidden, "Super admin access required")
+			}
+			return next(c)
+		}
+	}
+}
+
+// isSuperAdmin checks if the authenticated user on the request is a super admin.
+func isSuperAdmin(c echo.Context) bool {
+	u, ok := c.Get(auth.UserHTTPCtxKey).(auth.User)
+	if !ok {
+		return false
+	}
+	return u.IsSuperAdmin()
+}
+
 // handleTenantHealthCheck provides tenant-specific health checking
 func handleTenantHealthCheck(c echo.Context) error {
 	app := c.Get("app").(*App)
@@ -254,17 +325,6 @@ func getEnvBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
-// createDefaultTenantSettings creates default settings for a tenant
-func createDefaultTenantSettings(app *App, tenantID int) error {
-	// Copy settings from global_settings to tenant_settings for the default tenant
-	_, err := app.db.Exec(`
-		INSERT INTO tenant_settings (tenant_id, key, value, updated_at)
-		SELECT $1, key, value, NOW() FROM global_settings
-		ON CONFLICT (tenant_id, key) DO NOTHING
-	`, tenantID)
-	return err
-}
-
 // Enhanced core factory that returns tenant-aware core when needed
 func getTenantAwareCore(c echo.Context, app *App) (*core.Core, error) {
 	// If multi-tenancy is disabled, return regular core
@@ -1,34 +1,72 @@
 package main
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 
        "github.com/gofrs/uuid/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/auth"
+	"github.com/knadh/listmonk/internal/core"
+	"github.com/knadh/listmonk/internal/media"
 	"github.com/knadh/listmonk/internal/middleware"
+	"github.com/knadh/listmonk/internal/notifs"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
 	"github.com/lib/pq"
+	"github.com/zerodha/simplesessions/v3"
 )
 
-// handleGetTenants returns all tenants (admin only).
+var (
+	regexpTenantSlug = regexp.MustCompile(`^[a-z0-9-]+$`)
+	regexpHostname   = regexp.MustCompile(`^([a-zA-Z0-9]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?\.)+[a-zA-Z]([a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?$`)
+
+	// Slugs that can't be used by tenants as they clash with subdomains
+	// and paths used by the app.
+	reservedTenantSlugs = []string{"api", "admin", "www", "default"}
+)
+
+// defaultImpersonationTTL is how long a super admin's impersonation of a
+// tenant lasts when tenant.impersonation_ttl isn't configured.
+const defaultImpersonationTTL = 30 * time.Minute
+
+// handleGetTenants returns a page of all the tenants (admin only).
 func handleGetTenants(c echo.Context) error {
 	var (
 		app = c.Get("app").(*App)
-		out = struct {
-			Results []models.Tenant `json:"results"`
-		}{}
+
+		query   = strings.TrimSpace(c.FormValue("query"))
+		status  = c.FormValue("status")
+		orderBy = c.FormValue("order_by")
+		order   = c.FormValue("order")
+		pg      = app.pg.NewFromURL(c.Request().URL.Query())
 	)
 
-	// Only super admin can list all tenants
-	// TODO: Add super admin check
+	// Only super admin can list all tenants (enforced by requireSuperAdmin).
 
-	if err := app.queries.GetTenants.Select(&out.Results); err != nil {
-		app.log.Printf("error fetching tenants: %v", err)
-		return echo.NewHTTPError(http.StatusInternalServerError,
-			app.i18n.Ts("globals.messages.errorFetching", "name", "tenants", "error", pqErrMsg(err)))
+	res, total, err := app.core.QueryTenants(query, status, orderBy, order, pg.Offset, pg.Limit)
+	if err != nil {
+		return err
+	}
+
+	out := models.PageResults{
+		Query:   query,
+		Results: res,
+		Total:   total,
+		Page:    pg.Page,
+		PerPage: pg.PerPage,
 	}
 
 	return c.JSON(http.StatusOK, okResp{out})
@@ -48,8 +86,7 @@ func handleGetTenant(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
 	}
 
-	// TODO: Add super admin check
-	if tenant.ID != tenantID {
+	if tenant.ID != tenantID && !isSuperAdmin(c) {
 		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
 	}
 
@@ -80,8 +117,7 @@ func handleCreateTenant(c echo.Context) error {
 		}{}
 	)
 
-	// Only super admin can create tenants
-	// TODO: Add super admin check
+	// Only super admin can create tenants (enforced by requireSuperAdmin).
 
 	if err := c.Bind(&req); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
@@ -91,6 +127,10 @@ func handleCreateTenant(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	if err := validateTenantAddr(req.Slug, "", req.Domain); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	// Generate UUID
 	tenantUUID, err := uuid.NewV4()
 	if err != nil {
@@ -98,25 +138,31 @@ func handleCreateTenant(c echo.Context) error {
 	}
 
 	// Prepare tenant data
-	var (
-		settingsJSON = `{}`
-		featuresJSON = `{"max_subscribers": 10000, "max_campaigns_per_month": 100}`
-	)
-
+	settingsJSON := `{}`
 	if req.Settings != nil {
 		if b, err := json.Marshal(req.Settings); err == nil {
 			settingsJSON = string(b)
 		}
 	}
 
-	if req.Features != nil {
-		if b, err := json.Marshal(req.Features); err == nil {
-			featuresJSON = string(b)
-		}
+	// The plan's default features, with the ones in the request on top.
+	featuresJSON, err := tenantFeaturesJSON(req.Plan, req.Features)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, app.i18n.Ts("globals.messages.invalidFields", "name", "features"))
+	}
+
+	// Create the tenant and its default settings in a single transaction so
+	// that a tenant without (or with partial) settings is never visible.
+	tx, err := app.db.Beginx()
+	if err != nil {
+		app.log.Printf("error creating tenant: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorCreating", "name", "tenant", "error", pqErrMsg(err)))
 	}
+	defer tx.Rollback()
 
 	var out models.Tenant
-	if err := app.queries.CreateTenant.Get(&out,
+	if err := tx.Stmtx(app.queries.CreateTenant).Get(&out,
 		tenantUUID.String(),
 		req.Name,
 		req.Slug,
@@ -139,14 +185,54 @@ func handleCreateTenant(c echo.Context) error {
 			app.i18n.Ts("globals.messages.errorCreating", "name", "tenant", "error", pqErrMsg(err)))
 	}
 
-	// Create default tenant settings by copying from global settings
-	if err := createDefaultTenantSettings(app, out.ID); err != nil {
-		app.log.Printf("warning: failed to create default tenant settings: %v", err)
+	// Create default tenant settings by copying from global settings.
+	if err := createDefaultTenantSettings(tx, out.ID); err != nil {
+		app.log.Printf("error creating default tenant settings: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorCreating", "name", "tenant", "error", pqErrMsg(err)))
 	}
 
+	if err := tx.Commit(); err != nil {
+		app.log.Printf("error creating tenant: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorCreating", "name", "tenant", "error", pqErrMsg(err)))
+	}
+
+	// The new tenant's slug or domain may have been cached as a miss.
+	invalidateTenantCache(app, out.ID)
+
 	return c.JSON(http.StatusCreated, okResp{out})
 }
 
+// tenantFeaturesJSON returns the JSON features of a new tenant on the given
+// plan, which are the plan's defaults overridden by the given features.
+func tenantFeaturesJSON(plan string, features map[string]interface{}) (string, error) {
+	b, err := json.Marshal(loadTenantFeatureDefaults().FeaturesForPlan(plan))
+	if err != nil {
+		return "", err
+	}
+
+	out := map[string]interface{}{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return "", err
+	}
+	for k, v := range features {
+		out[k] = v
+	}
+
+	// Check that the overrides are of the right types.
+	b, err = json.Marshal(out)
+	if err != nil {
+		return "", err
+	}
+	var f models.TenantFeatures
+	if err := json.Unmarshal(b, &f); err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
 // handleUpdateTenant updates a tenant.
 func handleUpdateTenant(c echo.Context) error {
 	var (
@@ -170,8 +256,7 @@ func handleUpdateTenant(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
 	}
 
-	// TODO: Add super admin check
-	if tenant.ID != tenantID {
+	if tenant.ID != tenantID && !isSuperAdmin(c) {
 		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
 	}
 
@@ -183,6 +268,18 @@ func handleUpdateTenant(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
+	// Existing tenants (eg: the default tenant) can keep their reserved slugs.
+	var cur models.Tenant
+	if err := app.queries.GetTenant.Get(&cur, tenantID); err != nil && err != sql.ErrNoRows {
+		app.log.Printf("error fetching tenant: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "tenant", "error", pqErrMsg(err)))
+	}
+
+	if err := validateTenantAddr(req.Slug, cur.Slug, req.Domain); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
 	// Prepare JSON fields
 	var (
 		settingsJSON = `{}`
@@ -226,18 +323,41 @@ func handleUpdateTenant(c echo.Context) error {
 			app.i18n.Ts("globals.messages.errorUpdating", "name", "tenant", "error", pqErrMsg(err)))
 	}
 
+	invalidateTenantCache(app, tenantID)
+
+	// Halt all of a suspended tenant's campaigns.
+	if out.IsSuspended() {
+		if err := stopTenantCampaigns(app, tenantID); err != nil {
+			app.log.Printf("error stopping campaigns of suspended tenant %d: %v", tenantID, err)
+		}
+	}
+
 	return c.JSON(http.StatusOK, okResp{out})
 }
 
-// handleDeleteTenant soft deletes a tenant.
+// stopTenantCampaigns pauses all of a tenant's running campaigns so that
+// they're not picked up again and stops the ones that are being processed.
+func stopTenantCampaigns(app *App, tenantID int) error {
+	if app.tenantManager == nil {
+		return nil
+	}
+	return app.tenantManager.StopAllTenantCampaigns(tenantID)
+}
+
+// handleDeleteTenant soft deletes a tenant. With ?purge=true, the tenant and all
+// of its data and media are deleted permanently. As a safeguard, purging requires
+// the tenant's slug to be passed as ?confirm=<slug>.
 func handleDeleteTenant(c echo.Context) error {
 	var (
 		app      = c.Get("app").(*App)
 		tenantID, _ = strconv.Atoi(c.Param("id"))
 	)
 
-	// Only super admin can delete tenants
-	// TODO: Add super admin check
+	// Only super admin can delete tenants (enforced by requireSuperAdmin).
+
+	if c.QueryParam("purge") == "true" {
+		return purgeTenant(c, tenantID)
+	}
 
 	if _, err := app.queries.DeleteTenant.Exec(tenantID); err != nil {
 		app.log.Printf("error deleting tenant: %v", err)
@@ -245,9 +365,94 @@ func handleDeleteTenant(c echo.Context) error {
 			app.i18n.Ts("globals.messages.errorDeleting", "name", "tenant", "error", pqErrMsg(err)))
 	}
 
+	invalidateTenantCache(app, tenantID)
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// purgeTenant permanently deletes a tenant along with all of its data and media.
+func purgeTenant(c echo.Context, tenantID int) error {
+	var (
+		app = c.Get("app").(*App)
+		out models.Tenant
+	)
+
+	if !isSuperAdmin(c) {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	if err := app.queries.GetTenant.Get(&out, tenantID); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Tenant not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "tenant", "error", pqErrMsg(err)))
+	}
+
+	if c.QueryParam("confirm") != out.Slug {
+		return echo.NewHTTPError(http.StatusBadRequest, "Purging a tenant requires its slug as the confirm parameter")
+	}
+
+	// Files uploaded via a plain media store are deleted by their names as-is.
+	ms, ok := app.media.(*media.TenantStore)
+	if !ok {
+		ms = media.NewTenantStore(app.media, false, "", app.log)
+	}
+
+	if err := app.core.WithTenant(tenantID).PurgeTenant(ms); err != nil {
+		app.log.Printf("error purging tenant %d: %v", tenantID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorDeleting", "name", "tenant", "error", pqErrMsg(err)))
+	}
+
+	invalidateTenantCache(app, tenantID)
+
+	app.log.Printf("purged tenant %d (%s)", tenantID, out.Slug)
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
+// handleStartMediaMigration starts moving existing media files into their
+// tenants' directories in the background.
+func handleStartMediaMigration(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	ms, ok := app.media.(*media.TenantStore)
+	if !ok || !ms.IsEnabled() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Tenant media storage is not enabled")
+	}
+
+	files, err := app.core.GetMediaFiles()
+	if err != nil {
+		return err
+	}
+
+	// Progress is polled via handleGetMediaMigrationStatus.
+	_, err = ms.Migrate(files, func(f media.MigrationFile, newPath string) error {
+		return app.core.RenameMediaFile(f.TenantID, f.Filename, newPath)
+	})
+	if err != nil {
+		if err == media.ErrMigrationRunning {
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, okResp{ms.MigrationStatus()})
+}
+
+// handleGetMediaMigrationStatus returns the progress of the current (or the
+// last) media migration.
+func handleGetMediaMigrationStatus(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	ms, ok := app.media.(*media.TenantStore)
+	if !ok || !ms.IsEnabled() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Tenant media storage is not enabled")
+	}
+
+	return c.JSON(http.StatusOK, okResp{ms.MigrationStatus()})
+}
+
 // handleGetTenantStats returns statistics for a tenant.
 func handleGetTenantStats(c echo.Context) error {
 	var (
@@ -261,8 +466,7 @@ func handleGetTenantStats(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
 	}
 
-	// TODO: Add super admin check
-	if tenant.ID != tenantID {
+	if tenant.ID != tenantID && !isSuperAdmin(c) {
 		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
 	}
 
@@ -278,6 +482,149 @@ func handleGetTenantStats(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{stats})
 }
 
+// handleGetTenantCampaignAnalytics returns the aggregated performance of a
+// tenant's campaign. It requires the tenant's plan to have advanced analytics.
+func handleGetTenantCampaignAnalytics(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+		campID, _   = strconv.Atoi(c.Param("cid"))
+	)
+
+	if campID < 1 {
+		return echo.NewHTTPError(http.StatusBadRequest, app.i18n.T("globals.messages.invalidID"))
+	}
+
+	tenant, err := middleware.GetTenant(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
+	}
+
+	if tenant.ID != tenantID && !isSuperAdmin(c) {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	out, err := app.core.WithTenant(tenantID).GetCampaignAnalytics(campID)
+	if err != nil {
+		switch err {
+		case core.ErrFeatureDisabled:
+			return echo.NewHTTPError(http.StatusForbidden, "Advanced analytics is not available on the tenant's plan")
+		case core.ErrNotFound:
+			return echo.NewHTTPError(http.StatusNotFound,
+				app.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.campaign}"))
+		}
+
+		app.log.Printf("error fetching tenant campaign analytics: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "analytics", "error", pqErrMsg(err)))
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+const (
+	// tenantBounceSigHeader is the header that carries the HMAC-SHA256 signature
+	// of tenant bounce webhook requests, eg: sha256=abcd..
+	tenantBounceSigHeader = "X-Listmonk-Signature"
+
+	// maxTenantBounceBody is the maximum size of a tenant bounce webhook body.
+	// The endpoint isn't behind auth and the body is read before it's verified.
+	maxTenantBounceBody = 64 * 1024
+)
+
+// handleTenantBounceWebhook records a bounce that a tenant's external ESP
+// reports. Requests are signed with the tenant's bounce.webhook_secret setting
+// and the subscriber is looked up by e-mail within the tenant.
+func handleTenantBounceWebhook(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	tenant, err := middleware.GetTenant(c)
+	if err != nil || tenant.ID != tenantID {
+		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
+	}
+
+	// Read the raw body as the signature is over it.
+	body, err := io.ReadAll(http.MaxBytesReader(c.Response(), c.Request().Body, maxTenantBounceBody))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, app.i18n.T("globals.messages.invalidData"))
+	}
+
+	settings, err := app.core.WithTenant(tenantID).GetSettings()
+	if err != nil {
+		app.log.Printf("error fetching tenant %d settings: %v", tenantID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "settings", "error", pqErrMsg(err)))
+	}
+
+	secret, _ := settings["bounce.webhook_secret"].(string)
+	if secret == "" {
+		return echo.NewHTTPError(http.StatusForbidden, "Bounce webhook is not configured for the tenant")
+	}
+	if !validTenantBounceSig(secret, c.Request().Header.Get(tenantBounceSigHeader), body) {
+		return echo.NewHTTPError(http.StatusForbidden, "Invalid signature")
+	}
+
+	var req struct {
+		Email  string `json:"email"`
+		Type   string `json:"type"`
+		Reason string `json:"reason"`
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, app.i18n.T("globals.messages.invalidData"))
+	}
+
+	if req.Type != models.BounceTypeHard && req.Type != models.BounceTypeSoft && req.Type != models.BounceTypeComplaint {
+		return echo.NewHTTPError(http.StatusBadRequest, app.i18n.Ts("globals.messages.invalidFields", "name", "type"))
+	}
+
+	em, err := app.importer.SanitizeEmail(req.Email)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	sub, err := app.core.WithTenant(tenantID).GetSubscriberByEmail(em)
+	if err != nil {
+		if err == core.ErrNotFound {
+			return echo.NewHTTPError(http.StatusNotFound,
+				app.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.subscriber}"))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.subscriber}", "error", pqErrMsg(err)))
+	}
+
+	meta, _ := json.Marshal(map[string]string{"reason": req.Reason})
+	b := models.Bounce{
+		Type:         req.Type,
+		Source:       "webhook",
+		Meta:         meta,
+		Email:        em,
+		SubscriberID: sub.ID,
+	}
+	if err := app.manager.ProcessTenantBounce(tenantID, b); err != nil {
+		app.log.Printf("error processing tenant bounce: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.internalError"))
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// validTenantBounceSig checks the sha256=<hex HMAC> signature of a tenant
+// bounce webhook body.
+func validTenantBounceSig(secret, sig string, body []byte) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(sig, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
 // handleGetUserTenants returns all tenants a user has access to.
 func handleGetUserTenants(c echo.Context) error {
 	var (
@@ -287,9 +634,10 @@ func handleGetUserTenants(c echo.Context) error {
 		}{}
 	)
 
-	// Get user ID from session
-	// TODO: Get actual user ID from session
-	userID := 1 // Placeholder
+	userID, err := getSessionUserID(c)
+	if err != nil {
+		return err
+	}
 
 	if err := app.queries.GetUserTenants.Select(&out.Results, userID); err != nil {
 		app.log.Printf("error fetching user tenants: %v", err)
@@ -392,9 +740,10 @@ func handleSwitchTenant(c echo.Context) error {
 		tenantID, _ = strconv.Atoi(c.Param("id"))
 	)
 
-	// Get user ID from session
-	// TODO: Get actual user ID from session
-	userID := 1 // Placeholder
+	userID, err := getSessionUserID(c)
+	if err != nil {
+		return err
+	}
 
 	// Verify user has access to this tenant
 	var count int
@@ -407,9 +756,17 @@ func handleSwitchTenant(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Access denied to this tenant")
 	}
 
-	// Update session with new tenant ID
-	// TODO: Update session with tenant ID
-	// This would typically involve updating the session store or JWT token
+	// Persist the tenant in the user's session so that subsequent requests
+	// resolve to it in the tenant middleware.
+	sess, ok := c.Get(auth.SessionKey).(*simplesessions.Session)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Tenant switching requires a login session")
+	}
+
+	if err := sess.Set(middleware.SessionTenantKey, tenantID); err != nil {
+		app.log.Printf("error setting tenant in session: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to switch tenant")
+	}
 
 	return c.JSON(http.StatusOK, okResp{map[string]interface{}{
 		"tenant_id": tenantID,
@@ -417,7 +774,113 @@ func handleSwitchTenant(c echo.Context) error {
 	}})
 }
 
-// handleGetTenantSettings returns settings for a tenant.
+// handleGetTenantsLoad returns the queue depths, running campaign pipes and
+// workers of the tenants that campaigns are being sent for (super admin only).
+func handleGetTenantsLoad(c echo.Context) error {
+	app := c.Get("app").(*App)
+
+	return c.JSON(http.StatusOK, okResp{app.manager.TenantLoad()})
+}
+
+// handleImpersonateTenant lets a super admin act within a tenant that they
+// aren't a member of for a limited time. The impersonation is kept in the
+// admin's session and is recorded in the tenant's audit log.
+func handleImpersonateTenant(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+		t           models.Tenant
+	)
+
+	userID, err := getSessionUserID(c)
+	if err != nil {
+		return err
+	}
+
+	if err := app.queries.GetTenant.Get(&t, tenantID); err != nil {
+		if err == sql.ErrNoRows {
+			return echo.NewHTTPError(http.StatusNotFound, "Tenant not found")
+		}
+		app.log.Printf("error fetching tenant: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "tenant", "error", pqErrMsg(err)))
+	}
+	if t.Status == models.TenantStatusDeleted {
+		return echo.NewHTTPError(http.StatusBadRequest, "Cannot impersonate a deleted tenant")
+	}
+
+	sess, ok := c.Get(auth.SessionKey).(*simplesessions.Session)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Impersonation requires a login session")
+	}
+
+	ttl := ko.Duration("tenant.impersonation_ttl")
+	if ttl <= 0 {
+		ttl = defaultImpersonationTTL
+	}
+	until := time.Now().Add(ttl)
+
+	// Record the impersonation before it takes effect so that there's never
+	// an unaudited one.
+	if err := app.core.LogTenantAudit(tenantID, userID, "impersonate", map[string]any{
+		"expires_at": until,
+		"ip":         c.RealIP(),
+	}); err != nil {
+		return err
+	}
+
+	if err := sess.SetMulti(map[string]any{
+		middleware.SessionImpersonateKey:      tenantID,
+		middleware.SessionImpersonateUntilKey: until.Unix(),
+	}); err != nil {
+		app.log.Printf("error setting impersonation in session: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to impersonate tenant")
+	}
+
+	return c.JSON(http.StatusOK, okResp{map[string]interface{}{
+		"tenant_id":  tenantID,
+		"expires_at": until,
+	}})
+}
+
+// handleStopImpersonatingTenant ends a super admin's impersonation of a tenant
+// before it expires.
+func handleStopImpersonatingTenant(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	userID, err := getSessionUserID(c)
+	if err != nil {
+		return err
+	}
+
+	sess, ok := c.Get(auth.SessionKey).(*simplesessions.Session)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, "Impersonation requires a login session")
+	}
+
+	if id, ok := middleware.GetSessionImpersonation(c); !ok || id != tenantID {
+		return echo.NewHTTPError(http.StatusBadRequest, "Not impersonating this tenant")
+	}
+
+	if err := sess.SetMulti(map[string]any{
+		middleware.SessionImpersonateKey:      0,
+		middleware.SessionImpersonateUntilKey: 0,
+	}); err != nil {
+		app.log.Printf("error clearing impersonation in session: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to stop impersonating tenant")
+	}
+
+	// The error is logged by core and the impersonation has ended regardless.
+	_ = app.core.LogTenantAudit(tenantID, userID, "impersonate_stop", nil)
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// handleGetTenantSettings returns settings for a tenant. They include secrets,
+// eg: bounce.webhook_secret, and are only for the tenant's owners and admins.
 func handleGetTenantSettings(c echo.Context) error {
 	var (
 		app      = c.Get("app").(*App)
@@ -434,6 +897,11 @@ func handleGetTenantSettings(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
 	}
 
+	// Check if current user is owner/admin of this tenant
+	if tenant.UserRole != models.TenantUserRoleOwner && tenant.UserRole != models.TenantUserRoleAdmin {
+		return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+	}
+
 	// Use tenant-aware core
 	tenantCore := app.core.WithTenant(tenantID)
 	settings, err := tenantCore.GetSettings()
@@ -446,6 +914,62 @@ func handleGetTenantSettings(c echo.Context) error {
 	return c.JSON(http.StatusOK, okResp{settings})
 }
 
+// handlePreviewTenantTemplate renders the HTML preview of a tenant's template
+// with a dummy subscriber. Campaign templates are rendered with the tenant's
+// URLs and not the global instance's.
+func handlePreviewTenantTemplate(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+		tplID, _    = strconv.Atoi(c.Param("tid"))
+	)
+
+	tenant, err := middleware.GetTenant(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
+	}
+
+	if tenant.ID != tenantID {
+		return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+	}
+
+	tpl, err := app.core.WithTenant(tenantID).GetTemplate(tplID)
+	if err != nil {
+		if errors.Is(err, core.ErrNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound,
+				app.i18n.Ts("globals.messages.notFound", "name", "{globals.terms.template}"))
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			app.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.template}", "error", pqErrMsg(err)))
+	}
+
+	// Transactional templates have no URLs to be namespaced.
+	if tpl.Type != models.TemplateTypeCampaign && tpl.Type != models.TemplateTypeCampaignVisual {
+		out, err := app.previewTemplate(tpl)
+		if err != nil {
+			return err
+		}
+		return c.HTML(http.StatusOK, string(out))
+	}
+
+	camp := models.Campaign{
+		UUID:         dummyUUID,
+		Name:         app.i18n.T("templates.dummyName"),
+		Subject:      app.i18n.T("templates.dummySubject"),
+		FromEmail:    "dummy-campaign@listmonk.app",
+		TemplateBody: tpl.Body,
+		Body:         dummyTpl,
+	}
+
+	out, err := app.manager.PreviewTenantCampaign(tenantID, &camp, dummySubscriber)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			app.i18n.Ts("templates.errorRendering", "error", err.Error()))
+	}
+
+	return c.HTML(http.StatusOK, string(out))
+}
+
 // handleUpdateTenantSettings updates settings for a tenant.
 func handleUpdateTenantSettings(c echo.Context) error {
 	var (
@@ -481,14 +1005,218 @@ func handleUpdateTenantSettings(c echo.Context) error {
 			app.i18n.Ts("globals.messages.errorUpdating", "name", "settings", "error", pqErrMsg(err)))
 	}
 
+	invalidateTenantCache(app, tenantID)
+
+	// Have the tenant's running campaigns pick up the new settings (eg: message rate).
+	if app.tenantManager != nil {
+		if err := app.tenantManager.ReloadTenantConfig(tenantID); err != nil {
+			app.log.Printf("error reloading tenant %d config: %v", tenantID, err)
+		}
+	}
+
+	// Have the tenant's SMTP servers reloaded if the settings they're
+	// loaded from have changed.
+	if app.tenantEmailer != nil && hasSMTPSettings(req) {
+		app.tenantEmailer.InvalidateCache(tenantID)
+	}
+
+	return c.JSON(http.StatusOK, okResp{true})
+}
+
+// hasSMTPSettings returns true if the given settings include any of the
+// settings that a tenant's SMTP emailer is loaded from (smtp, smtp.*, dkim).
+func hasSMTPSettings(settings map[string]interface{}) bool {
+	for k := range settings {
+		if k == "smtp" || k == "dkim" || strings.HasPrefix(k, "smtp.") {
+			return true
+		}
+	}
+	return false
+}
+
+// handleReloadTenantSMTP discards a tenant's cached SMTP emailer so that its
+// servers are reloaded from the tenant's settings on the next send.
+func handleReloadTenantSMTP(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	tenant, err := middleware.GetTenant(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
+	}
+
+	// Only owners and admins of the tenant (or super admins) can reload its SMTP.
+	if !isSuperAdmin(c) {
+		if tenant.ID != tenantID {
+			return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+		}
+		if tenant.UserRole != models.TenantUserRoleOwner && tenant.UserRole != models.TenantUserRoleAdmin {
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+		}
+	}
+
+	if app.tenantEmailer != nil {
+		app.tenantEmailer.InvalidateCache(tenantID)
+	}
+
 	return c.JSON(http.StatusOK, okResp{true})
 }
 
+// handleTestTenantSMTP verifies a tenant's SMTP servers and optionally
+// sends a test e-mail through them.
+func handleTestTenantSMTP(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+		req         = struct {
+			Email string `json:"email"`
+		}{}
+	)
+
+	tenant, err := middleware.GetTenant(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
+	}
+
+	// Only owners and admins of the tenant (or super admins) can test its SMTP
+	// as the test can send e-mails from the tenant's servers.
+	if !isSuperAdmin(c) {
+		if tenant.ID != tenantID {
+			return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+		}
+		if tenant.UserRole != models.TenantUserRoleOwner && tenant.UserRole != models.TenantUserRoleAdmin {
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+		}
+	}
+
+	if err := c.Bind(&req); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	// Prepare the optional test message.
+	var msg *models.Message
+	if req.Email != "" {
+		var b bytes.Buffer
+		if err := notifs.Tpls.ExecuteTemplate(&b, "smtp-test", nil); err != nil {
+			app.log.Printf("error compiling notification template '%s': %v", "smtp-test", err)
+			return err
+		}
+
+		msg = &models.Message{
+			From:    app.cfg.FromEmail,
+			To:      []string{req.Email},
+			Subject:  app.i18n.T("settings.smtp.testConnection"),
+			Body:     b.Bytes(),
+			TenantID: tenantID,
+		}
+	}
+
+	out, err := app.tenantEmailer.TestTenantSMTP(tenantID, msg)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			app.i18n.Ts("globals.messages.errorCreating", "name", "SMTP", "error", err.Error()))
+	}
+
+	return c.JSON(http.StatusOK, okResp{out})
+}
+
+// handleExportTenant streams a ZIP archive of all of a tenant's data.
+func handleExportTenant(c echo.Context) error {
+	var (
+		app         = c.Get("app").(*App)
+		tenantID, _ = strconv.Atoi(c.Param("id"))
+	)
+
+	tenant, err := middleware.GetTenant(c)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusForbidden, "Tenant context required")
+	}
+
+	// Only owners and admins of the tenant (or super admins) can export its data.
+	if !isSuperAdmin(c) {
+		if tenant.ID != tenantID {
+			return echo.NewHTTPError(http.StatusForbidden, "Access denied")
+		}
+		if tenant.UserRole != models.TenantUserRoleOwner && tenant.UserRole != models.TenantUserRoleAdmin {
+			return echo.NewHTTPError(http.StatusForbidden, "Insufficient permissions")
+		}
+	}
+
+	// Set headers to force the browser to prompt for download.
+	c.Response().Header().Set("Cache-Control", "no-cache")
+	c.Response().Header().Set("Content-Type", "application/zip")
+	c.Response().Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="tenant-%d.zip"`, tenantID))
+	c.Response().WriteHeader(http.StatusOK)
+
+	// The response has already begun streaming, so errors can only be logged.
+	if err := app.core.WithTenant(tenantID).ExportTenant(c.Response()); err != nil {
+		app.log.Printf("error exporting tenant %d: %v", tenantID, err)
+	}
+
+	return nil
+}
+
+// invalidateTenantCache removes a tenant from the tenant middleware's lookup
+// cache after it has been changed.
+func invalidateTenantCache(app *App, tenantID int) {
+	if app.tenantMiddleware != nil {
+		app.tenantMiddleware.InvalidateTenant(tenantID)
+	}
+}
+
+// requireTenantAPIAccess is a middleware that rejects requests made with API
+// credentials (as opposed to admin sessions) to tenants whose plan doesn't
+// have API access. It should be used after the auth middleware.
+func requireTenantAPIAccess(next echo.HandlerFunc) echo.HandlerFunc {
+	gated := middleware.RequireFeature(models.TenantFeatureAPIAccess)(next)
+
+	return func(c echo.Context) error {
+		if u, ok := c.Get(auth.UserHTTPCtxKey).(auth.User); ok && u.Type == auth.UserTypeAPI {
+			return gated(c)
+		}
+
+		return next(c)
+	}
+}
+
+// getSessionUserID returns the ID of the authenticated user in the session
+// or a 401 error if the request is not authenticated.
+func getSessionUserID(c echo.Context) (int, error) {
+	sess := middleware.GetUserSession(c)
+	if sess == nil || sess.UserID < 1 {
+		return 0, echo.NewHTTPError(http.StatusUnauthorized, "Authentication required")
+	}
+
+	return sess.UserID, nil
+}
+
+// validateTenantAddr validates a tenant's slug and (optional) custom domain
+// that are used to resolve tenants from request hosts. curSlug is the tenant's
+// existing slug, if any, which is allowed even if it's reserved.
+func validateTenantAddr(slug, curSlug, domain string) error {
+	if !regexpTenantSlug.MatchString(slug) {
+		return errors.New("Invalid slug. Only lowercase letters, numbers, and hyphens are allowed")
+	}
+	if slug != curSlug && inArray(slug, reservedTenantSlugs) {
+		return fmt.Errorf("The slug '%s' is reserved", slug)
+	}
+
+	if domain != "" && (len(domain) > 253 || !regexpHostname.MatchString(domain)) {
+		return errors.New("Invalid domain. It should be a valid hostname, eg: news.example.com")
+	}
+
+	return nil
+}
+
 // createDefaultTenantSettings creates default settings for a new tenant by copying from global_settings.
-func createDefaultTenantSettings(app *App, tenantID int) error {
-	_, err := app.db.Exec(`
-		INSERT INTO tenant_settings (tenant_id, key, value)
-		SELECT $1, key, value FROM global_settings
+// Settings that the tenant already has are left untouched, so it's safe to call it concurrently
+// or more than once for the same tenant. db can be a transaction in which the tenant is created.
+func createDefaultTenantSettings(db sqlx.Execer, tenantID int) error {
+	_, err := db.Exec(`
+		INSERT INTO tenant_settings (tenant_id, key, value, updated_at)
+		SELECT $1, key, value, NOW() FROM global_settings
 		ON CONFLICT (tenant_id, key) DO NOTHING
 	`, tenantID)
 	return err
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/knadh/listmonk/internal/middleware"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+func TestValidateTenantAddr(t *testing.T) {
+	cases := []struct {
+		name    string
+		slug    string
+		curSlug string
+		domain  string
+		ok      bool
+	}{
+		{"valid", "acme-1", "", "", true},
+		{"valid domain", "acme", "", "news.example.com", true},
+		{"invalid slug", "Acme_1", "", "", false},
+		{"empty slug", "", "", "", false},
+		{"slug with path", "acme/admin", "", "", false},
+		{"reserved slug", "admin", "", "", false},
+		{"reserved slug rename", "default", "acme", "", false},
+		{"existing reserved slug", "default", "default", "", true},
+		{"malformed domain", "acme", "", "news..example.com", false},
+		{"domain without tld", "acme", "", "localhost", false},
+		{"domain with scheme", "acme", "", "https://news.example.com", false},
+		{"domain with port", "acme", "", "news.example.com:8080", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := validateTenantAddr(c.slug, c.curSlug, c.domain)
+			if c.ok && err != nil {
+				t.Fatalf("expected %q / %q to be valid, got: %v", c.slug, c.domain, err)
+			}
+			if !c.ok && err == nil {
+				t.Fatalf("expected %q / %q to be invalid", c.slug, c.domain)
+			}
+		})
+	}
+}
+
+func TestTestTenantSMTPRoles(t *testing.T) {
+	cases := []struct {
+		name     string
+		tenantID int
+		role     string
+	}{
+		{"member", 2, models.TenantUserRoleMember},
+		{"viewer", 2, models.TenantUserRoleViewer},
+		{"other tenant's owner", 3, models.TenantUserRoleOwner},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var (
+				req = httptest.NewRequest(http.MethodPost, "/api/tenants/2/smtp/test", strings.NewReader(`{"email": "x@example.com"}`))
+				ctx = echo.New().NewContext(req, httptest.NewRecorder())
+			)
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues("2")
+			ctx.Set("app", &App{})
+			ctx.Set(middleware.TenantCtxKey, &models.TenantContext{ID: c.tenantID, UserRole: c.role})
+
+			var he *echo.HTTPError
+			if err := handleTestTenantSMTP(ctx); !errors.As(err, &he) || he.Code != http.StatusForbidden {
+				t.Fatalf("expected the %s to be forbidden, got: %v", c.name, err)
+			}
+		})
+	}
+}
@@ -103,6 +103,9 @@ type User struct {
 	UserRolePerms pq.StringArray   `db:"user_role_permissions" json:"-"`
 	ListsPermsRaw *json.RawMessage `db:"list_role_perms" json:"-"`
 
+	// SuperAdmin indicates a platform super admin who can manage all tenants.
+	SuperAdmin bool `db:"is_super_admin" json:"-"`
+
 	// Non-DB fields filled post-retrieval.
 	UserRole struct {
 		ID          int      `db:"-" json:"id"`
@@ -154,6 +157,13 @@ type ListRole struct {
 	Lists    []ListPermission `db:"-" json:"lists"`
 }
 
+// IsSuperAdmin checks if the user is a platform super admin who can manage
+// all tenants. This is unlike the super admin role, which only grants all
+// permissions within the user's own tenant.
+func (u *User) IsSuperAdmin() bool {
+	return u.SuperAdmin
+}
+
 // HasPerm checks if the user has a specific permission.
 func (u *User) HasPerm(perm string) bool {
 	// Short-circuit if the user is the primordial super admin.
@@ -2,7 +2,9 @@ package core
 
 import (
 	"database/sql"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gofrs/uuid/v5"
@@ -21,6 +23,15 @@ const (
 	campaignTplArchive = "archive"
 )
 
+// allowedCampQueryTables are the tables that a campaign's subscriber query
+// expression can read in addition to those that the campaign subscriber
+// query reads. The subscriber being evaluated is available as `subscribers`,
+// but other subscribers and campaigns can't be read. Only tables that are
+// isolated by tenant with RLS can be allowed here.
+var allowedCampQueryTables = map[string]struct{}{
+	"bounces": {},
+}
+
 // QueryCampaigns retrieves paginated campaigns optionally filtering them by the given arbitrary
 // query expression. It also returns the total number of records in the DB.
 func (c *Core) QueryCampaigns(searchStr string, statuses, tags []string, orderBy, order string, getAll bool, permittedLists []int, offset, limit int) (models.Campaigns, int, error) {
@@ -168,6 +179,11 @@ func (c *Core) CreateCampaign(o models.Campaign, listIDs []int, mediaIDs []int)
 			c.i18n.Ts("globals.messages.errorUUID", "error", err.Error()))
 	}
 
+	o.SubscriberQuery = sanitizeSQLExp(o.SubscriberQuery)
+	if err := c.validateCampaignQuery(0, o.SubscriberQuery); err != nil {
+		return models.Campaign{}, err
+	}
+
 	// Insert and read ID.
 	var newID int
 	if err := c.q.CreateCampaign.Get(&newID,
@@ -191,6 +207,7 @@ func (c *Core) CreateCampaign(o models.Campaign, listIDs []int, mediaIDs []int)
 		o.ArchiveMeta,
 		pq.Array(mediaIDs),
 		o.BodySource,
+		o.SubscriberQuery,
 	); err != nil {
 		if err == sql.ErrNoRows {
 			return models.Campaign{}, echo.NewHTTPError(http.StatusBadRequest, c.i18n.T("campaigns.noSubs"))
@@ -211,6 +228,11 @@ func (c *Core) CreateCampaign(o models.Campaign, listIDs []int, mediaIDs []int)
 
 // UpdateCampaign updates a campaign.
 func (c *Core) UpdateCampaign(id int, o models.Campaign, listIDs []int, mediaIDs []int) (models.Campaign, error) {
+	o.SubscriberQuery = sanitizeSQLExp(o.SubscriberQuery)
+	if err := c.validateCampaignQuery(0, o.SubscriberQuery); err != nil {
+		return models.Campaign{}, err
+	}
+
 	_, err := c.q.UpdateCampaign.Exec(id,
 		o.Name,
 		o.Subject,
@@ -229,7 +251,8 @@ func (c *Core) UpdateCampaign(id int, o models.Campaign, listIDs []int, mediaIDs
 		o.ArchiveTemplateID,
 		o.ArchiveMeta,
 		pq.Array(mediaIDs),
-		o.BodySource)
+		o.BodySource,
+		o.SubscriberQuery)
 	if err != nil {
 		c.log.Printf("error updating campaign: %v", err)
 		return models.Campaign{}, echo.NewHTTPError(http.StatusInternalServerError,
@@ -448,3 +471,39 @@ func (c *Core) DeleteCampaignLinkClicks(before time.Time) error {
 
 	return nil
 }
+
+// validateCampaignQuery validates a campaign's subscriber query expression by
+// planning the exact query that it's evaluated in at send time. Apart from
+// the tables that the query itself reads, the expression can only read
+// allowedCampQueryTables.
+func (c *Core) validateCampaignQuery(tenantID int, exp string) error {
+	if exp == "" {
+		return nil
+	}
+
+	args := []any{tenantID, 0, models.CampaignTypeRegular, 0, 0, pq.Array([]int{}), 1}
+	base, err := explainQueryTables(c.db, strings.ReplaceAll(c.q.NextCampaignSubscriberIDs, "%query%", "TRUE"), args...)
+	if err != nil {
+		c.log.Printf("error planning campaign subscriber query: %v", err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("subscribers.errorPreparingQuery", "error", pqErrMsg(err)))
+	}
+
+	tables, err := explainQueryTables(c.db, strings.ReplaceAll(c.q.NextCampaignSubscriberIDs, "%query%", exp), args...)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("subscribers.errorPreparingQuery", "error", pqErrMsg(err)))
+	}
+
+	for table, n := range tables {
+		if n <= base[table] {
+			continue
+		}
+		if _, ok := allowedCampQueryTables[table]; !ok {
+			return echo.NewHTTPError(http.StatusBadRequest,
+				c.i18n.Ts("subscribers.errorPreparingQuery", "error", fmt.Sprintf("table '%s' is not allowed", table)))
+		}
+	}
+
+	return nil
+}
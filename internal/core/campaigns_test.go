@@ -0,0 +1,126 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/i18n"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// explainDB is a database connector that plans queries by listing every
+// table of tables that's read in them with FROM or JOIN as a relation in the
+// plan.
+type explainDB struct {
+	tables []string
+}
+
+func (e *explainDB) Connect(context.Context) (driver.Conn, error) { return &explainConn{e}, nil }
+func (e *explainDB) Driver() driver.Driver                        { return nil }
+
+type explainConn struct{ db *explainDB }
+
+func (c *explainConn) Prepare(q string) (driver.Stmt, error) { return &explainStmt{c.db, q}, nil }
+func (c *explainConn) Close() error                          { return nil }
+func (c *explainConn) Begin() (driver.Tx, error)             { return c, nil }
+func (c *explainConn) BeginTx(context.Context, driver.TxOptions) (driver.Tx, error) {
+	return c, nil
+}
+func (c *explainConn) Commit() error   { return nil }
+func (c *explainConn) Rollback() error { return nil }
+
+type explainStmt struct {
+	db    *explainDB
+	query string
+}
+
+func (s *explainStmt) Close() error  { return nil }
+func (s *explainStmt) NumInput() int { return -1 }
+func (s *explainStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *explainStmt) Query([]driver.Value) (driver.Rows, error) {
+	var plans []map[string]any
+	for _, t := range s.db.tables {
+		n := len(regexp.MustCompile(`(?i)(FROM|JOIN)\s+`+t+`\b`).FindAllString(s.query, -1))
+		for i := 0; i < n; i++ {
+			plans = append(plans, map[string]any{"Relation Name": t})
+		}
+	}
+
+	b, _ := json.Marshal([]map[string]any{{"Plan": map[string]any{"Plans": plans}}})
+	return &explainRows{plan: string(b)}, nil
+}
+
+type explainRows struct{ plan string }
+
+func (r *explainRows) Columns() []string { return []string{"QUERY PLAN"} }
+func (r *explainRows) Close() error      { return nil }
+func (r *explainRows) Next(dest []driver.Value) error {
+	if r.plan == "" {
+		return io.EOF
+	}
+	dest[0], r.plan = r.plan, ""
+	return nil
+}
+
+func TestValidateCampaignQuery(t *testing.T) {
+	db := sqlx.NewDb(sql.OpenDB(&explainDB{tables: []string{
+		"lists", "campaign_lists", "subscriber_lists", "subscribers",
+		"bounces", "campaign_views", "link_clicks", "campaigns",
+	}}), "postgres")
+	defer db.Close()
+
+	i, err := i18n.New([]byte(`{"_.code": "en", "_.name": "English",
+		"subscribers.errorPreparingQuery": "Error preparing subscriber query: {error}"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Core{db: db, i18n: i, log: log.New(io.Discard, "", 0), q: &models.Queries{
+		NextCampaignSubscriberIDs: `SELECT subscribers.id FROM subscriber_lists sl
+			JOIN lists ON (lists.id = sl.list_id) JOIN campaign_lists ON (campaign_lists.list_id = lists.id)
+			JOIN subscribers ON (subscribers.id = sl.subscriber_id)
+			WHERE subscribers.tenant_id = $1 AND (%query%)`,
+	}}
+
+	for _, exp := range []string{
+		"",
+		"subscribers.attribs->>'city' = 'Bengaluru'",
+		"(SELECT COUNT(*) FROM bounces WHERE bounces.subscriber_id = subscribers.id) < 3",
+	} {
+		if err := c.validateCampaignQuery(1, exp); err != nil {
+			t.Errorf("expected %q to be allowed, got %v", exp, err)
+		}
+	}
+
+	// Tables that aren't isolated by tenant can't be read, including
+	// subscriber_lists beyond what the campaign's query reads.
+	for table, exp := range map[string]string{
+		"link_clicks":      "subscribers.id IN (SELECT subscriber_id FROM link_clicks)",
+		"campaign_views":   "subscribers.id IN (SELECT subscriber_id FROM campaign_views)",
+		"subscriber_lists": "subscribers.id IN (SELECT subscriber_id FROM subscriber_lists WHERE list_id = 9)",
+		"campaigns":        "EXISTS (SELECT 1 FROM campaigns)",
+	} {
+		err := c.validateCampaignQuery(1, exp)
+		he, ok := err.(*echo.HTTPError)
+		if !ok || he.Code != http.StatusBadRequest {
+			t.Errorf("expected %s to be rejected with a bad request, got %v", table, err)
+			continue
+		}
+		if msg, _ := he.Message.(string); !strings.Contains(msg, "'"+table+"'") {
+			t.Errorf("expected the error to name %s, got %q", table, msg)
+		}
+	}
+}
@@ -47,6 +47,10 @@ type Constants struct {
 		Action string
 	}
 	CacheSlowQueries bool
+
+	// SecretKey is the app-level key used to encrypt secrets, such as
+	// SMTP passwords in tenant settings, before they're stored.
+	SecretKey string
 }
 
 // Hooks contains external function hooks that are required by the core package.
@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gofrs/uuid/v5"
+	"github.com/jmoiron/sqlx"
 	"github.com/knadh/listmonk/internal/media"
 	"github.com/knadh/listmonk/models"
 	"github.com/labstack/echo/v4"
@@ -100,3 +101,33 @@ func (c *Core) DeleteMedia(id int) (string, error) {
 
 	return fname, nil
 }
+
+// GetMediaFiles returns the files (and thumbnails) of all media items of all
+// tenants for migrating them into per-tenant directories.
+func (c *Core) GetMediaFiles() ([]media.MigrationFile, error) {
+	out := []media.MigrationFile{}
+	if err := c.db.Select(&out, `
+		SELECT tenant_id, filename, content_type FROM media
+		UNION
+		SELECT tenant_id, thumb, content_type FROM media WHERE thumb != ''
+		ORDER BY tenant_id, filename`); err != nil {
+		c.log.Printf("error fetching media files: %v", err)
+		return nil, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "{globals.terms.media}", "error", pqErrMsg(err)))
+	}
+
+	return out, nil
+}
+
+// RenameMediaFile updates the references to a media file (or thumbnail) of
+// a tenant after it has been moved in the media store.
+func (c *Core) RenameMediaFile(tenantID int, oldName, newName string) error {
+	return c.WithTenant(tenantID).Tx(func(tx *sqlx.Tx) error {
+		_, err := tx.Exec(`
+			UPDATE media SET
+				filename = (CASE WHEN filename = $2 THEN $3 ELSE filename END),
+				thumb = (CASE WHEN thumb = $2 THEN $3 ELSE thumb END)
+			WHERE tenant_id = $1 AND (filename = $2 OR thumb = $2)`, tenantID, oldName, newName)
+		return err
+	})
+}
@@ -128,7 +128,7 @@ func (c *Core) QuerySubscribers(searchStr, queryExp string, listIDs []int, subSt
 	stmt = strings.ReplaceAll(stmt, "%order%", orderBy+" "+order)
 
 	// Validate the tables used in the query.
-	if err := validateQueryTables(c.db, stmt, allowedSubQueryTables); err != nil {
+	if err := validateQueryTables(c.db, stmt, allowedSubQueryTables, nil, models.SubscriberStatusEnabled, "", 0, 10); err != nil {
 		c.log.Printf("error validating query tables: %v", err)
 		return nil, 0, echo.NewHTTPError(http.StatusBadRequest,
 			c.i18n.Ts("subscribers.errorPreparingQuery", "error", err.Error()))
@@ -577,59 +577,68 @@ func (c *Core) getSubscriberCount(searchStr, queryExp, subStatus string, listIDs
 	return total, nil
 }
 
-// validateQueryTables checks if the query accesses only allowed tables.
-func validateQueryTables(db *sqlx.DB, query string, allowedTables map[string]struct{}) error {
+// validateQueryTables checks if the query accesses only allowed tables. args
+// are the query's positional arguments to plan it with.
+func validateQueryTables(db *sqlx.DB, query string, allowedTables map[string]struct{}, args ...any) error {
+	tables, err := explainQueryTables(db, query, args...)
+	if err != nil {
+		return err
+	}
+
+	// Validate against allowed tables.
+	for table := range tables {
+		if _, ok := allowedTables[table]; !ok {
+			return fmt.Errorf("table '%s' is not allowed", table)
+		}
+	}
+
+	return nil
+}
+
+// explainQueryTables plans a query without running it and returns the number
+// of times each table is read in the plan.
+func explainQueryTables(db *sqlx.DB, query string, args ...any) (map[string]int, error) {
 	// Get the EXPLAIN (FORMAT JSON) output.
 	tx, err := db.BeginTxx(context.Background(), &sql.TxOptions{ReadOnly: true})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer tx.Rollback()
 
 	var plan string
-	if err = tx.QueryRow("EXPLAIN (FORMAT JSON) "+query, nil, models.SubscriberStatusEnabled, "", 0, 10).Scan(&plan); err != nil {
-		return err
+	if err = tx.QueryRow("EXPLAIN (FORMAT JSON) "+query, args...).Scan(&plan); err != nil {
+		return nil, err
 	}
 
 	// Extract all relation names from the JSON plan.
 	tables, err := getTablesFromQueryPlan(plan)
 	if err != nil {
-		return fmt.Errorf("error getting tables from query: %v", err)
+		return nil, fmt.Errorf("error getting tables from query: %v", err)
 	}
 
-	// Validate against allowed tables.
-	for _, table := range tables {
-		if _, ok := allowedTables[table]; !ok {
-			return fmt.Errorf("table '%s' is not allowed", table)
-		}
-	}
-
-	return nil
+	return tables, nil
 }
 
-// getTablesFromQueryPlan parses the EXPLAIN JSON to find all "Relation Name" entries.
-func getTablesFromQueryPlan(explainJSON string) ([]string, error) {
+// getTablesFromQueryPlan parses the EXPLAIN JSON to find all "Relation Name" entries
+// and the number of times each of them occurs.
+func getTablesFromQueryPlan(explainJSON string) (map[string]int, error) {
 	var plans []map[string]any
 	if err := json.Unmarshal([]byte(explainJSON), &plans); err != nil {
 		return nil, err
 	}
 
 	// Collect table names in `tables` recursively.
-	tables := make(map[string]struct{})
+	tables := make(map[string]int)
 	for _, plan := range plans {
 		traverseQueryPlan(plan, tables)
 	}
 
-	result := make([]string, 0, len(tables))
-	for table := range tables {
-		result = append(result, table)
-	}
-	return result, nil
+	return tables, nil
 }
 
-func traverseQueryPlan(node map[string]any, tables map[string]struct{}) {
+func traverseQueryPlan(node map[string]any, tables map[string]int) {
 	if relName, ok := node["Relation Name"].(string); ok {
-		tables[relName] = struct{}{}
+		tables[relName]++
 	}
 
 	// Recursively check nested plans (e.g., subqueries, CTEs).
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// ErrFeatureDisabled is returned when the tenant's plan doesn't include
+// the feature that's required for an operation.
+var ErrFeatureDisabled = echo.NewHTTPError(http.StatusForbidden, "feature not available on the tenant's plan")
+
+// qTenantCampaignAnalytics aggregates the performance of a tenant's campaign.
+// Views and clicks have no tenant_id of their own and are scoped through the
+// campaign. Unsubscribes aren't recorded against campaigns, so they're the
+// unsubscriptions from the campaign's lists since it started.
+const qTenantCampaignAnalytics = `
+WITH camp AS (
+    SELECT id, sent, started_at FROM campaigns WHERE tenant_id = $1 AND id = $2
+)
+SELECT camp.id AS campaign_id, camp.sent,
+    (SELECT COUNT(*) FROM campaign_views WHERE campaign_id = camp.id) AS views,
+    (SELECT COUNT(*) FROM link_clicks WHERE campaign_id = camp.id) AS clicks,
+    (SELECT COUNT(*) FROM bounces WHERE tenant_id = $1 AND campaign_id = camp.id) AS bounces,
+    (SELECT COUNT(*) FROM subscriber_lists sl
+        JOIN lists ON (lists.id = sl.list_id AND lists.tenant_id = $1)
+        WHERE sl.status = 'unsubscribed' AND camp.started_at IS NOT NULL AND sl.updated_at >= camp.started_at
+        AND sl.list_id IN (SELECT list_id FROM campaign_lists WHERE campaign_id = camp.id)
+    ) AS unsubscribes
+FROM camp`
+
+// GetCampaignAnalytics returns the sent, view, click, bounce, and unsubscribe
+// counts of a campaign of the current tenant. It requires the tenant's plan
+// to have advanced analytics.
+func (tc *TenantCore) GetCampaignAnalytics(campID int) (models.TenantCampaignAnalytics, error) {
+	var out models.TenantCampaignAnalytics
+	if err := tc.ensureTenantContext(); err != nil {
+		return out, err
+	}
+
+	tenant, err := tc.getTenant()
+	if err != nil {
+		return out, err
+	}
+
+	var features models.TenantFeatures
+	if err := tenant.Features.Unmarshal(&features); err != nil || !features.Has(models.TenantFeatureAdvancedAnalytics) {
+		return out, ErrFeatureDisabled
+	}
+
+	err = tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&out, qTenantCampaignAnalytics, tc.tenantID, campID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return out, ErrNotFound
+		}
+		return out, err
+	}
+
+	return out, nil
+}
@@ -1,15 +1,26 @@
 package core
 
 import (
-	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
+	"net/http"
+	"strings"
 
+	"github.com/gofrs/uuid/v5"
 	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/labstack/echo/v4"
 	"github.com/knadh/listmonk/models"
+	"github.com/lib/pq"
 )
 
+// tenantSettingKey is the Postgres run-time parameter that RLS policies
+// read the current tenant from.
+const tenantSettingKey = "app.current_tenant"
+
 // TenantCore wraps the Core struct to provide tenant-aware operations.
 type TenantCore struct {
 	*Core
@@ -26,11 +37,30 @@ func NewTenantCore(core *Core, tenantID int, db *sqlx.DB) *TenantCore {
 	}
 }
 
+// WithTenantTx runs fn in a transaction with the RLS tenant context set
+// transaction-locally. The setting is bound to the single connection that
+// runs fn's queries and is discarded on commit or rollback, so it never leaks
+// to other requests that the pool hands the connection to.
+func WithTenantTx(db *sqlx.DB, tenantID int, fn func(tx *sqlx.Tx) error) error {
+	tx, err := db.Beginx()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT set_config($1, $2, true)`, tenantSettingKey, strconv.Itoa(tenantID)); err != nil {
+		return err
+	}
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
 // WithTenant creates a tenant-scoped Core instance.
 func (c *Core) WithTenant(tenantID int) *TenantCore {
-	// Set the database session variable for RLS
-	c.db.MustExec(fmt.Sprintf("SELECT set_config('app.current_tenant', '%d', false)", tenantID))
-	
 	return &TenantCore{
 		Core:     c,
 		tenantID: tenantID,
@@ -43,10 +73,18 @@ func (tc *TenantCore) GetTenantID() int {
 	return tc.tenantID
 }
 
-// ensureTenantContext ensures all database operations are tenant-scoped.
+// Tx runs fn in a transaction bound to the current tenant's RLS context.
+func (tc *TenantCore) Tx(fn func(tx *sqlx.Tx) error) error {
+	return WithTenantTx(tc.db, tc.tenantID, fn)
+}
+
+// ensureTenantContext ensures that the instance is bound to a valid tenant.
+// The RLS context itself is set per transaction by Tx().
 func (tc *TenantCore) ensureTenantContext() error {
-	_, err := tc.db.Exec(fmt.Sprintf("SELECT set_config('app.current_tenant', '%d', false)", tc.tenantID))
-	return err
+	if tc.tenantID < 1 {
+		return errors.New("invalid tenant")
+	}
+	return nil
 }
 
 // Tenant-aware wrapper methods for Subscribers
@@ -60,7 +98,28 @@ func (tc *TenantCore) GetSubscriber(id int, subUUID string) (models.Subscriber,
 	// Add tenant check to the query
 	var sub models.Subscriber
 	query := `SELECT * FROM subscribers WHERE tenant_id = $1 AND (id = $2 OR uuid = $3)`
-	err := tc.db.Get(&sub, query, tc.tenantID, id, subUUID)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&sub, query, tc.tenantID, id, subUUID)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Subscriber{}, ErrNotFound
+		}
+		return models.Subscriber{}, err
+	}
+	return sub, nil
+}
+
+// GetSubscriberByEmail retrieves a subscriber of the current tenant by e-mail.
+func (tc *TenantCore) GetSubscriberByEmail(email string) (models.Subscriber, error) {
+	if err := tc.ensureTenantContext(); err != nil {
+		return models.Subscriber{}, err
+	}
+
+	var sub models.Subscriber
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&sub, `SELECT * FROM subscribers WHERE tenant_id = $1 AND LOWER(email) = LOWER($2)`, tc.tenantID, email)
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.Subscriber{}, ErrNotFound
@@ -76,17 +135,57 @@ func (tc *TenantCore) GetSubscribers(query string, searchStr string, listIDs []i
 		return nil, err
 	}
 
-	// The query should automatically be filtered by tenant_id through RLS
-	// But we can add explicit filtering for safety
-	baseQuery := query
-	if baseQuery == "" {
-		baseQuery = "tenant_id = $tenant_id"
-	} else {
-		baseQuery = fmt.Sprintf("(%s) AND tenant_id = $tenant_id", baseQuery)
+	// Sort params.
+	if !strSliceContains(orderBy, subQuerySortFields) {
+		orderBy = "subscribers.id"
+	}
+	if order != SortAsc && order != SortDesc {
+		order = SortDesc
+	}
+
+	// Required for pq.Array()
+	if listIDs == nil {
+		listIDs = []int{}
+	}
+
+	// There's an arbitrary query condition.
+	cond := "TRUE"
+	if query != "" {
+		cond = "(" + query + ")"
+	}
+
+	stmt := strings.ReplaceAll(tc.q.QuerySubscribers, "%query%", cond)
+	stmt = strings.ReplaceAll(stmt, "%order%", orderBy+" "+order)
+
+	// Validate the tables used in the arbitrary query, if there's one.
+	args := []any{tc.tenantID, pq.Array(listIDs), "", searchStr, offset, limit}
+	if query != "" {
+		if err := validateQueryTables(tc.db, stmt, allowedSubQueryTables, args...); err != nil {
+			return nil, err
+		}
+	}
+
+	// The statement filters by the tenant ($1) and runs in the tenant's RLS
+	// context, so even a query expression that manages to break out of its
+	// parentheses can't see other tenants' subscribers.
+	var out models.Subscribers
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		// Ensure that the arbitrary query is indeed readonly.
+		if _, err := tx.Exec(`SET TRANSACTION READ ONLY`); err != nil {
+			return err
+		}
+
+		if err := tx.Select(&out, stmt, args...); err != nil {
+			return err
+		}
+
+		return out.LoadTenantLists(tx.Stmtx(tc.q.GetSubscriberListsLazy), tc.tenantID)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Call the original method with tenant filtering
-	return tc.Core.GetSubscribers(baseQuery, searchStr, listIDs, orderBy, order, offset, limit)
+	return out, nil
 }
 
 // CreateSubscriber creates a new subscriber for the current tenant.
@@ -105,9 +204,63 @@ func (tc *TenantCore) CreateSubscriber(sub models.Subscriber, lists []int, listU
 		return models.Subscriber{}, err
 	}
 
-	// The tenant_id will be set automatically through database triggers or we can set it explicitly
-	// For safety, we should modify the query to include tenant_id
-	return tc.Core.CreateSubscriber(sub, lists, listUUIDs, preconfirm)
+	uu, err := uuid.NewV4()
+	if err != nil {
+		return models.Subscriber{}, err
+	}
+	sub.UUID = uu.String()
+
+	subStatus := models.SubscriptionStatusUnconfirmed
+	if preconfirm {
+		subStatus = models.SubscriptionStatusConfirmed
+	}
+	if sub.Status == "" {
+		sub.Status = models.SubscriberStatusEnabled
+	}
+
+	// Required for pq.Array()
+	if lists == nil {
+		lists = []int{}
+	}
+	if listUUIDs == nil {
+		listUUIDs = []string{}
+	}
+
+	// Insert with the tenant's ID explicitly instead of relying on triggers or RLS.
+	err = tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Stmtx(tc.q.InsertSubscriber).Get(&sub.ID,
+			tc.tenantID,
+			sub.UUID,
+			sub.Email,
+			strings.TrimSpace(sub.Name),
+			sub.Status,
+			sub.Attribs,
+			pq.Array(lists),
+			pq.Array(listUUIDs),
+			subStatus)
+	})
+	if err != nil {
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			return models.Subscriber{}, echo.NewHTTPError(http.StatusConflict, tc.i18n.T("subscribers.emailExists"))
+		}
+		return models.Subscriber{}, fmt.Errorf("error creating subscriber: %v", err)
+	}
+
+	tc.invalidateUsage()
+
+	out, err := tc.GetSubscriber(sub.ID, "")
+	if err != nil {
+		return models.Subscriber{}, err
+	}
+
+	// Send a confirmation e-mail (if there are any double opt-in lists).
+	if !preconfirm && tc.consts.SendOptinConfirmation {
+		if _, err := tc.h.SendOptinConfirmation(out, lists); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
 }
 
 // Tenant-aware wrapper methods for Lists
@@ -118,8 +271,25 @@ func (tc *TenantCore) GetLists(searchStr string, orderBy string, order string, o
 		return nil, err
 	}
 
-	// Lists will be automatically filtered by tenant through RLS
-	return tc.Core.GetLists(searchStr, orderBy, order, offset, limit)
+	var (
+		out            = []models.List{}
+		queryStr, stmt = makeSearchQuery(searchStr, orderBy, order, tc.q.QueryLists, listQuerySortFields)
+	)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Select(&out, stmt, tc.tenantID, 0, "", queryStr, "", "", pq.StringArray{}, true, pq.Array([]int{}), offset, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace null tags.
+	for i, l := range out {
+		if l.Tags == nil {
+			out[i].Tags = []string{}
+		}
+	}
+
+	return out, nil
 }
 
 // GetList retrieves a list by ID, ensuring it belongs to the current tenant.
@@ -130,7 +300,9 @@ func (tc *TenantCore) GetList(id int, uuid string) (models.List, error) {
 
 	var list models.List
 	query := `SELECT * FROM lists WHERE tenant_id = $1 AND (id = $2 OR uuid = $3)`
-	err := tc.db.Get(&list, query, tc.tenantID, id, uuid)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&list, query, tc.tenantID, id, uuid)
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.List{}, ErrNotFound
@@ -151,7 +323,31 @@ func (tc *TenantCore) CreateList(list models.List) (models.List, error) {
 		return models.List{}, err
 	}
 
-	return tc.Core.CreateList(list)
+	uu, err := uuid.NewV4()
+	if err != nil {
+		return models.List{}, err
+	}
+	list.UUID = uu.String()
+
+	if list.Type == "" {
+		list.Type = models.ListTypePrivate
+	}
+	if list.Optin == "" {
+		list.Optin = models.ListOptinSingle
+	}
+
+	// Insert with the tenant's ID explicitly instead of relying on triggers or RLS.
+	var newID int
+	err = tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Stmtx(tc.q.CreateList).Get(&newID, tc.tenantID, list.UUID, list.Name, list.Type, list.Optin,
+			pq.StringArray(normalizeTags(list.Tags)), list.Description)
+	})
+	if err != nil {
+		return models.List{}, fmt.Errorf("error creating list: %v", err)
+	}
+
+	tc.invalidateUsage()
+	return tc.GetList(newID, "")
 }
 
 // Tenant-aware wrapper methods for Campaigns
@@ -162,8 +358,29 @@ func (tc *TenantCore) GetCampaigns(searchStr string, status []string, orderBy st
 		return nil, err
 	}
 
-	// Campaigns will be automatically filtered by tenant through RLS
-	return tc.Core.GetCampaigns(searchStr, status, orderBy, order, offset, limit)
+	if status == nil {
+		status = []string{}
+	}
+
+	var (
+		out            models.Campaigns
+		queryStr, stmt = makeSearchQuery(searchStr, orderBy, order, tc.q.QueryCampaigns, campQuerySortFields)
+	)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Select(&out, stmt, tc.tenantID, 0, pq.StringArray(status), pq.StringArray{}, queryStr, true, pq.Array([]int{}), offset, limit)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace null tags.
+	for i := range out {
+		if out[i].Tags == nil {
+			out[i].Tags = []string{}
+		}
+	}
+
+	return out, nil
 }
 
 // GetCampaign retrieves a campaign by ID, ensuring it belongs to the current tenant.
@@ -174,7 +391,9 @@ func (tc *TenantCore) GetCampaign(id int, uuid string) (models.Campaign, error)
 
 	var campaign models.Campaign
 	query := `SELECT * FROM campaigns WHERE tenant_id = $1 AND (id = $2 OR uuid = $3)`
-	err := tc.db.Get(&campaign, query, tc.tenantID, id, uuid)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&campaign, query, tc.tenantID, id, uuid)
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.Campaign{}, ErrNotFound
@@ -185,7 +404,7 @@ func (tc *TenantCore) GetCampaign(id int, uuid string) (models.Campaign, error)
 }
 
 // CreateCampaign creates a new campaign for the current tenant.
-func (tc *TenantCore) CreateCampaign(campaign models.Campaign, listIDs []int) (models.Campaign, error) {
+func (tc *TenantCore) CreateCampaign(campaign models.Campaign, listIDs []int, mediaIDs []int) (models.Campaign, error) {
 	if err := tc.ensureTenantContext(); err != nil {
 		return models.Campaign{}, err
 	}
@@ -200,19 +419,76 @@ func (tc *TenantCore) CreateCampaign(campaign models.Campaign, listIDs []int) (m
 		return models.Campaign{}, err
 	}
 
-	return tc.Core.CreateCampaign(campaign, listIDs)
+	campaign.SubscriberQuery = sanitizeSQLExp(campaign.SubscriberQuery)
+	if err := tc.validateCampaignQuery(tc.tenantID, campaign.SubscriberQuery); err != nil {
+		return models.Campaign{}, err
+	}
+
+	uu, err := uuid.NewV4()
+	if err != nil {
+		return models.Campaign{}, err
+	}
+
+	// Insert with the tenant's ID explicitly instead of relying on triggers or RLS.
+	var (
+		o     = campaign
+		newID int
+	)
+	err = tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Stmtx(tc.q.CreateCampaign).Get(&newID,
+			tc.tenantID,
+			uu,
+			o.Type,
+			o.Name,
+			o.Subject,
+			o.FromEmail,
+			o.Body,
+			o.AltBody,
+			o.ContentType,
+			o.SendAt,
+			o.Headers,
+			pq.StringArray(normalizeTags(o.Tags)),
+			o.Messenger,
+			o.TemplateID,
+			pq.Array(listIDs),
+			o.Archive,
+			o.ArchiveSlug,
+			o.ArchiveTemplateID,
+			o.ArchiveMeta,
+			pq.Array(mediaIDs),
+			o.BodySource,
+			o.SubscriberQuery,
+		)
+	})
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Campaign{}, echo.NewHTTPError(http.StatusBadRequest, tc.i18n.T("campaigns.noSubs"))
+		}
+		return models.Campaign{}, fmt.Errorf("error creating campaign: %v", err)
+	}
+
+	tc.invalidateUsage()
+	return tc.GetCampaign(newID, "")
 }
 
 // Tenant-aware wrapper methods for Templates
 
-// GetTemplates retrieves templates for the current tenant.
-func (tc *TenantCore) GetTemplates(searchStr string, orderBy string, order string, offset int, limit int) ([]models.Template, error) {
+// GetTemplates retrieves templates of the given type (all if empty) for the
+// current tenant.
+func (tc *TenantCore) GetTemplates(typ string, noBody bool) ([]models.Template, error) {
 	if err := tc.ensureTenantContext(); err != nil {
 		return nil, err
 	}
 
-	// Templates will be automatically filtered by tenant through RLS
-	return tc.Core.GetTemplates(searchStr, orderBy, order, offset, limit)
+	out := []models.Template{}
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Stmtx(tc.q.GetTemplates).Select(&out, tc.tenantID, 0, noBody, typ)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
 }
 
 // GetTemplate retrieves a template by ID, ensuring it belongs to the current tenant.
@@ -223,7 +499,9 @@ func (tc *TenantCore) GetTemplate(id int) (models.Template, error) {
 
 	var template models.Template
 	query := `SELECT * FROM templates WHERE tenant_id = $1 AND id = $2`
-	err := tc.db.Get(&template, query, tc.tenantID, id)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&template, query, tc.tenantID, id)
+	})
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.Template{}, ErrNotFound
@@ -244,7 +522,18 @@ func (tc *TenantCore) CreateTemplate(template models.Template) (models.Template,
 		return models.Template{}, err
 	}
 
-	return tc.Core.CreateTemplate(template)
+	// Insert with the tenant's ID explicitly instead of relying on triggers or RLS.
+	var newID int
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Stmtx(tc.q.CreateTemplate).Get(&newID, tc.tenantID, template.Name, template.Type,
+			template.Subject, []byte(template.Body), template.BodySource)
+	})
+	if err != nil {
+		return models.Template{}, fmt.Errorf("error creating template: %v", err)
+	}
+
+	tc.invalidateUsage()
+	return tc.GetTemplate(newID)
 }
 
 // Tenant-aware settings management
@@ -256,28 +545,34 @@ func (tc *TenantCore) GetSettings() (map[string]interface{}, error) {
 	}
 
 	settings := make(map[string]interface{})
-	rows, err := tc.db.Query(`
-		SELECT key, value FROM tenant_settings 
-		WHERE tenant_id = $1
-	`, tc.tenantID)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	for rows.Next() {
-		var key string
-		var value []byte
-		if err := rows.Scan(&key, &value); err != nil {
-			return nil, err
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		rows, err := tx.Query(`
+			SELECT key, value FROM tenant_settings
+			WHERE tenant_id = $1
+		`, tc.tenantID)
+		if err != nil {
+			return err
 		}
-		// Parse JSON value
-		var val interface{}
-		if err := json.Unmarshal(value, &val); err != nil {
-			settings[key] = string(value)
-		} else {
-			settings[key] = val
+		defer rows.Close()
+
+		for rows.Next() {
+			var key string
+			var value []byte
+			if err := rows.Scan(&key, &value); err != nil {
+				return err
+			}
+			// Parse JSON value
+			var val interface{}
+			if err := json.Unmarshal(value, &val); err != nil {
+				settings[key] = string(value)
+			} else {
+				settings[key] = val
+			}
 		}
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	return settings, nil
@@ -289,41 +584,122 @@ func (tc *TenantCore) UpdateSettings(settings map[string]interface{}) error {
 		return err
 	}
 
-	tx, err := tc.db.Begin()
-	if err != nil {
+	// Never store SMTP passwords in plaintext.
+	if err := tc.encryptSecrets(settings); err != nil {
 		return err
 	}
-	defer tx.Rollback()
 
-	for key, value := range settings {
-		valueJSON, err := json.Marshal(value)
-		if err != nil {
-			return err
+	return tc.Tx(func(tx *sqlx.Tx) error {
+		for key, value := range settings {
+			valueJSON, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.Exec(`
+				INSERT INTO tenant_settings (tenant_id, key, value, updated_at)
+				VALUES ($1, $2, $3, NOW())
+				ON CONFLICT (tenant_id, key)
+				DO UPDATE SET value = $3, updated_at = NOW()
+			`, tc.tenantID, key, valueJSON)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// encryptSecrets encrypts the passwords of the SMTP servers and the DKIM
+// private key in the given settings in place.
+func (tc *TenantCore) encryptSecrets(settings map[string]interface{}) error {
+	if dkim, ok := settings["dkim"].(map[string]interface{}); ok {
+		if key, ok := dkim["private_key"].(string); ok && key != "" {
+			enc, err := tc.encryptSecret(key)
+			if err != nil {
+				return fmt.Errorf("error encrypting DKIM key: %v", err)
+			}
+			dkim["private_key"] = enc
+		}
+	}
+
+	servers, ok := settings["smtp"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	for _, s := range servers {
+		srv, ok := s.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		pwd, ok := srv["password"].(string)
+		if !ok || pwd == "" {
+			continue
 		}
 
-		_, err = tx.Exec(`
-			INSERT INTO tenant_settings (tenant_id, key, value, updated_at) 
-			VALUES ($1, $2, $3, NOW())
-			ON CONFLICT (tenant_id, key) 
-			DO UPDATE SET value = $3, updated_at = NOW()
-		`, tc.tenantID, key, valueJSON)
+		enc, err := tc.encryptSecret(pwd)
 		if err != nil {
-			return err
+			return fmt.Errorf("error encrypting SMTP password: %v", err)
 		}
+		srv["password"] = enc
 	}
 
-	return tx.Commit()
+	return nil
+}
+
+// encryptSecret encrypts a secret with the app's secret key. Values that
+// are already encrypted are returned as-is.
+func (tc *TenantCore) encryptSecret(val string) (string, error) {
+	return utils.EncryptSecret(tc.consts.SecretKey, val)
 }
 
 // Helper methods for tenant limits
 
+// ErrLimitExceeded is wrapped by the errors returned when an action would take
+// a tenant beyond one of its plan's limits. It can be checked with errors.Is().
+var ErrLimitExceeded = errors.New("tenant limit exceeded")
+
+// LimitError describes the plan limit that a tenant has hit. It's the
+// (JSON) message of the HTTP errors returned for exceeded limits.
+type LimitError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Limit   string `json:"limit"`
+	Current int    `json:"current"`
+	Max     int    `json:"max"`
+}
+
+func (e *LimitError) Error() string {
+	return e.Message
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// newLimitError returns a 402 HTTP error for the given exceeded limit
+// that carries a LimitError.
+func newLimitError(limit string, current, max int) error {
+	le := &LimitError{
+		Code:    "limit_exceeded",
+		Message: fmt.Sprintf("%s limit reached (%d/%d)", strings.ReplaceAll(limit, "_", " "), current, max),
+		Limit:   limit,
+		Current: current,
+		Max:     max,
+	}
+
+	return echo.NewHTTPError(http.StatusPaymentRequired, le).SetInternal(le)
+}
+
 // checkSubscriberLimit checks if the tenant can add more subscribers.
 func (tc *TenantCore) checkSubscriberLimit() error {
-	var count int
-	err := tc.db.Get(&count, `SELECT COUNT(*) FROM subscribers WHERE tenant_id = $1`, tc.tenantID)
+	usage, err := tc.getUsage()
 	if err != nil {
 		return err
 	}
+	count := usage.Subscribers
 
 	// Get tenant features
 	tenant, err := tc.getTenant()
@@ -337,7 +713,7 @@ func (tc *TenantCore) checkSubscriberLimit() error {
 	}
 
 	if features.MaxSubscribers > 0 && count >= features.MaxSubscribers {
-		return fmt.Errorf("subscriber limit reached (%d/%d)", count, features.MaxSubscribers)
+		return newLimitError("subscribers", count, features.MaxSubscribers)
 	}
 
 	return nil
@@ -345,11 +721,11 @@ func (tc *TenantCore) checkSubscriberLimit() error {
 
 // checkListLimit checks if the tenant can add more lists.
 func (tc *TenantCore) checkListLimit() error {
-	var count int
-	err := tc.db.Get(&count, `SELECT COUNT(*) FROM lists WHERE tenant_id = $1`, tc.tenantID)
+	usage, err := tc.getUsage()
 	if err != nil {
 		return err
 	}
+	count := usage.Lists
 
 	tenant, err := tc.getTenant()
 	if err != nil {
@@ -362,7 +738,7 @@ func (tc *TenantCore) checkListLimit() error {
 	}
 
 	if features.MaxLists > 0 && count >= features.MaxLists {
-		return fmt.Errorf("list limit reached (%d/%d)", count, features.MaxLists)
+		return newLimitError("lists", count, features.MaxLists)
 	}
 
 	return nil
@@ -370,15 +746,11 @@ func (tc *TenantCore) checkListLimit() error {
 
 // checkCampaignLimit checks if the tenant can create more campaigns this month.
 func (tc *TenantCore) checkCampaignLimit() error {
-	var count int
-	err := tc.db.Get(&count, `
-		SELECT COUNT(*) FROM campaigns 
-		WHERE tenant_id = $1 
-		AND created_at >= date_trunc('month', CURRENT_DATE)
-	`, tc.tenantID)
+	usage, err := tc.getUsage()
 	if err != nil {
 		return err
 	}
+	count := usage.MonthlyCampaigns
 
 	tenant, err := tc.getTenant()
 	if err != nil {
@@ -391,7 +763,7 @@ func (tc *TenantCore) checkCampaignLimit() error {
 	}
 
 	if features.MaxCampaignsPerMonth > 0 && count >= features.MaxCampaignsPerMonth {
-		return fmt.Errorf("monthly campaign limit reached (%d/%d)", count, features.MaxCampaignsPerMonth)
+		return newLimitError("campaigns_per_month", count, features.MaxCampaignsPerMonth)
 	}
 
 	return nil
@@ -399,11 +771,11 @@ func (tc *TenantCore) checkCampaignLimit() error {
 
 // checkTemplateLimit checks if the tenant can add more templates.
 func (tc *TenantCore) checkTemplateLimit() error {
-	var count int
-	err := tc.db.Get(&count, `SELECT COUNT(*) FROM templates WHERE tenant_id = $1`, tc.tenantID)
+	usage, err := tc.getUsage()
 	if err != nil {
 		return err
 	}
+	count := usage.Templates
 
 	tenant, err := tc.getTenant()
 	if err != nil {
@@ -416,7 +788,7 @@ func (tc *TenantCore) checkTemplateLimit() error {
 	}
 
 	if features.MaxTemplates > 0 && count >= features.MaxTemplates {
-		return fmt.Errorf("template limit reached (%d/%d)", count, features.MaxTemplates)
+		return newLimitError("templates", count, features.MaxTemplates)
 	}
 
 	return nil
@@ -428,35 +800,54 @@ func (tc *TenantCore) validateListOwnership(listIDs []int, listUUIDs []string) e
 		return nil
 	}
 
-	query := `SELECT COUNT(*) FROM lists WHERE tenant_id = $1 AND (`
-	args := []interface{}{tc.tenantID}
-	
-	if len(listIDs) > 0 {
-		query += `id = ANY($2)`
-		args = append(args, listIDs)
-		if len(listUUIDs) > 0 {
-			query += ` OR uuid = ANY($3)`
-			args = append(args, listUUIDs)
-		}
-	} else if len(listUUIDs) > 0 {
-		query += `uuid = ANY($2)`
-		args = append(args, listUUIDs)
+	// Fetch the requested lists that the tenant owns. An ID and a UUID
+	// (or duplicate IDs) may resolve to the same list, so instead of comparing
+	// counts, every requested list is checked against the owned set.
+	var owned []struct {
+		ID   int    `db:"id"`
+		UUID string `db:"uuid"`
 	}
-	query += `)`
-
-	var count int
-	err := tc.db.Get(&count, query, args...)
-	if err != nil {
+	if err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Select(&owned, `
+			SELECT DISTINCT id, uuid::TEXT AS uuid FROM lists
+			WHERE tenant_id = $1 AND (id = ANY($2::INT[]) OR uuid::TEXT = ANY($3::TEXT[]))`,
+			tc.tenantID, pq.Array(listIDs), pq.Array(listUUIDs))
+	}); err != nil {
 		return err
 	}
 
-	expectedCount := len(listIDs)
-	if expectedCount == 0 {
-		expectedCount = len(listUUIDs)
+	var (
+		ids   = make(map[int]struct{}, len(owned))
+		uuids = make(map[string]struct{}, len(owned))
+	)
+	for _, l := range owned {
+		ids[l.ID] = struct{}{}
+		uuids[l.UUID] = struct{}{}
+	}
+
+	var (
+		missing []string
+		seen    = make(map[string]struct{})
+	)
+	addMissing := func(l string) {
+		if _, ok := seen[l]; !ok {
+			seen[l] = struct{}{}
+			missing = append(missing, l)
+		}
+	}
+	for _, id := range listIDs {
+		if _, ok := ids[id]; !ok {
+			addMissing(strconv.Itoa(id))
+		}
+	}
+	for _, u := range listUUIDs {
+		if _, ok := uuids[strings.ToLower(u)]; !ok {
+			addMissing(u)
+		}
 	}
 
-	if count != expectedCount {
-		return fmt.Errorf("one or more lists do not belong to this tenant")
+	if len(missing) > 0 {
+		return fmt.Errorf("lists do not belong to this tenant: %s", strings.Join(missing, ", "))
 	}
 
 	return nil
@@ -465,7 +856,9 @@ func (tc *TenantCore) validateListOwnership(listIDs []int, listUUIDs []string) e
 // getTenant retrieves the current tenant's information.
 func (tc *TenantCore) getTenant() (*models.Tenant, error) {
 	var tenant models.Tenant
-	err := tc.db.Get(&tenant, `SELECT * FROM tenants WHERE id = $1`, tc.tenantID)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&tenant, `SELECT * FROM tenants WHERE id = $1`, tc.tenantID)
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -478,36 +871,60 @@ func (tc *TenantCore) GetTenantStats() (map[string]interface{}, error) {
 		return nil, err
 	}
 
-	stats := make(map[string]interface{})
-	
-	// Get subscriber count
-	var subCount int
-	tc.db.Get(&subCount, `SELECT COUNT(*) FROM subscribers WHERE tenant_id = $1`, tc.tenantID)
-	stats["subscribers"] = subCount
-
-	// Get campaign count
-	var campCount int
-	tc.db.Get(&campCount, `SELECT COUNT(*) FROM campaigns WHERE tenant_id = $1`, tc.tenantID)
-	stats["campaigns"] = campCount
-
-	// Get list count
-	var listCount int
-	tc.db.Get(&listCount, `SELECT COUNT(*) FROM lists WHERE tenant_id = $1`, tc.tenantID)
-	stats["lists"] = listCount
-
-	// Get template count
-	var templateCount int
-	tc.db.Get(&templateCount, `SELECT COUNT(*) FROM templates WHERE tenant_id = $1`, tc.tenantID)
-	stats["templates"] = templateCount
-
-	// Get monthly campaign count
-	var monthlyCampaigns int
-	tc.db.Get(&monthlyCampaigns, `
-		SELECT COUNT(*) FROM campaigns 
-		WHERE tenant_id = $1 
-		AND created_at >= date_trunc('month', CURRENT_DATE)
-	`, tc.tenantID)
-	stats["monthly_campaigns"] = monthlyCampaigns
+	usage, err := tc.getUsage()
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]interface{}{
+		"subscribers":       usage.Subscribers,
+		"campaigns":         usage.Campaigns,
+		"lists":             usage.Lists,
+		"templates":         usage.Templates,
+		"monthly_campaigns": usage.MonthlyCampaigns,
+		"storage_bytes":     usage.StorageBytes,
+	}
 
 	return stats, nil
+}
+
+// GetStorageQuota returns the media storage limit of the current tenant in
+// bytes (0 for no limit) and the bytes currently used by its media.
+func (tc *TenantCore) GetStorageQuota() (int64, int64, error) {
+	if err := tc.ensureTenantContext(); err != nil {
+		return 0, 0, err
+	}
+
+	tenant, err := tc.getTenant()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	used, err := tc.getStorageUsage()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var features models.TenantFeatures
+	if err := tenant.Features.Unmarshal(&features); err != nil {
+		return 0, used, nil // No limits if features can't be parsed
+	}
+
+	return features.MaxStorageBytes, used, nil
+}
+
+// TenantStorageQuota returns the media storage limit and usage of a tenant.
+// It can be used as a media.QuotaFunc.
+func (c *Core) TenantStorageQuota(tenantID int) (int64, int64, error) {
+	return c.WithTenant(tenantID).GetStorageQuota()
+}
+
+// getStorageUsage returns the total size of the tenant's media files as
+// recorded in the media metadata on upload.
+func (tc *TenantCore) getStorageUsage() (int64, error) {
+	var used int64
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&used, `SELECT COALESCE(SUM((meta->>'size')::BIGINT), 0) FROM media WHERE tenant_id = $1`, tc.tenantID)
+	})
+	return used, err
 }
\ No newline at end of file
@@ -0,0 +1,156 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// rlsDB is a database connector that mimics Postgres RLS on a table of rows
+// owned by tenants. Queries only see the rows of the tenant that's set with
+// set_config() on the connection, either for the transaction (is_local) or
+// for the whole session.
+type rlsDB struct {
+	rows map[int]int
+	mut  sync.Mutex
+}
+
+func (r *rlsDB) Connect(context.Context) (driver.Conn, error) { return &rlsConn{db: r}, nil }
+func (r *rlsDB) Driver() driver.Driver                        { return nil }
+
+type rlsConn struct {
+	db      *rlsDB
+	inTx    bool
+	local   string
+	session string
+}
+
+func (c *rlsConn) Prepare(q string) (driver.Stmt, error) { return &rlsStmt{c, q}, nil }
+func (c *rlsConn) Close() error                          { return nil }
+func (c *rlsConn) Begin() (driver.Tx, error) {
+	c.inTx = true
+	return c, nil
+}
+
+// Commit and Rollback end the transaction, discarding its local settings.
+func (c *rlsConn) Commit() error {
+	c.inTx, c.local = false, ""
+	return nil
+}
+func (c *rlsConn) Rollback() error { return c.Commit() }
+
+func (c *rlsConn) tenant() string {
+	if c.local != "" {
+		return c.local
+	}
+	return c.session
+}
+
+type rlsStmt struct {
+	conn  *rlsConn
+	query string
+}
+
+func (s *rlsStmt) Close() error  { return nil }
+func (s *rlsStmt) NumInput() int { return -1 }
+func (s *rlsStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.query != `SELECT set_config($1, $2, true)` || args[0] != tenantSettingKey {
+		return nil, fmt.Errorf("unexpected statement: %s", s.query)
+	}
+
+	// A transaction-local setting outside a transaction has no effect.
+	if s.conn.inTx {
+		s.conn.local = args[1].(string)
+	}
+	return driver.RowsAffected(1), nil
+}
+func (s *rlsStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.conn.db.mut.Lock()
+	defer s.conn.db.mut.Unlock()
+
+	out := &rlsRows{}
+	for id, tenantID := range s.conn.db.rows {
+		if fmt.Sprint(tenantID) == s.conn.tenant() {
+			out.ids = append(out.ids, id)
+		}
+	}
+	return out, nil
+}
+
+type rlsRows struct {
+	ids []int
+	n   int
+}
+
+func (r *rlsRows) Columns() []string { return []string{"id"} }
+func (r *rlsRows) Close() error      { return nil }
+func (r *rlsRows) Next(dest []driver.Value) error {
+	if r.n >= len(r.ids) {
+		return io.EOF
+	}
+	dest[0] = int64(r.ids[r.n])
+	r.n++
+	return nil
+}
+
+func TestWithTenantTxIsolation(t *testing.T) {
+	rls := &rlsDB{rows: map[int]int{1: 1, 2: 1, 3: 1, 11: 2, 12: 2}}
+
+	// A pool with a single connection that the tenants have to share.
+	db := sqlx.NewDb(sql.OpenDB(rls), "postgres")
+	db.SetMaxOpenConns(1)
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 2)
+	)
+	for _, tenantID := range []int{1, 2} {
+		wg.Add(1)
+		go func(tenantID int) {
+			defer wg.Done()
+
+			for i := 0; i < 100; i++ {
+				var ids []int
+				err := WithTenantTx(db, tenantID, func(tx *sqlx.Tx) error {
+					return tx.Select(&ids, `SELECT id FROM items`)
+				})
+				if err != nil {
+					errs <- err
+					return
+				}
+
+				if len(ids) == 0 {
+					errs <- fmt.Errorf("tenant %d: no rows", tenantID)
+					return
+				}
+				for _, id := range ids {
+					if owner := rls.rows[id]; owner != tenantID {
+						errs <- fmt.Errorf("tenant %d: got row %d of tenant %d", tenantID, id, owner)
+						return
+					}
+				}
+			}
+		}(tenantID)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// The tenant doesn't linger on the pooled connection after the transactions.
+	var ids []int
+	if err := db.Select(&ids, `SELECT id FROM items`); err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != 0 {
+		t.Errorf("expected no rows outside a tenant transaction, got %v", ids)
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// tenantExports is the list of files in a tenant export and the queries that
+// produce their records as JSON. Every query takes the tenant ID as $1.
+var tenantExports = []struct {
+	name  string
+	query string
+}{
+	{"subscribers.ndjson", `SELECT ROW_TO_JSON(t) FROM subscribers t WHERE tenant_id = $1 ORDER BY id`},
+	{"subscriber_lists.ndjson", `SELECT ROW_TO_JSON(t) FROM (
+		SELECT sl.* FROM subscriber_lists sl
+		INNER JOIN subscribers s ON (s.id = sl.subscriber_id)
+		WHERE s.tenant_id = $1 ORDER BY sl.subscriber_id, sl.list_id
+	) t`},
+	{"lists.ndjson", `SELECT ROW_TO_JSON(t) FROM lists t WHERE tenant_id = $1 ORDER BY id`},
+	{"campaigns.ndjson", `SELECT ROW_TO_JSON(t) FROM campaigns t WHERE tenant_id = $1 ORDER BY id`},
+	{"templates.ndjson", `SELECT ROW_TO_JSON(t) FROM templates t WHERE tenant_id = $1 ORDER BY id`},
+	{"settings.ndjson", `SELECT ROW_TO_JSON(t) FROM (
+		SELECT key, value, updated_at FROM tenant_settings WHERE tenant_id = $1 ORDER BY key
+	) t`},
+}
+
+// ExportTenant writes a ZIP archive of the current tenant's data to w. The
+// archive has one newline-delimited JSON file per type of record (subscribers,
+// lists, campaigns, templates, settings). Records are streamed from the DB
+// to w row by row so that large tenants aren't loaded into memory.
+func (tc *TenantCore) ExportTenant(w io.Writer) error {
+	if err := tc.ensureTenantContext(); err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		for _, e := range tenantExports {
+			f, err := zw.Create(e.name)
+			if err != nil {
+				return err
+			}
+
+			if err := exportRows(tx, f, e.query, tc.tenantID); err != nil {
+				return fmt.Errorf("error exporting %s: %v", e.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// exportRows runs a query that returns a single JSON column and writes every
+// row to w as a line.
+func exportRows(tx *sqlx.Tx, w io.Writer, query string, args ...any) error {
+	rows, err := tx.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var b []byte
+	for rows.Next() {
+		if err := rows.Scan(&b); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(append(b, '\n')); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
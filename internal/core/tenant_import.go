@@ -0,0 +1,351 @@
+package core
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/gofrs/uuid/v5"
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/subimporter"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+	"github.com/lib/pq"
+)
+
+const (
+	// ImportFormatCSV is a CSV file with a header row that has the columns
+	// email, name, and optionally, attributes (a JSON object).
+	ImportFormatCSV = "csv"
+
+	// ImportFormatJSON is a JSON array of {email, name, attribs} objects.
+	ImportFormatJSON = "json"
+
+	// Number of subscribers inserted in a single transaction.
+	importBatchSize = 1000
+)
+
+// ImportResult contains the outcome of a tenant subscriber import.
+type ImportResult struct {
+	Created int           `json:"created"`
+	Updated int           `json:"updated"`
+	Skipped int           `json:"skipped"`
+	Errors  []ImportError `json:"errors"`
+}
+
+// ImportError represents an import error on a single row.
+type ImportError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email"`
+	Error string `json:"error"`
+}
+
+// importRow is a single subscriber record read from an import file.
+type importRow struct {
+	row     int
+	Email   string      `json:"email"`
+	Name    string      `json:"name"`
+	Attribs models.JSON `json:"attribs"`
+}
+
+// ImportSubscribers streams subscribers in the given format (csv or json) from r
+// and imports them into the current tenant in batched transactions. In the
+// subscribe mode, subscribers are upserted and subscribed to the given lists.
+// In the blocklist mode, they're blocklisted and unsubscribed from all lists.
+// New subscribers that exceed the tenant's subscriber limit are skipped.
+func (tc *TenantCore) ImportSubscribers(r io.Reader, format string, lists []int, mode string) (ImportResult, error) {
+	out := ImportResult{Errors: []ImportError{}}
+
+	if err := tc.ensureTenantContext(); err != nil {
+		return out, err
+	}
+
+	if mode != subimporter.ModeSubscribe && mode != subimporter.ModeBlocklist {
+		return out, fmt.Errorf("unknown import mode: %s", mode)
+	}
+
+	// Ensure the lists belong to the current tenant.
+	if err := tc.validateListOwnership(lists, nil); err != nil {
+		return out, err
+	}
+
+	// Fail early if the tenant has already reached its limit.
+	if err := tc.checkSubscriberLimit(); err != nil {
+		return out, err
+	}
+	remaining, err := tc.subscriberQuota()
+	if err != nil {
+		return out, err
+	}
+
+	// Batches that are committed change the tenant's subscriber count.
+	defer tc.invalidateUsage()
+
+	var (
+		seen  = make(map[string]struct{})
+		batch = make([]importRow, 0, importBatchSize)
+	)
+
+	// Validate the incoming row and add it to the batch, flushing the batch
+	// to the DB when it's full.
+	push := func(r importRow) error {
+		email := strings.ToLower(strings.TrimSpace(r.Email))
+		if !utils.ValidateEmail(email) {
+			out.Skipped++
+			out.Errors = append(out.Errors, ImportError{Row: r.row, Email: r.Email, Error: "invalid e-mail"})
+			return nil
+		}
+
+		if _, ok := seen[email]; ok {
+			out.Skipped++
+			out.Errors = append(out.Errors, ImportError{Row: r.row, Email: r.Email, Error: "duplicate e-mail"})
+			return nil
+		}
+		seen[email] = struct{}{}
+
+		r.Email = email
+		r.Name = strings.TrimSpace(r.Name)
+		if r.Name == "" {
+			r.Name = strings.Split(email, "@")[0]
+		}
+		if r.Attribs == nil {
+			r.Attribs = models.JSON{}
+		}
+
+		batch = append(batch, r)
+		if len(batch) < importBatchSize {
+			return nil
+		}
+
+		err := tc.importBatch(batch, lists, mode, &remaining, &out)
+		batch = batch[:0]
+		return err
+	}
+
+	switch format {
+	case ImportFormatCSV:
+		err = readImportCSV(r, push)
+	case ImportFormatJSON:
+		err = readImportJSON(r, push)
+	default:
+		return out, fmt.Errorf("unknown import format: %s", format)
+	}
+	if err != nil {
+		return out, err
+	}
+
+	// Flush the remaining records.
+	if len(batch) > 0 {
+		if err := tc.importBatch(batch, lists, mode, &remaining, &out); err != nil {
+			return out, err
+		}
+	}
+
+	return out, nil
+}
+
+// errImportLimit is the error of a row of a new subscriber that's skipped as
+// the tenant has reached its subscriber limit.
+var errImportLimit = errors.New("subscriber limit reached")
+
+// importBatch inserts a batch of subscribers in a single transaction. remaining
+// is the number of subscribers the tenant can still create (-1 for no limit) and
+// is decremented as subscribers are created. Rows that fail are skipped and
+// recorded as errors.
+func (tc *TenantCore) importBatch(rows []importRow, lists []int, mode string, remaining *int, out *ImportResult) error {
+	return tc.Tx(func(tx *sqlx.Tx) error {
+		for _, r := range rows {
+			// A DB error aborts the transaction, so every row is imported in a
+			// savepoint that's rolled back on an error to skip just the row.
+			if _, err := tx.Exec(`SAVEPOINT import_row`); err != nil {
+				return err
+			}
+
+			created, err := tc.importRow(tx, r, lists, mode, *remaining)
+			if err != nil {
+				if _, err := tx.Exec(`ROLLBACK TO SAVEPOINT import_row`); err != nil {
+					return err
+				}
+
+				out.Skipped++
+				out.Errors = append(out.Errors, ImportError{Row: r.row, Email: r.Email, Error: pqErrMsg(err)})
+				continue
+			}
+
+			if _, err := tx.Exec(`RELEASE SAVEPOINT import_row`); err != nil {
+				return err
+			}
+
+			if !created {
+				out.Updated++
+				continue
+			}
+
+			out.Created++
+			if *remaining > 0 {
+				*remaining--
+			}
+		}
+
+		return nil
+	})
+}
+
+// importRow upserts a single subscriber and returns whether it was created.
+// If the quota (remaining) is exhausted, only existing subscribers are updated
+// and errImportLimit is returned for new ones.
+func (tc *TenantCore) importRow(tx *sqlx.Tx, r importRow, lists []int, mode string, remaining int) (bool, error) {
+	if remaining == 0 {
+		var exists bool
+		if err := tx.Get(&exists, `SELECT EXISTS(SELECT 1 FROM subscribers WHERE tenant_id = $1 AND email = $2)`,
+			tc.tenantID, r.Email); err != nil {
+			return false, err
+		}
+
+		if !exists {
+			return false, errImportLimit
+		}
+	}
+
+	uu, err := uuid.NewV4()
+	if err != nil {
+		return false, err
+	}
+
+	var created bool
+	if mode == subimporter.ModeBlocklist {
+		err = tx.QueryRow(`
+			WITH sub AS (
+				INSERT INTO subscribers (tenant_id, uuid, email, name, attribs, status)
+				VALUES ($1, $2, $3, $4, $5, 'blocklisted')
+				ON CONFLICT (tenant_id, email) DO UPDATE SET status='blocklisted', updated_at=NOW()
+				RETURNING id, (xmax = 0) AS created
+			),
+			subs AS (
+				UPDATE subscriber_lists SET status='unsubscribed', updated_at=NOW()
+				WHERE subscriber_id = (SELECT id FROM sub)
+			)
+			SELECT created FROM sub
+		`, tc.tenantID, uu, r.Email, r.Name, r.Attribs).Scan(&created)
+	} else {
+		err = tx.QueryRow(`
+			WITH sub AS (
+				INSERT INTO subscribers AS s (tenant_id, uuid, email, name, attribs, status)
+				VALUES ($1, $2, $3, $4, $5, 'enabled')
+				ON CONFLICT (tenant_id, email) DO UPDATE
+					SET name=EXCLUDED.name, attribs=EXCLUDED.attribs, updated_at=NOW()
+				RETURNING id, status, (xmax = 0) AS created
+			),
+			subs AS (
+				INSERT INTO subscriber_lists (subscriber_id, list_id, status)
+				SELECT sub.id, listID,
+					(CASE WHEN sub.status = 'blocklisted' THEN 'unsubscribed' ELSE $7 END)::subscription_status
+				FROM sub, UNNEST($6::INT[]) AS listID
+				ON CONFLICT (subscriber_id, list_id) DO NOTHING
+			)
+			SELECT created FROM sub
+		`, tc.tenantID, uu, r.Email, r.Name, r.Attribs, pq.Array(lists), models.SubscriptionStatusUnconfirmed).Scan(&created)
+	}
+
+	return created, err
+}
+
+// subscriberQuota returns the number of subscribers the tenant can still
+// create, or -1 if there's no limit.
+func (tc *TenantCore) subscriberQuota() (int, error) {
+	tenant, err := tc.getTenant()
+	if err != nil {
+		return 0, err
+	}
+
+	var features models.TenantFeatures
+	if err := tenant.Features.Unmarshal(&features); err != nil || features.MaxSubscribers <= 0 {
+		return -1, nil
+	}
+
+	// The count is read in the tenant's transaction as subscribers is under RLS.
+	var count int
+	if err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&count, `SELECT COUNT(*) FROM subscribers WHERE tenant_id = $1`, tc.tenantID)
+	}); err != nil {
+		return 0, err
+	}
+
+	return max(features.MaxSubscribers-count, 0), nil
+}
+
+// readImportCSV reads subscriber records from a CSV file with a header row
+// and passes them to fn one by one.
+func readImportCSV(r io.Reader, fn func(importRow) error) error {
+	rd := csv.NewReader(r)
+	rd.FieldsPerRecord = -1
+	rd.TrimLeadingSpace = true
+
+	hdr, err := rd.Read()
+	if err != nil {
+		return fmt.Errorf("error reading CSV header: %v", err)
+	}
+
+	cols := make(map[string]int)
+	for i, h := range hdr {
+		cols[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(h, "\ufeff")))] = i
+	}
+	if _, ok := cols["email"]; !ok {
+		return errors.New("CSV header is missing the 'email' column")
+	}
+
+	get := func(rec []string, col string) string {
+		if i, ok := cols[col]; ok && i < len(rec) {
+			return rec[i]
+		}
+		return ""
+	}
+
+	// The header is row 1.
+	for n := 2; ; n++ {
+		rec, err := rd.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading CSV row %d: %v", n, err)
+		}
+
+		row := importRow{row: n, Email: get(rec, "email"), Name: get(rec, "name")}
+		if a := get(rec, "attributes"); a != "" {
+			if err := json.Unmarshal([]byte(a), &row.Attribs); err != nil {
+				return fmt.Errorf("invalid attributes JSON on CSV row %d: %v", n, err)
+			}
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+}
+
+// readImportJSON streams subscriber records from a JSON array and passes
+// them to fn one by one.
+func readImportJSON(r io.Reader, fn func(importRow) error) error {
+	dec := json.NewDecoder(r)
+
+	if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+		return errors.New("expected a JSON array of subscribers")
+	}
+
+	for n := 1; dec.More(); n++ {
+		row := importRow{row: n}
+		if err := dec.Decode(&row); err != nil {
+			return fmt.Errorf("error reading JSON record %d: %v", n, err)
+		}
+
+		if err := fn(row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,223 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/subimporter"
+	"github.com/lib/pq"
+)
+
+// importDB is a database connector that mimics the queries of a tenant's
+// subscriber import. The tenant's subscribers are under RLS and are only
+// visible in transactions with the tenant set. Like Postgres, an error aborts
+// the transaction until it's rolled back to a savepoint.
+type importDB struct {
+	tenantID int
+	maxSubs  int
+
+	// E-mails of the tenant's subscribers, and of the ones that fail to insert.
+	subs map[string]bool
+	fail map[string]bool
+
+	mut sync.Mutex
+}
+
+func (d *importDB) Connect(context.Context) (driver.Conn, error) { return &importConn{db: d}, nil }
+func (d *importDB) Driver() driver.Driver                        { return nil }
+
+type importConn struct {
+	db      *importDB
+	tenant  string
+	aborted bool
+}
+
+func (c *importConn) Prepare(q string) (driver.Stmt, error) { return &importStmt{c, q}, nil }
+func (c *importConn) Close() error                          { return nil }
+func (c *importConn) Begin() (driver.Tx, error)             { return c, nil }
+
+func (c *importConn) Commit() error {
+	aborted := c.aborted
+	c.tenant, c.aborted = "", false
+	if aborted {
+		return errors.New("pq: Could not complete operation in a failed transaction")
+	}
+	return nil
+}
+
+func (c *importConn) Rollback() error {
+	c.tenant, c.aborted = "", false
+	return nil
+}
+
+// visible returns whether the tenant's subscribers are visible under RLS.
+func (c *importConn) visible() bool {
+	return c.tenant == fmt.Sprint(c.db.tenantID)
+}
+
+type importStmt struct {
+	conn  *importConn
+	query string
+}
+
+func (s *importStmt) Close() error  { return nil }
+func (s *importStmt) NumInput() int { return -1 }
+
+func (s *importStmt) Exec(args []driver.Value) (driver.Result, error) {
+	switch {
+	case strings.Contains(s.query, "set_config"):
+		s.conn.tenant = args[1].(string)
+	case s.query == `ROLLBACK TO SAVEPOINT import_row`:
+		s.conn.aborted = false
+	case s.conn.aborted:
+		return nil, errors.New("pq: current transaction is aborted")
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (s *importStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.conn.aborted {
+		return nil, errors.New("pq: current transaction is aborted")
+	}
+
+	d := s.conn.db
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	count := 0
+	if s.conn.visible() {
+		count = len(d.subs)
+	}
+
+	switch {
+	case strings.Contains(s.query, "FROM tenants"):
+		return &importRows{cols: []string{"id", "features"},
+			rows: [][]driver.Value{{int64(d.tenantID), fmt.Sprintf(`{"max_subscribers": %d}`, d.maxSubs)}}}, nil
+
+	case strings.Contains(s.query, "AS storage_bytes"):
+		return &importRows{cols: []string{"subscribers", "campaigns", "monthly_campaigns", "lists", "templates", "storage_bytes"},
+			rows: [][]driver.Value{{int64(count), int64(0), int64(0), int64(1), int64(0), int64(0)}}}, nil
+
+	case strings.Contains(s.query, "FROM lists"):
+		return &importRows{cols: []string{"id", "uuid"}, rows: [][]driver.Value{{int64(1), "list-1"}}}, nil
+
+	case strings.Contains(s.query, "SELECT COUNT(*) FROM subscribers"):
+		return &importRows{cols: []string{"count"}, rows: [][]driver.Value{{int64(count)}}}, nil
+
+	case strings.Contains(s.query, "SELECT EXISTS"):
+		return &importRows{cols: []string{"exists"}, rows: [][]driver.Value{{s.conn.visible() && d.subs[args[1].(string)]}}}, nil
+
+	case strings.Contains(s.query, "INSERT INTO subscribers"):
+		email := args[2].(string)
+		if d.fail[email] {
+			s.conn.aborted = true
+			return nil, &pq.Error{Code: "23514", Message: "new row violates check constraint"}
+		}
+
+		created := !d.subs[email]
+		d.subs[email] = true
+		return &importRows{cols: []string{"created"}, rows: [][]driver.Value{{created}}}, nil
+	}
+
+	return nil, fmt.Errorf("unexpected query: %s", s.query)
+}
+
+type importRows struct {
+	cols []string
+	rows [][]driver.Value
+}
+
+func (r *importRows) Columns() []string { return r.cols }
+func (r *importRows) Close() error      { return nil }
+func (r *importRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// newImportCore returns a tenant core on an importDB.
+func newImportCore(t *testing.T, d *importDB) *TenantCore {
+	t.Helper()
+
+	db := sqlx.NewDb(sql.OpenDB(d), "postgres")
+	t.Cleanup(func() { db.Close() })
+
+	c := &Core{db: db, log: log.New(io.Discard, "", 0)}
+	c.InvalidateTenantUsage(d.tenantID)
+	return c.WithTenant(d.tenantID)
+}
+
+func TestImportSubscribersRowErrors(t *testing.T) {
+	d := &importDB{tenantID: 7, subs: map[string]bool{}, fail: map[string]bool{"fail@example.com": true}}
+	tc := newImportCore(t, d)
+
+	csv := "email,name\n" +
+		"a@example.com,A\n" +
+		"b@example.com,B\n" +
+		"A@example.com,A again\n" +
+		"fail@example.com,Fail\n" +
+		"c@example.com,C\n" +
+		"not-an-email,X\n"
+
+	out, err := tc.ImportSubscribers(strings.NewReader(csv), ImportFormatCSV, []int{1}, subimporter.ModeSubscribe)
+	if err != nil {
+		t.Fatalf("expected the import to complete, got %v", err)
+	}
+	if out.Created != 3 || out.Updated != 0 || out.Skipped != 3 {
+		t.Errorf("unexpected result: %+v", out)
+	}
+
+	// The rows after the one that failed are imported.
+	if !d.subs["c@example.com"] {
+		t.Error("expected the rows after the failed one to be imported")
+	}
+
+	want := map[int]string{4: "duplicate e-mail", 5: "check constraint", 7: "invalid e-mail"}
+	if len(out.Errors) != len(want) {
+		t.Fatalf("expected %d row errors, got %+v", len(want), out.Errors)
+	}
+	for _, e := range out.Errors {
+		if !strings.Contains(e.Error, want[e.Row]) || want[e.Row] == "" {
+			t.Errorf("unexpected error on row %d: %q", e.Row, e.Error)
+		}
+	}
+}
+
+func TestImportSubscribersLimit(t *testing.T) {
+	d := &importDB{tenantID: 8, maxSubs: 3, subs: map[string]bool{"old@example.com": true}}
+	tc := newImportCore(t, d)
+
+	// The tenant has 1 of its 3 subscribers. Existing subscribers are
+	// updated even once the limit is reached.
+	csv := "email,name\n" +
+		"new1@example.com,New 1\n" +
+		"new2@example.com,New 2\n" +
+		"new3@example.com,New 3\n" +
+		"old@example.com,Old\n"
+
+	out, err := tc.ImportSubscribers(strings.NewReader(csv), ImportFormatCSV, []int{1}, subimporter.ModeSubscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Created != 2 || out.Updated != 1 || out.Skipped != 1 {
+		t.Errorf("unexpected result: %+v", out)
+	}
+	if d.subs["new3@example.com"] {
+		t.Error("expected the subscriber over the limit not to be created")
+	}
+	if len(out.Errors) != 1 || out.Errors[0].Row != 4 || out.Errors[0].Error != errImportLimit.Error() {
+		t.Errorf("expected the limit error on row 4, got %+v", out.Errors)
+	}
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/media"
+)
+
+// tenantPurgeTables is the list of tenant scoped tables in the order in which
+// their rows are deleted. Dependent rows (subscriber_lists, campaign_lists,
+// campaign_views etc.) are removed by the FK cascades on the parent tables.
+var tenantPurgeTables = []string{
+	"bounces",
+	"campaigns",
+	"subscribers",
+	"lists",
+	"templates",
+	"media",
+	"tenant_settings",
+}
+
+// PurgeTenant irreversibly deletes the current tenant along with all of its
+// data in a single transaction. Once the transaction is committed, the tenant's
+// media files are removed from the media store ms. Unlike the soft delete,
+// this cannot be undone.
+func (tc *TenantCore) PurgeTenant(ms *media.TenantStore) error {
+	if err := tc.ensureTenantContext(); err != nil {
+		return err
+	}
+
+	var files []string
+	err := tc.Tx(func(tx *sqlx.Tx) error {
+		// Collect the media files (and their thumbnails) before the rows are gone.
+		if err := tx.Select(&files, `
+			SELECT f FROM media, UNNEST(ARRAY[filename, thumb]) AS f
+			WHERE tenant_id = $1 AND f != ''`, tc.tenantID); err != nil {
+			return fmt.Errorf("error fetching media: %v", err)
+		}
+
+		for _, t := range tenantPurgeTables {
+			if _, err := tx.Exec(fmt.Sprintf(`DELETE FROM %s WHERE tenant_id = $1`, t), tc.tenantID); err != nil {
+				return fmt.Errorf("error deleting %s: %v", t, err)
+			}
+		}
+
+		// user_tenants and sessions cascade from the tenant.
+		if _, err := tx.Exec(`DELETE FROM tenants WHERE id = $1`, tc.tenantID); err != nil {
+			return fmt.Errorf("error deleting tenant: %v", err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	tc.invalidateUsage()
+
+	if ms == nil {
+		return nil
+	}
+
+	return ms.CleanupTenantFiles(tc.tenantID, files)
+}
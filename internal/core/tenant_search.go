@@ -0,0 +1,144 @@
+package core
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+	"github.com/lib/pq"
+)
+
+// Attribute filter operators.
+const (
+	AttribOpEq       = "eq"
+	AttribOpContains = "contains"
+)
+
+// AttribFilter matches a subscriber attribute. Key is the attribute's path
+// with nested keys separated by dots, eg: "address.city".
+type AttribFilter struct {
+	Key   string `json:"key"`
+	Op    string `json:"op"`
+	Value string `json:"value"`
+}
+
+// SubscriberFilter is a structured subscriber search that's compiled to
+// parameterized SQL, unlike the arbitrary SQL expressions of GetSubscribers().
+// All of the given conditions have to match.
+type SubscriberFilter struct {
+	Attribs []AttribFilter `json:"attribs"`
+
+	// Subscribers that are on any of the lists, optionally with the given
+	// subscription status on them.
+	ListIDs            []int  `json:"list_ids"`
+	SubscriptionStatus string `json:"subscription_status"`
+
+	// Subscriber status (enabled, disabled, blocklisted).
+	Status string `json:"status"`
+
+	OrderBy string `json:"order_by"`
+	Order   string `json:"order"`
+	Offset  int    `json:"offset"`
+	Limit   int    `json:"limit"`
+}
+
+// SearchSubscribers retrieves the current tenant's subscribers that match a
+// structured filter.
+func (tc *TenantCore) SearchSubscribers(f SubscriberFilter) ([]models.Subscriber, error) {
+	if err := tc.ensureTenantContext(); err != nil {
+		return nil, err
+	}
+
+	stmt, args, err := compileSubscriberFilter(tc.tenantID, f)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	var out models.Subscribers
+	err = tc.Tx(func(tx *sqlx.Tx) error {
+		if err := tx.Select(&out, stmt, args...); err != nil {
+			return err
+		}
+
+		return out.LoadTenantLists(tx.Stmtx(tc.q.GetSubscriberListsLazy), tc.tenantID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// compileSubscriberFilter compiles a filter to a tenant scoped query and its
+// arguments. Only the column names in ORDER BY are put into the query, and
+// they're from an allowed list. Everything else is a parameter.
+func compileSubscriberFilter(tenantID int, f SubscriberFilter) (string, []any, error) {
+	var (
+		conds = []string{"subscribers.tenant_id = $1"}
+		args  = []any{tenantID}
+	)
+	arg := func(v any) string {
+		args = append(args, v)
+		return "$" + strconv.Itoa(len(args))
+	}
+
+	for _, a := range f.Attribs {
+		if a.Key == "" {
+			return "", nil, fmt.Errorf("invalid attribute filter: empty key")
+		}
+
+		path := "(subscribers.attribs #>> " + arg(pq.Array(strings.Split(a.Key, "."))) + "::TEXT[])"
+		switch a.Op {
+		case AttribOpEq:
+			conds = append(conds, path+" = "+arg(a.Value))
+		case AttribOpContains:
+			conds = append(conds, "STRPOS(LOWER("+path+"), LOWER("+arg(a.Value)+")) > 0")
+		default:
+			return "", nil, fmt.Errorf("invalid attribute filter operator: %s", a.Op)
+		}
+	}
+
+	if len(f.ListIDs) > 0 {
+		c := "sl.list_id = ANY(" + arg(pq.Array(f.ListIDs)) + "::INT[])"
+		if f.SubscriptionStatus != "" {
+			switch f.SubscriptionStatus {
+			case models.SubscriptionStatusUnconfirmed, models.SubscriptionStatusConfirmed, models.SubscriptionStatusUnsubscribed:
+			default:
+				return "", nil, fmt.Errorf("invalid subscription status: %s", f.SubscriptionStatus)
+			}
+			c += " AND sl.status = " + arg(f.SubscriptionStatus) + "::subscription_status"
+		}
+
+		conds = append(conds, `EXISTS (SELECT 1 FROM subscriber_lists sl
+			JOIN lists ON (lists.id = sl.list_id AND lists.tenant_id = $1)
+			WHERE sl.subscriber_id = subscribers.id AND `+c+`)`)
+	}
+
+	if f.Status != "" {
+		switch f.Status {
+		case models.SubscriberStatusEnabled, models.SubscriberStatusDisabled, models.SubscriberStatusBlockListed:
+		default:
+			return "", nil, fmt.Errorf("invalid subscriber status: %s", f.Status)
+		}
+		conds = append(conds, "subscribers.status = "+arg(f.Status)+"::subscriber_status")
+	}
+
+	orderBy := "subscribers.id"
+	if strSliceContains(f.OrderBy, subQuerySortFields) {
+		orderBy = "subscribers." + f.OrderBy
+	}
+	order := SortDesc
+	if f.Order == SortAsc {
+		order = SortAsc
+	}
+
+	offset, limit := arg(max(f.Offset, 0)), arg(f.Limit)
+	stmt := fmt.Sprintf(`SELECT subscribers.* FROM subscribers WHERE %s ORDER BY %s %s OFFSET %s LIMIT (CASE WHEN %s < 1 THEN NULL ELSE %s END)`,
+		strings.Join(conds, " AND "), orderBy, order, offset, limit, limit)
+
+	return stmt, args, nil
+}
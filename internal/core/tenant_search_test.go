@@ -0,0 +1,105 @@
+package core
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/lib/pq"
+)
+
+func TestCompileSubscriberFilterAttribs(t *testing.T) {
+	stmt, args, err := compileSubscriberFilter(7, SubscriberFilter{
+		Attribs: []AttribFilter{
+			{Key: "address.city", Op: AttribOpEq, Value: "Chennai"},
+			{Key: "plan", Op: AttribOpContains, Value: "pro"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, c := range []string{
+		"(subscribers.attribs #>> $2::TEXT[]) = $3",
+		"STRPOS(LOWER((subscribers.attribs #>> $4::TEXT[])), LOWER($5)) > 0",
+	} {
+		if !strings.Contains(stmt, c) {
+			t.Errorf("expected condition %q in: %s", c, stmt)
+		}
+	}
+
+	if !reflect.DeepEqual(args[1], pq.Array([]string{"address", "city"})) {
+		t.Errorf("expected nested attribute path, got %v", args[1])
+	}
+	if args[2] != "Chennai" || args[4] != "pro" {
+		t.Errorf("unexpected attribute values: %v", args)
+	}
+}
+
+func TestCompileSubscriberFilterInvalidAttribs(t *testing.T) {
+	for _, a := range []AttribFilter{
+		{Key: "", Op: AttribOpEq, Value: "x"},
+		{Key: "city", Op: "regex", Value: "x"},
+	} {
+		if _, _, err := compileSubscriberFilter(1, SubscriberFilter{Attribs: []AttribFilter{a}}); err == nil {
+			t.Errorf("expected error for attribute filter %+v", a)
+		}
+	}
+}
+
+func TestCompileSubscriberFilterLists(t *testing.T) {
+	stmt, args, err := compileSubscriberFilter(7, SubscriberFilter{
+		ListIDs:            []int{3, 4},
+		SubscriptionStatus: models.SubscriptionStatusConfirmed,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, c := range []string{
+		"JOIN lists ON (lists.id = sl.list_id AND lists.tenant_id = $1)",
+		"sl.list_id = ANY($2::INT[])",
+		"sl.status = $3::subscription_status",
+	} {
+		if !strings.Contains(stmt, c) {
+			t.Errorf("expected condition %q in: %s", c, stmt)
+		}
+	}
+	if !reflect.DeepEqual(args[1], pq.Array([]int{3, 4})) {
+		t.Errorf("expected list IDs, got %v", args[1])
+	}
+
+	if _, _, err := compileSubscriberFilter(7, SubscriberFilter{ListIDs: []int{3}, SubscriptionStatus: "bogus"}); err == nil {
+		t.Error("expected error for invalid subscription status")
+	}
+}
+
+func TestCompileSubscriberFilterTenantIsolation(t *testing.T) {
+	const evil = "x' OR 1=1 --"
+
+	for _, f := range []SubscriberFilter{
+		{},
+		{Attribs: []AttribFilter{{Key: evil, Op: AttribOpEq, Value: evil}}},
+		{ListIDs: []int{1}},
+		{Status: models.SubscriberStatusEnabled, OrderBy: "id; DROP TABLE subscribers", Order: evil},
+	} {
+		stmt, args, err := compileSubscriberFilter(42, f)
+		if err != nil {
+			t.Fatalf("unexpected error for %+v: %v", f, err)
+		}
+
+		if !strings.Contains(stmt, "WHERE subscribers.tenant_id = $1") {
+			t.Errorf("query isn't scoped to the tenant: %s", stmt)
+		}
+		if args[0] != 42 {
+			t.Errorf("expected tenant ID as the first argument, got %v", args[0])
+		}
+		if strings.Contains(stmt, evil) || strings.Contains(stmt, "DROP") {
+			t.Errorf("filter values leaked into the query: %s", stmt)
+		}
+		if !strings.Contains(stmt, "ORDER BY subscribers.id "+SortDesc) {
+			t.Errorf("expected the default order, got: %s", stmt)
+		}
+	}
+}
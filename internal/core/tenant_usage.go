@@ -0,0 +1,83 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// tenantUsageTTL is the duration for which a tenant's usage counts are
+// cached before they're queried again.
+const tenantUsageTTL = 30 * time.Second
+
+// tenantUsage holds the counts of a tenant's resources that are checked
+// against its plan's limits.
+type tenantUsage struct {
+	Subscribers      int   `db:"subscribers"`
+	Campaigns        int   `db:"campaigns"`
+	MonthlyCampaigns int   `db:"monthly_campaigns"`
+	Lists            int   `db:"lists"`
+	Templates        int   `db:"templates"`
+	StorageBytes     int64 `db:"storage_bytes"`
+
+	expiry time.Time
+}
+
+// usageCache caches the usage counts of tenants for a short duration as
+// TenantCore instances are short-lived and created per request.
+type usageCache struct {
+	usage map[int]tenantUsage
+	mut   sync.Mutex
+}
+
+var tenantUsageCache = &usageCache{usage: make(map[int]tenantUsage)}
+
+// getUsage returns the current tenant's usage counts from the cache,
+// querying them in one go if they're not cached or have expired.
+func (tc *TenantCore) getUsage() (tenantUsage, error) {
+	tenantUsageCache.mut.Lock()
+	u, ok := tenantUsageCache.usage[tc.tenantID]
+	tenantUsageCache.mut.Unlock()
+
+	if ok && time.Now().Before(u.expiry) {
+		return u, nil
+	}
+
+	// The counts are read in the tenant's transaction as the tables are under
+	// RLS, which would have them all be 0 otherwise.
+	if err := tc.Tx(func(tx *sqlx.Tx) error {
+		return tx.Get(&u, `
+			SELECT
+				(SELECT COUNT(*) FROM subscribers WHERE tenant_id = $1) AS subscribers,
+				(SELECT COUNT(*) FROM campaigns WHERE tenant_id = $1) AS campaigns,
+				(SELECT COUNT(*) FROM campaigns WHERE tenant_id = $1
+					AND created_at >= date_trunc('month', CURRENT_DATE)) AS monthly_campaigns,
+				(SELECT COUNT(*) FROM lists WHERE tenant_id = $1) AS lists,
+				(SELECT COUNT(*) FROM templates WHERE tenant_id = $1) AS templates,
+				(SELECT COALESCE(SUM((meta->>'size')::BIGINT), 0) FROM media WHERE tenant_id = $1) AS storage_bytes
+		`, tc.tenantID)
+	}); err != nil {
+		return tenantUsage{}, err
+	}
+	u.expiry = time.Now().Add(tenantUsageTTL)
+
+	tenantUsageCache.mut.Lock()
+	tenantUsageCache.usage[tc.tenantID] = u
+	tenantUsageCache.mut.Unlock()
+
+	return u, nil
+}
+
+// invalidateUsage discards the current tenant's cached usage counts. This
+// should be called whenever the tenant's resources are created or deleted.
+func (tc *TenantCore) invalidateUsage() {
+	tc.Core.InvalidateTenantUsage(tc.tenantID)
+}
+
+// InvalidateTenantUsage discards a tenant's cached usage counts.
+func (c *Core) InvalidateTenantUsage(tenantID int) {
+	tenantUsageCache.mut.Lock()
+	delete(tenantUsageCache.usage, tenantID)
+	tenantUsageCache.mut.Unlock()
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+var (
+	tenantQuerySortFields = []string{"id", "name", "slug", "status", "plan", "created_at", "updated_at"}
+
+	// Escapes the wildcards in LIKE patterns.
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+)
+
+// QueryTenants retrieves a page of the tenants across the instance whose name,
+// slug, or domain contain searchStr and that have the given status (any but
+// deleted if it's empty), and the total number of matching tenants. It's not
+// tenant scoped and is only for super admins.
+func (c *Core) QueryTenants(searchStr, status, orderBy, order string, offset, limit int) ([]models.Tenant, int, error) {
+	switch status {
+	case "", models.TenantStatusActive, models.TenantStatusSuspended, models.TenantStatusDeleted:
+	default:
+		return nil, 0, echo.NewHTTPError(http.StatusBadRequest,
+			c.i18n.Ts("globals.messages.invalidFields", "name", "status"))
+	}
+
+	// Escape the LIKE wildcards so that the search is a plain substring match.
+	if searchStr != "" {
+		searchStr = "%" + likeEscaper.Replace(strings.TrimSpace(searchStr)) + "%"
+	}
+
+	if !strSliceContains(orderBy, tenantQuerySortFields) {
+		orderBy = "created_at"
+	}
+	if order != SortAsc && order != SortDesc {
+		order = SortDesc
+	}
+
+	var (
+		out  = []models.Tenant{}
+		stmt = strings.ReplaceAll(c.q.QueryTenants, "%order%", "t."+orderBy+" "+order+", t.id")
+	)
+	if err := c.db.Select(&out, stmt, offset, limit, searchStr, status); err != nil {
+		c.log.Printf("error fetching tenants: %v", err)
+		return nil, 0, echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorFetching", "name", "tenants", "error", pqErrMsg(err)))
+	}
+
+	total := 0
+	if len(out) > 0 {
+		total = out[0].Total
+	}
+
+	return out, total, nil
+}
+
+// LogTenantAudit records an action taken on a tenant by a user, eg: a super
+// admin impersonating it, in the tenant's audit log.
+func (c *Core) LogTenantAudit(tenantID, userID int, action string, meta map[string]any) error {
+	if meta == nil {
+		meta = map[string]any{}
+	}
+	b, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	if err := WithTenantTx(c.db, tenantID, func(tx *sqlx.Tx) error {
+		_, err := tx.Stmtx(c.q.InsertTenantAuditLog).Exec(tenantID, userID, action, b)
+		return err
+	}); err != nil {
+		c.log.Printf("error logging tenant audit action '%s': %v", action, err)
+		return echo.NewHTTPError(http.StatusInternalServerError,
+			c.i18n.Ts("globals.messages.errorCreating", "name", "audit log", "error", pqErrMsg(err)))
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package core
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+)
+
+// recDB is a database connector that records the statements executed on it.
+type recDB struct {
+	execs []recExec
+}
+
+type recExec struct {
+	query string
+	args  []driver.Value
+}
+
+func (r *recDB) Connect(context.Context) (driver.Conn, error) { return &recConn{r}, nil }
+func (r *recDB) Driver() driver.Driver                        { return nil }
+
+type recConn struct{ db *recDB }
+
+func (c *recConn) Prepare(q string) (driver.Stmt, error) { return &recStmt{c.db, q}, nil }
+func (c *recConn) Close() error                          { return nil }
+func (c *recConn) Begin() (driver.Tx, error)             { return &recTx{c.db}, nil }
+
+type recTx struct{ db *recDB }
+
+func (t *recTx) Commit() error {
+	t.db.execs = append(t.db.execs, recExec{query: "COMMIT"})
+	return nil
+}
+func (t *recTx) Rollback() error { return nil }
+
+type recStmt struct {
+	db    *recDB
+	query string
+}
+
+func (s *recStmt) Close() error  { return nil }
+func (s *recStmt) NumInput() int { return -1 }
+func (s *recStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.execs = append(s.db.execs, recExec{query: s.query, args: args})
+	return driver.RowsAffected(1), nil
+}
+func (s *recStmt) Query([]driver.Value) (driver.Rows, error) {
+	return nil, errors.New("not supported")
+}
+
+func TestLogTenantAudit(t *testing.T) {
+	var (
+		rec = &recDB{}
+		db  = sqlx.NewDb(sql.OpenDB(rec), "postgres")
+	)
+
+	stmt, err := db.Preparex(`INSERT INTO tenant_audit_log (tenant_id, user_id, action, meta) VALUES($1, $2, $3, $4)`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := &Core{db: db, q: &models.Queries{InsertTenantAuditLog: stmt}, log: log.Default()}
+	if err := c.LogTenantAudit(5, 10, "impersonate", map[string]any{"ip": "127.0.0.1"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The row is inserted in a transaction that's scoped to the tenant.
+	if len(rec.execs) != 3 {
+		t.Fatalf("expected set_config, insert and commit, got %+v", rec.execs)
+	}
+	if e := rec.execs[0]; !strings.Contains(e.query, "set_config") || e.args[1] != "5" {
+		t.Errorf("expected the tenant to be set on the transaction, got %+v", e)
+	}
+	if rec.execs[2].query != "COMMIT" {
+		t.Errorf("expected the transaction to be committed, got %+v", rec.execs[2])
+	}
+
+	e := rec.execs[1]
+	if !strings.Contains(e.query, "tenant_audit_log") {
+		t.Fatalf("expected an audit log insert, got %q", e.query)
+	}
+	if e.args[0] != int64(5) || e.args[1] != int64(10) || e.args[2] != "impersonate" {
+		t.Errorf("unexpected audit row: %v", e.args)
+	}
+
+	var meta map[string]any
+	if err := json.Unmarshal(e.args[3].([]byte), &meta); err != nil || meta["ip"] != "127.0.0.1" {
+		t.Errorf("unexpected audit meta: %s (%v)", e.args[3], err)
+	}
+}
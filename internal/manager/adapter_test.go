@@ -0,0 +1,55 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func newAdaptedManager(t *testing.T, st *testStore) *Manager {
+	t.Helper()
+
+	m, err := NewFromTenantStore(testConfig(), st, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.store.(TenantStore); ok {
+		t.Fatal("expected the store to be wrapped in the legacy adapter")
+	}
+	return m
+}
+
+func TestAdaptedManagerPreviewTenantCampaign(t *testing.T) {
+	st := newTestStore()
+	m := newAdaptedManager(t, st)
+
+	c := st.addCampaign(2, 1, 1, "email")
+	c.Body = `{{ UnsubscribeURL }}`
+
+	body, err := m.PreviewTenantCampaign(2, c, models.Subscriber{UUID: "sub-uuid", Email: "sub1@camp1.test"})
+	if err != nil {
+		t.Fatalf("error previewing: %v", err)
+	}
+	if want := "http://listmonk.test/tenant/2/subscription/"; !strings.Contains(string(body), want) {
+		t.Errorf("expected the tenant's unsubscribe URL %q in the preview, got %q", want, body)
+	}
+}
+
+func TestAdaptedManagerProcessTenantBounce(t *testing.T) {
+	st := newTestStore()
+	m := newAdaptedManager(t, st)
+
+	if err := m.ProcessTenantBounce(2, models.Bounce{SubscriberID: 7, Type: models.BounceTypeHard}); err != nil {
+		t.Fatalf("error processing bounce: %v", err)
+	}
+
+	st.mut.Lock()
+	defer st.mut.Unlock()
+	if st.bounces[7] != 1 {
+		t.Errorf("expected 1 recorded bounce, got %d", st.bounces[7])
+	}
+	if len(st.blocked) != 1 || st.blocked[0] != 7 {
+		t.Errorf("expected subscriber 7 to be blocklisted, got %v", st.blocked)
+	}
+}
@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// adaptiveBatch adjusts the subscriber batch size of a campaign to the latency
+// of its message pushes. The batch is halved (down to a floor) when the average
+// latency of the last batch is more than double the best seen, and grown by a
+// quarter (up to the configured batch size) when it's close to the best. This
+// smooths throughput when the messenger, eg: SMTP, slows down.
+type adaptiveBatch struct {
+	maxSize int
+	floor   int
+
+	// Push latencies recorded by the workers since the last batch.
+	total atomic.Int64
+	count atomic.Int64
+
+	// Only accessed by the pipe's NextSubscribers(), which is never
+	// called concurrently for a pipe.
+	size int
+	best time.Duration
+}
+
+// newAdaptiveBatch returns an adaptive batch that starts at and doesn't grow
+// beyond maxSize, or nil if adaptive batching is disabled.
+func newAdaptiveBatch(enabled bool, maxSize int) *adaptiveBatch {
+	if !enabled {
+		return nil
+	}
+
+	return &adaptiveBatch{
+		maxSize: maxSize,
+		floor:   max(maxSize/10, 1),
+		size:    maxSize,
+	}
+}
+
+// record records the latency of a message push.
+func (a *adaptiveBatch) record(d time.Duration) {
+	if a == nil {
+		return
+	}
+
+	a.total.Add(int64(d))
+	a.count.Add(1)
+}
+
+// next returns the size of the next batch, adjusted to the latencies recorded
+// since the last one. If adaptive batching is disabled, size is returned as-is.
+func (a *adaptiveBatch) next(size int) int {
+	if a == nil {
+		return size
+	}
+
+	n, total := a.count.Swap(0), a.total.Swap(0)
+	if n == 0 {
+		return a.size
+	}
+	lat := time.Duration(total / n)
+
+	// The best latency drifts up slowly so that a provider that has become
+	// permanently slower doesn't keep the batches small forever.
+	if a.best == 0 || lat < a.best {
+		a.best = lat
+	} else {
+		a.best += (lat - a.best) / 16
+	}
+
+	switch {
+	case lat > a.best*2:
+		a.size = max(a.size/2, a.floor)
+	case lat < a.best*5/4:
+		a.size = min(a.size+max(a.size/4, 1), a.maxSize)
+	}
+
+	return a.size
+}
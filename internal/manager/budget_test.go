@@ -0,0 +1,43 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGlobalMessageBudget(t *testing.T) {
+	cfg := testConfig()
+	cfg.GlobalMessageRate = 10
+
+	st := newTestStore()
+	tm, msgr := newTestTenantManager(t, cfg, st)
+
+	// Two tenants that could each send far more than the budget on their own.
+	for _, tenantID := range []int{2, 3} {
+		c := st.addCampaign(tenantID, tenantID, 30, "email")
+		startPipe(t, startTenant(t, tm, tenantID), c)
+	}
+
+	time.Sleep(time.Millisecond * 1050)
+
+	// A slot every 100ms, from 0 to 1s, across both tenants.
+	counts := map[int]int{}
+	for _, msg := range msgr.Sent() {
+		counts[msg.TenantID]++
+	}
+	if n := counts[2] + counts[3]; n > 11 {
+		t.Errorf("expected at most 11 messages across the tenants, got %d (%v)", n, counts)
+	}
+	if counts[2] == 0 || counts[3] == 0 {
+		t.Errorf("expected both tenants to send, got %v", counts)
+	}
+
+	// Workers waiting on the budget don't hold up stopping the instances.
+	start := time.Now()
+	for _, tenantID := range []int{2, 3} {
+		tm.tenantManagers[tenantID].stop()
+	}
+	if d := time.Since(start); d > time.Millisecond*500 {
+		t.Errorf("expected the instances to stop right away, took %v", d)
+	}
+}
@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestCancelCampaign(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+
+	st := newTestStore()
+	g := newGatedMessenger("gated")
+	m, msgr := newTestManager(t, cfg, st, g)
+
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 10, "gated"))
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 2, 10, "email"))
+
+	// Cancel with the rest of the campaign's messages queued.
+	g.allow(t, 2)
+	if err := m.CancelCampaign(1); err != nil {
+		t.Fatal(err)
+	}
+	if s := st.status(1); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to be cancelled right away, got %s", s)
+	}
+	close(g.gate)
+
+	waitFor(t, time.Second*2, "the campaigns to end", func() bool { return !m.HasRunningCampaigns() })
+
+	// Only the message a worker was pushing when it was cancelled may go out.
+	if n := len(g.Sent()); n > 3 {
+		t.Errorf("expected the queued messages to be dropped, got %d messages", n)
+	}
+	if s := st.status(1); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to stay cancelled, got %s", s)
+	}
+	m.pipesMut.RLock()
+	_, ok := m.checkpoints[1]
+	m.pipesMut.RUnlock()
+	if ok {
+		t.Error("expected no checkpoint for the cancelled campaign")
+	}
+
+	// The other campaign is unaffected.
+	if n := len(msgr.Sent()); n != 10 {
+		t.Errorf("expected the other campaign's 10 messages, got %d", n)
+	}
+	if s := st.status(2); s != models.CampaignStatusFinished {
+		t.Errorf("expected the other campaign to finish, got %s", s)
+	}
+}
+
+func TestCancelTenantCampaign(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+
+	st := newTestStore()
+	g := newGatedMessenger("gated")
+	tm, _ := newTestTenantManager(t, cfg, st, g)
+
+	c := st.addCampaign(2, 1, 10, "gated")
+	tim := startTenant(t, tm, 2)
+	startPipe(t, tim, c)
+
+	g.allow(t, 2)
+	if err := tm.CancelTenantCampaign(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	if s := st.status(1); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to be cancelled right away, got %s", s)
+	}
+	close(g.gate)
+
+	waitFor(t, time.Second*2, "the campaign to end", func() bool { return !tim.HasRunningCampaigns() })
+	if n := len(g.Sent()); n > 3 {
+		t.Errorf("expected the queued messages to be dropped, got %d messages", n)
+	}
+	if s := st.status(1); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to stay cancelled, got %s", s)
+	}
+
+	// Without a running instance, only the status is updated.
+	st.addCampaign(3, 2, 10, "gated")
+	if err := tm.CancelTenantCampaign(3, 2); err != nil {
+		t.Fatal(err)
+	}
+	if s := st.status(2); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to be cancelled, got %s", s)
+	}
+}
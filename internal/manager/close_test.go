@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// slowMessenger is a memory messenger that takes a while to push messages.
+type slowMessenger struct {
+	*MemoryMessenger
+}
+
+func (s *slowMessenger) Push(msg models.Message) error {
+	time.Sleep(time.Millisecond * 5)
+	return s.MemoryMessenger.Push(msg)
+}
+
+func TestCloseDrainsQueues(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 2
+	cfg.BatchSize = 30
+
+	st := newTestStore()
+	msgr := &slowMessenger{NewMemoryMessenger("slow")}
+	m, _ := newTestManager(t, cfg, st, msgr)
+
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 30, "slow"))
+	for i := 0; i < 5; i++ {
+		if err := m.PushMessage(models.Message{Messenger: "slow", Subject: "tx"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Close once the campaign's batch has been fetched and is being queued.
+	waitFor(t, time.Second, "the batch to be fetched", func() bool { return st.checkpoint(1) == 30 })
+	if err := m.Close(); err != nil {
+		t.Fatalf("unexpected error closing: %v", err)
+	}
+
+	if n := len(msgr.Sent()); n != 35 {
+		t.Errorf("expected all 35 queued messages to be sent before Close() returned, got %d", n)
+	}
+	if err := m.PushMessage(models.Message{Messenger: "slow"}); err == nil {
+		t.Error("expected pushes to be rejected after closing")
+	}
+}
+
+func TestCloseDrainTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+	cfg.DrainTimeout = time.Millisecond * 100
+
+	st := newTestStore()
+	g := newGatedMessenger("gated")
+	m, _ := newTestManager(t, cfg, st, g)
+
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 5, "gated"))
+	waitFor(t, time.Second, "the batch to be fetched", func() bool { return st.checkpoint(1) == 5 })
+
+	if err := m.Close(); err == nil {
+		t.Error("expected an error when the queues don't drain in time")
+	}
+	close(g.gate)
+}
@@ -0,0 +1,82 @@
+package manager
+
+import "fmt"
+
+// urlTpl is a URL template in the config and the number of formatting
+// verbs that it's expected to have.
+type urlTpl struct {
+	name  string
+	url   string
+	verbs int
+}
+
+// Validate checks that the URL templates in the config have exactly the
+// number of formatting verbs (%s) that they're formatted with. A stray verb,
+// eg: from a % in the root URL, would otherwise end up as %!(EXTRA ...)
+// or %!s(MISSING) garbage in messages. Empty URLs and URLs that are used
+// as-is (archive, root) are skipped.
+func (c Config) Validate() error {
+	return validateURLTpls([]urlTpl{
+		{"unsubscribe URL", c.UnsubURL, 2},
+		{"opt-in URL", c.OptinURL, 2},
+		{"message URL", c.MessageURL, 2},
+		{"link tracking URL", c.LinkTrackURL, 3},
+		{"view tracking URL", c.ViewTrackURL, 2},
+	})
+}
+
+// Validate checks the tenant's URL templates along with the base config's.
+func (c TenantConfig) Validate() error {
+	if err := c.Config.Validate(); err != nil {
+		return err
+	}
+
+	return validateURLTpls([]urlTpl{
+		{"tenant unsubscribe URL", c.TenantUnsubURL, 2},
+		{"tenant opt-in URL", c.TenantOptinURL, 2},
+		{"tenant message URL", c.TenantMessageURL, 2},
+	})
+}
+
+func validateURLTpls(tpls []urlTpl) error {
+	for _, t := range tpls {
+		if t.url == "" {
+			continue
+		}
+
+		if n := countVerbs(t.url); n != t.verbs {
+			return fmt.Errorf("invalid %s '%s': expected %d %%s verbs, found %d", t.name, t.url, t.verbs, n)
+		}
+	}
+
+	return nil
+}
+
+// countVerbs returns the number of formatting verbs in a format string,
+// ignoring escaped %%.
+func countVerbs(s string) int {
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+
+		if i+1 < len(s) && s[i+1] == '%' {
+			i++
+			continue
+		}
+		n++
+	}
+
+	return n
+}
+
+// setTenantURLs sets the tenant's public URLs, which are namespaced
+// under the tenant on the root URL.
+func (c *TenantConfig) setTenantURLs(rootURL string) {
+	c.TenantRootURL = fmt.Sprintf("%s/tenant/%d", rootURL, c.TenantID)
+	c.TenantUnsubURL = fmt.Sprintf("%s/tenant/%d/subscription/%%s/%%s", rootURL, c.TenantID)
+	c.TenantOptinURL = fmt.Sprintf("%s/tenant/%d/subscription/optin/%%s?l=%%s", rootURL, c.TenantID)
+	c.TenantMessageURL = fmt.Sprintf("%s/tenant/%d/campaign/%%s/%%s", rootURL, c.TenantID)
+	c.TenantArchiveURL = fmt.Sprintf("%s/tenant/%d/archive", rootURL, c.TenantID)
+}
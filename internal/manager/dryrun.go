@@ -0,0 +1,76 @@
+package manager
+
+import (
+	"sync"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// maxDryRunMessages is the maximum number of rendered messages that are
+// retained per campaign in the dry-run mode.
+const maxDryRunMessages = 1000
+
+// dryRunLog records the rendered messages of campaigns that are run in the
+// dry-run mode instead of sending them out.
+type dryRunLog struct {
+	msgs map[int][]models.Message
+	mut  sync.RWMutex
+}
+
+// reset discards the messages recorded for a campaign.
+func (d *dryRunLog) reset(campID int) {
+	d.mut.Lock()
+	delete(d.msgs, campID)
+	d.mut.Unlock()
+}
+
+// record records a rendered campaign message. Messages beyond
+// maxDryRunMessages are accounted for as sent but are not retained.
+func (d *dryRunLog) record(campID int, msg models.Message) error {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	if d.msgs == nil {
+		d.msgs = make(map[int][]models.Message)
+	}
+	if len(d.msgs[campID]) < maxDryRunMessages {
+		d.msgs[campID] = append(d.msgs[campID], msg)
+	}
+
+	return nil
+}
+
+// get returns a copy of the messages recorded for a campaign.
+func (d *dryRunLog) get(campID int) []models.Message {
+	d.mut.RLock()
+	defer d.mut.RUnlock()
+
+	return append([]models.Message{}, d.msgs[campID]...)
+}
+
+// SetCampaignDryRun overrides Config.DryRun for a campaign. In the dry-run
+// mode, the campaign's messages are rendered and accounted for as usual, but
+// are recorded instead of being pushed to the messenger.
+func (m *Manager) SetCampaignDryRun(id int, on bool) {
+	m.pipesMut.Lock()
+	m.dryRuns[id] = on
+	m.pipesMut.Unlock()
+}
+
+// DryRunResults returns the rendered messages recorded for a campaign
+// that was run in the dry-run mode.
+func (m *Manager) DryRunResults(campID int) []models.Message {
+	return m.dryRun.get(campID)
+}
+
+// isDryRun returns true if the campaign's messages should be recorded
+// instead of being sent.
+func (m *Manager) isDryRun(campID int) bool {
+	m.pipesMut.RLock()
+	defer m.pipesMut.RUnlock()
+
+	if on, ok := m.dryRuns[campID]; ok {
+		return on
+	}
+	return m.cfg.DryRun
+}
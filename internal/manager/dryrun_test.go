@@ -0,0 +1,48 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestDryRun(t *testing.T) {
+	st := newTestStore()
+	m, msgr := newTestManager(t, testConfig(), st)
+
+	// Campaign 1 is a dry run, campaign 2 is sent.
+	m.SetCampaignDryRun(1, true)
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 5, "email"))
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 2, 3, "email"))
+
+	waitFor(t, time.Second*2, "the campaigns to finish", func() bool {
+		return st.status(1) == models.CampaignStatusFinished && st.status(2) == models.CampaignStatusFinished
+	})
+
+	for _, msg := range msgr.Sent() {
+		if msg.Campaign.ID == 1 {
+			t.Fatalf("dry run message was sent to subscriber %d", msg.Subscriber.ID)
+		}
+	}
+	if n := len(msgr.Sent()); n != 3 {
+		t.Errorf("expected the other campaign's 3 messages, got %d", n)
+	}
+
+	// The dry run's messages are rendered and accounted for as sent.
+	res := m.DryRunResults(1)
+	if len(res) != 5 {
+		t.Fatalf("expected 5 dry run messages, got %d", len(res))
+	}
+	for _, msg := range res {
+		if b := string(msg.Body); b != "Hello "+msg.Subscriber.Email {
+			t.Errorf("unexpected dry run body: %q", b)
+		}
+	}
+	if c, _ := st.GetCampaign(1); c.Sent != 5 {
+		t.Errorf("expected the dry run to count 5 messages as sent, got %d", c.Sent)
+	}
+	if len(m.DryRunResults(2)) != 0 {
+		t.Error("expected no dry run messages for the sent campaign")
+	}
+}
@@ -0,0 +1,63 @@
+package manager
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func failingMessenger(name string) *MemoryMessenger {
+	m := NewMemoryMessenger(name)
+	m.FailWith(func(models.Message) error { return errors.New("backend down") })
+	return m
+}
+
+func TestFallbackMessenger(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxSendErrors = 10
+	cfg.FallbackMessenger = "email"
+
+	st := newTestStore()
+	primary := failingMessenger("primary")
+	m, fallback := newTestManager(t, cfg, st, primary)
+
+	p := startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 3, "primary"))
+
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(fallback.Sent()); n != 3 {
+		t.Errorf("expected 3 messages via the fallback, got %d", n)
+	}
+	if n := m.sent.Load(); n != 3 {
+		t.Errorf("expected 3 messages counted as sent, got %d", n)
+	}
+	if n := p.errors.Load(); n != 0 {
+		t.Errorf("expected no errors on the pipe, got %d", n)
+	}
+}
+
+func TestTenantFallbackMessenger(t *testing.T) {
+	cfg := testConfig()
+	cfg.MaxSendErrors = 10
+	cfg.FallbackMessenger = "email"
+
+	st := newTestStore()
+	primary := failingMessenger("primary")
+	tm, fallback := newTestTenantManager(t, cfg, st, primary)
+
+	c := st.addCampaign(2, 1, 3, "primary")
+	tim := startTenant(t, tm, 2)
+	tp := startPipe(t, tim, c)
+
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(fallback.Sent()); n != 3 {
+		t.Errorf("expected 3 messages via the fallback, got %d", n)
+	}
+	if n := tim.sent.Load(); n != 3 {
+		t.Errorf("expected 3 messages counted as sent, got %d", n)
+	}
+	if n := tp.errors.Load(); n != 0 {
+		t.Errorf("expected no errors on the pipe, got %d", n)
+	}
+}
@@ -0,0 +1,41 @@
+package manager
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+)
+
+var bodyCloseTag = []byte("</body>")
+
+// withUnsubFooter appends a footer with the unsubscribe URL to a rendered
+// message body that doesn't already reference it. HTML bodies get the footer
+// before the closing </body> tag if there's one.
+func withUnsubFooter(body []byte, unsubURL string, plain bool) []byte {
+	if len(body) == 0 || hasUnsubURL(body, unsubURL) {
+		return body
+	}
+
+	if plain {
+		return append(body, fmt.Sprintf("\n\nUnsubscribe: %s\n", unsubURL)...)
+	}
+
+	footer := []byte(fmt.Sprintf(`<p style="font-size: 12px; text-align: center;"><a href="%s">Unsubscribe</a></p>`,
+		html.EscapeString(unsubURL)))
+
+	if i := bytes.LastIndex(bytes.ToLower(body), bodyCloseTag); i > -1 {
+		out := make([]byte, 0, len(body)+len(footer))
+		out = append(out, body[:i]...)
+		out = append(out, footer...)
+		return append(out, body[i:]...)
+	}
+
+	return append(body, footer...)
+}
+
+// hasUnsubURL checks if the body references the unsubscribe URL, as-is or
+// HTML escaped.
+func hasUnsubURL(body []byte, unsubURL string) bool {
+	return bytes.Contains(body, []byte(unsubURL)) ||
+		bytes.Contains(body, []byte(html.EscapeString(unsubURL)))
+}
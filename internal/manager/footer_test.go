@@ -0,0 +1,28 @@
+package manager
+
+import "testing"
+
+func TestWithUnsubFooter(t *testing.T) {
+	const u = "https://listmonk.test/unsub/c/s?a=1&b=2"
+
+	for _, c := range []struct {
+		name  string
+		body  string
+		plain bool
+		want  string
+	}{
+		{"html", "<p>Hi</p>", false,
+			`<p>Hi</p><p style="font-size: 12px; text-align: center;"><a href="https://listmonk.test/unsub/c/s?a=1&amp;b=2">Unsubscribe</a></p>`},
+		{"before body close", "<html><body><p>Hi</p></BODY></html>", false,
+			`<html><body><p>Hi</p><p style="font-size: 12px; text-align: center;"><a href="https://listmonk.test/unsub/c/s?a=1&amp;b=2">Unsubscribe</a></p></BODY></html>`},
+		{"plain", "Hi", true, "Hi\n\nUnsubscribe: " + u + "\n"},
+		{"has link", `<a href="` + u + `">out</a>`, false, `<a href="` + u + `">out</a>`},
+		{"has escaped link", `<a href="https://listmonk.test/unsub/c/s?a=1&amp;b=2">out</a>`, false,
+			`<a href="https://listmonk.test/unsub/c/s?a=1&amp;b=2">out</a>`},
+		{"empty", "", true, ""},
+	} {
+		if got := string(withUnsubFooter([]byte(c.body), u, c.plain)); got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
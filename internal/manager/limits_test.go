@@ -0,0 +1,102 @@
+package manager
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestCanProcessCampaign(t *testing.T) {
+	st := newTestStore()
+	st.features[2] = models.TenantFeatures{MaxCampaignsPerMonth: 5, MaxSubscribers: 100}
+	le := NewTenantLimitsEnforcer(st)
+
+	for _, c := range []struct {
+		usage  TenantUsage
+		ok     bool
+		reason string
+	}{
+		{TenantUsage{CampaignsThisMonth: 1, Subscribers: 10}, true, ""},
+		{TenantUsage{CampaignsThisMonth: 5, Subscribers: 100}, true, ""},
+		{TenantUsage{CampaignsThisMonth: 6, Subscribers: 10}, false, "monthly campaign limit exceeded (6/5)"},
+		{TenantUsage{CampaignsThisMonth: 1, Subscribers: 101}, false, "subscriber limit exceeded (101/100)"},
+	} {
+		st.usage[2] = c.usage
+		ok, reason := le.CanProcessCampaign(2, nil)
+		if ok != c.ok || reason != c.reason {
+			t.Errorf("%+v: expected (%v, %q), got (%v, %q)", c.usage, c.ok, c.reason, ok, reason)
+		}
+	}
+
+	// Tenants without limits aren't checked for usage.
+	st.usage[3] = TenantUsage{CampaignsThisMonth: 1000, Subscribers: 1000000}
+	if ok, _ := le.CanProcessCampaign(3, nil); !ok {
+		t.Error("expected a tenant without limits to process campaigns")
+	}
+}
+
+func TestScanCampaignsLimits(t *testing.T) {
+	cfg := testConfig()
+	cfg.ScanCampaigns = true
+	cfg.ScanInterval = time.Millisecond * 20
+
+	st := newTestStore()
+	st.addCampaign(2, 1, 5, "email")
+	st.addCampaign(3, 2, 5, "email")
+
+	// Tenant 2 is over its monthly campaign limit and tenant 3 is under its limits.
+	st.features[2] = models.TenantFeatures{MaxCampaignsPerMonth: 1}
+	st.usage[2] = TenantUsage{CampaignsThisMonth: 2}
+	st.features[3] = models.TenantFeatures{MaxCampaignsPerMonth: 10, MaxSubscribers: 100}
+	st.usage[3] = TenantUsage{CampaignsThisMonth: 1, Subscribers: 5}
+
+	tm, msgr := newTestTenantManager(t, cfg, st)
+
+	var (
+		notifs []string
+		mut    sync.Mutex
+	)
+	tm.fnNotify = func(tenantID int, subject string, data any) error {
+		mut.Lock()
+		notifs = append(notifs, subject+": "+data.(map[string]any)["Reason"].(string))
+		mut.Unlock()
+		return nil
+	}
+	startTenant(t, tm, 2)
+	startTenant(t, tm, 3)
+
+	waitFor(t, 5*time.Second, "the campaign under the limits to finish", func() bool {
+		return st.status(2) == models.CampaignStatusFinished
+	})
+
+	// Let a few more scans pass over the skipped campaign.
+	time.Sleep(cfg.ScanInterval * 5)
+
+	for _, m := range msgr.Sent() {
+		if m.TenantID == 2 {
+			t.Fatalf("expected no messages for the tenant over its limits, got %+v", m)
+		}
+	}
+	if n := len(msgr.Sent()); n != 5 {
+		t.Errorf("expected 5 messages, got %d", n)
+	}
+	if s := st.status(1); s != models.CampaignStatusRunning {
+		t.Errorf("expected the skipped campaign to stay running, got %s", s)
+	}
+
+	mut.Lock()
+	defer mut.Unlock()
+
+	var skipped []string
+	for _, n := range notifs {
+		if strings.HasPrefix(n, "Tenant 2 ") {
+			skipped = append(skipped, n)
+		}
+	}
+	if len(skipped) != 1 || !strings.HasSuffix(skipped[0], "monthly campaign limit exceeded (2/1)") {
+		t.Errorf("expected one limit notification for tenant 2, got %v", skipped)
+	}
+}
@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultLinkCacheSize is the default maximum number of link URL to UUID
+// mappings that are cached in memory.
+const defaultLinkCacheSize = 10000
+
+// linkCache is an LRU cache of tracked link URLs and their UUIDs that evicts
+// the least recently used links once it's full so that long-running
+// instances sending campaigns with many unique URLs don't grow unbounded.
+// It's safe for concurrent use.
+type linkCache struct {
+	max   int
+	items map[string]*list.Element
+	order *list.List
+	mut   sync.Mutex
+}
+
+type linkEntry struct {
+	url  string
+	uuid string
+}
+
+// newLinkCache returns a link cache that holds up to max entries. If max < 1,
+// defaultLinkCacheSize is used.
+func newLinkCache(max int) *linkCache {
+	if max < 1 {
+		max = defaultLinkCacheSize
+	}
+
+	return &linkCache{
+		max:   max,
+		items: make(map[string]*list.Element),
+		order: list.New(),
+	}
+}
+
+// get returns the UUID of a cached link and marks it as recently used.
+func (l *linkCache) get(url string) (string, bool) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	el, ok := l.items[url]
+	if !ok {
+		return "", false
+	}
+	l.order.MoveToFront(el)
+
+	return el.Value.(*linkEntry).uuid, true
+}
+
+// set caches a link's UUID, evicting the least recently used link if
+// the cache is full.
+func (l *linkCache) set(url, uuid string) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	if el, ok := l.items[url]; ok {
+		el.Value.(*linkEntry).uuid = uuid
+		l.order.MoveToFront(el)
+		return
+	}
+
+	l.items[url] = l.order.PushFront(&linkEntry{url: url, uuid: uuid})
+
+	if l.order.Len() > l.max {
+		el := l.order.Back()
+		l.order.Remove(el)
+		delete(l.items, el.Value.(*linkEntry).url)
+	}
+}
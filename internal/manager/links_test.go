@@ -0,0 +1,93 @@
+package manager
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestLinkCacheLRU(t *testing.T) {
+	l := newLinkCache(2)
+	l.set("a", "uuid-a")
+	l.set("b", "uuid-b")
+
+	// Using a makes b the least recently used link, which is evicted by c.
+	if uu, ok := l.get("a"); !ok || uu != "uuid-a" {
+		t.Fatalf("expected a to be cached, got %q", uu)
+	}
+	l.set("c", "uuid-c")
+
+	if _, ok := l.get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	for url, want := range map[string]string{"a": "uuid-a", "c": "uuid-c"} {
+		if uu, ok := l.get(url); !ok || uu != want {
+			t.Errorf("expected %s to be cached as %s, got %q", url, want, uu)
+		}
+	}
+
+	// Updating an existing link doesn't grow the cache.
+	l.set("a", "uuid-a2")
+	if uu, _ := l.get("a"); uu != "uuid-a2" || l.order.Len() != 2 {
+		t.Errorf("expected a to be updated in place, got %q with %d entries", uu, l.order.Len())
+	}
+
+	if l := newLinkCache(0); l.max != defaultLinkCacheSize {
+		t.Errorf("expected the default size, got %d", l.max)
+	}
+}
+
+func TestTrackLinkRegistersOnce(t *testing.T) {
+	st := newTestStore()
+	m, err := New(testConfig(), st, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if got := m.trackLink("https://listmonk.app?a=1&amp;b=2", "camp", "sub"); got != "http://listmonk.test/link/link-1/camp/sub" {
+			t.Fatalf("unexpected tracked link: %s", got)
+		}
+	}
+	if n := st.count("CreateLink"); n != 1 {
+		t.Errorf("expected the link to be registered once, got %d", n)
+	}
+	if _, ok := st.links["https://listmonk.app?a=1&b=2"]; !ok {
+		t.Error("expected the link to be registered unescaped")
+	}
+}
+
+func TestWarmLinkCache(t *testing.T) {
+	st := newTestStore()
+	st.links["https://listmonk.app"] = "link-seeded"
+
+	cfg := testConfig()
+	cfg.WarmLinkCache = true
+	m, msgr := newTestManager(t, cfg, st)
+
+	c := st.addCampaign(legacyTenantID, 1, 3, "email")
+	c.Body = `{{ TrackLink "https://listmonk.app" }}`
+	startManagerPipe(t, m, c)
+
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool {
+		return st.status(1) == models.CampaignStatusFinished
+	})
+
+	// The links are loaded once and every message is served from the cache.
+	if n := st.count("GetLinks"); n != 1 {
+		t.Errorf("expected the links to be loaded once, got %d", n)
+	}
+	if n := st.count("CreateLink"); n != 0 {
+		t.Errorf("expected no links to be registered, got %d", n)
+	}
+	for _, msg := range msgr.Sent() {
+		if !strings.Contains(string(msg.Body), "/link/link-seeded/camp-1/") {
+			t.Errorf("expected the cached link in the message, got %q", msg.Body)
+		}
+	}
+	if n := len(msgr.Sent()); n != 3 {
+		t.Errorf("expected 3 messages, got %d", n)
+	}
+}
@@ -1,13 +1,17 @@
 package manager
 
 import (
+	"context"
+	"database/sql"
 	"errors"
 	"fmt"
 	"html/template"
 	"log"
+	"net/mail"
 	"net/textproto"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"maps"
@@ -28,20 +32,62 @@ const (
 	ContentTpl = "content"
 
 	dummyUUID = "00000000-0000-0000-0000-000000000000"
+
+	// maxRequeues is the number of times a campaign message that failed
+	// to send is requeued when RequeueOnError is enabled.
+	maxRequeues = 3
+
+	// requeueBackoff is the wait before requeuing a failed message, which
+	// is multiplied by the number of retries so far.
+	requeueBackoff = time.Millisecond * 500
+
+	// defaultPushTimeout is the push timeout used when one isn't configured.
+	defaultPushTimeout = time.Second * 3
+
+	// defaultSoftBounceThreshold is the number of soft bounces after which a
+	// tenant's subscriber is blocklisted when a threshold isn't configured.
+	defaultSoftBounceThreshold = 3
+
+	// defaultTenantDiscoveryInterval is the interval at which the tenant manager
+	// discovers active tenants when one isn't configured.
+	defaultTenantDiscoveryInterval = time.Minute * 5
+
+	// defaultDrainTimeout is the time Close() waits for running campaigns
+	// to drain when one isn't configured.
+	defaultDrainTimeout = time.Second * 2
+
+	// legacyTenantID is the tenant that a Manager created with
+	// NewFromTenantStore() operates on.
+	legacyTenantID = 1
+
+	// ReasonNoSubscribers is the reason in the status notification (and tenant
+	// webhook) of a campaign that finished without sending to anyone as its
+	// lists had no matching subscribers.
+	ReasonNoSubscribers = "No subscribers to send to"
 )
 
+// finishableStatuses are the statuses of campaigns that can be marked as
+// finished once their subscribers are exhausted.
+var finishableStatuses = []string{models.CampaignStatusRunning, models.CampaignStatusScheduled}
+
 // Store represents a data backend, such as a database,
 // that provides subscriber and campaign records.
 type Store interface {
 	NextCampaigns(currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error)
-	NextSubscribers(campID, limit int) ([]models.Subscriber, error)
+	NextSubscribers(ctx context.Context, campID, limit int) ([]models.Subscriber, error)
 	GetCampaign(campID int) (*models.Campaign, error)
 	GetAttachment(mediaID int) (models.Attachment, error)
 	UpdateCampaignStatus(campID int, status string) error
+
+	// UpdateCampaignStatusFrom updates a campaign's status only if its current
+	// status is one of from, and reports whether it was updated.
+	UpdateCampaignStatusFrom(campID int, from []string, status string) (bool, error)
 	UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID int) error
 	CreateLink(url string) (string, error)
+	GetLinks(campUUIDs []string) (map[string]string, error)
 	BlocklistSubscriber(id int64) error
 	DeleteSubscriber(id int64) error
+	SaveArchive(campID int, body []byte) error
 }
 
 // TenantStore extends Store with tenant-aware operations for multi-tenant campaign processing.
@@ -50,13 +96,16 @@ type TenantStore interface {
 	// NextTenantCampaigns retrieves active campaigns for a specific tenant
 	NextTenantCampaigns(tenantID int, currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error)
 	// NextTenantSubscribers retrieves subscribers for a campaign within a tenant
-	NextTenantSubscribers(tenantID, campID, limit int) ([]models.Subscriber, error)
+	NextTenantSubscribers(ctx context.Context, tenantID, campID, limit int) ([]models.Subscriber, error)
 	// GetTenantCampaign fetches a campaign from a specific tenant
 	GetTenantCampaign(tenantID, campID int) (*models.Campaign, error)
 	// GetTenantSettings retrieves tenant-specific settings (SMTP, etc.)
 	GetTenantSettings(tenantID int) (map[string]interface{}, error)
 	// UpdateTenantCampaignStatus updates a campaign status within a tenant
 	UpdateTenantCampaignStatus(tenantID, campID int, status string) error
+	// UpdateTenantCampaignStatusFrom updates a tenant campaign's status only if
+	// its current status is one of from, and reports whether it was updated
+	UpdateTenantCampaignStatusFrom(tenantID, campID int, from []string, status string) (bool, error)
 	// UpdateTenantCampaignCounts updates campaign counts for a tenant-specific campaign
 	UpdateTenantCampaignCounts(tenantID, campID int, toSend int, sent int, lastSubID int) error
 	// CreateTenantLink creates a tracking link for a tenant
@@ -65,6 +114,37 @@ type TenantStore interface {
 	BlocklistTenantSubscriber(tenantID int, id int64) error
 	// DeleteTenantSubscriber deletes a subscriber within a tenant
 	DeleteTenantSubscriber(tenantID int, id int64) error
+	// GetActiveTenantIDs returns the IDs of active tenants that have running or scheduled campaigns
+	GetActiveTenantIDs() ([]int, error)
+	// GetTenantFeatures retrieves the features and limits of a tenant's plan
+	GetTenantFeatures(tenantID int) (models.TenantFeatures, error)
+	// SaveTenantArchive saves the rendered content of a tenant's campaign for the archive
+	SaveTenantArchive(tenantID, campID int, body []byte) error
+	// GetTenantUsage retrieves a tenant's current usage counted against its plan's limits
+	GetTenantUsage(tenantID int) (TenantUsage, error)
+	// RecordTenantBounce records a bounce for a tenant's subscriber and returns the
+	// number of bounces of the same type recorded for the subscriber, including this one
+	RecordTenantBounce(tenantID int, b models.Bounce) (int, error)
+	// GetTenantSuppressions retrieves the e-mails on a tenant's suppression list
+	GetTenantSuppressions(tenantID int) ([]string, error)
+	// GetTenantAdminEmails retrieves the e-mails of a tenant's owners and admins
+	GetTenantAdminEmails(tenantID int) ([]string, error)
+	// PauseTenantCampaigns pauses all of a tenant's running campaigns and returns their IDs
+	PauseTenantCampaigns(tenantID int) ([]int, error)
+}
+
+// TenantUsage is a tenant's current usage that's counted against the
+// limits of its plan.
+type TenantUsage struct {
+	CampaignsThisMonth int
+	Subscribers        int
+}
+
+// CacheWarmer is implemented by components that lazily load per-tenant
+// state (eg: the tenant SMTP emailer) and can pre-load it for tenants
+// that are about to run campaigns.
+type CacheWarmer interface {
+	WarmCache(tenantIDs []int)
 }
 
 // Messenger is an interface for a generic messaging backend,
@@ -79,6 +159,61 @@ type Messenger interface {
 // CampStats contains campaign stats like per minute send rate.
 type CampStats struct {
 	SendRate int
+
+	// Send errors across all of the tenant's campaigns since the tenant
+	// instance started and the last error message. Only set for tenants.
+	Errors    int
+	LastError string
+}
+
+// RunningCampaign is a campaign that's currently being processed along with
+// its live stats.
+type RunningCampaign struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Sent      int       `json:"sent"`
+	SendRate  int       `json:"send_rate"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+// Campaign status notification targets of tenants.
+const (
+	// TenantNotifyAdmins e-mails the tenant's owners and admins.
+	TenantNotifyAdmins = "admins"
+
+	// TenantNotifyWebhook only posts to the tenant's campaign status webhook.
+	TenantNotifyWebhook = "webhook"
+
+	// TenantNotifyNone turns off notifications.
+	TenantNotifyNone = "none"
+)
+
+// ErrNoDefaultTenant is returned by NewFromTenantStore() when the default
+// tenant that the single-tenant manager operates on doesn't exist.
+var ErrNoDefaultTenant = errors.New("default tenant does not exist")
+
+// ErrAttachmentsTooLarge is wrapped by the errors returned when the total size
+// of a campaign's attachments exceeds Config.MaxAttachmentBytes.
+var ErrAttachmentsTooLarge = errors.New("attachments exceed the maximum size")
+
+// TenantLoad is a snapshot of a tenant instance's queue pressure.
+type TenantLoad struct {
+	CampaignQueue    int `json:"campaign_queue"`
+	CampaignQueueCap int `json:"campaign_queue_cap"`
+	MessageQueue     int `json:"message_queue"`
+	MessageQueueCap  int `json:"message_queue_cap"`
+	Pipes            int `json:"pipes"`
+	Workers          int `json:"workers"`
+}
+
+// CampaignResult is the outcome of a single campaign message push that is
+// passed to the callback set with SetResultCallback().
+type CampaignResult struct {
+	CampaignID   int
+	SubscriberID int
+	TenantID     int
+	Success      bool
+	Error        string
 }
 
 // Manager handles the scheduling, processing, and queuing of campaigns
@@ -88,21 +223,58 @@ type Manager struct {
 	store      Store
 	i18n       *i18n.I18n
 	messengers map[string]Messenger
+
+	// The tenant aware store for tenant previews and bounces. It's nil unless
+	// the store passed to New() is one or the manager is created with
+	// NewFromTenantStore(), where store is an adapter around it.
+	tenantStore TenantStore
+
 	fnNotify   func(subject string, data any) error
+	fnResult   func(CampaignResult)
 	log        *log.Logger
 
 	// Campaigns that are currently running.
 	pipes    map[int]*pipe
 	pipesMut sync.RWMutex
 
+	// Last processed subscriber IDs of campaigns paused with PauseCampaign()
+	// that are used to resume them. This is guarded by pipesMut.
+	checkpoints map[int]uint64
+
+	// Per-campaign send rates (messages per minute) set with SetCampaignRate().
+	// This is guarded by pipesMut.
+	rates map[int]int
+
+	// Attachments of running campaigns that are loaded once when the pipe is
+	// created and reused for every message. This is guarded by pipesMut.
+	attachments map[int][]models.Attachment
+
+	// Per-campaign dry-run overrides set with SetCampaignDryRun(). This is
+	// guarded by pipesMut.
+	dryRuns map[int]bool
+
+	// Rendered messages of campaigns run in the dry-run mode.
+	dryRun dryRunLog
+
+	// pipesWg is released when every pipe has been cleaned up. Close() waits
+	// on it to drain running campaigns.
+	pipesWg sync.WaitGroup
+
+	// closing is set when Close() is called to stop picking up new campaigns
+	// and batches of subscribers.
+	closing atomic.Bool
+
+	// ctx is cancelled by Close() to interrupt in-flight subscriber fetches.
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	tpls    map[int]*models.Template
 	tplsMut sync.RWMutex
 
 	// Links generated using Track() are cached here so as to not query
-	// the database for the link UUID for every message sent. This has to
-	// be locked as it may be used externally when previewing campaigns.
-	links    map[string]string
-	linksMut sync.RWMutex
+	// the database for the link UUID for every message sent. The cache is
+	// locked internally as it may be used externally when previewing campaigns.
+	links *linkCache
 
 	nextPipes chan *pipe
 	campMsgQ  chan CampaignMessage
@@ -111,8 +283,13 @@ type Manager struct {
 	// Sliding window keeps track of the total number of messages sent in a period
 	// and on reaching the specified limit, waits until the window is over before
 	// sending further messages.
-	slidingCount int
-	slidingStart time.Time
+	sliding *slidingWindow
+
+	// Time of the day outside of which campaign messages aren't sent (nil if unrestricted).
+	window *sendWindow
+
+	// Total number of messages sent since the manager started.
+	sent atomic.Int64
 
 	tplFuncs template.FuncMap
 }
@@ -124,12 +301,30 @@ type TenantManager struct {
 	tenantStore   TenantStore
 	i18n          *i18n.I18n
 	fnNotify      func(tenantID int, subject string, data any) error
+	fnResult      func(CampaignResult)
 	log           *log.Logger
 
+	// Messengers registered on the manager. These are copied into every
+	// tenant instance, including the ones that are discovered later.
+	messengers    map[string]Messenger
+	messengersMut sync.RWMutex
+
+	// Caches that are warmed up for tenants with campaigns to process
+	// on every tenant discovery.
+	warmers []CacheWarmer
+
+	// Message budget shared by all tenant instances. nil if there's no
+	// global message rate.
+	budget *throttle
+
 	// Per-tenant managers for isolated processing
 	tenantManagers    map[int]*tenantInstanceManager
 	tenantManagersMut sync.RWMutex
 
+	// Set by Close(), guarded by tenantManagersMut, so that an in-flight
+	// discovery doesn't create instances after shutdown
+	closed bool
+
 	// Global template functions
 	tplFuncs template.FuncMap
 
@@ -139,37 +334,82 @@ type TenantManager struct {
 
 	// Control channels
 	shutdownCh chan struct{}
+	refreshCh  chan struct{}
 	wg         sync.WaitGroup
 }
 
 // tenantInstanceManager handles campaign processing for a single tenant
 type tenantInstanceManager struct {
-	tenantID   int
-	cfg        TenantConfig
-	store      TenantStore
-	messengers map[string]Messenger
-	i18n       *i18n.I18n
-	fnNotify   func(tenantID int, subject string, data any) error
-	log        *log.Logger
+	tenantID int
+	store    TenantStore
+	i18n     *i18n.I18n
+	fnNotify func(tenantID int, subject string, data any) error
+	fnResult func(CampaignResult)
+	log      *log.Logger
+
+	// Config that can be swapped by ReloadConfig() while the workers are
+	// reading it. Use config() to access it.
+	cfg    *TenantConfig
+	cfgMut sync.RWMutex
+
+	// Messengers can be added by the TenantManager while the workers
+	// are reading them.
+	messengers    map[string]Messenger
+	messengersMut sync.RWMutex
 
 	// Tenant-specific processing state
 	pipes    map[int]*tenantPipe
 	pipesMut sync.RWMutex
 
+	// Checkpoints of paused campaigns, guarded by pipesMut
+	checkpoints map[int]uint64
+
+	// Per-campaign send rates (messages per minute), guarded by pipesMut
+	rates map[int]int
+
+	// Attachments of running campaigns loaded once per pipe, guarded by pipesMut
+	attachments map[int][]models.Attachment
+
 	tpls    map[int]*models.Template
 	tplsMut sync.RWMutex
 
-	links    map[string]string
-	linksMut sync.RWMutex
+	links *linkCache
 
 	// Tenant-specific processing queues
 	nextPipes chan *tenantPipe
 	campMsgQ  chan TenantCampaignMessage
 	msgQ      chan models.Message
 
-	// Tenant-specific rate limiting
-	slidingCount int
-	slidingStart time.Time
+	// Tenant-specific rate limiting shared by all of the tenant's pipes
+	sliding *slidingWindow
+
+	// Tenant's time of the day restriction on sending (nil if unrestricted)
+	window *sendWindow
+
+	// Global message budget shared with the other tenant instances (nil if unlimited)
+	budget *throttle
+
+	// Total messages sent by this tenant instance
+	sent atomic.Int64
+
+	// Checks the tenant's plan limits before its campaigns are processed
+	limits *TenantLimitsEnforcer
+
+	// Campaigns that were skipped for exceeding the tenant's limits and have
+	// been notified about. This is only accessed by scanCampaigns().
+	limitSkipped map[int]bool
+
+	// Unix nano timestamp of when a worker last picked up a message from the
+	// queues, used to detect stuck workers
+	lastProcessedAt atomic.Int64
+
+	// Number of running workers
+	workers atomic.Int32
+
+	// Total send errors across the tenant's campaigns and the last error
+	errors     atomic.Int64
+	lastErr    string
+	lastErrMut sync.Mutex
 
 	// Lifecycle management
 	active    bool
@@ -177,6 +417,10 @@ type tenantInstanceManager struct {
 	stopCh    chan struct{}
 	wg        sync.WaitGroup
 
+	// ctx is cancelled on stop() to interrupt in-flight subscriber fetches
+	ctx    context.Context
+	cancel context.CancelFunc
+
 	tplFuncs template.FuncMap
 }
 
@@ -187,6 +431,8 @@ type TenantConfig struct {
 	
 	// Tenant-specific SMTP settings loaded from tenant_settings
 	TenantFromEmail      string
+	TenantFromName       string
+	TenantReplyTo        string
 	TenantSMTPHost       string
 	TenantSMTPPort       int
 	TenantSMTPUsername   string
@@ -200,6 +446,25 @@ type TenantConfig struct {
 	TenantOptinURL    string
 	TenantMessageURL  string
 	TenantArchiveURL  string
+	TenantUnsubMailto string
+
+	// Default headers (default_headers in tenant_settings) that are applied
+	// to all the tenant's campaign messages beneath the campaign's own headers.
+	TenantDefaultHeaders []map[string]string
+
+	// Where the tenant's campaign status notifications are sent
+	// (notifications.campaign_status in tenant_settings). One of TenantNotify*.
+	TenantNotify string
+
+	// Campaign status webhook (webhook.campaign_status_url in tenant_settings),
+	// posted to only if the tenant's plan has webhooks enabled.
+	TenantWebhooksEnabled bool
+	TenantWebhookURL      string
+	TenantWebhookSecret   string
+
+	// Whether the tenant's plan limits its templates to a safe subset of
+	// the template functions.
+	TenantRestrictTemplateFuncs bool
 	
 	// Tenant-specific limits and features
 	TenantMaxBatchSize     int
@@ -221,6 +486,13 @@ type CampaignMessage struct {
 	altBody  []byte
 	unsubURL string
 
+	// Number of times the message has been requeued after send errors.
+	retries int
+
+	// Whether the message has already been assigned a send slot by its
+	// campaign's throttle.
+	throttled bool
+
 	pipe *pipe
 }
 
@@ -237,6 +509,12 @@ type TenantCampaignMessage struct {
 	altBody  []byte
 	unsubURL string
 
+	// Number of times the message has been requeued after send errors.
+	retries int
+
+	// Whether the message has already been assigned a send slot by its campaign's throttle.
+	throttled bool
+
 	pipe *tenantPipe
 }
 
@@ -262,6 +540,11 @@ type Config struct {
 	RootURL               string
 	UnsubHeader           bool
 
+	// UnsubMailto is an optional e-mail address (eg: unsubscribe@domain.com)
+	// that's offered as a mailto: option in the List-Unsubscribe header
+	// alongside the one-click unsubscribe URL.
+	UnsubMailto string
+
 	// Interval to scan the DB for active campaign checkpoints.
 	ScanInterval time.Duration
 
@@ -271,9 +554,89 @@ type Config struct {
 	// (exposed to the internet, private etc.) where only one does campaign
 	// processing while the others handle other kinds of traffic.
 	ScanCampaigns bool
-}
 
-var pushTimeout = time.Second * 3
+	// TenantDiscoveryInterval is the interval at which the multi-tenant manager
+	// looks for tenants with campaigns to process.
+	TenantDiscoveryInterval time.Duration
+
+	// FallbackMessenger is the name of an optional messenger via which campaign
+	// messages are retried when their campaign's messenger fails to send them.
+	FallbackMessenger string
+
+	// DrainTimeout is the duration for which Close() waits for the messages
+	// queued by running campaigns to be processed before giving up.
+	DrainTimeout time.Duration
+
+	// PushTimeout is the duration for which PushMessage() and PushCampaignMessage()
+	// wait for room in the queues before timing out. Tenant campaigns wait as
+	// long before leaving the rest of a batch of subscribers for later.
+	PushTimeout time.Duration
+
+	// DryRun renders campaign messages and runs them through all the accounting
+	// but records them (see DryRunResults()) instead of sending them out.
+	// This can be overridden per campaign with SetCampaignDryRun().
+	DryRun bool
+
+	// SoftBounceThreshold is the number of soft bounces after which a tenant's
+	// subscriber is blocklisted by TenantManager.ProcessBounce(). Hard bounces
+	// and complaints blocklist subscribers immediately.
+	SoftBounceThreshold int
+
+	// MaxLinkCache is the maximum number of tracked link UUIDs that are cached
+	// in memory, after which the least recently used ones are evicted.
+	MaxLinkCache int
+
+	// WarmLinkCache pre-populates the link cache with a campaign's existing
+	// tracked links when it's picked up so that they aren't registered again
+	// after a restart.
+	WarmLinkCache bool
+
+	// MaxConcurrencyPerTenant is the ceiling on the number of campaign workers
+	// a single tenant can run, irrespective of its settings or plan. 0 means
+	// no ceiling.
+	MaxConcurrencyPerTenant int
+
+	// GlobalMessageRate is the maximum number of campaign messages per second
+	// that all tenants combined can send, on top of the per-tenant rates.
+	// 0 means no global limit.
+	GlobalMessageRate int
+
+	// StreamAttachments loads campaign attachments from the media store
+	// each time a message is sent instead of holding their blobs in memory
+	// for the campaign's duration. This trades memory for media store reads.
+	StreamAttachments bool
+
+	// MaxAttachmentBytes is the maximum total size of the attachments of a
+	// campaign's messages. Campaigns that exceed it are cancelled instead of
+	// every message being rejected by the provider. 0 disables the limit.
+	MaxAttachmentBytes int64
+
+	// AdaptiveBatch shrinks a campaign's subscriber batches (down to a tenth of
+	// the batch size) when the latency of its message pushes rises, and grows
+	// them back (up to the batch size) when it falls.
+	AdaptiveBatch bool
+
+	// EnforceUnsubFooter appends a footer with the unsubscribe link to
+	// rendered campaign messages that don't already have the link.
+	EnforceUnsubFooter bool
+
+	// AutoPlainText generates a plain text alt body from the rendered HTML
+	// of campaign messages that don't have an alt body of their own.
+	AutoPlainText bool
+
+	// SendWindowStart and SendWindowEnd (HH:MM) restrict the sending of campaign
+	// messages to a time of the day in SendWindowTimezone (local time if empty).
+	// Outside the window, campaigns wait without fetching subscribers. Tenants
+	// can override them with send_window_* in their settings.
+	SendWindowStart    string
+	SendWindowEnd      string
+	SendWindowTimezone string
+
+	// ExposeTenantHeaders adds the X-Tenant-ID header with the tenant's ID
+	// to tenants' outgoing campaign messages. It's off by default so as to
+	// not disclose internal tenant IDs to recipients.
+	ExposeTenantHeaders bool
+}
 
 // NewTenantManager returns a new instance of multi-tenant Manager.
 func NewTenantManager(cfg Config, store TenantStore, i *i18n.I18n, l *log.Logger) *TenantManager {
@@ -286,27 +649,43 @@ func NewTenantManager(cfg Config, store TenantStore, i *i18n.I18n, l *log.Logger
 	if cfg.MessageRate < 1 {
 		cfg.MessageRate = 1
 	}
+	if cfg.PushTimeout <= 0 {
+		cfg.PushTimeout = defaultPushTimeout
+	}
+	if cfg.TenantDiscoveryInterval <= 0 {
+		cfg.TenantDiscoveryInterval = defaultTenantDiscoveryInterval
+	}
 
 	tm := &TenantManager{
 		cfg:            cfg,
 		tenantStore:    store,
 		i18n:           i,
 		log:            l,
+		messengers:     make(map[string]Messenger),
 		tenantManagers: make(map[int]*tenantInstanceManager),
 		activeTenants:  make(map[int]bool),
 		shutdownCh:     make(chan struct{}),
-		fnNotify: func(tenantID int, subject string, data any) error {
-			return notifs.NotifySystem(subject, notifs.TplCampaignStatus, data, nil)
-		},
+		refreshCh:      make(chan struct{}, 1),
 	}
+	tm.fnNotify = tm.notifyTenantAdmins
 	tm.tplFuncs = tm.makeGenericFuncMap()
 
+	if cfg.GlobalMessageRate > 0 {
+		tm.budget = &throttle{}
+		tm.budget.setRate(cfg.GlobalMessageRate * 60)
+	}
+
 	return tm
 }
 
-// New returns a new instance of Mailer.
+// New returns a new instance of Mailer. An error is returned if the config
+// is invalid (see Config.Validate()).
 // This function maintains backward compatibility for single-tenant deployments.
-func New(cfg Config, store Store, i *i18n.I18n, l *log.Logger) *Manager {
+func New(cfg Config, store Store, i *i18n.I18n, l *log.Logger) (*Manager, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid campaign manager config: %w", err)
+	}
+
 	if cfg.BatchSize < 1 {
 		cfg.BatchSize = 1000
 	}
@@ -316,6 +695,12 @@ func New(cfg Config, store Store, i *i18n.I18n, l *log.Logger) *Manager {
 	if cfg.MessageRate < 1 {
 		cfg.MessageRate = 1
 	}
+	if cfg.PushTimeout <= 0 {
+		cfg.PushTimeout = defaultPushTimeout
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = defaultDrainTimeout
+	}
 
 	m := &Manager{
 		cfg:   cfg,
@@ -327,31 +712,58 @@ func New(cfg Config, store Store, i *i18n.I18n, l *log.Logger) *Manager {
 		log:          l,
 		messengers:   make(map[string]Messenger),
 		pipes:        make(map[int]*pipe),
+		checkpoints:  make(map[int]uint64),
+		rates:        make(map[int]int),
+		attachments:  make(map[int][]models.Attachment),
+		dryRuns:      make(map[int]bool),
 		tpls:         make(map[int]*models.Template),
-		links:        make(map[string]string),
+		links:        newLinkCache(cfg.MaxLinkCache),
 		nextPipes:    make(chan *pipe, 1000),
 		campMsgQ:     make(chan CampaignMessage, cfg.Concurrency*cfg.MessageRate*2),
 		msgQ:         make(chan models.Message, cfg.Concurrency*cfg.MessageRate*2),
-		slidingStart: time.Now(),
+		sliding:      newSlidingWindow(cfg),
 	}
 	m.tplFuncs = m.makeGnericFuncMap()
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	if ts, ok := store.(TenantStore); ok {
+		m.tenantStore = ts
+	}
+
+	win, err := newSendWindow(cfg)
+	if err != nil {
+		l.Printf("ignoring send window: %v", err)
+	}
+	m.window = win
 
 	l.Printf("initialized single-tenant campaign manager (legacy mode)")
-	return m
+	return m, nil
 }
 
 // NewFromTenantStore creates a Manager that uses a TenantStore but operates in single-tenant mode.
 // This provides backward compatibility while using the new tenant-aware store interface.
-func NewFromTenantStore(cfg Config, store TenantStore, i *i18n.I18n, l *log.Logger) *Manager {
+// All operations are on the default tenant, which must exist, or ErrNoDefaultTenant is returned.
+func NewFromTenantStore(cfg Config, store TenantStore, i *i18n.I18n, l *log.Logger) (*Manager, error) {
 	// Wrap the TenantStore to make it compatible with the legacy Store interface
 	legacyStore := &tenantStoreAdapter{
-		tenantStore: store,
-		defaultTenantID: 1, // Use tenant ID 1 as default for backward compatibility
+		tenantStore:     store,
+		defaultTenantID: legacyTenantID,
 	}
-	
-	m := New(cfg, legacyStore, i, l)
+
+	// Fail early instead of with opaque errors deep in the send loop.
+	if _, err := store.GetTenantFeatures(legacyTenantID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("%w (tenant %d)", ErrNoDefaultTenant, legacyTenantID)
+		}
+		return nil, legacyStore.wrap("error checking tenant", err)
+	}
+
+	m, err := New(cfg, legacyStore, i, l)
+	if err != nil {
+		return nil, err
+	}
+	m.tenantStore = store
 	l.Printf("initialized single-tenant campaign manager with tenant store adapter")
-	return m
+	return m, nil
 }
 
 // tenantStoreAdapter adapts a TenantStore to work with the legacy Store interface
@@ -361,19 +773,31 @@ type tenantStoreAdapter struct {
 	defaultTenantID int
 }
 
+// wrap adds the default tenant to the adapter's errors as the tenant is
+// otherwise invisible to the single-tenant manager that logs them.
+func (tsa *tenantStoreAdapter) wrap(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("default tenant (%d): %s: %w", tsa.defaultTenantID, op, err)
+}
+
 // NextCampaigns adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) NextCampaigns(currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error) {
-	return tsa.tenantStore.NextTenantCampaigns(tsa.defaultTenantID, currentIDs, sentCounts)
+	out, err := tsa.tenantStore.NextTenantCampaigns(tsa.defaultTenantID, currentIDs, sentCounts)
+	return out, tsa.wrap("error fetching campaigns", err)
 }
 
 // NextSubscribers adapts the tenant method to the legacy interface
-func (tsa *tenantStoreAdapter) NextSubscribers(campID, limit int) ([]models.Subscriber, error) {
-	return tsa.tenantStore.NextTenantSubscribers(tsa.defaultTenantID, campID, limit)
+func (tsa *tenantStoreAdapter) NextSubscribers(ctx context.Context, campID, limit int) ([]models.Subscriber, error) {
+	out, err := tsa.tenantStore.NextTenantSubscribers(ctx, tsa.defaultTenantID, campID, limit)
+	return out, tsa.wrap(fmt.Sprintf("error fetching subscribers of campaign %d", campID), err)
 }
 
 // GetCampaign adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) GetCampaign(campID int) (*models.Campaign, error) {
-	return tsa.tenantStore.GetTenantCampaign(tsa.defaultTenantID, campID)
+	out, err := tsa.tenantStore.GetTenantCampaign(tsa.defaultTenantID, campID)
+	return out, tsa.wrap(fmt.Sprintf("error fetching campaign %d", campID), err)
 }
 
 // GetAttachment uses the base store method
@@ -383,27 +807,61 @@ func (tsa *tenantStoreAdapter) GetAttachment(mediaID int) (models.Attachment, er
 
 // UpdateCampaignStatus adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) UpdateCampaignStatus(campID int, status string) error {
-	return tsa.tenantStore.UpdateTenantCampaignStatus(tsa.defaultTenantID, campID, status)
+	return tsa.wrap(fmt.Sprintf("error updating status of campaign %d", campID),
+		tsa.tenantStore.UpdateTenantCampaignStatus(tsa.defaultTenantID, campID, status))
+}
+
+// UpdateCampaignStatusFrom adapts the tenant method to the legacy interface
+func (tsa *tenantStoreAdapter) UpdateCampaignStatusFrom(campID int, from []string, status string) (bool, error) {
+	ok, err := tsa.tenantStore.UpdateTenantCampaignStatusFrom(tsa.defaultTenantID, campID, from, status)
+	return ok, tsa.wrap(fmt.Sprintf("error updating status of campaign %d", campID), err)
 }
 
 // UpdateCampaignCounts adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID int) error {
-	return tsa.tenantStore.UpdateTenantCampaignCounts(tsa.defaultTenantID, campID, toSend, sent, lastSubID)
+	return tsa.wrap(fmt.Sprintf("error updating counts of campaign %d", campID),
+		tsa.tenantStore.UpdateTenantCampaignCounts(tsa.defaultTenantID, campID, toSend, sent, lastSubID))
 }
 
 // CreateLink adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) CreateLink(url string) (string, error) {
-	return tsa.tenantStore.CreateTenantLink(tsa.defaultTenantID, url)
+	out, err := tsa.tenantStore.CreateTenantLink(tsa.defaultTenantID, url)
+	return out, tsa.wrap("error creating link", err)
+}
+
+// GetLinks uses the base store method
+func (tsa *tenantStoreAdapter) GetLinks(campUUIDs []string) (map[string]string, error) {
+	return tsa.tenantStore.GetLinks(campUUIDs)
 }
 
 // BlocklistSubscriber adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) BlocklistSubscriber(id int64) error {
-	return tsa.tenantStore.BlocklistTenantSubscriber(tsa.defaultTenantID, id)
+	return tsa.wrap(fmt.Sprintf("error blocklisting subscriber %d", id),
+		tsa.tenantStore.BlocklistTenantSubscriber(tsa.defaultTenantID, id))
 }
 
 // DeleteSubscriber adapts the tenant method to the legacy interface
 func (tsa *tenantStoreAdapter) DeleteSubscriber(id int64) error {
-	return tsa.tenantStore.DeleteTenantSubscriber(tsa.defaultTenantID, id)
+	return tsa.wrap(fmt.Sprintf("error deleting subscriber %d", id),
+		tsa.tenantStore.DeleteTenantSubscriber(tsa.defaultTenantID, id))
+}
+
+// SaveArchive adapts the tenant method to the legacy interface
+func (tsa *tenantStoreAdapter) SaveArchive(campID int, body []byte) error {
+	return tsa.wrap(fmt.Sprintf("error saving archive of campaign %d", campID),
+		tsa.tenantStore.SaveTenantArchive(tsa.defaultTenantID, campID, body))
+}
+
+// ArchiveCampaign saves the rendered content of a sent campaign for the
+// public archive.
+func (m *Manager) ArchiveCampaign(campID int, html []byte) error {
+	return m.store.SaveArchive(campID, html)
+}
+
+// SetResultCallback sets a callback that is invoked with the outcome of every
+// campaign message push. It should be called before Run().
+func (m *Manager) SetResultCallback(fn func(CampaignResult)) {
+	m.fnResult = fn
 }
 
 // AddMessenger adds a Messenger messaging backend to the manager.
@@ -420,7 +878,11 @@ func (m *Manager) AddMessenger(msg Messenger) error {
 // PushMessage pushes an arbitrary non-campaign Message to be sent out by the workers.
 // It times out if the queue is busy.
 func (m *Manager) PushMessage(msg models.Message) error {
-	t := time.NewTicker(pushTimeout)
+	if m.closing.Load() {
+		return errors.New("campaign manager is shutting down")
+	}
+
+	t := time.NewTicker(m.cfg.PushTimeout)
 	defer t.Stop()
 
 	select {
@@ -436,13 +898,19 @@ func (m *Manager) PushMessage(msg models.Message) error {
 // PushCampaignMessage pushes a campaign messages into a queue to be sent out by the workers.
 // It times out if the queue is busy.
 func (m *Manager) PushCampaignMessage(msg CampaignMessage) error {
-	t := time.NewTicker(pushTimeout)
+	if m.closing.Load() {
+		return errors.New("campaign manager is shutting down")
+	}
+
+	t := time.NewTicker(m.cfg.PushTimeout)
 	defer t.Stop()
 
 	// Load any media/attachments.
-	if err := m.attachMedia(msg.Campaign); err != nil {
+	c, err := m.attachMedia(msg.Campaign)
+	if err != nil {
 		return err
 	}
+	msg.Campaign = c
 
 	select {
 	case m.campMsgQ <- msg:
@@ -463,21 +931,41 @@ func (m *Manager) HasMessenger(id string) bool {
 
 // HasRunningCampaigns checks if there are any active campaigns.
 func (m *Manager) HasRunningCampaigns() bool {
-	m.pipesMut.Lock()
-	defer m.pipesMut.Unlock()
+	m.pipesMut.RLock()
+	defer m.pipesMut.RUnlock()
 
 	return len(m.pipes) > 0
 }
 
+// RunningCampaigns returns the campaigns that are currently being processed.
+// Sent includes the messages sent before the campaign was last (re)started.
+func (m *Manager) RunningCampaigns() []RunningCampaign {
+	m.pipesMut.RLock()
+	defer m.pipesMut.RUnlock()
+
+	out := make([]RunningCampaign, 0, len(m.pipes))
+	for _, p := range m.pipes {
+		out = append(out, RunningCampaign{
+			ID:        p.camp.ID,
+			Name:      p.camp.Name,
+			Sent:      p.camp.Sent + int(p.sent.Load()),
+			SendRate:  int(p.rate.Rate()),
+			StartedAt: p.started,
+		})
+	}
+
+	return out
+}
+
 // GetCampaignStats returns campaign statistics.
 func (m *Manager) GetCampaignStats(id int) CampStats {
 	n := 0
 
-	m.pipesMut.Lock()
+	m.pipesMut.RLock()
 	if c, ok := m.pipes[id]; ok {
 		n = int(c.rate.Rate())
 	}
-	m.pipesMut.Unlock()
+	m.pipesMut.RUnlock()
 
 	return CampStats{SendRate: n}
 }
@@ -609,6 +1097,42 @@ func (m *Manager) GenericTemplateFuncs() template.FuncMap {
 	return m.tplFuncs
 }
 
+// PreviewTenantCampaign compiles and renders a campaign message for a tenant
+// with the tenant's template functions, so that the unsubscribe, opt-in,
+// archive and other URLs in the preview point to the tenant and not to the
+// global instance.
+func (m *Manager) PreviewTenantCampaign(tenantID int, c *models.Campaign, sub models.Subscriber) ([]byte, error) {
+	store := m.tenantStore
+	if store == nil {
+		return nil, errors.New("tenant previews require a tenant aware store")
+	}
+
+	cfg := TenantConfig{Config: m.cfg, TenantID: tenantID}
+	cfg.setTenantURLs(m.cfg.RootURL)
+
+	// A throwaway instance that isn't started. It only renders the message.
+	tim := &tenantInstanceManager{
+		tenantID: tenantID,
+		store:    store,
+		i18n:     m.i18n,
+		log:      m.log,
+		cfg:      &cfg,
+		links:    newLinkCache(cfg.MaxLinkCache),
+		tplFuncs: m.tplFuncs,
+	}
+
+	if err := c.CompileTemplate(tim.TemplateFuncs(c)); err != nil {
+		return nil, err
+	}
+
+	msg, err := tim.NewTenantCampaignMessage(c, sub)
+	if err != nil {
+		return nil, err
+	}
+
+	return msg.Body(), nil
+}
+
 // StopCampaign marks a running campaign as stopped so that all its queued messages are ignored.
 func (m *Manager) StopCampaign(id int) {
 	m.pipesMut.RLock()
@@ -618,20 +1142,211 @@ func (m *Manager) StopCampaign(id int) {
 	m.pipesMut.RUnlock()
 }
 
-// Close closes and exits the campaign manager.
-func (m *Manager) Close() {
+// StopTenantCampaign stops a running campaign. It exists for the Manager to
+// satisfy ManagerInterface, and as there's only one tenant, tenantID is ignored.
+func (m *Manager) StopTenantCampaign(tenantID, campID int) {
+	m.StopCampaign(campID)
+}
+
+// SetCampaignRate sets the maximum number of messages per minute for a campaign,
+// overriding the global message rate. This applies to the campaign if it's
+// running and when it's started later. A rate < 1 removes the override.
+func (m *Manager) SetCampaignRate(id, rate int) {
+	m.pipesMut.Lock()
+	defer m.pipesMut.Unlock()
+
+	if rate < 1 {
+		delete(m.rates, id)
+	} else {
+		m.rates[id] = rate
+	}
+
+	if p, ok := m.pipes[id]; ok {
+		p.throttle.setRate(rate)
+	}
+}
+
+// PauseCampaign pauses a running campaign. No further subscribers are fetched
+// and queued messages are ignored, but the campaign's progress is preserved
+// so that it can be picked up again with ResumeCampaign().
+func (m *Manager) PauseCampaign(id int) error {
+	m.pipesMut.RLock()
+	p, ok := m.pipes[id]
+	m.pipesMut.RUnlock()
+	if !ok {
+		return fmt.Errorf("campaign %d is not running", id)
+	}
+
+	// Mark the campaign as paused in the DB first so that it's not picked up
+	// again by the campaign scanner once its pipe is cleaned up.
+	if err := m.store.UpdateCampaignStatus(id, models.CampaignStatusPaused); err != nil {
+		return err
+	}
+
+	p.Pause()
+	return nil
+}
+
+// CancelCampaign cancels a campaign, setting its status to cancelled right
+// away instead of leaving it to the pipe's cleanup. If the campaign is running,
+// its pipe is stopped and its messages that are still in the queue are dropped
+// by the workers instead of being sent. A paused campaign's checkpoint is discarded.
+func (m *Manager) CancelCampaign(id int) error {
+	if err := m.store.UpdateCampaignStatus(id, models.CampaignStatusCancelled); err != nil {
+		return err
+	}
+
+	m.pipesMut.Lock()
+	p, ok := m.pipes[id]
+	delete(m.checkpoints, id)
+	m.pipesMut.Unlock()
+
+	if ok {
+		p.Cancel()
+	}
+	return nil
+}
+
+// ResumeCampaign resumes a paused campaign by re-creating its pipe at the
+// last processed subscriber checkpoint.
+func (m *Manager) ResumeCampaign(id int) error {
+	m.pipesMut.RLock()
+	_, ok := m.pipes[id]
+	m.pipesMut.RUnlock()
+	if ok {
+		return fmt.Errorf("campaign %d is already running", id)
+	}
+
+	c, err := m.store.GetCampaign(id)
+	if err != nil {
+		return err
+	}
+	if c.Status != models.CampaignStatusPaused {
+		return fmt.Errorf("campaign %s is not paused", c.Name)
+	}
+	if m.closing.Load() {
+		return errors.New("campaign manager is shutting down")
+	}
+
+	p, err := m.newPipe(c)
+	if err != nil {
+		return err
+	}
+
+	// Seed the pipe with the checkpoint so that cleanup() doesn't reset
+	// the campaign's progress if no messages are sent after resuming.
+	m.pipesMut.Lock()
+	if lastID, ok := m.checkpoints[id]; ok {
+		p.lastID.Store(lastID)
+		delete(m.checkpoints, id)
+	}
+	m.pipesMut.Unlock()
+
+	if err := m.store.UpdateCampaignStatus(id, models.CampaignStatusRunning); err != nil {
+		// Release the pipe, retaining the checkpoint.
+		p.Pause()
+		p.wg.Done()
+		return err
+	}
+	c.Status = models.CampaignStatusRunning
+
+	m.log.Printf("resume processing campaign (%s)", c.Name)
+	m.nextPipes <- p
+	return nil
+}
+
+// flushMessengers flushes all the messengers so that batching messengers
+// send the messages that they've buffered.
+func (m *Manager) flushMessengers() {
+	for name, msgr := range m.messengers {
+		if err := msgr.Flush(); err != nil {
+			m.log.Printf("error flushing messenger %s: %v", name, err)
+		}
+	}
+}
+
+// Close gracefully shuts down the campaign manager. It stops picking up new
+// campaigns and batches of subscribers, waits for the messages already queued
+// by running campaigns to be processed, and then closes the queues. If that
+// doesn't happen within the drain timeout, an error is returned and the queues
+// are left open so that the remaining messages don't end up on closed channels.
+// The messengers are flushed in either case.
+func (m *Manager) Close() error {
+	if !m.closing.CompareAndSwap(false, true) {
+		return nil
+	}
+
+	// Flush whatever the messengers have buffered, even if draining times out.
+	defer m.flushMessengers()
+
+	// Interrupt any subscriber fetch that's in progress. No new ones are
+	// started once closing is set.
+	m.cancel()
+
+	deadline := time.After(m.cfg.DrainTimeout)
+
+	// Wait for the running campaigns to finish processing their queued messages.
+	drained := make(chan struct{})
+	go func() {
+		m.pipesWg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+	case <-deadline:
+		return errors.New("timed out waiting for running campaigns to drain")
+	}
+
+	// Wait for the workers to pick up any remaining (arbitrary) messages.
+	t := time.NewTicker(time.Millisecond * 50)
+	defer t.Stop()
+	for len(m.campMsgQ) > 0 || len(m.msgQ) > 0 {
+		select {
+		case <-t.C:
+		case <-deadline:
+			return errors.New("timed out waiting for the message queues to drain")
+		}
+	}
+
 	close(m.nextPipes)
+	close(m.campMsgQ)
 	close(m.msgQ)
+	return nil
 }
 
 // TenantManager Methods
 
-// AddMessenger adds a Messenger to all tenant instances.
+// AddCacheWarmer registers a cache that's warmed up for the tenants with
+// campaigns to process on every tenant discovery.
+func (tm *TenantManager) AddCacheWarmer(w CacheWarmer) {
+	tm.messengersMut.Lock()
+	tm.warmers = append(tm.warmers, w)
+	tm.messengersMut.Unlock()
+}
+
+// SetResultCallback sets a callback that is invoked with the outcome of every
+// campaign message push across all tenants. It should be called before Run().
+func (tm *TenantManager) SetResultCallback(fn func(CampaignResult)) {
+	tm.fnResult = fn
+}
+
+// AddMessenger adds a Messenger to the manager and all tenant instances.
+// Tenant instances that are created later also receive the messenger.
 func (tm *TenantManager) AddMessenger(msg Messenger) error {
+	id := msg.Name()
+
+	tm.messengersMut.Lock()
+	if _, ok := tm.messengers[id]; ok {
+		tm.messengersMut.Unlock()
+		return fmt.Errorf("messenger '%s' is already loaded", id)
+	}
+	tm.messengers[id] = msg
+	tm.messengersMut.Unlock()
+
 	tm.tenantManagersMut.RLock()
 	defer tm.tenantManagersMut.RUnlock()
 
-	id := msg.Name()
 	// Add to all existing tenant managers
 	for _, t := range tm.tenantManagers {
 		if err := t.AddMessenger(msg); err != nil {
@@ -660,50 +1375,287 @@ func (tm *TenantManager) Run() {
 }
 
 // Close closes the tenant manager and all tenant instances.
-func (tm *TenantManager) Close() {
+func (tm *TenantManager) Close() error {
 	close(tm.shutdownCh)
 
-	// Stop all tenant instances
+	// Detach the instances under the lock, but stop them outside it as
+	// stop() blocks on the instances' workers, which would otherwise hold
+	// up (or deadlock with) discovery and the other users of the lock.
 	tm.tenantManagersMut.Lock()
-	for _, t := range tm.tenantManagers {
-		t.stop()
+	tm.closed = true
+	instances := make([]*tenantInstanceManager, 0, len(tm.tenantManagers))
+	for id, t := range tm.tenantManagers {
+		instances = append(instances, t)
+		delete(tm.tenantManagers, id)
 	}
 	tm.tenantManagersMut.Unlock()
 
+	for _, t := range instances {
+		t.stop()
+	}
+
 	tm.wg.Wait()
+	return nil
 }
 
-// GetTenantCampaignStats returns campaign stats for a specific tenant.
-func (tm *TenantManager) GetTenantCampaignStats(tenantID, campID int) CampStats {
-	tm.tenantManagersMut.RLock()
-	defer tm.tenantManagersMut.RUnlock()
+// notifyTenantAdmins is the default notification function of tenants that
+// e-mails campaign status notifications to the tenant's owners and admins
+// instead of the instance's system e-mails.
+func (tm *TenantManager) notifyTenantAdmins(tenantID int, subject string, data any) error {
+	emails, err := tm.tenantStore.GetTenantAdminEmails(tenantID)
+	if err != nil {
+		tm.log.Printf("tenant %d: error fetching admin e-mails for notification: %v", tenantID, err)
+		return err
+	}
+	if len(emails) == 0 {
+		tm.log.Printf("tenant %d: no owners or admins to notify: %s", tenantID, subject)
+		return nil
+	}
 
-	if t, exists := tm.tenantManagers[tenantID]; exists {
-		return t.GetCampaignStats(campID)
+	return notifs.Notify(emails, subject, notifs.TplCampaignStatus, data, nil)
+}
+
+// TenantLoad returns the manager's queue depths, running campaign pipes and
+// workers like TenantManager.TenantLoad(). A Manager sends for a single
+// tenant, the default one, under which its load is reported.
+func (m *Manager) TenantLoad() map[int]TenantLoad {
+	m.pipesMut.RLock()
+	pipes := len(m.pipes)
+	m.pipesMut.RUnlock()
+
+	return map[int]TenantLoad{
+		legacyTenantID: {
+			CampaignQueue:    len(m.campMsgQ),
+			CampaignQueueCap: cap(m.campMsgQ),
+			MessageQueue:     len(m.msgQ),
+			MessageQueueCap:  cap(m.msgQ),
+			Pipes:            pipes,
+			Workers:          m.cfg.Concurrency,
+		},
 	}
-	return CampStats{SendRate: 0}
 }
 
-// HasRunningCampaigns checks if any tenant has active campaigns.
-func (tm *TenantManager) HasRunningCampaigns() bool {
+// TenantLoad returns the queue depths, running campaign pipes and workers
+// of every tenant instance, which shows the tenants that are saturated and
+// may need their concurrency raised or their sending throttled.
+func (tm *TenantManager) TenantLoad() map[int]TenantLoad {
 	tm.tenantManagersMut.RLock()
 	defer tm.tenantManagersMut.RUnlock()
 
-	for _, t := range tm.tenantManagers {
-		if t.HasRunningCampaigns() {
-			return true
-		}
+	out := make(map[int]TenantLoad, len(tm.tenantManagers))
+	for id, t := range tm.tenantManagers {
+		out[id] = t.load()
 	}
-	return false
+
+	return out
 }
 
-// StopTenantCampaign stops a campaign for a specific tenant.
-func (tm *TenantManager) StopTenantCampaign(tenantID, campID int) {
+// GetTenantCampaignStats returns campaign stats for a specific tenant.
+func (tm *TenantManager) GetTenantCampaignStats(tenantID, campID int) CampStats {
 	tm.tenantManagersMut.RLock()
 	defer tm.tenantManagersMut.RUnlock()
 
 	if t, exists := tm.tenantManagers[tenantID]; exists {
-		t.StopCampaign(campID)
+		out := t.GetCampaignStats(campID)
+		out.Errors, out.LastError = t.errorStats()
+		return out
+	}
+	return CampStats{SendRate: 0}
+}
+
+// RunningCampaigns returns a tenant's campaigns that are currently being processed.
+func (tm *TenantManager) RunningCampaigns(tenantID int) []RunningCampaign {
+	tm.tenantManagersMut.RLock()
+	defer tm.tenantManagersMut.RUnlock()
+
+	if t, exists := tm.tenantManagers[tenantID]; exists {
+		return t.RunningCampaigns()
+	}
+	return []RunningCampaign{}
+}
+
+// ArchiveTenantCampaign saves the rendered content of a tenant's sent campaign
+// for the public archive.
+func (tm *TenantManager) ArchiveTenantCampaign(tenantID, campID int, html []byte) error {
+	return tm.tenantStore.SaveTenantArchive(tenantID, campID, html)
+}
+
+// ProcessBounce records a bounce for a tenant's subscriber and blocklists the
+// subscriber on a hard bounce or a complaint, or once their soft bounces
+// reach Config.SoftBounceThreshold.
+func (tm *TenantManager) ProcessBounce(tenantID int, b models.Bounce) error {
+	return processTenantBounce(tm.tenantStore, tm.cfg.SoftBounceThreshold, tm.log, tenantID, b)
+}
+
+// ProcessTenantBounce records a bounce for a tenant's subscriber the same way
+// as TenantManager.ProcessBounce(). It's for single-tenant setups that serve
+// tenants' bounce webhooks without running a TenantManager.
+func (m *Manager) ProcessTenantBounce(tenantID int, b models.Bounce) error {
+	if m.tenantStore == nil {
+		return errors.New("tenant bounces require a tenant aware store")
+	}
+
+	return processTenantBounce(m.tenantStore, m.cfg.SoftBounceThreshold, m.log, tenantID, b)
+}
+
+// processTenantBounce records a tenant bounce and blocklists the subscriber
+// if the bounce type or the number of soft bounces calls for it.
+func processTenantBounce(st TenantStore, threshold int, lo *log.Logger, tenantID int, b models.Bounce) error {
+	if b.SubscriberID < 1 {
+		return errors.New("bounce has no subscriber ID")
+	}
+
+	num, err := st.RecordTenantBounce(tenantID, b)
+	if err != nil {
+		return fmt.Errorf("tenant %d: error recording bounce for subscriber %d: %v", tenantID, b.SubscriberID, err)
+	}
+
+	if threshold < 1 {
+		threshold = defaultSoftBounceThreshold
+	}
+
+	if b.Type == models.BounceTypeSoft && num < threshold {
+		return nil
+	}
+
+	if err := st.BlocklistTenantSubscriber(tenantID, int64(b.SubscriberID)); err != nil {
+		return fmt.Errorf("tenant %d: error blocklisting subscriber %d: %v", tenantID, b.SubscriberID, err)
+	}
+	lo.Printf("tenant %d: blocklisted subscriber %d after %d %s bounce(s)", tenantID, b.SubscriberID, num, b.Type)
+
+	return nil
+}
+
+// HasRunningCampaigns checks if any tenant has active campaigns.
+func (tm *TenantManager) HasRunningCampaigns() bool {
+	tm.tenantManagersMut.RLock()
+	defer tm.tenantManagersMut.RUnlock()
+
+	for _, t := range tm.tenantManagers {
+		if t.HasRunningCampaigns() {
+			return true
+		}
+	}
+	return false
+}
+
+// StopTenantCampaign stops a campaign for a specific tenant.
+func (tm *TenantManager) StopTenantCampaign(tenantID, campID int) {
+	tm.tenantManagersMut.RLock()
+	defer tm.tenantManagersMut.RUnlock()
+
+	if t, exists := tm.tenantManagers[tenantID]; exists {
+		t.StopCampaign(campID)
+	}
+}
+
+// ReloadTenantConfig reloads a running tenant instance's config from the
+// tenant's settings, for instance, after they've been updated.
+func (tm *TenantManager) ReloadTenantConfig(tenantID int) error {
+	tm.tenantManagersMut.RLock()
+	t, exists := tm.tenantManagers[tenantID]
+	tm.tenantManagersMut.RUnlock()
+	if !exists {
+		return nil
+	}
+
+	cfg, err := tm.loadTenantConfig(tenantID)
+	if err != nil {
+		return fmt.Errorf("failed to load tenant config: %v", err)
+	}
+
+	t.ReloadConfig(cfg)
+	return nil
+}
+
+// StopAllTenantCampaigns pauses all of a tenant's running campaigns, for
+// instance, when the tenant is suspended. The campaigns are paused in the
+// store first so that they're not picked up again, and then the pipes of the
+// ones that are being processed are stopped retaining their progress.
+func (tm *TenantManager) StopAllTenantCampaigns(tenantID int) error {
+	if _, err := tm.tenantStore.PauseTenantCampaigns(tenantID); err != nil {
+		return err
+	}
+
+	tm.tenantManagersMut.RLock()
+	defer tm.tenantManagersMut.RUnlock()
+
+	if t, exists := tm.tenantManagers[tenantID]; exists {
+		t.StopAllCampaigns()
+	}
+	return nil
+}
+
+// SetTenantCampaignRate sets the maximum number of messages per minute for a
+// tenant's campaign. A rate < 1 removes the override.
+func (tm *TenantManager) SetTenantCampaignRate(tenantID, campID, rate int) error {
+	tm.tenantManagersMut.RLock()
+	t, exists := tm.tenantManagers[tenantID]
+	tm.tenantManagersMut.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("tenant %d is not active", tenantID)
+	}
+
+	t.SetCampaignRate(campID, rate)
+	return nil
+}
+
+// PauseTenantCampaign pauses a running campaign for a specific tenant.
+func (tm *TenantManager) PauseTenantCampaign(tenantID, campID int) error {
+	tm.tenantManagersMut.RLock()
+	t, exists := tm.tenantManagers[tenantID]
+	tm.tenantManagersMut.RUnlock()
+
+	if !exists {
+		return fmt.Errorf("campaign %d is not running for tenant %d", campID, tenantID)
+	}
+	return t.PauseCampaign(campID)
+}
+
+// CancelTenantCampaign cancels a campaign of a specific tenant. The tenant
+// may not have a running instance, eg: if the campaign was paused and the
+// instance removed, in which case only the campaign's status is updated.
+func (tm *TenantManager) CancelTenantCampaign(tenantID, campID int) error {
+	tm.tenantManagersMut.RLock()
+	t, exists := tm.tenantManagers[tenantID]
+	tm.tenantManagersMut.RUnlock()
+
+	if !exists {
+		return tm.tenantStore.UpdateTenantCampaignStatus(tenantID, campID, models.CampaignStatusCancelled)
+	}
+	return t.CancelCampaign(campID)
+}
+
+// ResumeTenantCampaign resumes a paused campaign for a specific tenant. As the
+// tenant's instance may have been removed while it had no running campaigns,
+// it's created if necessary.
+func (tm *TenantManager) ResumeTenantCampaign(tenantID, campID int) error {
+	tm.tenantManagersMut.Lock()
+	if tm.closed {
+		tm.tenantManagersMut.Unlock()
+		return errors.New("tenant manager is closed")
+	}
+
+	t, exists := tm.tenantManagers[tenantID]
+	if !exists {
+		if err := tm.createTenantInstance(tenantID); err != nil {
+			tm.tenantManagersMut.Unlock()
+			return fmt.Errorf("failed to create tenant instance %d: %v", tenantID, err)
+		}
+		t = tm.tenantManagers[tenantID]
+	}
+	tm.tenantManagersMut.Unlock()
+
+	return t.ResumeCampaign(campID)
+}
+
+// RefreshTenants triggers an immediate tenant discovery pass without waiting
+// for the discovery interval. If a pass is already pending, this is a no-op.
+func (tm *TenantManager) RefreshTenants() {
+	select {
+	case tm.refreshCh <- struct{}{}:
+	default:
 	}
 }
 
@@ -712,7 +1664,7 @@ func (tm *TenantManager) manageTenants() {
 	defer tm.wg.Done()
 
 	// Discover active tenants periodically
-	ticker := time.NewTicker(5 * time.Minute)
+	ticker := time.NewTicker(tm.cfg.TenantDiscoveryInterval)
 	defer ticker.Stop()
 
 	// Initial tenant discovery
@@ -722,6 +1674,8 @@ func (tm *TenantManager) manageTenants() {
 		select {
 		case <-ticker.C:
 			tm.discoverActiveTenants()
+		case <-tm.refreshCh:
+			tm.discoverActiveTenants()
 		case <-tm.shutdownCh:
 			return
 		}
@@ -730,19 +1684,39 @@ func (tm *TenantManager) manageTenants() {
 
 // discoverActiveTenants finds active tenants and creates instances.
 func (tm *TenantManager) discoverActiveTenants() {
-	// Get list of active tenants from database
-	// This would need to be implemented in the TenantStore
-	tenantIDs, err := tm.getActiveTenantIDs()
+	// Get list of active tenants with campaigns to process from the database
+	tenantIDs, err := tm.tenantStore.GetActiveTenantIDs()
 	if err != nil {
 		tm.log.Printf("error discovering active tenants: %v", err)
 		return
 	}
 
+	// Pre-load the tenants' lazily loaded state before their campaigns
+	// start sending.
+	tm.messengersMut.RLock()
+	warmers := tm.warmers
+	tm.messengersMut.RUnlock()
+	for _, w := range warmers {
+		w.WarmCache(tenantIDs)
+	}
+
+	// Instances removed below are stopped after the locks are released.
+	var removed []*tenantInstanceManager
+	defer func() {
+		for _, t := range removed {
+			t.stop()
+		}
+	}()
+
 	tm.activeTenantsMut.Lock()
 	tm.tenantManagersMut.Lock()
 	defer tm.activeTenantsMut.Unlock()
 	defer tm.tenantManagersMut.Unlock()
 
+	if tm.closed {
+		return
+	}
+
 	// Add new tenants
 	for _, tenantID := range tenantIDs {
 		if !tm.activeTenants[tenantID] {
@@ -766,7 +1740,12 @@ func (tm *TenantManager) discoverActiveTenants() {
 		}
 		if !found {
 			if t, exists := tm.tenantManagers[tenantID]; exists {
-				t.stop()
+				// Let in-flight campaigns drain before tearing the instance down.
+				if t.HasRunningCampaigns() {
+					continue
+				}
+
+				removed = append(removed, t)
 				delete(tm.tenantManagers, tenantID)
 				delete(tm.activeTenants, tenantID)
 				tm.log.Printf("removed tenant manager instance for tenant %d", tenantID)
@@ -783,27 +1762,52 @@ func (tm *TenantManager) createTenantInstance(tenantID int) error {
 		return fmt.Errorf("failed to load tenant config: %v", err)
 	}
 
+	win, err := newSendWindow(tenantCfg.Config)
+	if err != nil {
+		tm.log.Printf("tenant %d: ignoring send window: %v", tenantID, err)
+	}
+
 	// Create tenant instance
 	instance := &tenantInstanceManager{
 		tenantID:     tenantID,
-		cfg:          tenantCfg,
+		cfg:          &tenantCfg,
 		store:        tm.tenantStore,
 		i18n:         tm.i18n,
 		fnNotify:     tm.fnNotify,
+		fnResult:     tm.fnResult,
 		log:          tm.log,
+		limits:       NewTenantLimitsEnforcer(tm.tenantStore),
+		limitSkipped: make(map[int]bool),
 		pipes:        make(map[int]*tenantPipe),
+		checkpoints:  make(map[int]uint64),
+		rates:        make(map[int]int),
+		attachments:  make(map[int][]models.Attachment),
 		tpls:         make(map[int]*models.Template),
-		links:        make(map[string]string),
+		links:        newLinkCache(tenantCfg.MaxLinkCache),
 		nextPipes:    make(chan *tenantPipe, 1000),
 		campMsgQ:     make(chan TenantCampaignMessage, tenantCfg.Concurrency*tenantCfg.MessageRate*2),
 		msgQ:         make(chan models.Message, tenantCfg.Concurrency*tenantCfg.MessageRate*2),
-		slidingStart: time.Now(),
+		sliding:      newSlidingWindow(tenantCfg.Config),
+		window:       win,
+		budget:       tm.budget,
 		active:       true,
 		stopCh:       make(chan struct{}),
 		messengers:   make(map[string]Messenger),
 		tplFuncs:     tm.tplFuncs,
 	}
 
+	if tenantCfg.TenantRestrictTemplateFuncs {
+		instance.tplFuncs = restrictFuncMap(tm.tplFuncs)
+	}
+
+	instance.lastProcessedAt.Store(time.Now().UnixNano())
+	instance.ctx, instance.cancel = context.WithCancel(context.Background())
+
+	// Copy the registered messengers into the new instance
+	tm.messengersMut.RLock()
+	maps.Copy(instance.messengers, tm.messengers)
+	tm.messengersMut.RUnlock()
+
 	// Start tenant instance
 	instance.wg.Add(1)
 	go instance.run()
@@ -812,14 +1816,6 @@ func (tm *TenantManager) createTenantInstance(tenantID int) error {
 	return nil
 }
 
-// getActiveTenantIDs retrieves list of active tenant IDs.
-// This would need to be implemented based on your tenant discovery logic.
-func (tm *TenantManager) getActiveTenantIDs() ([]int, error) {
-	// This is a placeholder - you would implement this to query the database
-	// for active tenants that have campaigns to process
-	return []int{1}, nil // Return default tenant for now
-}
-
 // loadTenantConfig loads tenant-specific configuration.
 func (tm *TenantManager) loadTenantConfig(tenantID int) (TenantConfig, error) {
 	// Load tenant settings from database
@@ -841,6 +1837,18 @@ func (tm *TenantManager) loadTenantConfig(tenantID int) (TenantConfig, error) {
 		tenantCfg.TenantFromEmail = tm.cfg.FromEmail
 	}
 
+	if fromName, ok := settings["from_name"].(string); ok {
+		tenantCfg.TenantFromName = strings.TrimSpace(fromName)
+	}
+
+	if replyTo, ok := settings["reply_to"].(string); ok && replyTo != "" {
+		addr, err := mail.ParseAddress(replyTo)
+		if err != nil {
+			return TenantConfig{}, fmt.Errorf("invalid reply_to '%s': %v", replyTo, err)
+		}
+		tenantCfg.TenantReplyTo = addr.String()
+	}
+
 	if smtpHost, ok := settings["smtp_host"].(string); ok {
 		tenantCfg.TenantSMTPHost = smtpHost
 	}
@@ -858,11 +1866,36 @@ func (tm *TenantManager) loadTenantConfig(tenantID int) (TenantConfig, error) {
 	}
 
 	// URLs with tenant context
-	tenantCfg.TenantRootURL = fmt.Sprintf("%s/tenant/%d", tm.cfg.RootURL, tenantID)
-	tenantCfg.TenantUnsubURL = fmt.Sprintf("%s/tenant/%d/subscription/%%s/%%s", tm.cfg.RootURL, tenantID)
-	tenantCfg.TenantOptinURL = fmt.Sprintf("%s/tenant/%d/subscription/optin/%%s?l=%%s", tm.cfg.RootURL, tenantID)
-	tenantCfg.TenantMessageURL = fmt.Sprintf("%s/tenant/%d/campaign/%%s/%%s", tm.cfg.RootURL, tenantID)
-	tenantCfg.TenantArchiveURL = fmt.Sprintf("%s/tenant/%d/archive", tm.cfg.RootURL, tenantID)
+	tenantCfg.setTenantURLs(tm.cfg.RootURL)
+
+	if mailto, ok := settings["unsubscribe_mailto"].(string); ok && mailto != "" {
+		tenantCfg.TenantUnsubMailto = mailto
+	} else {
+		tenantCfg.TenantUnsubMailto = tm.cfg.UnsubMailto
+	}
+
+	tenantCfg.TenantDefaultHeaders = parseDefaultHeaders(settings["default_headers"])
+
+	// Webhooks are gated by the tenant's plan.
+	features, err := tm.tenantStore.GetTenantFeatures(tenantID)
+	if err != nil {
+		return TenantConfig{}, fmt.Errorf("failed to get tenant features: %v", err)
+	}
+	tenantCfg.TenantWebhooksEnabled = features.WebhooksEnabled
+	tenantCfg.TenantRestrictTemplateFuncs = features.RestrictTemplateFuncs
+	tenantCfg.TenantWebhookURL, _ = settings["webhook.campaign_status_url"].(string)
+	tenantCfg.TenantWebhookSecret, _ = settings["webhook.secret"].(string)
+
+	// Where campaign status notifications go.
+	tenantCfg.TenantNotify = TenantNotifyAdmins
+	if v, ok := settings["notifications.campaign_status"].(string); ok && v != "" {
+		switch v {
+		case TenantNotifyAdmins, TenantNotifyWebhook, TenantNotifyNone:
+			tenantCfg.TenantNotify = v
+		default:
+			tm.log.Printf("tenant %d: ignoring unknown notifications.campaign_status '%s'", tenantID, v)
+		}
+	}
 
 	// Apply tenant-specific limits if present
 	if batchSize, ok := settings["max_batch_size"].(float64); ok && batchSize > 0 {
@@ -871,11 +1904,19 @@ func (tm *TenantManager) loadTenantConfig(tenantID int) (TenantConfig, error) {
 		tenantCfg.TenantMaxBatchSize = tm.cfg.BatchSize
 	}
 
+	// Concurrency explicitly set in the tenant's settings takes precedence
+	// over the one that comes with the plan's tier.
 	if concurrency, ok := settings["max_concurrency"].(float64); ok && concurrency > 0 {
 		tenantCfg.TenantMaxConcurrency = int(concurrency)
+	} else if features.MaxConcurrency > 0 {
+		tenantCfg.TenantMaxConcurrency = features.MaxConcurrency
 	} else {
 		tenantCfg.TenantMaxConcurrency = tm.cfg.Concurrency
 	}
+	if max := tm.cfg.MaxConcurrencyPerTenant; max > 0 && tenantCfg.TenantMaxConcurrency > max {
+		tm.log.Printf("clamping concurrency for tenant %d from %d to %d", tenantID, tenantCfg.TenantMaxConcurrency, max)
+		tenantCfg.TenantMaxConcurrency = max
+	}
 
 	if msgRate, ok := settings["message_rate"].(float64); ok && msgRate > 0 {
 		tenantCfg.TenantMessageRate = int(msgRate)
@@ -883,12 +1924,23 @@ func (tm *TenantManager) loadTenantConfig(tenantID int) (TenantConfig, error) {
 		tenantCfg.TenantMessageRate = tm.cfg.MessageRate
 	}
 
+	// The tenant's send window replaces the manager-wide one entirely.
+	if start, ok := settings["send_window_start"].(string); ok {
+		tenantCfg.SendWindowStart = start
+		tenantCfg.SendWindowEnd, _ = settings["send_window_end"].(string)
+		tenantCfg.SendWindowTimezone, _ = settings["send_window_timezone"].(string)
+	}
+
 	if maxErrors, ok := settings["max_send_errors"].(float64); ok && maxErrors > 0 {
 		tenantCfg.TenantMaxSendErrors = int(maxErrors)
 	} else {
 		tenantCfg.TenantMaxSendErrors = tm.cfg.MaxSendErrors
 	}
 
+	if err := tenantCfg.Validate(); err != nil {
+		return TenantConfig{}, fmt.Errorf("invalid config for tenant %d: %v", tenantID, err)
+	}
+
 	return tenantCfg, nil
 }
 
@@ -942,6 +1994,35 @@ func (tm *TenantManager) makeGenericFuncMap() template.FuncMap {
 	return funcs
 }
 
+// restrictedTplFuncs are the sprig template functions that are unavailable to
+// tenants whose plans restrict template functions.
+var restrictedTplFuncs = []string{
+	// Random data.
+	"randAlphaNum", "randAlpha", "randAscii", "randNumeric", "randBytes", "randInt",
+
+	// Cryptography.
+	"bcrypt", "htpasswd", "derivePassword", "genPrivateKey", "buildCustomCert",
+	"genCA", "genCAWithKey", "genSelfSignedCert", "genSelfSignedCertWithKey",
+	"genSignedCert", "genSignedCertWithKey", "encryptAES", "decryptAES",
+
+	// Network.
+	"getHostByName",
+
+	// File paths.
+	"base", "dir", "clean", "ext", "isAbs", "osBase", "osClean", "osDir", "osExt", "osIsAbs",
+}
+
+// restrictFuncMap returns a copy of the given template func map without
+// the restricted functions.
+func restrictFuncMap(funcs template.FuncMap) template.FuncMap {
+	out := maps.Clone(funcs)
+	for _, name := range restrictedTplFuncs {
+		delete(out, name)
+	}
+
+	return out
+}
+
 // scanCampaigns is a blocking function that periodically scans the data source
 // for campaigns to process and dispatches them to the manager. It feeds campaigns
 // into nextPipes.
@@ -951,6 +2032,11 @@ func (m *Manager) scanCampaigns(tick time.Duration) {
 
 	// Periodically scan the data source for campaigns to process.
 	for range t.C {
+		// The manager is shutting down.
+		if m.closing.Load() {
+			return
+		}
+
 		ids, counts := m.getCurrentCampaigns()
 		campaigns, err := m.store.NextCampaigns(ids, counts)
 		if err != nil {
@@ -959,6 +2045,13 @@ func (m *Manager) scanCampaigns(tick time.Duration) {
 		}
 
 		for _, c := range campaigns {
+			// The store is expected to only return campaigns that are due,
+			// but never start one that's scheduled for the future.
+			if isScheduledLater(c) {
+				m.log.Printf("skipping campaign (%s) scheduled for %s", c.Name, c.SendAt.Time.Format(time.RFC822Z))
+				continue
+			}
+
 			// Create a new pipe that'll handle this campaign's states.
 			p, err := m.newPipe(c)
 			if err != nil {
@@ -978,6 +2071,26 @@ func (m *Manager) scanCampaigns(tick time.Duration) {
 	}
 }
 
+// NextRunAt returns the time at which a scheduled campaign is due to start.
+// It returns false if the campaign isn't scheduled for the future or can't be fetched.
+func (m *Manager) NextRunAt(campID int) (time.Time, bool) {
+	c, err := m.store.GetCampaign(campID)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	if !isScheduledLater(c) {
+		return time.Time{}, false
+	}
+
+	return c.SendAt.Time, true
+}
+
+// isScheduledLater checks whether a campaign is scheduled to start in the future.
+func isScheduledLater(c *models.Campaign) bool {
+	return c.Status == models.CampaignStatusScheduled && c.SendAt.Valid && c.SendAt.Time.After(time.Now())
+}
+
 // worker is a blocking function that perpetually listents to events (message) on different
 // queues and processes them.
 func (m *Manager) worker() {
@@ -998,6 +2111,18 @@ func (m *Manager) worker() {
 				continue
 			}
 
+			// If the campaign has its own send rate, reserve a slot for the message.
+			// If the slot is in the future, requeue the message to be sent then instead
+			// of holding up the worker, which is shared by all campaigns.
+			if msg.pipe != nil && !msg.throttled {
+				if wait := msg.pipe.throttle.reserve(); wait > 0 {
+					msg.throttled = true
+					go m.requeue(msg, wait)
+					continue
+				}
+			}
+			msg.throttled = false
+
 			// Pause on hitting the message rate.
 			if numMsg >= m.cfg.MessageRate {
 				time.Sleep(time.Second)
@@ -1005,86 +2130,196 @@ func (m *Manager) worker() {
 			}
 			numMsg++
 
-			// Outgoing message.
-			out := models.Message{
-				From:        msg.from,
-				To:          []string{msg.to},
-				Subject:     msg.subject,
-				ContentType: msg.Campaign.ContentType,
-				Body:        msg.body,
-				AltBody:     msg.altBody,
-				Subscriber:  msg.Subscriber,
-				Campaign:    msg.Campaign,
-				Attachments: msg.Campaign.Attachments,
+			m.sendCampaignMessage(msg)
+
+		// Arbitrary message.
+		case msg, ok := <-m.msgQ:
+			if !ok {
+				return
 			}
 
-			h := textproto.MIMEHeader{}
-			h.Set(models.EmailHeaderCampaignUUID, msg.Campaign.UUID)
-			h.Set(models.EmailHeaderSubscriberUUID, msg.Subscriber.UUID)
+			m.sendMessage(msg)
+		}
+	}
+}
+
+// sendCampaignMessage pushes a campaign message to its messenger and updates the
+// pipe's counters. A panic while doing so (eg: a malformed template or a nil
+// messenger) is recovered and counted as a send error on the pipe so that the
+// worker stays alive and the pipe's waitgroup is released.
+func (m *Manager) sendCampaignMessage(msg CampaignMessage) {
+	// Whether the message has been marked as done on the pipe.
+	done := false
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
 
-			// Attach List-Unsubscribe headers?
-			if m.cfg.UnsubHeader {
-				h.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
-				h.Set("List-Unsubscribe", `<`+msg.unsubURL+`>`)
-			}
+		m.log.Printf("recovered from panic sending message in campaign %s: subscriber %d: %v", msg.Campaign.Name, msg.Subscriber.ID, r)
+		if msg.pipe != nil && !done {
+			msg.pipe.wg.Done()
+			msg.pipe.OnError()
+		}
+	}()
+
+	// Outgoing message.
+	out := models.Message{
+		From:        msg.from,
+		To:          []string{msg.to},
+		Subject:     msg.subject,
+		ContentType: msg.Campaign.ContentType,
+		Body:        msg.body,
+		AltBody:     msg.altBody,
+		Subscriber:  msg.Subscriber,
+		Campaign:    msg.Campaign,
+		Attachments: msg.Campaign.Attachments,
+	}
 
-			// Attach any custom headers.
-			if len(msg.Campaign.Headers) > 0 {
-				for _, set := range msg.Campaign.Headers {
-					for hdr, val := range set {
-						h.Add(hdr, val)
-					}
-				}
-			}
+	h := textproto.MIMEHeader{}
+	h.Set(models.EmailHeaderCampaignUUID, msg.Campaign.UUID)
+	h.Set(models.EmailHeaderSubscriberUUID, msg.Subscriber.UUID)
 
-			// Set the headers.
-			out.Headers = h
+	// Attach List-Unsubscribe headers?
+	if m.cfg.UnsubHeader {
+		h.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+		h.Set("List-Unsubscribe", makeUnsubHeader(msg.unsubURL, m.cfg.UnsubMailto))
+	}
 
-			// Push the message to the messenger.
-			err := m.messengers[msg.Campaign.Messenger].Push(out)
-			if err != nil {
-				m.log.Printf("error sending message in campaign %s: subscriber %d: %v", msg.Campaign.Name, msg.Subscriber.ID, err)
+	// Attach any custom headers.
+	if len(msg.Campaign.Headers) > 0 {
+		for _, set := range msg.Campaign.Headers {
+			for hdr, val := range set {
+				h.Add(hdr, val)
 			}
+		}
+	}
 
-			// Increment the send rate or the error counter if there was an error.
-			if msg.pipe != nil {
-				// Mark the message as done.
-				msg.pipe.wg.Done()
+	// Set the headers.
+	out.Headers = h
 
-				if err != nil {
-					// Call the error callback, which keeps track of the error count
-					// and stops the campaign if the error count exceeds the threshold.
-					msg.pipe.OnError()
-				} else {
-					id := uint64(msg.Subscriber.ID)
-					if id > msg.pipe.lastID.Load() {
-						msg.pipe.lastID.Store(uint64(msg.Subscriber.ID))
-					}
-					msg.pipe.rate.Incr(1)
-					msg.pipe.sent.Add(1)
-				}
-			}
+	// The campaign's messenger may have been removed or never registered.
+	// Cancel the campaign instead of attempting to push every message.
+	if _, ok := m.messengers[msg.Campaign.Messenger]; !ok {
+		m.log.Printf("unknown messenger %s on campaign %s: subscriber %d", msg.Campaign.Messenger, msg.Campaign.Name, msg.Subscriber.ID)
+		if msg.pipe == nil {
+			return
+		}
 
-		// Arbitrary message.
-		case msg, ok := <-m.msgQ:
-			if !ok {
-				return
+		done = true
+		if !msg.pipe.stopped.Load() {
+			msg.pipe.Stop(false)
+			if err := m.store.UpdateCampaignStatus(msg.Campaign.ID, models.CampaignStatusCancelled); err != nil {
+				m.log.Printf("error cancelling campaign (%s): %v", msg.Campaign.Name, err)
 			}
+		}
+		msg.pipe.wg.Done()
+		return
+	}
 
-			// Push the message to the messenger.
-			if err := m.messengers[msg.Messenger].Push(msg); err != nil {
-				m.log.Printf("error sending message '%s': %v", msg.Subject, err)
+	// Push the message to the messenger, or record it in the dry-run mode.
+	var err error
+	if m.isDryRun(msg.Campaign.ID) {
+		err = m.dryRun.record(msg.Campaign.ID, out)
+	} else {
+		start := time.Now()
+		err = m.pushWithFallback(msg.Campaign.Messenger, out)
+		if msg.pipe != nil {
+			msg.pipe.batch.record(time.Since(start))
+		}
+	}
+	if err != nil {
+		// Requeue the message for another attempt before counting it as an error.
+		if m.cfg.RequeueOnError && msg.retries < maxRequeues {
+			msg.retries++
+			m.log.Printf("error sending message in campaign %s: subscriber %d: %v. requeuing (%d/%d)",
+				msg.Campaign.Name, msg.Subscriber.ID, err, msg.retries, maxRequeues)
+
+			go m.requeue(msg, requeueBackoff*time.Duration(msg.retries))
+			return
+		}
+
+		m.log.Printf("error sending message in campaign %s: subscriber %d: %v", msg.Campaign.Name, msg.Subscriber.ID, err)
+	} else {
+		m.sent.Add(1)
+	}
+
+	if m.fnResult != nil {
+		m.fnResult(makeCampaignResult(msg.Campaign, msg.Subscriber, 0, err))
+	}
+
+	// Increment the send rate or the error counter if there was an error.
+	if msg.pipe != nil {
+		// Mark the message as done.
+		done = true
+		msg.pipe.wg.Done()
+
+		if err != nil {
+			// Call the error callback, which keeps track of the error count
+			// and stops the campaign if the error count exceeds the threshold.
+			msg.pipe.OnError()
+		} else {
+			id := uint64(msg.Subscriber.ID)
+			if id > msg.pipe.lastID.Load() {
+				msg.pipe.lastID.Store(uint64(msg.Subscriber.ID))
 			}
+			msg.pipe.rate.Incr(1)
+			msg.pipe.sent.Add(1)
 		}
 	}
 }
 
+// pushWithFallback pushes a message to the given messenger. If that fails and
+// a fallback messenger is configured, the message is retried via the fallback.
+func (m *Manager) pushWithFallback(name string, out models.Message) error {
+	err := m.messengers[name].Push(out)
+	if err == nil || m.cfg.FallbackMessenger == "" || m.cfg.FallbackMessenger == name {
+		return err
+	}
+
+	fb, ok := m.messengers[m.cfg.FallbackMessenger]
+	if !ok {
+		return err
+	}
+
+	m.log.Printf("error sending message via %s: %v. retrying via fallback %s", name, err, m.cfg.FallbackMessenger)
+	return fb.Push(out)
+}
+
+// sendMessage pushes an arbitrary message to its messenger, recovering from
+// any panic so that the worker stays alive.
+func (m *Manager) sendMessage(msg models.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			m.log.Printf("recovered from panic sending message '%s': %v", msg.Subject, r)
+		}
+	}()
+
+	// Push the message to the messenger.
+	if err := m.messengers[msg.Messenger].Push(msg); err != nil {
+		m.log.Printf("error sending message '%s': %v", msg.Subject, err)
+	} else {
+		m.sent.Add(1)
+	}
+}
+
+// requeue pushes a campaign message back onto the queue after the given wait,
+// either as a backoff after a send error or to honour its campaign's send rate.
+// The message remains counted in its pipe's waitgroup until it's finally
+// processed by a worker.
+func (m *Manager) requeue(msg CampaignMessage, wait time.Duration) {
+	time.Sleep(wait)
+	m.campMsgQ <- msg
+}
+
 // getCurrentCampaigns returns the IDs of campaigns currently being processed
 // and their sent counts.
 func (m *Manager) getCurrentCampaigns() ([]int64, []int64) {
 	// Needs to return an empty slice in case there are no campaigns.
-	m.pipesMut.RLock()
-	defer m.pipesMut.RUnlock()
+	// The counts are reset, so the write lock ensures that concurrent
+	// calls don't both read and flush the same counts.
+	m.pipesMut.Lock()
+	defer m.pipesMut.Unlock()
 
 	var (
 		ids    = make([]int64, 0, len(m.pipes))
@@ -1095,8 +2330,8 @@ func (m *Manager) getCurrentCampaigns() ([]int64, []int64) {
 
 		// Get the sent counts for campaigns and reset them to 0
 		// as in the database, they're stored cumulatively (sent += $newSent).
-		counts = append(counts, p.sent.Load())
-		p.sent.Store(0)
+		// Swap() doesn't lose the messages sent between reading and resetting.
+		counts = append(counts, p.sent.Swap(0))
 	}
 
 	return ids, counts
@@ -1107,12 +2342,9 @@ func (m *Manager) getCurrentCampaigns() ([]int64, []int64) {
 func (m *Manager) trackLink(url, campUUID, subUUID string) string {
 	url = strings.ReplaceAll(url, "&amp;", "&")
 
-	m.linksMut.RLock()
-	if uu, ok := m.links[url]; ok {
-		m.linksMut.RUnlock()
+	if uu, ok := m.links.get(url); ok {
 		return fmt.Sprintf(m.cfg.LinkTrackURL, uu, campUUID, subUUID)
 	}
-	m.linksMut.RUnlock()
 
 	// Register link.
 	uu, err := m.store.CreateLink(url)
@@ -1123,13 +2355,24 @@ func (m *Manager) trackLink(url, campUUID, subUUID string) string {
 		return url
 	}
 
-	m.linksMut.Lock()
-	m.links[url] = uu
-	m.linksMut.Unlock()
+	m.links.set(url, uu)
 
 	return fmt.Sprintf(m.cfg.LinkTrackURL, uu, campUUID, subUUID)
 }
 
+// warmLinks loads the campaign's existing tracked links into the link cache.
+func (m *Manager) warmLinks(c *models.Campaign) {
+	links, err := m.store.GetLinks([]string{c.UUID})
+	if err != nil {
+		m.log.Printf("error loading links for campaign %s: %v", c.Name, err)
+		return
+	}
+
+	for url, uu := range links {
+		m.links.set(url, uu)
+	}
+}
+
 // sendNotif sends a notification to registered admin e-mails.
 func (m *Manager) sendNotif(c *models.Campaign, status, reason string) error {
 	var (
@@ -1175,20 +2418,78 @@ func (m *Manager) makeGnericFuncMap() template.FuncMap {
 	return funcs
 }
 
-// attachMedia loads any media/attachments from the media store and attaches
-// the byte blobs to the campaign.
-func (m *Manager) attachMedia(c *models.Campaign) error {
-	// Load any media/attachments.
+// attachMedia returns a copy of the campaign with the media/attachment byte
+// blobs attached. The campaign itself isn't modified as it may be shared by
+// messages that the workers are sending. If the campaign is running, the
+// attachments loaded by its pipe are reused instead of fetching them from
+// the media store again.
+func (m *Manager) attachMedia(c *models.Campaign) (*models.Campaign, error) {
+	m.pipesMut.RLock()
+	att, ok := m.attachments[c.ID]
+	m.pipesMut.RUnlock()
+
+	if !ok {
+		var err error
+		if att, err = m.loadMedia(c); err != nil {
+			return nil, err
+		}
+	}
+
+	out := *c
+	out.Attachments = att
+	return &out, nil
+}
+
+// loadMedia fetches the campaign's media/attachments from the media store.
+func (m *Manager) loadMedia(c *models.Campaign) ([]models.Attachment, error) {
+	var (
+		out  = make([]models.Attachment, 0, len(c.MediaIDs))
+		size int64
+	)
 	for _, mid := range []int64(c.MediaIDs) {
 		a, err := m.store.GetAttachment(int(mid))
 		if err != nil {
-			return fmt.Errorf("error fetching attachment %d on campaign %s: %v", mid, c.Name, err)
+			return nil, fmt.Errorf("error fetching attachment %d on campaign %s: %v", mid, c.Name, err)
 		}
 
-		c.Attachments = append(c.Attachments, a)
+		size += int64(len(a.Content))
+		if err := checkAttachmentSize(size, m.cfg.MaxAttachmentBytes); err != nil {
+			return nil, fmt.Errorf("campaign %s: %w", c.Name, err)
+		}
+
+		if m.cfg.StreamAttachments {
+			a = streamAttachment(a, int(mid), m.store.GetAttachment)
+		}
+
+		out = append(out, a)
 	}
 
-	return nil
+	return out, nil
+}
+
+// checkAttachmentSize returns an error wrapping ErrAttachmentsTooLarge if the
+// total size of attachments exceeds the limit. A limit < 1 is no limit.
+func checkAttachmentSize(size, limit int64) error {
+	if limit < 1 || size <= limit {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %d bytes (max %d)", ErrAttachmentsTooLarge, size, limit)
+}
+
+// streamAttachment drops an attachment's content and sets it to be loaded
+// from the store whenever a message is pushed, after which it can be freed.
+func streamAttachment(a models.Attachment, mediaID int, get func(int) (models.Attachment, error)) models.Attachment {
+	a.Content = nil
+	a.Loader = func() ([]byte, error) {
+		out, err := get(mediaID)
+		if err != nil {
+			return nil, err
+		}
+		return out.Content, nil
+	}
+
+	return a
 }
 
 // MakeAttachmentHeader is a helper function that returns a
@@ -1208,3 +2509,66 @@ func MakeAttachmentHeader(filename, encoding, contentType string) textproto.MIME
 	h.Set("Content-Transfer-Encoding", encoding)
 	return h
 }
+
+// makeUnsubHeader returns the value of the List-Unsubscribe header with the
+// unsubscribe URL and, if an address is given, a mailto: option.
+func makeUnsubHeader(unsubURL, mailto string) string {
+	h := `<` + unsubURL + `>`
+	if mailto == "" {
+		return h
+	}
+
+	if !strings.HasPrefix(mailto, "mailto:") {
+		mailto = "mailto:" + mailto
+	}
+	return h + `, <` + mailto + `>`
+}
+
+// parseDefaultHeaders parses headers from a tenant setting, which is either
+// a list of {"header": "value"} objects like campaign headers, or a single
+// object of header-value pairs. Non-string values are ignored.
+func parseDefaultHeaders(v any) []map[string]string {
+	var sets []any
+	switch val := v.(type) {
+	case []any:
+		sets = val
+	case map[string]any:
+		sets = []any{val}
+	default:
+		return nil
+	}
+
+	out := make([]map[string]string, 0, len(sets))
+	for _, s := range sets {
+		set, ok := s.(map[string]any)
+		if !ok {
+			continue
+		}
+
+		hdrs := make(map[string]string, len(set))
+		for hdr, val := range set {
+			if v, ok := val.(string); ok && hdr != "" {
+				hdrs[hdr] = v
+			}
+		}
+		if len(hdrs) > 0 {
+			out = append(out, hdrs)
+		}
+	}
+
+	return out
+}
+
+// makeCampaignResult returns the result of a campaign message push.
+func makeCampaignResult(c *models.Campaign, sub models.Subscriber, tenantID int, err error) CampaignResult {
+	out := CampaignResult{
+		CampaignID:   c.ID,
+		SubscriberID: sub.ID,
+		TenantID:     tenantID,
+		Success:      err == nil,
+	}
+	if err != nil {
+		out.Error = err.Error()
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package manager
+
+import (
+	"io"
+	"log"
+	"testing"
+)
+
+func TestManagerTenantLoad(t *testing.T) {
+	m, err := New(Config{Concurrency: 2, MessageRate: 5}, nil, nil, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.pipes[1] = &pipe{}
+
+	load, ok := m.TenantLoad()[legacyTenantID]
+	if !ok {
+		t.Fatalf("expected the default tenant's load")
+	}
+
+	want := TenantLoad{CampaignQueueCap: 20, MessageQueueCap: 20, Pipes: 1, Workers: 2}
+	if load != want {
+		t.Errorf("expected %+v, got %+v", want, load)
+	}
+}
+
+func TestNewInvalidConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{UnsubURL: "http://listmonk.test/100%/unsub/%s/%s"},
+		{LinkTrackURL: "http://listmonk.test/link/%s/%s"},
+		{OptinURL: "http://listmonk.test/optin/%s"},
+	} {
+		if m, err := New(cfg, nil, nil, log.New(io.Discard, "", 0)); err == nil || m != nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+
+	// Escaped percents aren't verbs.
+	cfg := Config{UnsubURL: "http://listmonk.test/100%%/unsub/%s/%s"}
+	if _, err := New(cfg, nil, nil, log.New(io.Discard, "", 0)); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestNewFromTenantStoreInvalidConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.MessageURL = "http://listmonk.test/message/%s"
+
+	if _, err := NewFromTenantStore(cfg, newTestStore(), nil, testLogger()); err == nil {
+		t.Error("expected an error for the malformed message URL")
+	}
+}
+
+func TestTenantConfigValidate(t *testing.T) {
+	cfg := TenantConfig{Config: testConfig(), TenantID: 2}
+	cfg.setTenantURLs("http://listmonk.test")
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A % in the root URL ends up as a stray verb in the tenant's URLs.
+	cfg.setTenantURLs("http://listmonk.test/50%off")
+	if err := cfg.Validate(); err == nil {
+		t.Error("expected an error for the stray verb in the tenant URLs")
+	}
+}
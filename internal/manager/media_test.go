@@ -0,0 +1,44 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/lib/pq"
+)
+
+func TestCampaignAttachmentsLoadedOnce(t *testing.T) {
+	st := newTestStore()
+	st.media[7] = models.Attachment{Name: "report.pdf", Content: []byte("pdf")}
+	m, msgr := newTestManager(t, testConfig(), st)
+
+	c := st.addCampaign(legacyTenantID, 1, 5, "email")
+	c.MediaIDs = pq.Int64Array{7}
+
+	// The pipe loads the attachments and holds them until it's done.
+	p, err := m.newPipe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, sub := range st.subs[1] {
+		msg, err := m.NewCampaignMessage(c, sub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := m.PushCampaignMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, time.Second, "the messages to be sent", func() bool { return len(msgr.Sent()) == 5 })
+	p.wg.Done()
+
+	if n := st.count("GetAttachment"); n != 1 {
+		t.Errorf("expected the attachment to be fetched once, got %d", n)
+	}
+	for _, msg := range msgr.Sent() {
+		if len(msg.Attachments) != 1 || msg.Attachments[0].Name != "report.pdf" {
+			t.Errorf("expected the message to have the one attachment, got %d", len(msg.Attachments))
+		}
+	}
+}
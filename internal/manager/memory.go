@@ -0,0 +1,79 @@
+package manager
+
+import (
+	"sync"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// MemoryMessenger is a Messenger that records the messages pushed to it in
+// memory instead of sending them. It's meant for tests and development.
+type MemoryMessenger struct {
+	name string
+	fail func(models.Message) error
+
+	sent []models.Message
+	mut  sync.Mutex
+}
+
+// NewMemoryMessenger returns an in-memory Messenger with the given name.
+func NewMemoryMessenger(name string) *MemoryMessenger {
+	return &MemoryMessenger{name: name}
+}
+
+// Name returns the messenger's name.
+func (m *MemoryMessenger) Name() string {
+	return m.name
+}
+
+// Push records a message. If a failure function is set with FailWith() and it
+// returns an error for the message, the message isn't recorded and the error
+// is returned.
+func (m *MemoryMessenger) Push(msg models.Message) error {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	if m.fail != nil {
+		if err := m.fail(msg); err != nil {
+			return err
+		}
+	}
+
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// FailWith sets a function that decides whether pushing a message fails,
+// eg: to fail every message to a particular e-mail. nil disables failures.
+func (m *MemoryMessenger) FailWith(fn func(models.Message) error) {
+	m.mut.Lock()
+	m.fail = fn
+	m.mut.Unlock()
+}
+
+// Sent returns a copy of the messages pushed so far.
+func (m *MemoryMessenger) Sent() []models.Message {
+	m.mut.Lock()
+	defer m.mut.Unlock()
+
+	out := make([]models.Message, len(m.sent))
+	copy(out, m.sent)
+	return out
+}
+
+// Reset discards the recorded messages.
+func (m *MemoryMessenger) Reset() {
+	m.mut.Lock()
+	m.sent = nil
+	m.mut.Unlock()
+}
+
+// Flush is a no-op.
+func (m *MemoryMessenger) Flush() error {
+	return nil
+}
+
+// Close is a no-op.
+func (m *MemoryMessenger) Close() error {
+	return nil
+}
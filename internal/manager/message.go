@@ -25,6 +25,17 @@ func (m *Manager) NewCampaignMessage(c *models.Campaign, s models.Subscriber) (C
 		return msg, err
 	}
 
+	// Add the unsubscribe link to bodies that don't have it.
+	if m.cfg.EnforceUnsubFooter {
+		msg.body = withUnsubFooter(msg.body, msg.unsubURL, c.ContentType == models.CampaignContentTypePlain)
+		msg.altBody = withUnsubFooter(msg.altBody, msg.unsubURL, true)
+	}
+
+	// Generate a plain text alt body for HTML messages that don't have one.
+	if m.cfg.AutoPlainText && c.ContentType != models.CampaignContentTypePlain && len(msg.altBody) == 0 {
+		msg.altBody = htmlToText(msg.body)
+	}
+
 	return msg, nil
 }
 
@@ -0,0 +1,56 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestMissingMessengerCancelsCampaign(t *testing.T) {
+	st := newTestStore()
+	m, msgr := newTestManager(t, testConfig(), st)
+
+	p, err := m.newPipe(st.addCampaign(legacyTenantID, 1, 5, "email"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The campaign's messenger goes away after the campaign has started.
+	p.camp.Messenger = "missing"
+	m.nextPipes <- p
+
+	waitFor(t, time.Second, "the campaign to end", func() bool { return !m.HasRunningCampaigns() })
+	if s := st.status(1); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to be cancelled, got %s", s)
+	}
+	if n := len(msgr.Sent()); n != 0 {
+		t.Errorf("expected no messages, got %d", n)
+	}
+}
+
+func TestTenantMissingMessengerCancelsCampaign(t *testing.T) {
+	st := newTestStore()
+	tm, msgr := newTestTenantManager(t, testConfig(), st)
+
+	c := st.addCampaign(2, 1, 5, "email")
+	tim := startTenant(t, tm, 2)
+	tp, err := tim.newTenantPipe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The messenger is removed after the campaign has started.
+	tim.messengersMut.Lock()
+	delete(tim.messengers, "email")
+	tim.messengersMut.Unlock()
+	tim.nextPipes <- tp
+
+	waitFor(t, time.Second, "the campaign to end", func() bool { return !tim.HasRunningCampaigns() })
+	if s := st.status(1); s != models.CampaignStatusCancelled {
+		t.Errorf("expected the campaign to be cancelled, got %s", s)
+	}
+	if n := len(msgr.Sent()); n != 0 {
+		t.Errorf("expected no messages, got %d", n)
+	}
+}
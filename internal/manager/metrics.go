@@ -0,0 +1,78 @@
+package manager
+
+// Metrics is a point-in-time snapshot of a manager's queues and throughput.
+type Metrics struct {
+	// Number of campaign messages waiting in the queue to be sent.
+	CampaignQueue int `json:"campaign_queue"`
+
+	// Number of arbitrary (non-campaign) messages waiting in the queue.
+	MessageQueue int `json:"message_queue"`
+
+	// Number of campaigns that are currently being processed.
+	ActivePipes int `json:"active_pipes"`
+
+	Workers int `json:"workers"`
+
+	// Total number of messages successfully sent since the manager started.
+	Sent int64 `json:"sent"`
+}
+
+// TenantMetrics contains the metrics aggregated across all tenant instances
+// along with a per-tenant breakdown.
+type TenantMetrics struct {
+	Metrics
+
+	Tenants map[int]Metrics `json:"tenants"`
+}
+
+// Metrics returns a snapshot of the manager's queue depths and throughput.
+func (m *Manager) Metrics() Metrics {
+	m.pipesMut.RLock()
+	numPipes := len(m.pipes)
+	m.pipesMut.RUnlock()
+
+	return Metrics{
+		CampaignQueue: len(m.campMsgQ),
+		MessageQueue:  len(m.msgQ),
+		ActivePipes:   numPipes,
+		Workers:       m.cfg.Concurrency,
+		Sent:          m.sent.Load(),
+	}
+}
+
+// Metrics returns the metrics of all tenant instances, aggregated and per tenant.
+func (tm *TenantManager) Metrics() TenantMetrics {
+	tm.tenantManagersMut.RLock()
+	defer tm.tenantManagersMut.RUnlock()
+
+	out := TenantMetrics{
+		Tenants: make(map[int]Metrics, len(tm.tenantManagers)),
+	}
+	for id, t := range tm.tenantManagers {
+		tmt := t.Metrics()
+		out.Tenants[id] = tmt
+
+		out.CampaignQueue += tmt.CampaignQueue
+		out.MessageQueue += tmt.MessageQueue
+		out.ActivePipes += tmt.ActivePipes
+		out.Workers += tmt.Workers
+		out.Sent += tmt.Sent
+	}
+
+	return out
+}
+
+// Metrics returns a snapshot of this tenant's queue depths and throughput.
+func (tim *tenantInstanceManager) Metrics() Metrics {
+	tim.pipesMut.RLock()
+	numPipes := len(tim.pipes)
+	tim.pipesMut.RUnlock()
+
+	return Metrics{
+		CampaignQueue: len(tim.campMsgQ),
+		MessageQueue:  len(tim.msgQ),
+		ActivePipes:   numPipes,
+		Workers:       tim.config().TenantMaxConcurrency,
+		Sent:          tim.sent.Load(),
+	}
+}
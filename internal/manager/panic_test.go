@@ -0,0 +1,67 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// panicMessenger is a memory messenger that panics on pushes to the
+// first subscriber.
+type panicMessenger struct {
+	*MemoryMessenger
+}
+
+func (p *panicMessenger) Push(msg models.Message) error {
+	if msg.Subscriber.ID == 1 {
+		panic("push exploded")
+	}
+	return p.MemoryMessenger.Push(msg)
+}
+
+func TestWorkerRecoversFromPanic(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+	cfg.MaxSendErrors = 10
+
+	st := newTestStore()
+	msgr := &panicMessenger{NewMemoryMessenger("panicky")}
+	m, _ := newTestManager(t, cfg, st, msgr)
+
+	p := startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 3, "panicky"))
+
+	// The only worker has to survive the panic to send the rest.
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(msgr.Sent()); n != 2 {
+		t.Errorf("expected 2 messages after the panic, got %d", n)
+	}
+	if n := p.errors.Load(); n != 1 {
+		t.Errorf("expected 1 error on the pipe, got %d", n)
+	}
+}
+
+func TestTenantWorkerRecoversFromPanic(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+	cfg.MaxSendErrors = 10
+
+	st := newTestStore()
+	msgr := &panicMessenger{NewMemoryMessenger("panicky")}
+	tm, _ := newTestTenantManager(t, cfg, st, msgr)
+
+	c := st.addCampaign(2, 1, 3, "panicky")
+	tim := startTenant(t, tm, 2)
+	tp := startPipe(t, tim, c)
+
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(msgr.Sent()); n != 2 {
+		t.Errorf("expected 2 messages after the panic, got %d", n)
+	}
+	if n := tp.errors.Load(); n != 1 {
+		t.Errorf("expected 1 error on the pipe, got %d", n)
+	}
+	if n, last := tim.errorStats(); n != 1 || last != "panic: push exploded" {
+		t.Errorf("expected the panic to be recorded as the tenant's error, got %d (%s)", n, last)
+	}
+}
@@ -0,0 +1,168 @@
+package manager
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// checkResumed checks that a campaign paused after n messages was resumed
+// from its checkpoint, sending every subscriber exactly once.
+func checkResumed(t *testing.T, sent []models.Message, n, total int) {
+	t.Helper()
+
+	if len(sent) != total {
+		t.Fatalf("expected %d messages, got %d", total, len(sent))
+	}
+	if id := sent[n].Subscriber.ID; id != n+1 {
+		t.Errorf("expected the campaign to resume from subscriber %d, got %d", n+1, id)
+	}
+
+	seen := make(map[int]bool, total)
+	for _, m := range sent {
+		if seen[m.Subscriber.ID] {
+			t.Errorf("subscriber %d was sent to more than once", m.Subscriber.ID)
+		}
+		seen[m.Subscriber.ID] = true
+	}
+}
+
+func TestPauseResumeCampaign(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+
+	st := newTestStore()
+	g := newGatedMessenger("gated")
+	m, _ := newTestManager(t, cfg, st, g)
+
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 20, "gated"))
+
+	// Pause mid-batch.
+	g.allow(t, 5)
+	if err := m.PauseCampaign(1); err != nil {
+		t.Fatal(err)
+	}
+	close(g.gate)
+
+	waitFor(t, time.Second*2, "the campaign to pause", func() bool { return !m.HasRunningCampaigns() })
+
+	// The message that a worker was pushing when the campaign was paused may go out.
+	n := len(g.Sent())
+	if n > 6 {
+		t.Fatalf("expected no sends after pausing, got %d messages", n)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if len(g.Sent()) != n {
+		t.Fatalf("messages were sent after the campaign was paused")
+	}
+	if s := st.status(1); s != models.CampaignStatusPaused {
+		t.Fatalf("expected the campaign to be paused, got %s", s)
+	}
+	if cp := st.checkpoint(1); cp != n {
+		t.Fatalf("expected the checkpoint at subscriber %d, got %d", n, cp)
+	}
+
+	if err := m.ResumeCampaign(1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+
+	checkResumed(t, g.Sent(), n, 20)
+}
+
+func TestPauseResumeTenantCampaign(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+
+	st := newTestStore()
+	g := newGatedMessenger("gated")
+	tm, _ := newTestTenantManager(t, cfg, st, g)
+
+	c := st.addCampaign(2, 1, 20, "gated")
+	startPipe(t, startTenant(t, tm, 2), c)
+
+	g.allow(t, 5)
+	if err := tm.PauseTenantCampaign(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	close(g.gate)
+
+	waitFor(t, time.Second*2, "the campaign to pause", func() bool { return !tm.HasRunningCampaigns() })
+
+	n := len(g.Sent())
+	if n > 6 {
+		t.Fatalf("expected no sends after pausing, got %d messages", n)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if len(g.Sent()) != n {
+		t.Fatalf("messages were sent after the campaign was paused")
+	}
+	if s := st.status(1); s != models.CampaignStatusPaused {
+		t.Fatalf("expected the campaign to be paused, got %s", s)
+	}
+	if cp := st.checkpoint(1); cp != n {
+		t.Fatalf("expected the checkpoint at subscriber %d, got %d", n, cp)
+	}
+
+	if err := tm.ResumeTenantCampaign(2, 1); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, time.Second*2, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+
+	checkResumed(t, g.Sent(), n, 20)
+}
+
+// TestPauseWhileFinishing pauses campaigns in the store, as the API does,
+// after their pipes have fetched them to be finished. The pause is never
+// overwritten by the finish.
+func TestPauseWhileFinishing(t *testing.T) {
+	const num = 20
+
+	st := newTestStore()
+
+	// Even campaigns are paused concurrently once their pipes have read them
+	// as running.
+	st.afterGet = func(id int) {
+		if id%2 != 0 {
+			return
+		}
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			st.mut.Lock()
+			if c := st.camps[id]; c.Status == models.CampaignStatusRunning {
+				c.Status = models.CampaignStatusPaused
+			}
+			st.mut.Unlock()
+		}()
+		wg.Wait()
+	}
+
+	m, _ := newTestManager(t, testConfig(), st)
+	for id := 1; id <= num; id++ {
+		startManagerPipe(t, m, st.addCampaign(legacyTenantID, id, 5, "email"))
+	}
+
+	waitFor(t, time.Second*2, "the pipes to end", func() bool {
+		for id := 1; id <= num; id += 2 {
+			if st.status(id) != models.CampaignStatusFinished {
+				return false
+			}
+		}
+		return !m.HasRunningCampaigns()
+	})
+
+	for id := 1; id <= num; id++ {
+		want := models.CampaignStatusFinished
+		if id%2 == 0 {
+			want = models.CampaignStatusPaused
+		}
+		if s := st.status(id); s != want {
+			t.Errorf("expected campaign %d to be %s, got %s", id, want, s)
+		}
+	}
+}
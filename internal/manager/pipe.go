@@ -1,6 +1,8 @@
 package manager
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"sync"
 	"sync/atomic"
@@ -18,8 +20,19 @@ type pipe struct {
 	lastID     atomic.Uint64
 	errors     atomic.Uint64
 	stopped    atomic.Bool
+	paused     atomic.Bool
+	cancelled  atomic.Bool
 	withErrors atomic.Bool
 
+	// Paces the campaign's messages if it has its own send rate.
+	throttle throttle
+
+	// Adjusts the batch size to the send latency (nil if disabled).
+	batch *adaptiveBatch
+
+	// When the campaign was picked up for processing.
+	started time.Time
+
 	m *Manager
 }
 
@@ -36,17 +49,38 @@ func (m *Manager) newPipe(c *models.Campaign) (*pipe, error) {
 		return nil, err
 	}
 
-	// Load any media/attachments.
-	if err := m.attachMedia(c); err != nil {
+	// Load any media/attachments once for all of the campaign's messages.
+	att, err := m.loadMedia(c)
+	if err != nil {
+		// Every message would be rejected, so cancel the campaign right away.
+		if errors.Is(err, ErrAttachmentsTooLarge) {
+			if err := m.store.UpdateCampaignStatus(c.ID, models.CampaignStatusCancelled); err != nil {
+				m.log.Printf("error cancelling campaign (%s): %v", c.Name, err)
+			}
+			_ = m.sendNotif(c, models.CampaignStatusCancelled, err.Error())
+		}
 		return nil, err
 	}
+	c.Attachments = att
+
+	// Pre-populate the link cache with the links the campaign already has.
+	if m.cfg.WarmLinkCache {
+		m.warmLinks(c)
+	}
+
+	// Don't pick up new campaigns while the manager is shutting down.
+	if m.closing.Load() {
+		return nil, errors.New("campaign manager is shutting down")
+	}
 
 	// Add the campaign to the active map.
 	p := &pipe{
-		camp: c,
-		rate: ratecounter.NewRateCounter(time.Minute),
-		wg:   &sync.WaitGroup{},
-		m:    m,
+		camp:    c,
+		rate:    ratecounter.NewRateCounter(time.Minute),
+		wg:      &sync.WaitGroup{},
+		batch:   newAdaptiveBatch(m.cfg.AdaptiveBatch, m.cfg.BatchSize),
+		started: time.Now(),
+		m:       m,
 	}
 
 	// Increment the waitgroup so that Wait() blocks immediately. This is necessary
@@ -54,8 +88,11 @@ func (m *Manager) newPipe(c *models.Campaign) (*pipe, error) {
 	// fetched asynchronolusly later. The messages each add to the wg and that
 	// count is used to determine the exhaustion/completion of all messages.
 	p.wg.Add(1)
+	m.pipesWg.Add(1)
 
 	go func() {
+		defer m.pipesWg.Done()
+
 		// Wait for all the messages in the campaign to be processed
 		// (successfully or skipped after errors or cancellation).
 		p.wg.Wait()
@@ -63,8 +100,17 @@ func (m *Manager) newPipe(c *models.Campaign) (*pipe, error) {
 		p.cleanup()
 	}()
 
+	// Discard the messages recorded by a previous dry run of the campaign.
+	if m.isDryRun(c.ID) {
+		m.dryRun.reset(c.ID)
+	}
+
 	m.pipesMut.Lock()
 	m.pipes[c.ID] = p
+	m.attachments[c.ID] = att
+	if rate, ok := m.rates[c.ID]; ok {
+		p.throttle.setRate(rate)
+	}
 	m.pipesMut.Unlock()
 	return p, nil
 }
@@ -74,9 +120,27 @@ func (m *Manager) newPipe(c *models.Campaign) (*pipe, error) {
 // in the current batch or not. A false indicates that all subscribers
 // have been processed, or that a campaign has been paused or cancelled.
 func (p *pipe) NextSubscribers() (bool, error) {
+	// The campaign has been stopped or paused, or the manager is shutting down.
+	// Don't fetch any more subscribers.
+	if p.stopped.Load() || p.m.closing.Load() {
+		return false, nil
+	}
+
+	// Outside the send window. Wait a while and have the pipe queued again
+	// without fetching any subscribers.
+	if wait := p.m.window.wait(time.Now()); wait > 0 {
+		time.Sleep(min(wait, sendWindowCheckInterval))
+		return true, nil
+	}
+
 	// Fetch the next batch of subscribers from a 'running' campaign.
-	subs, err := p.m.store.NextSubscribers(p.camp.ID, p.m.cfg.BatchSize)
+	subs, err := p.m.store.NextSubscribers(p.m.ctx, p.camp.ID, p.batch.next(p.m.cfg.BatchSize))
 	if err != nil {
+		// The fetch was interrupted by Close(). End the pipe so that it drains.
+		if p.m.ctx.Err() != nil {
+			return false, nil
+		}
+
 		return false, fmt.Errorf("error fetching campaign subscribers (%s): %v", p.camp.Name, err)
 	}
 
@@ -86,11 +150,6 @@ func (p *pipe) NextSubscribers() (bool, error) {
 		return false, nil
 	}
 
-	// Is there a sliding window limit configured?
-	hasSliding := p.m.cfg.SlidingWindow &&
-		p.m.cfg.SlidingWindowRate > 0 &&
-		p.m.cfg.SlidingWindowDuration.Seconds() > 1
-
 	// Push messages.
 	for _, s := range subs {
 		msg, err := p.newMessage(s)
@@ -99,36 +158,26 @@ func (p *pipe) NextSubscribers() (bool, error) {
 			continue
 		}
 
-		// Push the message to the queue while blocking and waiting until
-		// the queue is drained.
-		p.m.campMsgQ <- msg
-
-		// Check if the sliding window is active.
-		if hasSliding {
-			diff := time.Since(p.m.slidingStart)
-
-			// Window has expired. Reset the clock.
-			if diff >= p.m.cfg.SlidingWindowDuration {
-				p.m.slidingStart = time.Now()
-				p.m.slidingCount = 0
-				continue
+		// If a sliding window limit is configured and the messages have exceeded
+		// the limit for the window, wait until the window is over. The window is
+		// shared by all the pipes, that is, all running campaigns.
+		for {
+			wait, start := p.m.sliding.reserve()
+			if wait == 0 {
+				break
 			}
 
-			// Have the messages exceeded the limit?
-			p.m.slidingCount++
-			if p.m.slidingCount >= p.m.cfg.SlidingWindowRate {
-				wait := p.m.cfg.SlidingWindowDuration - diff
-
-				p.m.log.Printf("messages exceeded (%d) for the window (%v since %s). Sleeping for %s.",
-					p.m.slidingCount,
-					p.m.cfg.SlidingWindowDuration,
-					p.m.slidingStart.Format(time.RFC822Z),
-					wait.Round(time.Second)*1)
-
-				p.m.slidingCount = 0
-				time.Sleep(wait)
-			}
+			p.m.log.Printf("messages exceeded (%d) for the window (%v since %s). Sleeping for %s.",
+				p.m.cfg.SlidingWindowRate,
+				p.m.cfg.SlidingWindowDuration,
+				start.Format(time.RFC822Z),
+				wait.Round(time.Second)*1)
+			time.Sleep(wait)
 		}
+
+		// Push the message to the queue while blocking and waiting until
+		// the queue is drained.
+		p.m.campMsgQ <- msg
 	}
 
 	return true, nil
@@ -167,6 +216,21 @@ func (p *pipe) Stop(withErrors bool) {
 	p.stopped.Store(true)
 }
 
+// Pause marks a campaign as paused. Like Stop(), queued messages are ignored,
+// but the last processed subscriber ID is retained as a checkpoint in cleanup()
+// for the campaign to be resumed later.
+func (p *pipe) Pause() {
+	p.paused.Store(true)
+	p.Stop(false)
+}
+
+// Cancel marks a campaign as cancelled. Like Stop(), queued messages are
+// ignored, and no checkpoint is retained in cleanup().
+func (p *pipe) Cancel() {
+	p.cancelled.Store(true)
+	p.Stop(false)
+}
+
 // newMessage returns a campaign message while internally incrementing the
 // number of messages in the pipe wait group so that the status of every
 // message can be atomically tracked.
@@ -189,14 +253,39 @@ func (p *pipe) cleanup() {
 	defer func() {
 		p.m.pipesMut.Lock()
 		delete(p.m.pipes, p.camp.ID)
+		delete(p.m.attachments, p.camp.ID)
 		p.m.pipesMut.Unlock()
 	}()
 
+	// All of the campaign's messages have been pushed. Flush the ones that
+	// its messenger may have buffered.
+	if msgr, ok := p.m.messengers[p.camp.Messenger]; ok {
+		if err := msgr.Flush(); err != nil {
+			p.m.log.Printf("error flushing messenger %s (%s): %v", msgr.Name(), p.camp.Name, err)
+		}
+	}
+
 	// Update campaign's 'sent count.
 	if err := p.m.store.UpdateCampaignCounts(p.camp.ID, 0, int(p.sent.Load()), int(p.lastID.Load())); err != nil {
 		p.m.log.Printf("error updating campaign counts (%s): %v", p.camp.Name, err)
 	}
 
+	// The campaign was cancelled. Its status has already been set.
+	if p.cancelled.Load() {
+		p.m.log.Printf("cancelled campaign (%s)", p.camp.Name)
+		return
+	}
+
+	// The campaign was paused. Retain the checkpoint to resume from.
+	if p.paused.Load() {
+		p.m.pipesMut.Lock()
+		p.m.checkpoints[p.camp.ID] = p.lastID.Load()
+		p.m.pipesMut.Unlock()
+
+		p.m.log.Printf("paused campaign (%s)", p.camp.Name)
+		return
+	}
+
 	// The campaign was auto-paused due to errors.
 	if p.withErrors.Load() {
 		if err := p.m.store.UpdateCampaignStatus(p.camp.ID, models.CampaignStatusPaused); err != nil {
@@ -215,6 +304,13 @@ func (p *pipe) cleanup() {
 		return
 	}
 
+	// The manager is shutting down. Leave the campaign's status as-is for it
+	// to be picked up again from its checkpoint on the next start.
+	if p.m.closing.Load() {
+		p.m.log.Printf("stop processing campaign (%s) on shutdown", p.camp.Name)
+		return
+	}
+
 	// Campaign wasn't manually stopped and subscribers were naturally exhausted.
 	// Fetch the up-to-date campaign status from the DB.
 	c, err := p.m.store.GetCampaign(p.camp.ID)
@@ -223,18 +319,43 @@ func (p *pipe) cleanup() {
 		return
 	}
 
-	// If a running campaign has exhausted subscribers, it's finished.
+	// If a running campaign has exhausted subscribers, it's finished. The
+	// update is conditional as the status may have been changed (eg: paused)
+	// since it was fetched, in which case that change is kept.
 	if c.Status == models.CampaignStatusRunning || c.Status == models.CampaignStatusScheduled {
-		c.Status = models.CampaignStatusFinished
-		if err := p.m.store.UpdateCampaignStatus(p.camp.ID, models.CampaignStatusFinished); err != nil {
+		ok, err := p.m.store.UpdateCampaignStatusFrom(p.camp.ID, finishableStatuses, models.CampaignStatusFinished)
+		if err != nil {
 			p.m.log.Printf("error finishing campaign (%s): %v", p.camp.Name, err)
+		} else if !ok {
+			p.m.log.Printf("campaign (%s) status changed before it could be finished", p.camp.Name)
+			return
 		} else {
+			c.Status = models.CampaignStatusFinished
 			p.m.log.Printf("campaign (%s) finished", p.camp.Name)
 		}
 	} else {
 		p.m.log.Printf("finish processing campaign (%s)", p.camp.Name)
 	}
 
+	// Save the campaign's content for the public archive.
+	if c.Status == models.CampaignStatusFinished && p.camp.Archive {
+		msg := CampaignMessage{Campaign: p.camp}
+		if err := json.Unmarshal(p.camp.ArchiveMeta, &msg.Subscriber); err != nil {
+			p.m.log.Printf("error reading campaign (%s) archive meta: %v", p.camp.Name, err)
+		} else if err := msg.render(); err != nil {
+			p.m.log.Printf("error rendering campaign (%s) for archive: %v", p.camp.Name, err)
+		} else if err := p.m.ArchiveCampaign(p.camp.ID, msg.body); err != nil {
+			p.m.log.Printf("error archiving campaign (%s): %v", p.camp.Name, err)
+		}
+	}
+
+	// Flag campaigns that went out to no one so that it doesn't go unnoticed.
+	reason := ""
+	if c.Status == models.CampaignStatusFinished && c.Sent == 0 {
+		p.m.log.Printf("campaign (%s) finished without any subscribers to send to", p.camp.Name)
+		reason = ReasonNoSubscribers
+	}
+
 	// Notify admin.
-	_ = p.m.sendNotif(c, c.Status, "")
+	_ = p.m.sendNotif(c, c.Status, reason)
 }
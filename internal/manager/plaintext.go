@@ -0,0 +1,50 @@
+package manager
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	reHTMLHidden   = regexp.MustCompile(`(?is)<(head|style|script|title)[^>]*>.*?</(head|style|script|title)>`)
+	reHTMLComment  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	reHTMLLink     = regexp.MustCompile(`(?is)<a\s[^>]*href\s*=\s*["']([^"']+)["'][^>]*>(.*?)</a>`)
+	reHTMLListItem = regexp.MustCompile(`(?i)<li[^>]*>`)
+	reHTMLBreak    = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|h[1-6]|li|tr|table|blockquote)>`)
+	reHTMLTag      = regexp.MustCompile(`(?s)<[^>]*>`)
+	reSpaces       = regexp.MustCompile(`[ \t\r\f\v]+`)
+	reBlankLines   = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToText converts an HTML message body into a plain text alt body. Tags
+// are stripped, block elements are turned into line breaks, and links retain
+// their URLs as "text (url)".
+func htmlToText(b []byte) []byte {
+	s := reHTMLHidden.ReplaceAllString(string(b), "")
+	s = reHTMLComment.ReplaceAllString(s, "")
+
+	s = reHTMLLink.ReplaceAllStringFunc(s, func(a string) string {
+		m := reHTMLLink.FindStringSubmatch(a)
+		url, text := m[1], strings.TrimSpace(reHTMLTag.ReplaceAllString(m[2], ""))
+		if text == "" || text == url {
+			return url
+		}
+		return text + " (" + url + ")"
+	})
+
+	s = reHTMLListItem.ReplaceAllString(s, "- ")
+	s = reHTMLBreak.ReplaceAllString(s, "\n")
+	s = reHTMLTag.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+
+	// Tidy up the whitespace left behind by the markup.
+	s = reSpaces.ReplaceAllString(s, " ")
+	lines := strings.Split(s, "\n")
+	for i, l := range lines {
+		lines[i] = strings.TrimSpace(l)
+	}
+	s = reBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+
+	return []byte(strings.TrimSpace(s))
+}
@@ -0,0 +1,59 @@
+package manager
+
+import (
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestHTMLToText(t *testing.T) {
+	in := `<html><head><title>Hi</title><style>p { color: red; }</style></head>
+<body>
+<!-- preheader -->
+<h1>Hello &amp; welcome</h1>
+<p>Read   the <a href="https://listmonk.app/docs">docs</a> or visit <a href="https://listmonk.app">https://listmonk.app</a>.</p>
+<ul><li>One</li><li>Two</li></ul>
+<p>Bye<br/>Team</p>
+<script>alert(1)</script>
+</body></html>`
+
+	want := "Hello & welcome\n\nRead the docs (https://listmonk.app/docs) or visit https://listmonk.app.\n\n- One\n- Two\n\nBye\nTeam"
+	if got := string(htmlToText([]byte(in))); got != want {
+		t.Errorf("unexpected text:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestAutoPlainText(t *testing.T) {
+	cfg := testConfig()
+	cfg.AutoPlainText = true
+
+	st := newTestStore()
+	m, err := New(cfg, st, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := st.addCampaign(legacyTenantID, 1, 1, "email")
+	c.Body = `<p>Hello <b>{{ .Subscriber.Email }}</b></p>`
+	if err := c.CompileTemplate(m.TemplateFuncs(c)); err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := m.NewCampaignMessage(c, st.subs[1][0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if alt := string(msg.AltBody()); alt != "Hello sub1@camp1.test" {
+		t.Errorf("unexpected alt body: %q", alt)
+	}
+
+	// Plain text campaigns are left as-is.
+	c.ContentType = models.CampaignContentTypePlain
+	c.Body = "Hello"
+	if err := c.CompileTemplate(m.TemplateFuncs(c)); err != nil {
+		t.Fatal(err)
+	}
+	if msg, _ := m.NewCampaignMessage(c, st.subs[1][0]); len(msg.AltBody()) != 0 {
+		t.Errorf("expected no alt body for a plain text campaign, got %q", msg.AltBody())
+	}
+}
@@ -0,0 +1,47 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestReloadTenantConfigRate(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+
+	st := newTestStore()
+	st.settings[2] = map[string]interface{}{"message_rate": float64(1)}
+	tm, msgr := newTestTenantManager(t, cfg, st)
+
+	// Tenants without a running instance have nothing to reload.
+	if err := tm.ReloadTenantConfig(3); err != nil {
+		t.Fatal(err)
+	}
+
+	tim := startTenant(t, tm, 2)
+	if r := tim.config().TenantMessageRate; r != 1 {
+		t.Fatalf("expected the tenant's message rate to be 1, got %d", r)
+	}
+
+	// At one message a second, the campaign would take five seconds.
+	startPipe(t, tim, st.addCampaign(2, 1, 6, "email"))
+	waitFor(t, time.Second, "the first message", func() bool { return len(msgr.Sent()) >= 1 })
+
+	st.mut.Lock()
+	st.settings[2]["message_rate"] = float64(1000)
+	st.mut.Unlock()
+	if err := tm.ReloadTenantConfig(2); err != nil {
+		t.Fatal(err)
+	}
+	if r := tim.config().TenantMessageRate; r != 1000 {
+		t.Fatalf("expected the reloaded message rate to be 1000, got %d", r)
+	}
+
+	// The running campaign picks up the new rate without being restarted.
+	waitFor(t, time.Second*2, "the campaign to finish at the new rate", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(msgr.Sent()); n != 6 {
+		t.Errorf("expected 6 messages, got %d", n)
+	}
+}
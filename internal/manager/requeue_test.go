@@ -0,0 +1,75 @@
+package manager
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// failTimes returns a MemoryMessenger failure function that fails the
+// first n attempts.
+func failTimes(n int) func(models.Message) error {
+	var (
+		attempts int
+		mut      sync.Mutex
+	)
+	return func(models.Message) error {
+		mut.Lock()
+		defer mut.Unlock()
+
+		attempts++
+		if attempts <= n {
+			return errors.New("temporary failure")
+		}
+		return nil
+	}
+}
+
+func TestRequeueOnError(t *testing.T) {
+	cfg := testConfig()
+	cfg.RequeueOnError = true
+
+	st := newTestStore()
+	m, msgr := newTestManager(t, cfg, st)
+	msgr.FailWith(failTimes(2))
+
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 1, "email"))
+
+	waitFor(t, time.Second*5, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(msgr.Sent()); n != 1 {
+		t.Fatalf("expected the message to be sent on the third attempt, got %d messages", n)
+	}
+	if n := m.sent.Load(); n != 1 {
+		t.Errorf("expected 1 message counted as sent, got %d", n)
+	}
+	if c, _ := st.GetCampaign(1); c.Sent != 1 {
+		t.Errorf("expected the campaign's sent count to be 1, got %d", c.Sent)
+	}
+}
+
+func TestTenantRequeueOnError(t *testing.T) {
+	cfg := testConfig()
+	cfg.RequeueOnError = true
+
+	st := newTestStore()
+	tm, msgr := newTestTenantManager(t, cfg, st)
+	msgr.FailWith(failTimes(2))
+
+	c := st.addCampaign(2, 1, 1, "email")
+	tim := startTenant(t, tm, 2)
+	startPipe(t, tim, c)
+
+	waitFor(t, time.Second*5, "the campaign to finish", func() bool { return st.status(1) == models.CampaignStatusFinished })
+	if n := len(msgr.Sent()); n != 1 {
+		t.Fatalf("expected the message to be sent on the third attempt, got %d messages", n)
+	}
+	if n := tim.sent.Load(); n != 1 {
+		t.Errorf("expected 1 message counted as sent, got %d", n)
+	}
+	if n, _ := tim.errorStats(); n != 0 {
+		t.Errorf("expected no errors to be counted, got %d", n)
+	}
+}
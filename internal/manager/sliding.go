@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+// slidingWindow keeps track of the total number of messages sent in a period
+// and on reaching the specified limit, makes callers wait until the window is
+// over before sending further messages. It is shared by all the pipes of
+// a manager (or a tenant instance) and is safe for concurrent use.
+type slidingWindow struct {
+	rate     int
+	duration time.Duration
+
+	count int
+	start time.Time
+	mut   sync.Mutex
+}
+
+// newSlidingWindow returns a sliding window limiter for the given config.
+// If the sliding window is not enabled in the config, nil is returned, which
+// is a valid no-op limiter.
+func newSlidingWindow(cfg Config) *slidingWindow {
+	if !cfg.SlidingWindow || cfg.SlidingWindowRate < 1 || cfg.SlidingWindowDuration.Seconds() <= 1 {
+		return nil
+	}
+
+	return &slidingWindow{
+		rate:     cfg.SlidingWindowRate,
+		duration: cfg.SlidingWindowDuration,
+		start:    time.Now(),
+	}
+}
+
+// reserve attempts to reserve a slot for one message in the current window.
+// If there's room, it returns 0. Otherwise, it returns the duration to wait
+// until the current window expires along with the window's start time.
+func (w *slidingWindow) reserve() (time.Duration, time.Time) {
+	if w == nil {
+		return 0, time.Time{}
+	}
+
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	// Window has expired. Reset the clock.
+	diff := time.Since(w.start)
+	if diff >= w.duration {
+		w.start = time.Now()
+		w.count = 0
+		diff = 0
+	}
+
+	if w.count < w.rate {
+		w.count++
+		return 0, time.Time{}
+	}
+
+	return w.duration - diff, w.start
+}
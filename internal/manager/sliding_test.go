@@ -0,0 +1,71 @@
+package manager
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSlidingWindowConcurrent(t *testing.T) {
+	w := newSlidingWindow(Config{SlidingWindow: true, SlidingWindowRate: 10, SlidingWindowDuration: time.Minute})
+
+	var (
+		wg      sync.WaitGroup
+		mut     sync.Mutex
+		allowed int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				if wait, _ := w.reserve(); wait == 0 {
+					mut.Lock()
+					allowed++
+					mut.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 10 {
+		t.Errorf("expected 10 messages in the window, got %d", allowed)
+	}
+}
+
+func TestTenantSlidingWindowAcrossPipes(t *testing.T) {
+	cfg := testConfig()
+	cfg.SlidingWindow = true
+	cfg.SlidingWindowRate = 5
+	cfg.SlidingWindowDuration = time.Second * 2
+
+	st := newTestStore()
+	tm, msgr := newTestTenantManager(t, cfg, st)
+
+	camps := []int{1, 2, 3}
+	for _, id := range camps {
+		st.addCampaign(2, id, 10, "email")
+	}
+
+	tim := startTenant(t, tm, 2)
+	start := time.Now()
+	for _, id := range camps {
+		c, _ := st.GetTenantCampaign(2, id)
+		startPipe(t, tim, c)
+	}
+
+	// The three pipes share the tenant's window.
+	waitFor(t, time.Second, "the first window's messages", func() bool { return len(msgr.Sent()) >= cfg.SlidingWindowRate })
+	time.Sleep(cfg.SlidingWindowDuration - time.Since(start) - time.Millisecond*200)
+	if n := len(msgr.Sent()); n != cfg.SlidingWindowRate {
+		t.Fatalf("expected %d messages in the first window, got %d", cfg.SlidingWindowRate, n)
+	}
+
+	// The next window.
+	waitFor(t, cfg.SlidingWindowDuration, "the second window's messages", func() bool { return len(msgr.Sent()) >= cfg.SlidingWindowRate*2 })
+	time.Sleep(time.Millisecond * 200)
+	if n := len(msgr.Sent()); n > cfg.SlidingWindowRate*2 {
+		t.Errorf("expected at most %d messages in two windows, got %d", cfg.SlidingWindowRate*2, n)
+	}
+}
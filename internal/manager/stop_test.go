@@ -0,0 +1,58 @@
+package manager
+
+import (
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+func TestStopAllTenantCampaigns(t *testing.T) {
+	cfg := testConfig()
+	cfg.Concurrency = 1
+
+	st := newTestStore()
+	g2, g3 := newGatedMessenger("gated2"), newGatedMessenger("gated3")
+	tm, _ := newTestTenantManager(t, cfg, st, g2, g3)
+
+	// Tenant 2 has two campaigns and tenant 3 has one.
+	tim2, tim3 := startTenant(t, tm, 2), startTenant(t, tm, 3)
+	startPipe(t, tim2, st.addCampaign(2, 1, 20, "gated2"))
+	startPipe(t, tim2, st.addCampaign(2, 2, 20, "gated2"))
+	startPipe(t, tim3, st.addCampaign(3, 3, 20, "gated3"))
+
+	g2.allow(t, 4)
+	g3.allow(t, 2)
+	if err := tm.StopAllTenantCampaigns(2); err != nil {
+		t.Fatal(err)
+	}
+	close(g2.gate)
+
+	waitFor(t, time.Second*2, "tenant 2's campaigns to stop", func() bool { return len(tm.RunningCampaigns(2)) == 0 })
+	for _, id := range []int{1, 2} {
+		if s := st.status(id); s != models.CampaignStatusPaused {
+			t.Errorf("expected campaign %d to be paused, got %s", id, s)
+		}
+	}
+
+	// The messages that the worker was pushing when the campaigns were
+	// stopped may go out, but nothing after that.
+	n := len(g2.Sent())
+	if n > 5 {
+		t.Errorf("expected no sends after stopping, got %d messages", n)
+	}
+	time.Sleep(time.Millisecond * 100)
+	if len(g2.Sent()) != n {
+		t.Errorf("messages were sent after tenant 2's campaigns were stopped")
+	}
+
+	// The other tenant's campaign keeps running to the end.
+	if rc := tm.RunningCampaigns(3); len(rc) != 1 || st.status(3) != models.CampaignStatusRunning {
+		t.Fatalf("expected tenant 3's campaign to be running, got %v (%s)", rc, st.status(3))
+	}
+	close(g3.gate)
+	waitFor(t, time.Second*2, "tenant 3's campaign to finish", func() bool { return st.status(3) == models.CampaignStatusFinished })
+	if n := len(g3.Sent()); n != 20 {
+		t.Errorf("expected tenant 3's 20 messages, got %d", n)
+	}
+}
@@ -0,0 +1,519 @@
+package manager
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// testStore is an in-memory TenantStore for the tests. Every campaign belongs
+// to a tenant, and like the DB, fetching a campaign's subscribers moves its
+// checkpoint ahead, which UpdateTenantCampaignCounts() sets (or rewinds).
+type testStore struct {
+	camps      map[int]*models.Campaign
+	campTenant map[int]int
+	subs       map[int][]models.Subscriber
+	cursor     map[int]int
+	settings   map[int]map[string]interface{}
+	features   map[int]models.TenantFeatures
+	usage      map[int]TenantUsage
+	media      map[int]models.Attachment
+	links      map[string]string
+	archives   map[int][]byte
+	bounces    map[int]int
+	blocked    []int64
+
+	// afterGet, if set, is called after a campaign is fetched.
+	afterGet func(campID int)
+
+	// Number of calls to each method.
+	calls map[string]int
+
+	mut sync.Mutex
+}
+
+func newTestStore() *testStore {
+	return &testStore{
+		camps:      make(map[int]*models.Campaign),
+		campTenant: make(map[int]int),
+		subs:       make(map[int][]models.Subscriber),
+		cursor:     make(map[int]int),
+		settings:   make(map[int]map[string]interface{}),
+		features:   make(map[int]models.TenantFeatures),
+		usage:      make(map[int]TenantUsage),
+		media:      make(map[int]models.Attachment),
+		links:      make(map[string]string),
+		archives:   make(map[int][]byte),
+		bounces:    make(map[int]int),
+		calls:      make(map[string]int),
+	}
+}
+
+// addCampaign adds a running campaign with n subscribers (IDs 1..n) to a
+// tenant and returns it.
+func (s *testStore) addCampaign(tenantID, id, n int, messenger string) *models.Campaign {
+	c := &models.Campaign{
+		UUID:        fmt.Sprintf("camp-%d", id),
+		Name:        fmt.Sprintf("campaign-%d", id),
+		Subject:     "Hello",
+		Body:        "Hello {{ .Subscriber.Email }}",
+		ContentType: models.CampaignContentTypeHTML,
+		Status:      models.CampaignStatusRunning,
+		Messenger:   messenger,
+	}
+	c.ID = id
+
+	subs := make([]models.Subscriber, 0, n)
+	for i := 1; i <= n; i++ {
+		sub := models.Subscriber{UUID: fmt.Sprintf("sub-%d-%d", id, i), Email: fmt.Sprintf("sub%d@camp%d.test", i, id)}
+		sub.ID = i
+		subs = append(subs, sub)
+	}
+
+	s.mut.Lock()
+	s.camps[id] = c
+	s.campTenant[id] = tenantID
+	s.subs[id] = subs
+	if _, ok := s.settings[tenantID]; !ok {
+		s.settings[tenantID] = map[string]interface{}{}
+	}
+	s.mut.Unlock()
+
+	// The pipes compile the templates on the campaign they're given.
+	cp := *c
+	return &cp
+}
+
+func (s *testStore) count(name string) int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.calls[name]
+}
+
+func (s *testStore) status(campID int) string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.camps[campID].Status
+}
+
+func (s *testStore) checkpoint(campID int) int {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.cursor[campID]
+}
+
+func (s *testStore) setStatus(campID int, status string) {
+	s.mut.Lock()
+	s.camps[campID].Status = status
+	s.mut.Unlock()
+}
+
+func (s *testStore) call(name string) {
+	s.mut.Lock()
+	s.calls[name]++
+	s.mut.Unlock()
+}
+
+func (s *testStore) NextCampaigns(currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error) {
+	return s.NextTenantCampaigns(legacyTenantID, currentIDs, sentCounts)
+}
+
+func (s *testStore) NextSubscribers(ctx context.Context, campID, limit int) ([]models.Subscriber, error) {
+	return s.NextTenantSubscribers(ctx, legacyTenantID, campID, limit)
+}
+
+func (s *testStore) GetCampaign(campID int) (*models.Campaign, error) {
+	return s.GetTenantCampaign(legacyTenantID, campID)
+}
+
+func (s *testStore) GetAttachment(mediaID int) (models.Attachment, error) {
+	s.call("GetAttachment")
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	a, ok := s.media[mediaID]
+	if !ok {
+		return a, sql.ErrNoRows
+	}
+	return a, nil
+}
+
+func (s *testStore) UpdateCampaignStatus(campID int, status string) error {
+	return s.UpdateTenantCampaignStatus(legacyTenantID, campID, status)
+}
+
+func (s *testStore) UpdateCampaignStatusFrom(campID int, from []string, status string) (bool, error) {
+	return s.UpdateTenantCampaignStatusFrom(legacyTenantID, campID, from, status)
+}
+
+func (s *testStore) UpdateCampaignCounts(campID int, toSend int, sent int, lastSubID int) error {
+	return s.UpdateTenantCampaignCounts(legacyTenantID, campID, toSend, sent, lastSubID)
+}
+
+func (s *testStore) CreateLink(url string) (string, error) {
+	return s.CreateTenantLink(legacyTenantID, url)
+}
+
+func (s *testStore) GetLinks(campUUIDs []string) (map[string]string, error) {
+	s.call("GetLinks")
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	out := make(map[string]string, len(s.links))
+	for url, uu := range s.links {
+		out[url] = uu
+	}
+	return out, nil
+}
+
+func (s *testStore) BlocklistSubscriber(id int64) error {
+	return s.BlocklistTenantSubscriber(legacyTenantID, id)
+}
+
+func (s *testStore) DeleteSubscriber(id int64) error {
+	return s.DeleteTenantSubscriber(legacyTenantID, id)
+}
+
+func (s *testStore) SaveArchive(campID int, body []byte) error {
+	return s.SaveTenantArchive(legacyTenantID, campID, body)
+}
+
+func (s *testStore) NextTenantCampaigns(tenantID int, currentIDs []int64, sentCounts []int64) ([]*models.Campaign, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var out []*models.Campaign
+	for id, c := range s.camps {
+		if s.campTenant[id] != tenantID || c.Status != models.CampaignStatusRunning || slices.Contains(currentIDs, int64(id)) {
+			continue
+		}
+		cp := *c
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (s *testStore) NextTenantSubscribers(ctx context.Context, tenantID, campID, limit int) ([]models.Subscriber, error) {
+	s.call("NextTenantSubscribers")
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	c, ok := s.camps[campID]
+	if !ok || s.campTenant[campID] != tenantID || c.Status != models.CampaignStatusRunning {
+		return nil, nil
+	}
+
+	var out []models.Subscriber
+	for _, sub := range s.subs[campID] {
+		if sub.ID > s.cursor[campID] && len(out) < limit {
+			out = append(out, sub)
+		}
+	}
+	if len(out) > 0 {
+		s.cursor[campID] = out[len(out)-1].ID
+	}
+	return out, nil
+}
+
+func (s *testStore) GetTenantCampaign(tenantID, campID int) (*models.Campaign, error) {
+	s.mut.Lock()
+	c, ok := s.camps[campID]
+	if !ok || s.campTenant[campID] != tenantID {
+		s.mut.Unlock()
+		return nil, sql.ErrNoRows
+	}
+	cp, fn := *c, s.afterGet
+	s.mut.Unlock()
+
+	if fn != nil {
+		fn(campID)
+	}
+	return &cp, nil
+}
+
+func (s *testStore) GetTenantSettings(tenantID int) (map[string]interface{}, error) {
+	s.call("GetTenantSettings")
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	out := make(map[string]interface{}, len(s.settings[tenantID]))
+	for k, v := range s.settings[tenantID] {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (s *testStore) UpdateTenantCampaignStatus(tenantID, campID int, status string) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	c, ok := s.camps[campID]
+	if !ok || s.campTenant[campID] != tenantID {
+		return sql.ErrNoRows
+	}
+	c.Status = status
+	return nil
+}
+
+func (s *testStore) UpdateTenantCampaignStatusFrom(tenantID, campID int, from []string, status string) (bool, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	c, ok := s.camps[campID]
+	if !ok || s.campTenant[campID] != tenantID || !slices.Contains(from, c.Status) {
+		return false, nil
+	}
+	c.Status = status
+	return true, nil
+}
+
+func (s *testStore) UpdateTenantCampaignCounts(tenantID, campID int, toSend int, sent int, lastSubID int) error {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	c, ok := s.camps[campID]
+	if !ok || s.campTenant[campID] != tenantID {
+		return sql.ErrNoRows
+	}
+	c.Sent += sent
+	if lastSubID > 0 {
+		s.cursor[campID] = lastSubID
+	}
+	return nil
+}
+
+func (s *testStore) CreateTenantLink(tenantID int, url string) (string, error) {
+	s.call("CreateLink")
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if uu, ok := s.links[url]; ok {
+		return uu, nil
+	}
+	uu := fmt.Sprintf("link-%d", len(s.links)+1)
+	s.links[url] = uu
+	return uu, nil
+}
+
+func (s *testStore) BlocklistTenantSubscriber(tenantID int, id int64) error {
+	s.mut.Lock()
+	s.blocked = append(s.blocked, id)
+	s.mut.Unlock()
+	return nil
+}
+
+func (s *testStore) DeleteTenantSubscriber(tenantID int, id int64) error {
+	return nil
+}
+
+func (s *testStore) GetActiveTenantIDs() ([]int, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var out []int
+	for id, c := range s.camps {
+		if c.Status == models.CampaignStatusRunning && !slices.Contains(out, s.campTenant[id]) {
+			out = append(out, s.campTenant[id])
+		}
+	}
+	return out, nil
+}
+
+func (s *testStore) GetTenantFeatures(tenantID int) (models.TenantFeatures, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.features[tenantID], nil
+}
+
+func (s *testStore) SaveTenantArchive(tenantID, campID int, body []byte) error {
+	s.mut.Lock()
+	s.archives[campID] = body
+	s.mut.Unlock()
+	return nil
+}
+
+func (s *testStore) GetTenantUsage(tenantID int) (TenantUsage, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return s.usage[tenantID], nil
+}
+
+func (s *testStore) RecordTenantBounce(tenantID int, b models.Bounce) (int, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.bounces[b.SubscriberID]++
+	return s.bounces[b.SubscriberID], nil
+}
+
+func (s *testStore) GetTenantSuppressions(tenantID int) ([]string, error) {
+	return nil, nil
+}
+
+func (s *testStore) GetTenantAdminEmails(tenantID int) ([]string, error) {
+	return nil, nil
+}
+
+func (s *testStore) PauseTenantCampaigns(tenantID int) ([]int, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	var out []int
+	for id, c := range s.camps {
+		if s.campTenant[id] == tenantID && c.Status == models.CampaignStatusRunning {
+			c.Status = models.CampaignStatusPaused
+			out = append(out, id)
+		}
+	}
+	return out, nil
+}
+
+// testConfig returns a manager config for the tests that sends right away.
+func testConfig() Config {
+	return Config{
+		BatchSize:    10,
+		Concurrency:  4,
+		MessageRate:  1000,
+		UnsubURL:     "http://listmonk.test/unsub/%s/%s",
+		LinkTrackURL: "http://listmonk.test/link/%s/%s/%s",
+		RootURL:      "http://listmonk.test",
+		PushTimeout:  time.Second,
+		DrainTimeout: time.Second * 5,
+	}
+}
+
+func testLogger() *log.Logger {
+	return log.New(io.Discard, "", 0)
+}
+
+// newTestTenantManager returns a tenant manager on the store with a memory
+// messenger named "email" and any other given messengers. The manager isn't
+// run. Tenant instances are started with startTenant().
+func newTestTenantManager(t *testing.T, cfg Config, st *testStore, extra ...Messenger) (*TenantManager, *MemoryMessenger) {
+	t.Helper()
+
+	tm := NewTenantManager(cfg, st, nil, testLogger())
+	msgr := NewMemoryMessenger("email")
+	for _, m := range append([]Messenger{msgr}, extra...) {
+		if err := tm.AddMessenger(m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() { tm.Close() })
+
+	return tm, msgr
+}
+
+// startTenant creates and starts a tenant's instance.
+func startTenant(t *testing.T, tm *TenantManager, tenantID int) *tenantInstanceManager {
+	t.Helper()
+
+	tm.tenantManagersMut.Lock()
+	defer tm.tenantManagersMut.Unlock()
+
+	if err := tm.createTenantInstance(tenantID); err != nil {
+		t.Fatal(err)
+	}
+	return tm.tenantManagers[tenantID]
+}
+
+// startPipe creates a pipe for a tenant's campaign and queues it for processing.
+func startPipe(t *testing.T, tim *tenantInstanceManager, c *models.Campaign) *tenantPipe {
+	t.Helper()
+
+	tp, err := tim.newTenantPipe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tim.nextPipes <- tp
+	return tp
+}
+
+// newTestManager returns a single-tenant manager on the store with a memory
+// messenger named "email" and any other given messengers that's running
+// with its workers.
+func newTestManager(t *testing.T, cfg Config, st *testStore, extra ...Messenger) (*Manager, *MemoryMessenger) {
+	t.Helper()
+
+	m, err := New(cfg, st, nil, testLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.fnNotify = func(subject string, data any) error { return nil }
+
+	msgr := NewMemoryMessenger("email")
+	for _, mg := range append([]Messenger{msgr}, extra...) {
+		if err := m.AddMessenger(mg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	go m.Run()
+	t.Cleanup(func() { m.Close() })
+
+	return m, msgr
+}
+
+// startManagerPipe creates a pipe for a campaign and queues it for processing.
+func startManagerPipe(t *testing.T, m *Manager, c *models.Campaign) *pipe {
+	t.Helper()
+
+	p, err := m.newPipe(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.nextPipes <- p
+	return p
+}
+
+// gatedMessenger is a memory messenger whose pushes block until they're let
+// through the gate, one per value sent on it, or all once it's closed.
+type gatedMessenger struct {
+	*MemoryMessenger
+	gate chan struct{}
+}
+
+func newGatedMessenger(name string) *gatedMessenger {
+	return &gatedMessenger{MemoryMessenger: NewMemoryMessenger(name), gate: make(chan struct{})}
+}
+
+func (g *gatedMessenger) Push(msg models.Message) error {
+	<-g.gate
+	return g.MemoryMessenger.Push(msg)
+}
+
+// allow lets n pushes through and waits for them to be recorded.
+func (g *gatedMessenger) allow(t *testing.T, n int) {
+	t.Helper()
+
+	want := len(g.Sent()) + n
+	for i := 0; i < n; i++ {
+		g.gate <- struct{}{}
+	}
+	waitFor(t, time.Second, "the messages to be pushed", func() bool { return len(g.Sent()) >= want })
+}
+
+// waitFor polls fn until it returns true or fails the test after the timeout.
+func waitFor(t *testing.T, timeout time.Duration, what string, fn func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for !fn() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond * 5)
+	}
+}
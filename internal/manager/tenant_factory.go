@@ -3,6 +3,7 @@ package manager
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/knadh/listmonk/internal/i18n"
 	"github.com/knadh/listmonk/models"
@@ -48,7 +49,7 @@ func (mf *ManagerFactory) CreateManager() (interface{}, error) {
 			return nil, fmt.Errorf("store is required for single-tenant mode")
 		}
 		mf.log.Printf("creating single-tenant campaign manager")
-		return New(mf.cfg, mf.store, mf.i18n, mf.log), nil
+		return New(mf.cfg, mf.store, mf.i18n, mf.log)
 	
 	case MultiTenantMode:
 		if mf.tenantStore == nil {
@@ -76,9 +77,13 @@ func DetermineMode(cfg Config, hasMultiTenancy bool) ManagerMode {
 // ManagerInterface defines common methods that both Manager and TenantManager should implement
 type ManagerInterface interface {
 	Run()
-	Close()
+	Close() error
 	AddMessenger(msg Messenger) error
 	HasRunningCampaigns() bool
+
+	// StopTenantCampaign stops a tenant's running campaign. The single-tenant
+	// Manager ignores the tenant ID.
+	StopTenantCampaign(tenantID, campID int)
 }
 
 // Ensure both managers implement the interface
@@ -88,7 +93,6 @@ var _ ManagerInterface = (*Manager)(nil)
 type TenantManagerInterface interface {
 	ManagerInterface
 	GetTenantCampaignStats(tenantID, campID int) CampStats
-	StopTenantCampaign(tenantID, campID int)
 }
 
 // Ensure TenantManager implements the extended interface
@@ -148,14 +152,39 @@ func NewTenantLimitsEnforcer(store TenantStore) *TenantLimitsEnforcer {
 	}
 }
 
-// CanProcessCampaign checks if a tenant can process campaigns based on their limits
+// CanProcessCampaign checks if a tenant can process campaigns based on their
+// monthly campaign and subscriber limits. If features is nil, the tenant's
+// features are fetched from the store. If the tenant can't process campaigns,
+// the reason is returned.
 func (tle *TenantLimitsEnforcer) CanProcessCampaign(tenantID int, features *models.TenantFeatures) (bool, string) {
-	// Check if tenant has campaign processing enabled
-	if features != nil && !features.WebhooksEnabled {
-		return false, "campaign processing disabled for tenant"
+	if features == nil {
+		f, err := tle.store.GetTenantFeatures(tenantID)
+		if err != nil {
+			return false, fmt.Sprintf("error fetching tenant features: %v", err)
+		}
+		features = &f
+	}
+
+	// No limits to enforce.
+	if features.MaxCampaignsPerMonth <= 0 && features.MaxSubscribers <= 0 {
+		return true, ""
+	}
+
+	usage, err := tle.store.GetTenantUsage(tenantID)
+	if err != nil {
+		return false, fmt.Sprintf("error fetching tenant usage: %v", err)
+	}
+
+	// Campaigns are counted when they're created, so the limit is only
+	// exceeded if there are more campaigns than allowed.
+	if features.MaxCampaignsPerMonth > 0 && usage.CampaignsThisMonth > features.MaxCampaignsPerMonth {
+		return false, fmt.Sprintf("monthly campaign limit exceeded (%d/%d)", usage.CampaignsThisMonth, features.MaxCampaignsPerMonth)
+	}
+
+	if features.MaxSubscribers > 0 && usage.Subscribers > features.MaxSubscribers {
+		return false, fmt.Sprintf("subscriber limit exceeded (%d/%d)", usage.Subscribers, features.MaxSubscribers)
 	}
 
-	// Add more limit checks as needed
 	return true, ""
 }
 
@@ -175,6 +204,19 @@ type MultiTenantConfig struct {
 	
 	// Default tenant limits
 	DefaultTenantLimits models.TenantFeatures
+
+	// Default limits of tenants on specific plans (models.Tenant.Plan).
+	// Plans that aren't here get DefaultTenantLimits.
+	PlanLimits map[string]models.TenantFeatures
+}
+
+// FeaturesForPlan returns the default features and limits of new tenants
+// on the given plan.
+func (c MultiTenantConfig) FeaturesForPlan(plan string) models.TenantFeatures {
+	if f, ok := c.PlanLimits[plan]; ok {
+		return f
+	}
+	return c.DefaultTenantLimits
 }
 
 // DefaultMultiTenantConfig returns sensible defaults for multi-tenant configuration
@@ -195,21 +237,46 @@ func DefaultMultiTenantConfig() MultiTenantConfig {
 			WebhooksEnabled:     true,
 			AdvancedAnalytics:   false,
 		},
+		PlanLimits: map[string]models.TenantFeatures{
+			"pro": {
+				MaxSubscribers:       100000,
+				MaxCampaignsPerMonth: 500,
+				MaxLists:             100,
+				MaxTemplates:         50,
+				MaxUsers:             25,
+				CustomDomain:         true,
+				APIAccess:            true,
+				WebhooksEnabled:      true,
+				AdvancedAnalytics:    true,
+			},
+		},
 	}
 }
 
+// defaultWorkerStallTimeout is the duration for which a tenant's workers can
+// go without picking up queued messages before they're reported as stalled.
+const defaultWorkerStallTimeout = 2 * time.Minute
+
 // ManagerHealthChecker provides health checking for both manager types
 type ManagerHealthChecker struct {
-	manager interface{}
+	manager      interface{}
+	stallTimeout time.Duration
 }
 
 // NewManagerHealthChecker creates a health checker for any manager type
 func NewManagerHealthChecker(manager interface{}) *ManagerHealthChecker {
 	return &ManagerHealthChecker{
-		manager: manager,
+		manager:      manager,
+		stallTimeout: defaultWorkerStallTimeout,
 	}
 }
 
+// SetStallTimeout sets the duration for which a tenant's workers can go without
+// picking up queued messages before the tenant is reported as unhealthy.
+func (mhc *ManagerHealthChecker) SetStallTimeout(d time.Duration) {
+	mhc.stallTimeout = d
+}
+
 // CheckHealth performs health checks on the manager
 func (mhc *ManagerHealthChecker) CheckHealth() map[string]interface{} {
 	health := make(map[string]interface{})
@@ -229,15 +296,23 @@ func (mhc *ManagerHealthChecker) CheckHealth() map[string]interface{} {
 		m.tenantManagersMut.RLock()
 		tenantCount := len(m.tenantManagers)
 		activeTenants := 0
+		stalled := []int{}
 		for _, tim := range m.tenantManagers {
 			if tim.IsActive() {
 				activeTenants++
 			}
+
+			// Workers that have queued messages but haven't picked any up are stuck.
+			if tim.isStalled(mhc.stallTimeout) {
+				stalled = append(stalled, tim.tenantID)
+			}
 		}
 		m.tenantManagersMut.RUnlock()
 		
 		health["total_tenants"] = tenantCount
 		health["active_tenants"] = activeTenants
+		health["stalled_tenants"] = stalled
+		health["healthy"] = len(stalled) == 0
 		
 	default:
 		health["type"] = "unknown"
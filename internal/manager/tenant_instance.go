@@ -4,14 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"html/template"
+	"net/mail"
 	"net/textproto"
 	"strings"
-	"sync"
 	"time"
 
 	"maps"
 
-	"github.com/knadh/listmonk/internal/notifs"
 	"github.com/knadh/listmonk/models"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -22,6 +21,10 @@ import (
 // AddMessenger adds a messenger to this tenant instance
 func (tim *tenantInstanceManager) AddMessenger(msg Messenger) error {
 	id := msg.Name()
+
+	tim.messengersMut.Lock()
+	defer tim.messengersMut.Unlock()
+
 	if _, ok := tim.messengers[id]; ok {
 		return fmt.Errorf("messenger '%s' is already loaded for tenant %d", id, tim.tenantID)
 	}
@@ -29,6 +32,31 @@ func (tim *tenantInstanceManager) AddMessenger(msg Messenger) error {
 	return nil
 }
 
+// getMessenger returns the named messenger of this tenant instance
+func (tim *tenantInstanceManager) getMessenger(name string) (Messenger, bool) {
+	tim.messengersMut.RLock()
+	m, ok := tim.messengers[name]
+	tim.messengersMut.RUnlock()
+	return m, ok
+}
+
+// config returns the tenant instance's current config
+func (tim *tenantInstanceManager) config() *TenantConfig {
+	tim.cfgMut.RLock()
+	defer tim.cfgMut.RUnlock()
+	return tim.cfg
+}
+
+// ReloadConfig swaps the tenant instance's config for the given one. Changes
+// to the message rate, batch size, error threshold, sender, headers, URLs,
+// and webhooks take effect immediately. The number of workers, the queue
+// sizes, and the rate windows are only applied when the instance is recreated
+func (tim *tenantInstanceManager) ReloadConfig(cfg TenantConfig) {
+	tim.cfgMut.Lock()
+	tim.cfg = &cfg
+	tim.cfgMut.Unlock()
+}
+
 // IsActive checks if this tenant instance is active
 func (tim *tenantInstanceManager) IsActive() bool {
 	tim.activeMut.RLock()
@@ -38,15 +66,34 @@ func (tim *tenantInstanceManager) IsActive() bool {
 
 // HasRunningCampaigns checks if this tenant has active campaigns
 func (tim *tenantInstanceManager) HasRunningCampaigns() bool {
-	tim.pipesMut.Lock()
-	defer tim.pipesMut.Unlock()
+	tim.pipesMut.RLock()
+	defer tim.pipesMut.RUnlock()
 	return len(tim.pipes) > 0
 }
 
+// recordError counts a send error across the tenant's campaigns and records
+// it as the last error.
+func (tim *tenantInstanceManager) recordError(msg string) {
+	tim.errors.Add(1)
+
+	tim.lastErrMut.Lock()
+	tim.lastErr = msg
+	tim.lastErrMut.Unlock()
+}
+
+// errorStats returns the number of send errors across the tenant's
+// campaigns and the last error message.
+func (tim *tenantInstanceManager) errorStats() (int, string) {
+	tim.lastErrMut.Lock()
+	defer tim.lastErrMut.Unlock()
+
+	return int(tim.errors.Load()), tim.lastErr
+}
+
 // GetCampaignStats returns campaign stats for this tenant
 func (tim *tenantInstanceManager) GetCampaignStats(id int) CampStats {
-	tim.pipesMut.Lock()
-	defer tim.pipesMut.Unlock()
+	tim.pipesMut.RLock()
+	defer tim.pipesMut.RUnlock()
 
 	if p, ok := tim.pipes[id]; ok {
 		return CampStats{SendRate: int(p.rate.Rate())}
@@ -54,6 +101,41 @@ func (tim *tenantInstanceManager) GetCampaignStats(id int) CampStats {
 	return CampStats{SendRate: 0}
 }
 
+// RunningCampaigns returns the tenant's campaigns that are currently being processed
+func (tim *tenantInstanceManager) RunningCampaigns() []RunningCampaign {
+	tim.pipesMut.RLock()
+	defer tim.pipesMut.RUnlock()
+
+	out := make([]RunningCampaign, 0, len(tim.pipes))
+	for _, p := range tim.pipes {
+		out = append(out, RunningCampaign{
+			ID:        p.camp.ID,
+			Name:      p.camp.Name,
+			Sent:      p.camp.Sent + int(p.sent.Load()),
+			SendRate:  int(p.rate.Rate()),
+			StartedAt: p.started,
+		})
+	}
+
+	return out
+}
+
+// load returns a snapshot of the instance's queue pressure.
+func (tim *tenantInstanceManager) load() TenantLoad {
+	tim.pipesMut.RLock()
+	pipes := len(tim.pipes)
+	tim.pipesMut.RUnlock()
+
+	return TenantLoad{
+		CampaignQueue:    len(tim.campMsgQ),
+		CampaignQueueCap: cap(tim.campMsgQ),
+		MessageQueue:     len(tim.msgQ),
+		MessageQueueCap:  cap(tim.msgQ),
+		Pipes:            pipes,
+		Workers:          int(tim.workers.Load()),
+	}
+}
+
 // StopCampaign stops a campaign for this tenant
 func (tim *tenantInstanceManager) StopCampaign(id int) {
 	tim.pipesMut.RLock()
@@ -64,6 +146,127 @@ func (tim *tenantInstanceManager) StopCampaign(id int) {
 	}
 }
 
+// isStalled returns true if there are queued messages but the workers haven't
+// picked up any message for longer than timeout, indicating stuck workers
+func (tim *tenantInstanceManager) isStalled(timeout time.Duration) bool {
+	if len(tim.campMsgQ) == 0 && len(tim.msgQ) == 0 {
+		return false
+	}
+
+	last := time.Unix(0, tim.lastProcessedAt.Load())
+	return time.Since(last) > timeout
+}
+
+// StopAllCampaigns stops all of the running campaigns of this tenant, retaining
+// their progress
+func (tim *tenantInstanceManager) StopAllCampaigns() {
+	tim.pipesMut.RLock()
+	defer tim.pipesMut.RUnlock()
+
+	for _, p := range tim.pipes {
+		p.Pause()
+	}
+}
+
+// SetCampaignRate sets the messages per minute for a campaign of this tenant
+func (tim *tenantInstanceManager) SetCampaignRate(id, rate int) {
+	tim.pipesMut.Lock()
+	defer tim.pipesMut.Unlock()
+
+	if rate < 1 {
+		delete(tim.rates, id)
+	} else {
+		tim.rates[id] = rate
+	}
+
+	if tp, ok := tim.pipes[id]; ok {
+		tp.throttle.setRate(rate)
+	}
+}
+
+// PauseCampaign pauses a running campaign for this tenant, preserving its progress
+func (tim *tenantInstanceManager) PauseCampaign(id int) error {
+	tim.pipesMut.RLock()
+	tp, ok := tim.pipes[id]
+	tim.pipesMut.RUnlock()
+	if !ok {
+		return fmt.Errorf("campaign %d is not running for tenant %d", id, tim.tenantID)
+	}
+
+	// Update the status first so that the scanner doesn't pick the campaign up again
+	if err := tim.store.UpdateTenantCampaignStatus(tim.tenantID, id, models.CampaignStatusPaused); err != nil {
+		return err
+	}
+
+	tp.Pause()
+	tim.sendTenantWebhook(tp.camp, models.CampaignStatusPaused, "")
+	return nil
+}
+
+// CancelCampaign cancels a campaign for this tenant, setting its status right
+// away. If it's running, its pipe is stopped and its queued messages are dropped
+// by the workers. A paused campaign's checkpoint is discarded.
+func (tim *tenantInstanceManager) CancelCampaign(id int) error {
+	if err := tim.store.UpdateTenantCampaignStatus(tim.tenantID, id, models.CampaignStatusCancelled); err != nil {
+		return err
+	}
+
+	tim.pipesMut.Lock()
+	tp, ok := tim.pipes[id]
+	delete(tim.checkpoints, id)
+	tim.pipesMut.Unlock()
+
+	if ok {
+		tp.Cancel()
+		tim.sendTenantWebhook(tp.camp, models.CampaignStatusCancelled, "")
+	}
+	return nil
+}
+
+// ResumeCampaign resumes a paused campaign for this tenant from its last checkpoint
+func (tim *tenantInstanceManager) ResumeCampaign(id int) error {
+	tim.pipesMut.RLock()
+	_, ok := tim.pipes[id]
+	tim.pipesMut.RUnlock()
+	if ok {
+		return fmt.Errorf("campaign %d is already running for tenant %d", id, tim.tenantID)
+	}
+
+	c, err := tim.store.GetTenantCampaign(tim.tenantID, id)
+	if err != nil {
+		return err
+	}
+	if c.Status != models.CampaignStatusPaused {
+		return fmt.Errorf("campaign %s is not paused for tenant %d", c.Name, tim.tenantID)
+	}
+
+	tp, err := tim.newTenantPipe(c)
+	if err != nil {
+		return err
+	}
+
+	// Seed the pipe with the checkpoint of the paused campaign
+	tim.pipesMut.Lock()
+	if lastID, ok := tim.checkpoints[id]; ok {
+		tp.lastID.Store(lastID)
+		delete(tim.checkpoints, id)
+	}
+	tim.pipesMut.Unlock()
+
+	if err := tim.store.UpdateTenantCampaignStatus(tim.tenantID, id, models.CampaignStatusRunning); err != nil {
+		// Release the pipe, retaining the checkpoint
+		tp.Pause()
+		tp.wg.Done()
+		return err
+	}
+	c.Status = models.CampaignStatusRunning
+	tim.sendTenantWebhook(c, models.CampaignStatusRunning, "")
+
+	tim.log.Printf("tenant %d: resume processing campaign (%s)", tim.tenantID, c.Name)
+	tim.nextPipes <- tp
+	return nil
+}
+
 // CacheTpl caches a template for this tenant
 func (tim *tenantInstanceManager) CacheTpl(id int, tpl *models.Template) {
 	tim.tplsMut.Lock()
@@ -95,15 +298,15 @@ func (tim *tenantInstanceManager) run() {
 	defer tim.wg.Done()
 
 	// Start workers for this tenant
-	for i := 0; i < tim.cfg.TenantMaxConcurrency; i++ {
+	for i := 0; i < tim.config().TenantMaxConcurrency; i++ {
 		tim.wg.Add(1)
 		go tim.worker()
 	}
 
 	// Start campaign scanning for this tenant
-	if tim.cfg.ScanCampaigns {
+	if tim.config().ScanCampaigns {
 		tim.wg.Add(1)
-		go tim.scanCampaigns(tim.cfg.ScanInterval)
+		go tim.scanCampaigns(tim.config().ScanInterval)
 	}
 
 	// Process tenant-specific pipes
@@ -144,9 +347,19 @@ func (tim *tenantInstanceManager) stop() {
 	}
 
 	tim.active = false
+	tim.cancel()
 	close(tim.stopCh)
 	tim.wg.Wait()
 
+	// The workers have exited. Flush what the messengers have buffered.
+	tim.messengersMut.RLock()
+	for name, msgr := range tim.messengers {
+		if err := msgr.Flush(); err != nil {
+			tim.log.Printf("tenant %d: error flushing messenger %s: %v", tim.tenantID, name, err)
+		}
+	}
+	tim.messengersMut.RUnlock()
+
 	// Close channels
 	close(tim.nextPipes)
 	close(tim.campMsgQ)
@@ -176,13 +389,41 @@ func (tim *tenantInstanceManager) scanCampaigns(tick time.Duration) {
 				continue
 			}
 
+			// Check the tenant's plan limits once for all of the campaigns
+			var (
+				canProcess = true
+				reason     string
+			)
+			if len(campaigns) > 0 {
+				canProcess, reason = tim.limits.CanProcessCampaign(tim.tenantID, nil)
+			}
+
 			for _, c := range campaigns {
+				// Don't start campaigns that are scheduled for the future
+				if isScheduledLater(c) {
+					tim.log.Printf("tenant %d: skipping campaign (%s) scheduled for %s",
+						tim.tenantID, c.Name, c.SendAt.Time.Format(time.RFC822Z))
+					continue
+				}
+
+				// Skip the campaigns of tenants over their limits, notifying once per campaign
+				if !canProcess {
+					if !tim.limitSkipped[c.ID] {
+						tim.limitSkipped[c.ID] = true
+						tim.log.Printf("tenant %d: skipping campaign (%s): %s", tim.tenantID, c.Name, reason)
+						_ = tim.sendTenantNotif(c, c.Status, reason)
+					}
+					continue
+				}
+				delete(tim.limitSkipped, c.ID)
+
 				tp, err := tim.newTenantPipe(c)
 				if err != nil {
 					tim.log.Printf("tenant %d: error processing campaign (%s): %v", tim.tenantID, c.Name, err)
 					continue
 				}
 				tim.log.Printf("tenant %d: start processing campaign (%s)", tim.tenantID, c.Name)
+				tim.sendTenantWebhook(c, models.CampaignStatusRunning, "")
 
 				select {
 				case tim.nextPipes <- tp:
@@ -198,8 +439,9 @@ func (tim *tenantInstanceManager) scanCampaigns(tick time.Duration) {
 
 // getCurrentCampaigns returns current campaigns and counts for this tenant
 func (tim *tenantInstanceManager) getCurrentCampaigns() ([]int64, []int64) {
-	tim.pipesMut.RLock()
-	defer tim.pipesMut.RUnlock()
+	// Write lock as the counts are reset
+	tim.pipesMut.Lock()
+	defer tim.pipesMut.Unlock()
 
 	var (
 		ids    = make([]int64, 0, len(tim.pipes))
@@ -208,8 +450,7 @@ func (tim *tenantInstanceManager) getCurrentCampaigns() ([]int64, []int64) {
 
 	for _, p := range tim.pipes {
 		ids = append(ids, int64(p.camp.ID))
-		counts = append(counts, p.sent.Load())
-		p.sent.Store(0)
+		counts = append(counts, p.sent.Swap(0))
 	}
 
 	return ids, counts
@@ -219,6 +460,9 @@ func (tim *tenantInstanceManager) getCurrentCampaigns() ([]int64, []int64) {
 func (tim *tenantInstanceManager) worker() {
 	defer tim.wg.Done()
 
+	tim.workers.Add(1)
+	defer tim.workers.Add(-1)
+
 	numMsg := 0
 	for {
 		select {
@@ -226,6 +470,7 @@ func (tim *tenantInstanceManager) worker() {
 			if !ok {
 				return
 			}
+			tim.lastProcessedAt.Store(time.Now().UnixNano())
 
 			// Check if campaign is stopped
 			if msg.pipe != nil && msg.pipe.stopped.Load() {
@@ -233,84 +478,253 @@ func (tim *tenantInstanceManager) worker() {
 				continue
 			}
 
+			// Honour the campaign's own send rate without holding up the worker
+			if msg.pipe != nil && !msg.throttled {
+				if wait := msg.pipe.throttle.reserve(); wait > 0 {
+					msg.throttled = true
+					tim.wg.Add(1)
+					go tim.requeue(msg, wait)
+					continue
+				}
+			}
+			msg.throttled = false
+
 			// Apply tenant rate limiting
-			if numMsg >= tim.cfg.TenantMessageRate {
+			if numMsg >= tim.config().TenantMessageRate {
 				time.Sleep(time.Second)
 				numMsg = 0
 			}
 			numMsg++
 
-			// Create outgoing message with tenant context
-			out := models.Message{
-				From:        msg.from,
-				To:          []string{msg.to},
-				Subject:     msg.subject,
-				ContentType: msg.Campaign.ContentType,
-				Body:        msg.body,
-				AltBody:     msg.altBody,
-				Subscriber:  msg.Subscriber,
-				Campaign:    msg.Campaign,
-				Attachments: msg.Campaign.Attachments,
+			// Wait for a slot in the message budget shared by all tenants
+			// without holding up the instance from stopping
+			if tim.budget != nil {
+				if wait := tim.budget.reserve(); wait > 0 {
+					t := time.NewTimer(wait)
+					select {
+					case <-t.C:
+					case <-tim.stopCh:
+						t.Stop()
+						return
+					}
+				}
 			}
 
-			h := textproto.MIMEHeader{}
-			h.Set(models.EmailHeaderCampaignUUID, msg.Campaign.UUID)
-			h.Set(models.EmailHeaderSubscriberUUID, msg.Subscriber.UUID)
-			h.Set("X-Tenant-ID", fmt.Sprintf("%d", tim.tenantID))
+			tim.sendCampaignMessage(msg)
 
-			// Add List-Unsubscribe headers if enabled
-			if tim.cfg.UnsubHeader {
-				h.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
-				h.Set("List-Unsubscribe", `<`+msg.unsubURL+`>`)
+		case msg, ok := <-tim.msgQ:
+			if !ok {
+				return
 			}
+			tim.lastProcessedAt.Store(time.Now().UnixNano())
 
-			// Add custom headers
-			if len(msg.Campaign.Headers) > 0 {
-				for _, set := range msg.Campaign.Headers {
-					for hdr, val := range set {
-						h.Add(hdr, val)
-					}
-				}
-			}
+			tim.sendMessage(msg)
 
-			out.Headers = h
+		case <-tim.stopCh:
+			return
+		}
+	}
+}
 
-			// Send message using tenant messenger
-			err := tim.messengers[msg.Campaign.Messenger].Push(out)
-			if err != nil {
-				tim.log.Printf("tenant %d: error sending message in campaign %s: subscriber %d: %v", 
-					tim.tenantID, msg.Campaign.Name, msg.Subscriber.ID, err)
-			}
+// sendCampaignMessage sends a tenant campaign message and updates the pipe statistics.
+// Panics are recovered and counted as send errors to keep the worker alive
+func (tim *tenantInstanceManager) sendCampaignMessage(msg TenantCampaignMessage) {
+	done := false
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
 
-			// Update pipe statistics
-			if msg.pipe != nil {
-				msg.pipe.wg.Done()
+		tim.log.Printf("tenant %d: recovered from panic sending message in campaign %s: subscriber %d: %v",
+			tim.tenantID, msg.Campaign.Name, msg.Subscriber.ID, r)
+		tim.recordError(fmt.Sprintf("panic: %v", r))
+		if msg.pipe != nil && !done {
+			msg.pipe.wg.Done()
+			msg.pipe.OnError()
+		}
+	}()
+
+	// Create outgoing message with tenant context
+	out := models.Message{
+		From:        tim.formatFrom(msg.from),
+		To:          []string{msg.to},
+		Subject:     msg.subject,
+		ContentType: msg.Campaign.ContentType,
+		Body:        msg.body,
+		AltBody:     msg.altBody,
+		Subscriber:  msg.Subscriber,
+		Campaign:    msg.Campaign,
+		Attachments: msg.Campaign.Attachments,
+		TenantID:    tim.tenantID,
+	}
 
-				if err != nil {
-					msg.pipe.OnError()
-				} else {
-					id := uint64(msg.Subscriber.ID)
-					if id > msg.pipe.lastID.Load() {
-						msg.pipe.lastID.Store(uint64(msg.Subscriber.ID))
-					}
-					msg.pipe.rate.Incr(1)
-					msg.pipe.sent.Add(1)
-				}
+	h := textproto.MIMEHeader{}
+	h.Set(models.EmailHeaderCampaignUUID, msg.Campaign.UUID)
+	h.Set(models.EmailHeaderSubscriberUUID, msg.Subscriber.UUID)
+	if tim.config().ExposeTenantHeaders {
+		h.Set("X-Tenant-ID", fmt.Sprintf("%d", tim.tenantID))
+	}
+
+	// Add List-Unsubscribe headers if enabled
+	if tim.config().UnsubHeader {
+		h.Set("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+		h.Set("List-Unsubscribe", makeUnsubHeader(msg.unsubURL, tim.config().TenantUnsubMailto))
+	}
+
+	// Add custom headers
+	if len(msg.Campaign.Headers) > 0 {
+		for _, set := range msg.Campaign.Headers {
+			for hdr, val := range set {
+				h.Add(hdr, val)
 			}
+		}
+	}
 
-		case msg, ok := <-tim.msgQ:
-			if !ok {
-				return
+	// Add the tenant's default headers unless the campaign (or the system
+	// headers above) have already set them.
+	for _, set := range tim.config().TenantDefaultHeaders {
+		for hdr, val := range set {
+			if _, ok := h[textproto.CanonicalMIMEHeaderKey(hdr)]; !ok {
+				h.Set(hdr, val)
 			}
+		}
+	}
 
-			// Push arbitrary message
-			if err := tim.messengers[msg.Messenger].Push(msg); err != nil {
-				tim.log.Printf("tenant %d: error sending message '%s': %v", tim.tenantID, msg.Subject, err)
+	// The tenant's Reply-To, unless the campaign has its own.
+	if replyTo := tim.config().TenantReplyTo; replyTo != "" && h.Get("Reply-To") == "" {
+		h.Set("Reply-To", replyTo)
+	}
+
+	out.Headers = h
+
+	// The campaign's messenger may have been removed or never registered.
+	// Cancel the campaign instead of attempting to push every message.
+	if _, ok := tim.getMessenger(msg.Campaign.Messenger); !ok {
+		tim.log.Printf("tenant %d: unknown messenger %s on campaign %s: subscriber %d",
+			tim.tenantID, msg.Campaign.Messenger, msg.Campaign.Name, msg.Subscriber.ID)
+		if msg.pipe == nil {
+			return
+		}
+
+		done = true
+		if !msg.pipe.stopped.Load() {
+			msg.pipe.Stop(false)
+			if err := tim.store.UpdateTenantCampaignStatus(tim.tenantID, msg.Campaign.ID, models.CampaignStatusCancelled); err != nil {
+				tim.log.Printf("tenant %d: error cancelling campaign (%s): %v", tim.tenantID, msg.Campaign.Name, err)
 			}
+			tim.sendTenantWebhook(msg.Campaign, models.CampaignStatusCancelled, "unknown messenger "+msg.Campaign.Messenger)
+		}
+		msg.pipe.wg.Done()
+		return
+	}
 
-		case <-tim.stopCh:
+	// Send message using tenant messenger
+	start := time.Now()
+	err := tim.pushWithFallback(msg.Campaign.Messenger, out)
+	if msg.pipe != nil {
+		msg.pipe.batch.record(time.Since(start))
+	}
+	if err != nil {
+		// Requeue the message for another attempt before counting it as an error
+		if tim.config().RequeueOnError && msg.retries < maxRequeues {
+			msg.retries++
+			tim.log.Printf("tenant %d: error sending message in campaign %s: subscriber %d: %v. requeuing (%d/%d)",
+				tim.tenantID, msg.Campaign.Name, msg.Subscriber.ID, err, msg.retries, maxRequeues)
+
+			tim.wg.Add(1)
+			go tim.requeue(msg, requeueBackoff*time.Duration(msg.retries))
 			return
 		}
+
+		tim.log.Printf("tenant %d: error sending message in campaign %s: subscriber %d: %v", 
+			tim.tenantID, msg.Campaign.Name, msg.Subscriber.ID, err)
+		tim.recordError(err.Error())
+	} else {
+		tim.sent.Add(1)
+	}
+
+	if tim.fnResult != nil {
+		tim.fnResult(makeCampaignResult(msg.Campaign, msg.Subscriber, tim.tenantID, err))
+	}
+
+	// Update pipe statistics
+	if msg.pipe != nil {
+		done = true
+		msg.pipe.wg.Done()
+
+		if err != nil {
+			msg.pipe.OnError()
+		} else {
+			id := uint64(msg.Subscriber.ID)
+			if id > msg.pipe.lastID.Load() {
+				msg.pipe.lastID.Store(uint64(msg.Subscriber.ID))
+			}
+			msg.pipe.rate.Incr(1)
+			msg.pipe.sent.Add(1)
+		}
+	}
+}
+
+// pushWithFallback pushes a message via the given messenger, retrying via the
+// configured fallback messenger if that fails
+func (tim *tenantInstanceManager) pushWithFallback(name string, out models.Message) error {
+	m, ok := tim.getMessenger(name)
+	if !ok {
+		return fmt.Errorf("unknown messenger %s", name)
+	}
+
+	err := m.Push(out)
+	if err == nil || tim.config().FallbackMessenger == "" || tim.config().FallbackMessenger == name {
+		return err
+	}
+
+	fb, ok := tim.getMessenger(tim.config().FallbackMessenger)
+	if !ok {
+		return err
+	}
+
+	tim.log.Printf("tenant %d: error sending message via %s: %v. retrying via fallback %s",
+		tim.tenantID, name, err, tim.config().FallbackMessenger)
+	return fb.Push(out)
+}
+
+// sendMessage sends an arbitrary tenant message, recovering from panics
+func (tim *tenantInstanceManager) sendMessage(msg models.Message) {
+	defer func() {
+		if r := recover(); r != nil {
+			tim.log.Printf("tenant %d: recovered from panic sending message '%s': %v", tim.tenantID, msg.Subject, r)
+		}
+	}()
+
+	// Push arbitrary message
+	m, ok := tim.getMessenger(msg.Messenger)
+	if !ok {
+		tim.log.Printf("tenant %d: unknown messenger %s on message '%s'", tim.tenantID, msg.Messenger, msg.Subject)
+		return
+	}
+	if err := m.Push(msg); err != nil {
+		tim.log.Printf("tenant %d: error sending message '%s': %v", tim.tenantID, msg.Subject, err)
+	} else {
+		tim.sent.Add(1)
+	}
+}
+
+// requeue pushes a tenant campaign message back onto the queue after the given
+// wait (a backoff after an error or a throttle slot), unless the instance is
+// stopped in the meantime.
+func (tim *tenantInstanceManager) requeue(msg TenantCampaignMessage, wait time.Duration) {
+	defer tim.wg.Done()
+
+	select {
+	case <-time.After(wait):
+	case <-tim.stopCh:
+		return
+	}
+
+	select {
+	case tim.campMsgQ <- msg:
+	case <-tim.stopCh:
 	}
 }
 
@@ -323,13 +737,24 @@ func (tim *tenantInstanceManager) NewTenantCampaignMessage(c *models.Campaign, s
 		subject:    c.Subject,
 		from:       tim.getFromEmail(c),
 		to:         s.Email,
-		unsubURL:   fmt.Sprintf(tim.cfg.TenantUnsubURL, c.UUID, s.UUID),
+		unsubURL:   fmt.Sprintf(tim.config().TenantUnsubURL, c.UUID, s.UUID),
 	}
 
 	if err := msg.render(); err != nil {
 		return msg, err
 	}
 
+	// Add the unsubscribe link to bodies that don't have it
+	if tim.config().EnforceUnsubFooter {
+		msg.body = withUnsubFooter(msg.body, msg.unsubURL, c.ContentType == models.CampaignContentTypePlain)
+		msg.altBody = withUnsubFooter(msg.altBody, msg.unsubURL, true)
+	}
+
+	// Generate a plain text alt body for HTML messages that don't have one
+	if tim.config().AutoPlainText && c.ContentType != models.CampaignContentTypePlain && len(msg.altBody) == 0 {
+		msg.altBody = htmlToText(msg.body)
+	}
+
 	return msg, nil
 }
 
@@ -341,12 +766,29 @@ func (tim *tenantInstanceManager) getFromEmail(c *models.Campaign) string {
 	}
 	
 	// Use tenant-specific from email if configured
-	if tim.cfg.TenantFromEmail != "" {
-		return tim.cfg.TenantFromEmail
+	if tim.config().TenantFromEmail != "" {
+		return tim.config().TenantFromEmail
 	}
 	
 	// Fall back to global config
-	return tim.cfg.FromEmail
+	return tim.config().FromEmail
+}
+
+// formatFrom adds the tenant's from name to a from address that doesn't
+// have a display name of its own, eg: "Name" <addr>.
+func (tim *tenantInstanceManager) formatFrom(from string) string {
+	name := tim.config().TenantFromName
+	if name == "" {
+		return from
+	}
+
+	addr, err := mail.ParseAddress(from)
+	if err != nil || addr.Name != "" {
+		return from
+	}
+	addr.Name = name
+
+	return addr.String()
 }
 
 // TemplateFuncs returns template functions for this tenant
@@ -354,18 +796,18 @@ func (tim *tenantInstanceManager) TemplateFuncs(c *models.Campaign) template.Fun
 	f := template.FuncMap{
 		"TrackLink": func(url string, msg *TenantCampaignMessage) string {
 			subUUID := msg.Subscriber.UUID
-			if !tim.cfg.IndividualTracking {
+			if !tim.config().IndividualTracking {
 				subUUID = dummyUUID
 			}
 			return tim.trackLink(url, msg.Campaign.UUID, subUUID)
 		},
 		"TrackView": func(msg *TenantCampaignMessage) template.HTML {
 			subUUID := msg.Subscriber.UUID
-			if !tim.cfg.IndividualTracking {
+			if !tim.config().IndividualTracking {
 				subUUID = dummyUUID
 			}
 			return template.HTML(fmt.Sprintf(`<img src="%s" alt="" />`,
-				fmt.Sprintf(tim.cfg.ViewTrackURL, msg.Campaign.UUID, subUUID)))
+				fmt.Sprintf(tim.config().ViewTrackURL, msg.Campaign.UUID, subUUID)))
 		},
 		"UnsubscribeURL": func(msg *TenantCampaignMessage) string {
 			return msg.unsubURL
@@ -374,16 +816,16 @@ func (tim *tenantInstanceManager) TemplateFuncs(c *models.Campaign) template.Fun
 			return msg.unsubURL + "?manage=true"
 		},
 		"OptinURL": func(msg *TenantCampaignMessage) string {
-			return fmt.Sprintf(tim.cfg.TenantOptinURL, msg.Subscriber.UUID, "")
+			return fmt.Sprintf(tim.config().TenantOptinURL, msg.Subscriber.UUID, "")
 		},
 		"MessageURL": func(msg *TenantCampaignMessage) string {
-			return fmt.Sprintf(tim.cfg.TenantMessageURL, c.UUID, msg.Subscriber.UUID)
+			return fmt.Sprintf(tim.config().TenantMessageURL, c.UUID, msg.Subscriber.UUID)
 		},
 		"ArchiveURL": func() string {
-			return tim.cfg.TenantArchiveURL
+			return tim.config().TenantArchiveURL
 		},
 		"RootURL": func() string {
-			return tim.cfg.TenantRootURL
+			return tim.config().TenantRootURL
 		},
 	}
 
@@ -395,12 +837,9 @@ func (tim *tenantInstanceManager) TemplateFuncs(c *models.Campaign) template.Fun
 func (tim *tenantInstanceManager) trackLink(url, campUUID, subUUID string) string {
 	url = strings.ReplaceAll(url, "&amp;", "&")
 
-	tim.linksMut.RLock()
-	if uu, ok := tim.links[url]; ok {
-		tim.linksMut.RUnlock()
-		return fmt.Sprintf(tim.cfg.LinkTrackURL, uu, campUUID, subUUID)
+	if uu, ok := tim.links.get(url); ok {
+		return fmt.Sprintf(tim.config().LinkTrackURL, uu, campUUID, subUUID)
 	}
-	tim.linksMut.RUnlock()
 
 	// Register link with tenant context
 	uu, err := tim.store.CreateTenantLink(tim.tenantID, url)
@@ -409,15 +848,18 @@ func (tim *tenantInstanceManager) trackLink(url, campUUID, subUUID string) strin
 		return url
 	}
 
-	tim.linksMut.Lock()
-	tim.links[url] = uu
-	tim.linksMut.Unlock()
+	tim.links.set(url, uu)
 
-	return fmt.Sprintf(tim.cfg.LinkTrackURL, uu, campUUID, subUUID)
+	return fmt.Sprintf(tim.config().LinkTrackURL, uu, campUUID, subUUID)
 }
 
 // sendTenantNotif sends a tenant-specific notification
 func (tim *tenantInstanceManager) sendTenantNotif(c *models.Campaign, status, reason string) error {
+	// Tenants on webhook notifications get the status via sendTenantWebhook().
+	if tim.config().TenantNotify != TenantNotifyAdmins {
+		return nil
+	}
+
 	subject := fmt.Sprintf("Tenant %d - %s: %s", tim.tenantID, cases.Title(language.Und).String(status), c.Name)
 	data := map[string]any{
 		"TenantID": tim.tenantID,
@@ -432,19 +874,34 @@ func (tim *tenantInstanceManager) sendTenantNotif(c *models.Campaign, status, re
 	return tim.fnNotify(tim.tenantID, subject, data)
 }
 
-// attachMedia loads media/attachments for tenant campaigns
-func (tim *tenantInstanceManager) attachMedia(c *models.Campaign) error {
+// loadMedia fetches the media/attachments of a tenant campaign
+func (tim *tenantInstanceManager) loadMedia(c *models.Campaign) ([]models.Attachment, error) {
+	var (
+		out  = make([]models.Attachment, 0, len(c.MediaIDs))
+		size int64
+	)
 	for _, mid := range []int64(c.MediaIDs) {
 		a, err := tim.store.GetAttachment(int(mid))
 		if err != nil {
-			return fmt.Errorf("tenant %d: error fetching attachment %d on campaign %s: %v", tim.tenantID, mid, c.Name, err)
+			return nil, fmt.Errorf("tenant %d: error fetching attachment %d on campaign %s: %v", tim.tenantID, mid, c.Name, err)
+		}
+
+		size += int64(len(a.Content))
+		if err := checkAttachmentSize(size, tim.config().MaxAttachmentBytes); err != nil {
+			return nil, fmt.Errorf("tenant %d: campaign %s: %w", tim.tenantID, c.Name, err)
+		}
+		if tim.config().StreamAttachments {
+			a = streamAttachment(a, int(mid), tim.store.GetAttachment)
 		}
-		c.Attachments = append(c.Attachments, a)
+		out = append(out, a)
 	}
-	return nil
+	return out, nil
 }
 
-// TenantCampaignMessage render method
+// render executes the campaign's pre-compiled templates for the message.
+// Markdown campaign bodies are converted to HTML when the templates are compiled
+// (Campaign.CompileTemplate() in newTenantPipe()), same as single-tenant campaigns,
+// so the rendered body is always HTML for every content type except plain.
 func (m *TenantCampaignMessage) render() error {
 	out := bytes.Buffer{}
 
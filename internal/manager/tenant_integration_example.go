@@ -14,16 +14,11 @@ import (
 // into the main application. It shows the usage patterns and setup required.
 
 // ExampleTenantManagerSetup demonstrates how to set up the multi-tenant manager
-func ExampleTenantManagerSetup(db *sqlx.DB, queries *models.Queries) {
+// with the app's TenantStore implementation (cmd/manager_store.go) and i18n.
+func ExampleTenantManagerSetup(tenantStore TenantStore, i18nInstance *i18n.I18n) {
 	// Initialize logger
 	logger := log.New(os.Stdout, "[TENANT-MANAGER] ", log.LstdFlags)
 
-	// Initialize i18n
-	i18nInstance, err := i18n.New("en", "./i18n")
-	if err != nil {
-		logger.Fatalf("failed to initialize i18n: %v", err)
-	}
-
 	// Configure the manager
 	cfg := Config{
 		BatchSize:             1000,
@@ -48,13 +43,6 @@ func ExampleTenantManagerSetup(db *sqlx.DB, queries *models.Queries) {
 		ScanCampaigns:       true,
 	}
 
-	// Create tenant store implementation
-	tenantStore := &store{
-		queries: queries,
-		db:      db,
-		// core and media would be initialized here in real usage
-	}
-
 	// Create manager factory
 	factory := NewManagerFactory(
 		MultiTenantMode,
@@ -74,9 +62,10 @@ func ExampleTenantManagerSetup(db *sqlx.DB, queries *models.Queries) {
 	tenantManager := managerInterface.(*TenantManager)
 
 	// Add messengers (SMTP, etc.)
-	// This would typically be done in your main application setup
-	// smtp := email.New(...)
-	// tenantManager.AddMessenger(smtp)
+	// This would typically be done in your main application setup.
+	// email.NewTenantMessenger() sends every message with its tenant's SMTP settings.
+	// te := email.NewTenantEmailer(db, fallback, secretKey, logger)
+	// tenantManager.AddMessenger(email.NewTenantMessenger(te))
 
 	// Start the tenant manager
 	go tenantManager.Run()
@@ -85,14 +74,9 @@ func ExampleTenantManagerSetup(db *sqlx.DB, queries *models.Queries) {
 }
 
 // ExampleSingleTenantCompatibility demonstrates backward compatibility
-func ExampleSingleTenantCompatibility(db *sqlx.DB, queries *models.Queries) {
+func ExampleSingleTenantCompatibility(legacyStore Store, tenantStore TenantStore, i18nInstance *i18n.I18n) {
 	logger := log.New(os.Stdout, "[SINGLE-MANAGER] ", log.LstdFlags)
 
-	i18nInstance, err := i18n.New("en", "./i18n")
-	if err != nil {
-		logger.Fatalf("failed to initialize i18n: %v", err)
-	}
-
 	cfg := Config{
 		BatchSize:     1000,
 		Concurrency:   5,
@@ -103,21 +87,17 @@ func ExampleSingleTenantCompatibility(db *sqlx.DB, queries *models.Queries) {
 	}
 
 	// Option 1: Use traditional single-tenant manager
-	legacyStore := &store{
-		queries: queries,
-		db:      db,
+	traditionalManager, err := New(cfg, legacyStore, i18nInstance, logger)
+	if err != nil {
+		logger.Fatalf("error initializing campaign manager: %v", err)
 	}
-
-	traditionalManager := New(cfg, legacyStore, i18nInstance, logger)
 	go traditionalManager.Run()
 
 	// Option 2: Use single-tenant manager with tenant store adapter (recommended)
-	tenantStore := &store{
-		queries: queries,
-		db:      db,
+	adaptedManager, err := NewFromTenantStore(cfg, tenantStore, i18nInstance, logger)
+	if err != nil {
+		logger.Fatalf("error initializing campaign manager: %v", err)
 	}
-
-	adaptedManager := NewFromTenantStore(cfg, tenantStore, i18nInstance, logger)
 	go adaptedManager.Run()
 
 	logger.Printf("single-tenant managers started")
@@ -263,15 +243,10 @@ func (tl *TenantLogger) Printf(format string, v ...interface{}) {
 // ExampleTenantAwareBounceHandling shows how to handle bounces per tenant
 func ExampleTenantAwareBounceHandling(tenantManager *TenantManager, tenantID int, bounceEvent models.Bounce) {
 	// Process bounce event with tenant context
-	tenantManager.tenantManagersMut.RLock()
-	if tim, exists := tenantManager.tenantManagers[tenantID]; exists {
-		// Handle bounce for specific tenant
-		if err := tim.store.BlocklistTenantSubscriber(tenantID, int64(bounceEvent.SubscriberID)); err != nil {
-			log.Printf("tenant %d: failed to blocklist subscriber %d: %v", 
-				tenantID, bounceEvent.SubscriberID, err)
-		}
+	if err := tenantManager.ProcessBounce(tenantID, bounceEvent); err != nil {
+		log.Printf("tenant %d: failed to process bounce for subscriber %d: %v",
+			tenantID, bounceEvent.SubscriberID, err)
 	}
-	tenantManager.tenantManagersMut.RUnlock()
 }
 
 // ExampleTenantSpecificNotifications demonstrates tenant-specific notification handling
@@ -1,7 +1,10 @@
 package manager
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -10,6 +13,10 @@ import (
 	"github.com/paulbellamy/ratecounter"
 )
 
+// errInstanceStopping is returned when a message can't be pushed as the
+// tenant instance is stopping.
+var errInstanceStopping = errors.New("tenant instance is stopping")
+
 // tenantPipe handles campaign processing for a specific tenant with isolated context
 type tenantPipe struct {
 	tenantID   int
@@ -20,16 +27,32 @@ type tenantPipe struct {
 	lastID     atomic.Uint64
 	errors     atomic.Uint64
 	stopped    atomic.Bool
+	paused     atomic.Bool
+	cancelled  atomic.Bool
 	withErrors atomic.Bool
 
+	// Paces the campaign's messages if it has its own send rate
+	throttle throttle
+
+	// Adjusts the batch size to the send latency (nil if disabled)
+	batch *adaptiveBatch
+
+	// Lowercased e-mails on the tenant's suppression list, loaded once per
+	// campaign run, that are skipped without being counted as errors
+	suppressed map[string]struct{}
+
+	// When the campaign was picked up for processing
+	started time.Time
+
 	m *tenantInstanceManager
 }
 
 // newTenantPipe creates a new tenant-specific campaign pipe
 func (tim *tenantInstanceManager) newTenantPipe(c *models.Campaign) (*tenantPipe, error) {
 	// Validate messenger exists for this tenant
-	if _, ok := tim.messengers[c.Messenger]; !ok {
+	if _, ok := tim.getMessenger(c.Messenger); !ok {
 		tim.store.UpdateTenantCampaignStatus(tim.tenantID, c.ID, models.CampaignStatusCancelled)
+		tim.sendTenantWebhook(c, models.CampaignStatusCancelled, "unknown messenger "+c.Messenger)
 		return nil, fmt.Errorf("unknown messenger %s on campaign %s for tenant %d", c.Messenger, c.Name, tim.tenantID)
 	}
 
@@ -38,18 +61,41 @@ func (tim *tenantInstanceManager) newTenantPipe(c *models.Campaign) (*tenantPipe
 		return nil, err
 	}
 
-	// Load any media/attachments for the tenant
-	if err := tim.attachMedia(c); err != nil {
+	// Load any media/attachments once for all of the campaign's messages
+	att, err := tim.loadMedia(c)
+	if err != nil {
+		// Every message would be rejected, so cancel the campaign right away
+		if errors.Is(err, ErrAttachmentsTooLarge) {
+			if err := tim.store.UpdateTenantCampaignStatus(tim.tenantID, c.ID, models.CampaignStatusCancelled); err != nil {
+				tim.log.Printf("tenant %d: error cancelling campaign (%s): %v", tim.tenantID, c.Name, err)
+			}
+			_ = tim.sendTenantNotif(c, models.CampaignStatusCancelled, err.Error())
+			tim.sendTenantWebhook(c, models.CampaignStatusCancelled, err.Error())
+		}
 		return nil, err
 	}
+	c.Attachments = att
+
+	// Load the tenant's suppression list once for the campaign run
+	emails, err := tim.store.GetTenantSuppressions(tim.tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching suppression list for tenant %d: %v", tim.tenantID, err)
+	}
+	suppressed := make(map[string]struct{}, len(emails))
+	for _, e := range emails {
+		suppressed[strings.ToLower(e)] = struct{}{}
+	}
 
 	// Create tenant pipe
 	tp := &tenantPipe{
-		tenantID: tim.tenantID,
-		camp:     c,
-		rate:     ratecounter.NewRateCounter(time.Minute),
-		wg:       &sync.WaitGroup{},
-		m:        tim,
+		tenantID:   tim.tenantID,
+		camp:       c,
+		rate:       ratecounter.NewRateCounter(time.Minute),
+		wg:         &sync.WaitGroup{},
+		suppressed: suppressed,
+		batch:      newAdaptiveBatch(tim.config().AdaptiveBatch, tim.config().TenantMaxBatchSize),
+		started:    time.Now(),
+		m:          tim,
 	}
 
 	// Increment the waitgroup so that Wait() blocks immediately
@@ -63,6 +109,10 @@ func (tim *tenantInstanceManager) newTenantPipe(c *models.Campaign) (*tenantPipe
 
 	tim.pipesMut.Lock()
 	tim.pipes[c.ID] = tp
+	tim.attachments[c.ID] = att
+	if rate, ok := tim.rates[c.ID]; ok {
+		tp.throttle.setRate(rate)
+	}
 	tim.pipesMut.Unlock()
 	
 	return tp, nil
@@ -70,9 +120,25 @@ func (tim *tenantInstanceManager) newTenantPipe(c *models.Campaign) (*tenantPipe
 
 // NextSubscribers processes the next batch of subscribers for this tenant's campaign
 func (tp *tenantPipe) NextSubscribers() (bool, error) {
+	// Campaign has been stopped or paused
+	if tp.stopped.Load() {
+		return false, nil
+	}
+
+	// Outside the tenant's send window. Wait a while and requeue the pipe
+	if wait := tp.m.window.wait(time.Now()); wait > 0 {
+		time.Sleep(min(wait, sendWindowCheckInterval))
+		return true, nil
+	}
+
 	// Fetch next batch of subscribers for this tenant and campaign
-	subs, err := tp.m.store.NextTenantSubscribers(tp.tenantID, tp.camp.ID, tp.m.cfg.TenantMaxBatchSize)
+	subs, err := tp.m.store.NextTenantSubscribers(tp.m.ctx, tp.tenantID, tp.camp.ID, tp.batch.next(tp.m.config().TenantMaxBatchSize))
 	if err != nil {
+		// The fetch was interrupted as the instance is stopping
+		if tp.m.ctx.Err() != nil {
+			return false, nil
+		}
+
 		return false, fmt.Errorf("error fetching campaign subscribers for tenant %d (%s): %v", tp.tenantID, tp.camp.Name, err)
 	}
 
@@ -81,66 +147,85 @@ func (tp *tenantPipe) NextSubscribers() (bool, error) {
 		return false, nil
 	}
 
-	// Check sliding window limits
-	hasSliding := tp.m.cfg.SlidingWindow &&
-		tp.m.cfg.SlidingWindowRate > 0 &&
-		tp.m.cfg.SlidingWindowDuration.Seconds() > 1
-
 	// Process messages with tenant context
 	for _, s := range subs {
+		// Skip suppressed subscribers
+		if _, ok := tp.suppressed[strings.ToLower(s.Email)]; ok {
+			continue
+		}
+
 		msg, err := tp.newTenantMessage(s)
 		if err != nil {
 			tp.m.log.Printf("error rendering message for tenant %d (%s) (%s): %v", tp.tenantID, tp.camp.Name, s.Email, err)
 			continue
 		}
 
-		// Push to tenant-specific message queue
-		tp.m.campMsgQ <- msg
-
-		// Apply sliding window limits per tenant
-		if hasSliding {
-			diff := time.Since(tp.m.slidingStart)
-
-			if diff >= tp.m.cfg.SlidingWindowDuration {
-				tp.m.slidingStart = time.Now()
-				tp.m.slidingCount = 0
-				continue
+		// Apply sliding window limits shared by all of the tenant's pipes
+		for {
+			wait, start := tp.m.sliding.reserve()
+			if wait == 0 {
+				break
 			}
 
-			tp.m.slidingCount++
-			if tp.m.slidingCount >= tp.m.cfg.SlidingWindowRate {
-				wait := tp.m.cfg.SlidingWindowDuration - diff
-
-				tp.m.log.Printf("tenant %d: messages exceeded (%d) for window (%v since %s). Sleeping for %s.",
-					tp.tenantID,
-					tp.m.slidingCount,
-					tp.m.cfg.SlidingWindowDuration,
-					tp.m.slidingStart.Format(time.RFC822Z),
-					wait.Round(time.Second)*1)
+			tp.m.log.Printf("tenant %d: messages exceeded (%d) for window (%v since %s). Sleeping for %s.",
+				tp.tenantID,
+				tp.m.config().SlidingWindowRate,
+				tp.m.config().SlidingWindowDuration,
+				start.Format(time.RFC822Z),
+				wait.Round(time.Second)*1)
+			time.Sleep(wait)
+		}
 
-				tp.m.slidingCount = 0
-				time.Sleep(wait)
+		// Push to tenant-specific message queue. If it stays full, say, as the
+		// workers are stuck, rewind the campaign's checkpoint so that the rest
+		// of the batch is fetched again later instead of wedging the tenant.
+		if err := tp.push(msg); err != nil {
+			tp.wg.Done()
+			tp.m.log.Printf("tenant %d: %v (%s). requeuing the remaining subscribers", tp.tenantID, err, tp.camp.Name)
+
+			if s.ID > 1 {
+				if err := tp.m.store.UpdateTenantCampaignCounts(tp.tenantID, tp.camp.ID, 0, 0, s.ID-1); err != nil {
+					tp.m.log.Printf("tenant %d: error rewinding campaign (%s) checkpoint: %v", tp.tenantID, tp.camp.Name, err)
+				}
 			}
+
+			return !errors.Is(err, errInstanceStopping), nil
 		}
 	}
 
 	return true, nil
 }
 
+// push pushes a message to the tenant's campaign message queue, waiting for
+// room in the queue for up to PushTimeout
+func (tp *tenantPipe) push(msg TenantCampaignMessage) error {
+	t := time.NewTimer(tp.m.config().PushTimeout)
+	defer t.Stop()
+
+	select {
+	case tp.m.campMsgQ <- msg:
+		return nil
+	case <-tp.m.stopCh:
+		return errInstanceStopping
+	case <-t.C:
+		return errors.New("message push timed out")
+	}
+}
+
 // OnError handles errors with tenant context
 func (tp *tenantPipe) OnError() {
-	if tp.m.cfg.TenantMaxSendErrors < 1 {
+	if tp.m.config().TenantMaxSendErrors < 1 {
 		return
 	}
 
 	count := tp.errors.Add(1)
-	if int(count) < tp.m.cfg.TenantMaxSendErrors {
+	if int(count) < tp.m.config().TenantMaxSendErrors {
 		return
 	}
 
 	tp.Stop(true)
 	tp.m.log.Printf("tenant %d: error count exceeded %d. pausing campaign %s", 
-		tp.tenantID, tp.m.cfg.TenantMaxSendErrors, tp.camp.Name)
+		tp.tenantID, tp.m.config().TenantMaxSendErrors, tp.camp.Name)
 }
 
 // Stop marks a tenant campaign as stopped
@@ -156,6 +241,19 @@ func (tp *tenantPipe) Stop(withErrors bool) {
 	tp.stopped.Store(true)
 }
 
+// Pause marks a tenant campaign as paused, retaining its checkpoint on cleanup
+func (tp *tenantPipe) Pause() {
+	tp.paused.Store(true)
+	tp.Stop(false)
+}
+
+// Cancel marks a tenant campaign as cancelled, dropping its queued messages
+// without retaining a checkpoint on cleanup
+func (tp *tenantPipe) Cancel() {
+	tp.cancelled.Store(true)
+	tp.Stop(false)
+}
+
 // newTenantMessage creates a tenant-specific campaign message
 func (tp *tenantPipe) newTenantMessage(s models.Subscriber) (TenantCampaignMessage, error) {
 	msg, err := tp.m.NewTenantCampaignMessage(tp.camp, s)
@@ -174,14 +272,38 @@ func (tp *tenantPipe) cleanup() {
 	defer func() {
 		tp.m.pipesMut.Lock()
 		delete(tp.m.pipes, tp.camp.ID)
+		delete(tp.m.attachments, tp.camp.ID)
 		tp.m.pipesMut.Unlock()
 	}()
 
+	// Flush the messages that the campaign's messenger may have buffered
+	if msgr, ok := tp.m.getMessenger(tp.camp.Messenger); ok {
+		if err := msgr.Flush(); err != nil {
+			tp.m.log.Printf("tenant %d: error flushing messenger %s (%s): %v", tp.tenantID, msgr.Name(), tp.camp.Name, err)
+		}
+	}
+
 	// Update campaign counts for this tenant
 	if err := tp.m.store.UpdateTenantCampaignCounts(tp.tenantID, tp.camp.ID, 0, int(tp.sent.Load()), int(tp.lastID.Load())); err != nil {
 		tp.m.log.Printf("tenant %d: error updating campaign counts (%s): %v", tp.tenantID, tp.camp.Name, err)
 	}
 
+	// Campaign was cancelled - its status has already been set
+	if tp.cancelled.Load() {
+		tp.m.log.Printf("tenant %d: cancelled campaign (%s)", tp.tenantID, tp.camp.Name)
+		return
+	}
+
+	// Campaign was paused - retain the checkpoint to resume from
+	if tp.paused.Load() {
+		tp.m.pipesMut.Lock()
+		tp.m.checkpoints[tp.camp.ID] = tp.lastID.Load()
+		tp.m.pipesMut.Unlock()
+
+		tp.m.log.Printf("tenant %d: paused campaign (%s)", tp.tenantID, tp.camp.Name)
+		return
+	}
+
 	// Handle campaign paused due to errors
 	if tp.withErrors.Load() {
 		if err := tp.m.store.UpdateTenantCampaignStatus(tp.tenantID, tp.camp.ID, models.CampaignStatusPaused); err != nil {
@@ -194,6 +316,7 @@ func (tp *tenantPipe) cleanup() {
 
 		// Send tenant-specific notification
 		_ = tp.m.sendTenantNotif(tp.camp, models.CampaignStatusPaused, "Too many errors")
+		tp.m.sendTenantWebhook(tp.camp, models.CampaignStatusPaused, "Too many errors")
 		return
 	}
 
@@ -210,18 +333,43 @@ func (tp *tenantPipe) cleanup() {
 		return
 	}
 
-	// Mark as finished if it was running
+	// Mark as finished if it's still running. A status change since it was
+	// fetched (eg: a pause) is kept
 	if c.Status == models.CampaignStatusRunning || c.Status == models.CampaignStatusScheduled {
-		c.Status = models.CampaignStatusFinished
-		if err := tp.m.store.UpdateTenantCampaignStatus(tp.tenantID, tp.camp.ID, models.CampaignStatusFinished); err != nil {
+		ok, err := tp.m.store.UpdateTenantCampaignStatusFrom(tp.tenantID, tp.camp.ID, finishableStatuses, models.CampaignStatusFinished)
+		if err != nil {
 			tp.m.log.Printf("tenant %d: error finishing campaign (%s): %v", tp.tenantID, tp.camp.Name, err)
+		} else if !ok {
+			tp.m.log.Printf("tenant %d: campaign (%s) status changed before it could be finished", tp.tenantID, tp.camp.Name)
+			return
 		} else {
+			c.Status = models.CampaignStatusFinished
 			tp.m.log.Printf("tenant %d: campaign (%s) finished", tp.tenantID, tp.camp.Name)
 		}
 	} else {
 		tp.m.log.Printf("tenant %d: finish processing campaign (%s)", tp.tenantID, tp.camp.Name)
 	}
 
+	// Save the campaign's content for the tenant's public archive
+	if c.Status == models.CampaignStatusFinished && tp.camp.Archive {
+		msg := TenantCampaignMessage{TenantID: tp.tenantID, Campaign: tp.camp}
+		if err := json.Unmarshal(tp.camp.ArchiveMeta, &msg.Subscriber); err != nil {
+			tp.m.log.Printf("tenant %d: error reading campaign (%s) archive meta: %v", tp.tenantID, tp.camp.Name, err)
+		} else if err := msg.render(); err != nil {
+			tp.m.log.Printf("tenant %d: error rendering campaign (%s) for archive: %v", tp.tenantID, tp.camp.Name, err)
+		} else if err := tp.m.store.SaveTenantArchive(tp.tenantID, tp.camp.ID, msg.body); err != nil {
+			tp.m.log.Printf("tenant %d: error archiving campaign (%s): %v", tp.tenantID, tp.camp.Name, err)
+		}
+	}
+
+	// Flag campaigns that went out to no one so that the tenant knows
+	reason := ""
+	if c.Status == models.CampaignStatusFinished && c.Sent == 0 {
+		tp.m.log.Printf("tenant %d: campaign (%s) finished without any subscribers to send to", tp.tenantID, tp.camp.Name)
+		reason = ReasonNoSubscribers
+	}
+
 	// Send tenant-specific notification
-	_ = tp.m.sendTenantNotif(c, c.Status, "")
+	_ = tp.m.sendTenantNotif(c, c.Status, reason)
+	tp.m.sendTenantWebhook(c, c.Status, reason)
 }
\ No newline at end of file
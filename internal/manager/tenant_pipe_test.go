@@ -0,0 +1,80 @@
+package manager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// newIdleTenantInstance returns a tenant instance that isn't running, ie: has
+// no workers to drain its campaign message queue, which holds up to qSize messages.
+func newIdleTenantInstance(st *testStore, tenantID, qSize int, pushTimeout time.Duration) *tenantInstanceManager {
+	cfg := TenantConfig{Config: testConfig(), TenantID: tenantID, TenantMaxBatchSize: 10}
+	cfg.PushTimeout = pushTimeout
+	cfg.setTenantURLs(cfg.RootURL)
+
+	tim := &tenantInstanceManager{
+		tenantID:    tenantID,
+		store:       st,
+		cfg:         &cfg,
+		log:         testLogger(),
+		messengers:  map[string]Messenger{"email": NewMemoryMessenger("email")},
+		pipes:       make(map[int]*tenantPipe),
+		checkpoints: make(map[int]uint64),
+		rates:       make(map[int]int),
+		attachments: make(map[int][]models.Attachment),
+		links:       newLinkCache(0),
+		campMsgQ:    make(chan TenantCampaignMessage, qSize),
+		stopCh:      make(chan struct{}),
+		fnNotify:    func(int, string, any) error { return nil },
+	}
+	tim.ctx, tim.cancel = context.WithCancel(context.Background())
+
+	return tim
+}
+
+func TestTenantPipeFullQueue(t *testing.T) {
+	st := newTestStore()
+	tim := newIdleTenantInstance(st, 2, 2, time.Millisecond*100)
+
+	tp, err := tim.newTenantPipe(st.addCampaign(2, 1, 10, "email"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The queue fills up after two messages and the push of the third times out.
+	has, err := tp.NextSubscribers()
+	if err != nil || !has {
+		t.Fatalf("expected the pipe to be requeued, got %v, %v", has, err)
+	}
+	if cp := st.checkpoint(1); cp != 2 {
+		t.Errorf("expected the checkpoint to be rewound to subscriber 2, got %d", cp)
+	}
+
+	// A pipe waiting on the full queue returns as soon as the instance stops.
+	cfg := *tim.config()
+	cfg.PushTimeout = time.Hour
+	tim.ReloadConfig(cfg)
+	st.UpdateTenantCampaignCounts(2, 1, 0, 0, 1)
+	<-tim.campMsgQ
+
+	done := make(chan bool)
+	go func() {
+		has, _ := tp.NextSubscribers()
+		done <- has
+	}()
+
+	time.Sleep(time.Millisecond * 50)
+	close(tim.stopCh)
+
+	select {
+	case has := <-done:
+		if has {
+			t.Error("expected the pipe not to be requeued once the instance stops")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("pipe deadlocked on the full queue after the instance stopped")
+	}
+}
@@ -0,0 +1,49 @@
+package manager
+
+import (
+	"sync"
+	"time"
+)
+
+// throttle paces messages to a fixed number of messages per minute. It hands
+// out evenly spaced send slots and is safe for concurrent use by the workers.
+// It's used per campaign, where a zero rate means no per-campaign limit, and
+// as the budget shared by all tenants in multi-tenant mode.
+type throttle struct {
+	rate int
+	next time.Time
+	mut  sync.Mutex
+}
+
+// setRate sets the number of messages per minute. A rate < 1 disables the throttle.
+func (t *throttle) setRate(rate int) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if rate < 0 {
+		rate = 0
+	}
+	t.rate = rate
+	t.next = time.Time{}
+}
+
+// reserve reserves the next send slot and returns the duration to wait
+// until it. 0 is returned if the message can be sent right away.
+func (t *throttle) reserve() time.Duration {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if t.rate < 1 {
+		return 0
+	}
+
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+
+	wait := t.next.Sub(now)
+	t.next = t.next.Add(time.Minute / time.Duration(t.rate))
+
+	return wait
+}
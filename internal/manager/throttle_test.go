@@ -0,0 +1,61 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestThrottle(t *testing.T) {
+	var th throttle
+
+	// No rate, no waiting.
+	for i := 0; i < 3; i++ {
+		if wait := th.reserve(); wait != 0 {
+			t.Fatalf("expected no wait without a rate, got %v", wait)
+		}
+	}
+
+	// 60 per minute is a slot every second.
+	th.setRate(60)
+	if wait := th.reserve(); wait != 0 {
+		t.Errorf("expected the first slot right away, got %v", wait)
+	}
+	for i := 1; i <= 3; i++ {
+		wait := th.reserve()
+		if want := time.Duration(i) * time.Second; wait > want || wait < want-time.Millisecond*100 {
+			t.Errorf("expected slot %d in ~%v, got %v", i, want, wait)
+		}
+	}
+
+	// Removing the rate discards the reserved slots.
+	th.setRate(0)
+	if wait := th.reserve(); wait != 0 {
+		t.Errorf("expected no wait after removing the rate, got %v", wait)
+	}
+}
+
+func TestCampaignRates(t *testing.T) {
+	st := newTestStore()
+	m, msgr := newTestManager(t, testConfig(), st)
+
+	// A slow campaign at 2 messages per second and a fast one at 10.
+	m.SetCampaignRate(1, 120)
+	m.SetCampaignRate(2, 600)
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 1, 4, "email"))
+	startManagerPipe(t, m, st.addCampaign(legacyTenantID, 2, 15, "email"))
+
+	time.Sleep(time.Millisecond * 1100)
+
+	counts := map[int]int{}
+	for _, msg := range msgr.Sent() {
+		counts[msg.Campaign.ID]++
+	}
+
+	// Slots at 0, 0.5 and 1s, and at every 100ms.
+	if n := counts[1]; n < 2 || n > 3 {
+		t.Errorf("expected 2-3 messages from the slow campaign, got %d", n)
+	}
+	if n := counts[2]; n < 9 || n > 12 {
+		t.Errorf("expected 9-12 messages from the fast campaign, got %d", n)
+	}
+}
@@ -0,0 +1,165 @@
+package manager
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// webhookSignatureHeader is the header that carries the HMAC-SHA256
+// signature of a webhook's body, signed with the tenant's webhook secret.
+const webhookSignatureHeader = "X-Listmonk-Signature"
+
+// maxWebhookRedirects is the number of redirects a webhook request follows.
+const maxWebhookRedirects = 3
+
+// webhookClient posts webhooks to the URLs that tenants configure. It only
+// connects to public addresses over https, including when following
+// redirects, so that webhooks can't be used to reach internal services.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		// Proxies from the environment would be dialed instead of the
+		// webhook's host, bypassing the address check.
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: checkWebhookAddr,
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxWebhookRedirects {
+			return errors.New("too many webhook redirects")
+		}
+		return checkWebhookURL(req.URL)
+	},
+}
+
+// campaignWebhook is the payload posted to the campaign status webhook.
+type campaignWebhook struct {
+	TenantID   int       `json:"tenant_id"`
+	CampaignID int       `json:"campaign_id"`
+	Name       string    `json:"name"`
+	Status     string    `json:"status"`
+	Sent       int       `json:"sent"`
+	ToSend     int       `json:"to_send"`
+	Reason     string    `json:"reason"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// sendTenantWebhook posts a campaign's status change to the tenant's
+// campaign status webhook in the background, if the tenant has webhooks
+// enabled and a URL configured.
+func (tim *tenantInstanceManager) sendTenantWebhook(c *models.Campaign, status, reason string) {
+	if !tim.config().TenantWebhooksEnabled || tim.config().TenantWebhookURL == "" {
+		return
+	}
+
+	p := campaignWebhook{
+		TenantID:   tim.tenantID,
+		CampaignID: c.ID,
+		Name:       c.Name,
+		Status:     status,
+		Sent:       c.Sent,
+		ToSend:     c.ToSend,
+		Reason:     reason,
+		Timestamp:  time.Now(),
+	}
+
+	go func() {
+		if err := postWebhook(tim.config().TenantWebhookURL, tim.config().TenantWebhookSecret, p); err != nil {
+			tim.log.Printf("tenant %d: error posting campaign (%s) status webhook: %v", tim.tenantID, c.Name, err)
+		}
+	}()
+}
+
+// postWebhook posts the payload as JSON to the URL. If secret is set, the
+// body's HMAC-SHA256 signature is sent in the signature header.
+func postWebhook(rawURL, secret string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	if err := checkWebhookURL(u); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if secret != "" {
+		req.Header.Set(webhookSignatureHeader, signWebhook(secret, body))
+	}
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+// checkWebhookURL checks that a webhook URL is an https one.
+func checkWebhookURL(u *url.URL) error {
+	if u.Scheme != "https" || u.Hostname() == "" {
+		return fmt.Errorf("webhook URL is not https: %s", u.Redacted())
+	}
+	return nil
+}
+
+// checkWebhookAddr is the webhook dialer's hook that's called with the
+// resolved IP address of every connection before it's made. It rejects
+// loopback, private, link-local and other non-public addresses.
+func checkWebhookAddr(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !isPublicIP(ip) {
+		return fmt.Errorf("webhook address %s is not allowed", host)
+	}
+	return nil
+}
+
+// isPublicIP checks if an IP is a publicly routable unicast address.
+func isPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// signWebhook returns the hex encoded HMAC-SHA256 signature of the body
+// prefixed with the algorithm, eg: sha256=abcd..
+func signWebhook(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,54 @@
+package manager
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for ip, public := range map[string]bool{
+		"93.184.216.34":    true,
+		"2606:4700::1111":  true,
+		"127.0.0.1":        false,
+		"::1":              false,
+		"10.1.2.3":         false,
+		"172.16.0.1":       false,
+		"192.168.1.1":      false,
+		"169.254.169.254":  false,
+		"fe80::1":          false,
+		"fd00::1":          false,
+		"0.0.0.0":          false,
+		"::ffff:127.0.0.1": false,
+		"224.0.0.1":        false,
+	} {
+		if got := isPublicIP(net.ParseIP(ip)); got != public {
+			t.Errorf("isPublicIP(%s) = %v, want %v", ip, got, public)
+		}
+	}
+}
+
+func TestPostWebhookRejectsInternal(t *testing.T) {
+	var hit bool
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hit = true
+	}))
+	defer srv.Close()
+
+	// Loopback over https.
+	err := postWebhook(srv.URL, "", map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "not allowed") {
+		t.Errorf("expected loopback webhook to be rejected, got %v", err)
+	}
+
+	// Plain http.
+	if err := postWebhook("http://example.com/hook", "", map[string]string{}); err == nil {
+		t.Error("expected http webhook to be rejected")
+	}
+
+	if hit {
+		t.Error("webhook server shouldn't have been reached")
+	}
+}
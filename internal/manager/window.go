@@ -0,0 +1,87 @@
+package manager
+
+import (
+	"fmt"
+	"time"
+)
+
+// sendWindowCheckInterval is the maximum duration for which a pipe waits for
+// the send window to open before checking on the campaign again, so that
+// paused or cancelled campaigns and shutdowns aren't held up.
+const sendWindowCheckInterval = time.Second * 10
+
+// sendWindow restricts the sending of campaign messages to a time of the day,
+// eg: business hours. The window can span midnight (eg: 22:00 - 06:00).
+type sendWindow struct {
+	start time.Duration
+	end   time.Duration
+	loc   *time.Location
+}
+
+// newSendWindow returns a send window for the given config. If the window
+// is not configured, nil is returned, which is a valid no-op window.
+func newSendWindow(cfg Config) (*sendWindow, error) {
+	if cfg.SendWindowStart == "" && cfg.SendWindowEnd == "" {
+		return nil, nil
+	}
+
+	start, err := parseTimeOfDay(cfg.SendWindowStart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid send window start: %v", err)
+	}
+	end, err := parseTimeOfDay(cfg.SendWindowEnd)
+	if err != nil {
+		return nil, fmt.Errorf("invalid send window end: %v", err)
+	}
+	if start == end {
+		return nil, nil
+	}
+
+	loc := time.Local
+	if cfg.SendWindowTimezone != "" {
+		l, err := time.LoadLocation(cfg.SendWindowTimezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid send window timezone: %v", err)
+		}
+		loc = l
+	}
+
+	return &sendWindow{start: start, end: end, loc: loc}, nil
+}
+
+// wait returns the duration until the window opens if the given time
+// is outside it. 0 is returned if the time is within the window.
+func (w *sendWindow) wait(now time.Time) time.Duration {
+	if w == nil {
+		return 0
+	}
+
+	now = now.In(w.loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, w.loc)
+	tod := now.Sub(midnight)
+
+	if w.start < w.end {
+		// Window within the same day.
+		if tod >= w.start && tod < w.end {
+			return 0
+		}
+	} else if tod >= w.start || tod < w.end {
+		// Window that spans midnight.
+		return 0
+	}
+
+	if tod < w.start {
+		return w.start - tod
+	}
+	return midnight.AddDate(0, 0, 1).Add(w.start).Sub(now)
+}
+
+// parseTimeOfDay parses a HH:MM time of the day into the duration since midnight.
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
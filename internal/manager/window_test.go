@@ -0,0 +1,74 @@
+package manager
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSendWindow(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		tm, err := time.ParseInLocation("2006-01-02 15:04", "2024-03-10 "+hhmm, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	for _, c := range []struct {
+		start, end, now string
+		wait            time.Duration
+	}{
+		// Business hours.
+		{"09:00", "17:00", "09:00", 0},
+		{"09:00", "17:00", "16:59", 0},
+		{"09:00", "17:00", "08:30", time.Minute * 30},
+		{"09:00", "17:00", "17:00", time.Hour * 16},
+		{"09:00", "17:00", "23:00", time.Hour * 10},
+
+		// Overnight.
+		{"22:00", "06:00", "23:00", 0},
+		{"22:00", "06:00", "05:59", 0},
+		{"22:00", "06:00", "06:00", time.Hour * 16},
+		{"22:00", "06:00", "21:00", time.Hour},
+	} {
+		w, err := newSendWindow(Config{SendWindowStart: c.start, SendWindowEnd: c.end, SendWindowTimezone: "UTC"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if wait := w.wait(at(c.now)); wait != c.wait {
+			t.Errorf("%s-%s at %s: expected to wait %v, got %v", c.start, c.end, c.now, c.wait, wait)
+		}
+	}
+}
+
+func TestSendWindowConfig(t *testing.T) {
+	// No window, or an empty one, is unrestricted.
+	for _, cfg := range []Config{{}, {SendWindowStart: "10:00", SendWindowEnd: "10:00"}} {
+		w, err := newSendWindow(cfg)
+		if err != nil || w != nil {
+			t.Errorf("expected no window for %+v, got %v, %v", cfg, w, err)
+		}
+		if wait := w.wait(time.Now()); wait != 0 {
+			t.Errorf("expected a nil window not to wait, got %v", wait)
+		}
+	}
+
+	for _, cfg := range []Config{
+		{SendWindowStart: "9am", SendWindowEnd: "17:00"},
+		{SendWindowStart: "09:00", SendWindowEnd: "25:00"},
+		{SendWindowStart: "09:00", SendWindowEnd: "17:00", SendWindowTimezone: "Mars/Olympus"},
+	} {
+		if _, err := newSendWindow(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+
+	// The window is in the configured timezone.
+	w, err := newSendWindow(Config{SendWindowStart: "09:00", SendWindowEnd: "17:00", SendWindowTimezone: "Asia/Kolkata"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wait := w.wait(time.Date(2024, 3, 10, 4, 0, 0, 0, time.UTC)); wait != 0 {
+		t.Errorf("expected 09:30 IST to be in the window, got a wait of %v", wait)
+	}
+}
@@ -1,106 +1,300 @@
 package media
 
 import (
-    "bytes"
-    "fmt"
-    "io"
-    "path/filepath"
-    "strings"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// ErrQuotaExceeded is returned when storing a file would take a tenant
+// over its storage quota.
+var ErrQuotaExceeded = errors.New("tenant storage quota exceeded")
+
+// QuotaFunc returns the storage limit of a tenant in bytes (0 for no limit)
+// and the number of bytes the tenant's files currently take up.
+type QuotaFunc func(tenantID int) (limit, used int64, err error)
+
+// tenantUsage is the cached storage limit and usage of a tenant.
+type tenantUsage struct {
+	limit int64
+	used  int64
+}
+
+// defaultTenantID is the tenant that the plain Store interface methods
+// operate on for backwards compatibility.
+const defaultTenantID = 1
+
 // TenantStore wraps a media Store with tenant-aware functionality.
+// It implements Store itself, where the Store methods operate on the
+// default tenant, and the *ForTenant methods on an explicit tenant.
 type TenantStore struct {
-    store   Store
-    enabled bool
-    basePath string
+	store    Store
+	enabled  bool
+	basePath string
+	log      *log.Logger
+
+	// quota, if set, is used to load a tenant's storage usage, which is then
+	// cached in usage and kept up to date on every Put.
+	quota QuotaFunc
+	usage map[int]*tenantUsage
+	mut   sync.Mutex
+
+	// Status of the current (or the last) media migration.
+	migration MigrationStatus
+	migMut    sync.Mutex
 }
 
-// NewTenantStore creates a new tenant-aware media store.
-func NewTenantStore(store Store, tenantModeEnabled bool, basePath string) *TenantStore {
-    if basePath == "" {
-        basePath = "uploads"
-    }
-    return &TenantStore{
-        store:   store,
-        enabled: tenantModeEnabled,
-        basePath: basePath,
-    }
+var _ Store = (*TenantStore)(nil)
+
+// NewTenantStore creates a new tenant-aware media store. If lo is nil,
+// the standard logger is used.
+func NewTenantStore(store Store, tenantModeEnabled bool, basePath string, lo *log.Logger) *TenantStore {
+	if basePath == "" {
+		basePath = "uploads"
+	}
+	if lo == nil {
+		lo = log.Default()
+	}
+	return &TenantStore{
+		store:    store,
+		enabled:  tenantModeEnabled,
+		basePath: basePath,
+		log:      lo,
+		usage:    make(map[int]*tenantUsage),
+	}
 }
 
-// tenantPath generates a tenant-specific path for media files.
+// SetQuotaFunc sets the function that returns the storage quota and usage
+// of tenants. Once set, files that would take a tenant over its quota are
+// rejected with ErrQuotaExceeded.
+func (ts *TenantStore) SetQuotaFunc(fn QuotaFunc) {
+	ts.mut.Lock()
+	ts.quota = fn
+	ts.usage = make(map[int]*tenantUsage)
+	ts.mut.Unlock()
+}
+
+// ResetUsage discards the cached storage usage of a tenant so that it's
+// loaded afresh on the next Put, eg: after the tenant's quota is changed.
+func (ts *TenantStore) ResetUsage(tenantID int) {
+	ts.mut.Lock()
+	delete(ts.usage, tenantID)
+	ts.mut.Unlock()
+}
+
+// reserve adds size bytes to the tenant's storage usage if it's within
+// the tenant's quota, or returns ErrQuotaExceeded.
+func (ts *TenantStore) reserve(tenantID int, size int64) error {
+	ts.mut.Lock()
+	defer ts.mut.Unlock()
+
+	if ts.quota == nil {
+		return nil
+	}
+
+	u, ok := ts.usage[tenantID]
+	if !ok {
+		limit, used, err := ts.quota(tenantID)
+		if err != nil {
+			return fmt.Errorf("error fetching storage quota for tenant %d: %w", tenantID, err)
+		}
+
+		u = &tenantUsage{limit: limit, used: used}
+		ts.usage[tenantID] = u
+	}
+
+	if u.limit > 0 && u.used+size > u.limit {
+		return ErrQuotaExceeded
+	}
+
+	u.used += size
+	return nil
+}
+
+// release removes size bytes from the tenant's cached storage usage.
+func (ts *TenantStore) release(tenantID int, size int64) {
+	ts.mut.Lock()
+	defer ts.mut.Unlock()
+
+	if u, ok := ts.usage[tenantID]; ok {
+		u.used = max(u.used-size, 0)
+	}
+}
+
+// fileSize returns the size of a file and rewinds it to the beginning.
+func fileSize(file io.ReadSeeker) (int64, error) {
+	size, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// tenantPath generates a tenant-specific path for media files. Paths always
+// use forward slashes irrespective of the OS as they're also used as
+// object keys by stores such as S3.
 func (ts *TenantStore) tenantPath(tenantID int, filename string) string {
-    if !ts.enabled || tenantID <= 0 {
-        return filename
-    }
-    tenantDir := fmt.Sprintf("tenants/%d/media", tenantID)
-    return filepath.Join(tenantDir, filename)
+	if !ts.enabled || tenantID <= 0 {
+		return filename
+	}
+	tenantDir := fmt.Sprintf("tenants/%d/media", tenantID)
+	return path.Join(tenantDir, toSlash(filename))
+}
+
+// extractTenantFromPath returns the tenant ID and the filename (relative to the
+// tenant's media directory) from a tenant path generated by tenantPath. Both
+// forward and backward slashes are accepted as separators and the path is
+// cleaned first so that ../ can't be used to step into another tenant's directory.
+func extractTenantFromPath(p string) (int, string, bool) {
+	parts := strings.SplitN(strings.TrimPrefix(path.Clean(toSlash(p)), "/"), "/", 4)
+	if len(parts) != 4 || parts[0] != "tenants" || parts[2] != "media" || parts[3] == "" {
+		return 0, "", false
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil || id <= 0 {
+		return 0, "", false
+	}
+
+	return id, parts[3], true
+}
+
+// toSlash converts backslash separators in a path to forward slashes.
+func toSlash(p string) string {
+	return strings.ReplaceAll(p, "\\", "/")
 }
 
 // validateTenantAccess ensures the file belongs to the specified tenant.
 func (ts *TenantStore) validateTenantAccess(tenantID int, filename string) error {
-    if !ts.enabled {
-        return nil
-    }
-    if tenantID <= 0 {
-        return fmt.Errorf("invalid tenant ID: %d", tenantID)
-    }
-    expectedPrefix := fmt.Sprintf("tenants/%d/media/", tenantID)
-    if !strings.HasPrefix(filename, expectedPrefix) && !strings.HasPrefix(filename, "/"+expectedPrefix) {
-        return fmt.Errorf("file does not belong to tenant %d: %s", tenantID, filename)
-    }
-    return nil
-}
-
-// Put stores a file with tenant isolation.
-func (ts *TenantStore) Put(tenantID int, filename, cType string, file io.ReadSeeker) (string, error) {
-    tenantPath := ts.tenantPath(tenantID, filename)
-    savedPath, err := ts.store.Put(tenantPath, cType, file)
-    if err != nil {
-        return "", fmt.Errorf("failed to store file for tenant %d: %w", tenantID, err)
-    }
-    return savedPath, nil
-}
-
-// Get retrieves a file with tenant validation.
-func (ts *TenantStore) Get(tenantID int, filename string) (io.ReadCloser, error) {
-    if err := ts.validateTenantAccess(tenantID, filename); err != nil {
-        return nil, err
-    }
-    b, err := ts.store.GetBlob(filename)
-    if err != nil {
-        return nil, err
-    }
-    return io.NopCloser(bytes.NewReader(b)), nil
-}
-
-// Delete removes a file with tenant validation.
-func (ts *TenantStore) Delete(tenantID int, filename string) error {
-    if err := ts.validateTenantAccess(tenantID, filename); err != nil {
-        return err
-    }
-    return ts.store.Delete(filename)
-}
-
-// GetURL generates a URL for a file with tenant validation.
-func (ts *TenantStore) GetURL(tenantID int, filename string) string {
-    if err := ts.validateTenantAccess(tenantID, filename); err != nil {
-        fmt.Printf("Warning: Potential cross-tenant access to %s by tenant %d: %v\n", filename, tenantID, err)
-    }
-    return ts.store.GetURL(filename)
+	if !ts.enabled {
+		return nil
+	}
+	if tenantID <= 0 {
+		return fmt.Errorf("invalid tenant ID: %d", tenantID)
+	}
+	if id, _, ok := extractTenantFromPath(filename); !ok || id != tenantID {
+		return fmt.Errorf("file does not belong to tenant %d: %s", tenantID, filename)
+	}
+	return nil
+}
+
+// PutForTenant stores a file with tenant isolation. If a quota function is set,
+// files that would take the tenant over its quota are rejected with ErrQuotaExceeded.
+func (ts *TenantStore) PutForTenant(tenantID int, filename, cType string, file io.ReadSeeker) (string, error) {
+	size, err := fileSize(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file for tenant %d: %w", tenantID, err)
+	}
+
+	if err := ts.reserve(tenantID, size); err != nil {
+		return "", err
+	}
+
+	tenantPath := ts.tenantPath(tenantID, filename)
+	savedPath, err := ts.store.Put(tenantPath, cType, file)
+	if err != nil {
+		ts.release(tenantID, size)
+		return "", fmt.Errorf("failed to store file for tenant %d: %w", tenantID, err)
+	}
+	return savedPath, nil
+}
+
+// GetForTenant retrieves a file with tenant validation.
+func (ts *TenantStore) GetForTenant(tenantID int, filename string) (io.ReadCloser, error) {
+	if err := ts.validateTenantAccess(tenantID, filename); err != nil {
+		return nil, err
+	}
+	b, err := ts.store.GetBlob(filename)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(b)), nil
+}
+
+// DeleteForTenant removes a file with tenant validation.
+func (ts *TenantStore) DeleteForTenant(tenantID int, filename string) error {
+	if err := ts.validateTenantAccess(tenantID, filename); err != nil {
+		return err
+	}
+
+	// The store doesn't report file sizes, so the cached usage is discarded
+	// and reloaded from the quota function on the next Put.
+	ts.ResetUsage(tenantID)
+
+	return ts.store.Delete(filename)
+}
+
+// CleanupTenantFiles removes the given media files of a tenant from the store.
+// Filenames that aren't tenant paths are resolved to the tenant's media directory.
+// It attempts to delete all the files and returns the errors, if any, together.
+func (ts *TenantStore) CleanupTenantFiles(tenantID int, filenames []string) error {
+	var errs []error
+	for _, f := range filenames {
+		if !ts.ValidateMediaAccess(tenantID, f) {
+			f = ts.tenantPath(tenantID, f)
+		}
+
+		if err := ts.DeleteForTenant(tenantID, f); err != nil {
+			errs = append(errs, fmt.Errorf("error deleting %s: %w", f, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// GetURLForTenant generates a URL for a file with tenant validation.
+func (ts *TenantStore) GetURLForTenant(tenantID int, filename string) string {
+	if err := ts.validateTenantAccess(tenantID, filename); err != nil {
+		ts.log.Printf("potential cross-tenant access to %s by tenant %d: %v", filename, tenantID, err)
+	}
+	return ts.store.GetURL(filename)
+}
+
+// Put stores a file for the default tenant. It implements Store.
+func (ts *TenantStore) Put(filename, cType string, file io.ReadSeeker) (string, error) {
+	return ts.PutForTenant(defaultTenantID, filename, cType, file)
+}
+
+// Delete removes a file of the default tenant. It implements Store.
+func (ts *TenantStore) Delete(filename string) error {
+	return ts.DeleteForTenant(defaultTenantID, filename)
+}
+
+// GetURL generates a URL for a file of the default tenant. It implements Store.
+func (ts *TenantStore) GetURL(filename string) string {
+	return ts.GetURLForTenant(defaultTenantID, filename)
+}
+
+// GetBlob retrieves the bytes of a file of the default tenant. It implements Store.
+func (ts *TenantStore) GetBlob(filename string) ([]byte, error) {
+	if err := ts.validateTenantAccess(defaultTenantID, filename); err != nil {
+		return nil, err
+	}
+	return ts.store.GetBlob(filename)
 }
 
 // ValidateMediaAccess validates that a media file belongs to the specified tenant.
 func (ts *TenantStore) ValidateMediaAccess(tenantID int, filename string) bool {
-    return ts.validateTenantAccess(tenantID, filename) == nil
+	return ts.validateTenantAccess(tenantID, filename) == nil
 }
 
 // GetTenantPath returns the tenant-specific path for a filename.
 func (ts *TenantStore) GetTenantPath(tenantID int, filename string) string {
-    return ts.tenantPath(tenantID, filename)
+	return ts.tenantPath(tenantID, filename)
 }
 
 // IsEnabled returns whether tenant mode is enabled.
 func (ts *TenantStore) IsEnabled() bool {
-    return ts.enabled
+	return ts.enabled
 }
-
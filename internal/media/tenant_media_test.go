@@ -0,0 +1,153 @@
+package media
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memStore is an in-memory Store.
+type memStore struct {
+	files map[string][]byte
+	mut   sync.Mutex
+}
+
+func newMemStore() *memStore {
+	return &memStore{files: make(map[string][]byte)}
+}
+
+func (s *memStore) Put(name, cType string, file io.ReadSeeker) (string, error) {
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+
+	s.mut.Lock()
+	s.files[name] = b
+	s.mut.Unlock()
+	return name, nil
+}
+
+func (s *memStore) Delete(name string) error {
+	s.mut.Lock()
+	delete(s.files, name)
+	s.mut.Unlock()
+	return nil
+}
+
+func (s *memStore) GetURL(name string) string {
+	return "http://listmonk.test/" + name
+}
+
+func (s *memStore) GetBlob(name string) ([]byte, error) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	b, ok := s.files[name]
+	if !ok {
+		return nil, os.ErrNotExist
+	}
+	return b, nil
+}
+
+func TestGetURLForTenantLogsCrossTenantAccess(t *testing.T) {
+	var buf bytes.Buffer
+	ts := NewTenantStore(newMemStore(), true, "", log.New(&buf, "", 0))
+
+	if u := ts.GetURLForTenant(2, "tenants/2/media/a.png"); u != "http://listmonk.test/tenants/2/media/a.png" {
+		t.Errorf("unexpected URL: %s", u)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("unexpected log for the tenant's own file: %s", buf.String())
+	}
+
+	ts.GetURLForTenant(3, "tenants/2/media/a.png")
+	if !strings.Contains(buf.String(), "potential cross-tenant access to tenants/2/media/a.png by tenant 3") {
+		t.Errorf("expected the cross-tenant access to be logged, got %q", buf.String())
+	}
+}
+
+func TestPutForTenantQuota(t *testing.T) {
+	var loads int
+	ts := NewTenantStore(newMemStore(), true, "", nil)
+	ts.SetQuotaFunc(func(tenantID int) (int64, int64, error) {
+		loads++
+		if tenantID == 2 {
+			return 100, 40, nil
+		}
+		return 0, 0, nil
+	})
+
+	// 40 + 59 is just under the quota.
+	if _, err := ts.PutForTenant(2, "a.png", "image/png", bytes.NewReader(make([]byte, 59))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := ts.PutForTenant(2, "b.png", "image/png", bytes.NewReader(make([]byte, 2))); err != ErrQuotaExceeded {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	// The last byte still fits.
+	if _, err := ts.PutForTenant(2, "c.png", "image/png", bytes.NewReader(make([]byte, 1))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if loads != 1 {
+		t.Errorf("expected the usage to be loaded once and cached, got %d loads", loads)
+	}
+
+	// Tenants without a limit aren't restricted.
+	if _, err := ts.PutForTenant(3, "a.png", "image/png", bytes.NewReader(make([]byte, 1000))); err != nil {
+		t.Fatalf("unexpected error for the unlimited tenant: %v", err)
+	}
+}
+
+func TestDeleteForTenantResetsUsage(t *testing.T) {
+	used := int64(0)
+	ts := NewTenantStore(newMemStore(), true, "", nil)
+	ts.SetQuotaFunc(func(tenantID int) (int64, int64, error) {
+		return 10, used, nil
+	})
+
+	p, err := ts.PutForTenant(2, "a.png", "image/png", bytes.NewReader(make([]byte, 10)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	used = 10
+	if _, err := ts.PutForTenant(2, "b.png", "image/png", bytes.NewReader(make([]byte, 1))); err != ErrQuotaExceeded {
+		t.Fatalf("expected ErrQuotaExceeded, got %v", err)
+	}
+
+	if err := ts.DeleteForTenant(2, p); err != nil {
+		t.Fatalf("error deleting: %v", err)
+	}
+	used = 0
+	if _, err := ts.PutForTenant(2, "b.png", "image/png", bytes.NewReader(make([]byte, 1))); err != nil {
+		t.Fatalf("expected the upload to fit after the delete, got %v", err)
+	}
+}
+
+func TestPutForTenantReleasesOnError(t *testing.T) {
+	ts := NewTenantStore(&failStore{newMemStore()}, true, "", nil)
+	ts.SetQuotaFunc(func(tenantID int) (int64, int64, error) {
+		return 10, 0, nil
+	})
+
+	for i := 0; i < 3; i++ {
+		if _, err := ts.PutForTenant(2, "a.png", "image/png", bytes.NewReader(make([]byte, 5))); err == nil || err == ErrQuotaExceeded {
+			t.Fatalf("expected the store's error, got %v", err)
+		}
+	}
+}
+
+// failStore is a Store that fails to store files.
+type failStore struct {
+	*memStore
+}
+
+func (*failStore) Put(string, string, io.ReadSeeker) (string, error) {
+	return "", os.ErrPermission
+}
@@ -0,0 +1,165 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"gopkg.in/volatiletech/null.v6"
+)
+
+// ErrMigrationRunning is returned when a migration is started while another
+// one is in progress.
+var ErrMigrationRunning = errors.New("a media migration is already running")
+
+// MigrationFile is a media file to be moved into its tenant's directory.
+type MigrationFile struct {
+	TenantID    int    `db:"tenant_id"`
+	Filename    string `db:"filename"`
+	ContentType string `db:"content_type"`
+}
+
+// MigrationStatus represents the progress of a tenant media migration.
+type MigrationStatus struct {
+	Running  bool     `json:"running"`
+	Total    int      `json:"total"`
+	Migrated int      `json:"migrated"`
+	Skipped  int      `json:"skipped"`
+	Errors   []string `json:"errors"`
+	Current  string   `json:"current"`
+
+	// Checkpoint is the number of files that have been processed so far.
+	Checkpoint int `json:"checkpoint"`
+
+	StartedAt  null.Time `json:"started_at"`
+	FinishedAt null.Time `json:"finished_at"`
+}
+
+// MigrateFunc is called after a file is copied to its tenant path so that
+// references to the file (eg: in the DB) can be updated. The original file
+// is deleted only if it returns without an error.
+type MigrateFunc func(f MigrationFile, newPath string) error
+
+// Migrate moves the given files into their tenants' directories in the
+// background. The latest status is sent on the returned channel, which is
+// closed when the migration is complete, and can also be queried any time with
+// MigrationStatus(). Files that are already under their tenant's directory are
+// skipped, so an interrupted migration can safely be run again.
+func (ts *TenantStore) Migrate(files []MigrationFile, fn MigrateFunc) (<-chan MigrationStatus, error) {
+	if !ts.enabled {
+		return nil, errors.New("tenant media storage is not enabled")
+	}
+
+	ts.migMut.Lock()
+	if ts.migration.Running {
+		ts.migMut.Unlock()
+		return nil, ErrMigrationRunning
+	}
+	ts.migration = MigrationStatus{
+		Running:   true,
+		Total:     len(files),
+		Errors:    []string{},
+		StartedAt: null.TimeFrom(time.Now()),
+	}
+	ts.migMut.Unlock()
+
+	ch := make(chan MigrationStatus, 1)
+	go func() {
+		defer close(ch)
+
+		for _, f := range files {
+			ts.updateMigration(func(s *MigrationStatus) {
+				s.Current = f.Filename
+			})
+
+			migrated, err := ts.migrateFile(f, fn)
+			st := ts.updateMigration(func(s *MigrationStatus) {
+				switch {
+				case err != nil:
+					s.Errors = append(s.Errors, fmt.Sprintf("%s: %v", f.Filename, err))
+				case migrated:
+					s.Migrated++
+				default:
+					s.Skipped++
+				}
+				s.Checkpoint++
+			})
+			sendLatest(ch, st)
+		}
+
+		st := ts.updateMigration(func(s *MigrationStatus) {
+			s.Running = false
+			s.Current = ""
+			s.FinishedAt = null.TimeFrom(time.Now())
+		})
+		sendLatest(ch, st)
+	}()
+
+	return ch, nil
+}
+
+// MigrationStatus returns the status of the current (or the last) migration.
+func (ts *TenantStore) MigrationStatus() MigrationStatus {
+	ts.migMut.Lock()
+	defer ts.migMut.Unlock()
+
+	return ts.migration.copy()
+}
+
+// migrateFile copies a file to its tenant path and deletes the original. It
+// returns false if the file has already been migrated.
+func (ts *TenantStore) migrateFile(f MigrationFile, fn MigrateFunc) (bool, error) {
+	if ts.ValidateMediaAccess(f.TenantID, f.Filename) {
+		return false, nil
+	}
+
+	b, err := ts.store.GetBlob(f.Filename)
+	if err != nil {
+		return false, fmt.Errorf("error reading file: %w", err)
+	}
+
+	newPath, err := ts.store.Put(ts.tenantPath(f.TenantID, f.Filename), f.ContentType, bytes.NewReader(b))
+	if err != nil {
+		return false, fmt.Errorf("error copying file: %w", err)
+	}
+
+	if fn != nil {
+		if err := fn(f, newPath); err != nil {
+			ts.store.Delete(newPath)
+			return false, err
+		}
+	}
+
+	if err := ts.store.Delete(f.Filename); err != nil {
+		return true, fmt.Errorf("error deleting the original file: %w", err)
+	}
+
+	return true, nil
+}
+
+// updateMigration applies fn to the migration status and returns a copy of it.
+func (ts *TenantStore) updateMigration(fn func(s *MigrationStatus)) MigrationStatus {
+	ts.migMut.Lock()
+	defer ts.migMut.Unlock()
+
+	fn(&ts.migration)
+	return ts.migration.copy()
+}
+
+// copy returns a copy of the status that doesn't share the errors slice.
+func (s MigrationStatus) copy() MigrationStatus {
+	s.Errors = append([]string{}, s.Errors...)
+	return s
+}
+
+// sendLatest sends the status on the buffered channel ch, replacing an earlier
+// status that's yet to be received so that the migration never blocks on a
+// slow (or absent) reader.
+func sendLatest(ch chan MigrationStatus, s MigrationStatus) {
+	select {
+	case <-ch:
+	default:
+	}
+	ch <- s
+}
@@ -0,0 +1,133 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// waitMigration drains the status channel and returns the last status.
+func waitMigration(t *testing.T, ch <-chan MigrationStatus) (MigrationStatus, int) {
+	t.Helper()
+
+	var (
+		last MigrationStatus
+		n    int
+	)
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case st, ok := <-ch:
+			if !ok {
+				return last, n
+			}
+			if st.Checkpoint < last.Checkpoint {
+				t.Fatalf("checkpoint went back from %d to %d", last.Checkpoint, st.Checkpoint)
+			}
+			last = st
+			n++
+		case <-timeout:
+			t.Fatal("timed out waiting for the migration")
+		}
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	ms := newMemStore()
+	ts := NewTenantStore(ms, true, "", nil)
+
+	var files []MigrationFile
+	for i := 0; i < 100; i++ {
+		f := MigrationFile{TenantID: i%3 + 1, Filename: fmt.Sprintf("file%d.png", i), ContentType: "image/png"}
+
+		// Every 10th file has already been migrated.
+		if i%10 == 0 {
+			f.Filename = ts.tenantPath(f.TenantID, f.Filename)
+		}
+		ms.Put(f.Filename, f.ContentType, bytes.NewReader([]byte(f.Filename)))
+		files = append(files, f)
+	}
+
+	// The names of the migrated files as they would be updated in the DB.
+	renamed := make(map[string]string)
+	ch, err := ts.Migrate(files, func(f MigrationFile, newPath string) error {
+		if f.Filename == "file55.png" {
+			return errors.New("error updating the DB")
+		}
+		renamed[f.Filename] = newPath
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error starting the migration: %v", err)
+	}
+
+	st, n := waitMigration(t, ch)
+	if n == 0 {
+		t.Fatal("expected progress updates")
+	}
+	if st.Running || !st.FinishedAt.Valid || st.Current != "" {
+		t.Errorf("expected a finished migration, got %+v", st)
+	}
+	if st.Total != 100 || st.Checkpoint != 100 || st.Migrated != 89 || st.Skipped != 10 || len(st.Errors) != 1 {
+		t.Errorf("unexpected status: %+v", st)
+	}
+	if len(renamed) != 89 {
+		t.Errorf("expected 89 renamed files, got %d", len(renamed))
+	}
+	if got := ts.MigrationStatus(); got.Migrated != st.Migrated || got.Running {
+		t.Errorf("unexpected queried status: %+v", got)
+	}
+
+	// The failed file stays where it was and the rest are moved.
+	if _, err := ms.GetBlob("file55.png"); err != nil {
+		t.Errorf("expected the failed file to be kept: %v", err)
+	}
+	if _, err := ms.GetBlob(ts.tenantPath(2, "file55.png")); err == nil {
+		t.Error("expected the copy of the failed file to be removed")
+	}
+	if b, err := ms.GetBlob(ts.tenantPath(2, "file1.png")); err != nil || string(b) != "file1.png" {
+		t.Errorf("expected file1.png to be migrated: %v", err)
+	}
+	if _, err := ms.GetBlob("file1.png"); err == nil {
+		t.Error("expected the original file1.png to be deleted")
+	}
+
+	// Running the migration again only picks up the failed file.
+	for i, f := range files {
+		if p, ok := renamed[f.Filename]; ok {
+			files[i].Filename = p
+		}
+	}
+	ch, err = ts.Migrate(files, nil)
+	if err != nil {
+		t.Fatalf("error restarting the migration: %v", err)
+	}
+	if st, _ := waitMigration(t, ch); st.Migrated != 1 || st.Skipped != 99 || len(st.Errors) != 0 {
+		t.Errorf("unexpected status of the second run: %+v", st)
+	}
+}
+
+func TestMigrateRunning(t *testing.T) {
+	ts := NewTenantStore(newMemStore(), true, "", nil)
+
+	block := make(chan struct{})
+	ch, err := ts.Migrate([]MigrationFile{{TenantID: 1, Filename: "a.png"}}, func(MigrationFile, string) error {
+		<-block
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("error starting the migration: %v", err)
+	}
+
+	if _, err := ts.Migrate(nil, nil); err != ErrMigrationRunning {
+		t.Errorf("expected ErrMigrationRunning, got %v", err)
+	}
+	close(block)
+	waitMigration(t, ch)
+
+	if _, err := NewTenantStore(newMemStore(), false, "", nil).Migrate(nil, nil); err == nil {
+		t.Error("expected an error when tenant storage isn't enabled")
+	}
+}
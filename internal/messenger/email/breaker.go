@@ -0,0 +1,125 @@
+package email
+
+import (
+	"errors"
+	"net/textproto"
+	"sync"
+	"time"
+)
+
+// defaultBreakerCooldown is the duration for which a server's circuit stays
+// open if the server doesn't specify one.
+const defaultBreakerCooldown = time.Second * 30
+
+// ErrServersUnavailable is returned when the circuits of all of an Emailer's
+// servers are open after repeated failures.
+var ErrServersUnavailable = errors.New("SMTP servers unavailable after repeated failures (circuit open)")
+
+// breaker is a circuit breaker for an SMTP server. After threshold consecutive
+// failures, the circuit opens and no messages are sent via the server for the
+// cooldown, after which a single probe message is let through (half-open).
+// A successful probe closes the circuit and a failed one reopens it.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mut       sync.Mutex
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// newBreaker returns a circuit breaker. If threshold is 0, nil is returned,
+// which is a valid no-op breaker that's always closed.
+func newBreaker(threshold int, cooldown time.Duration) *breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	if cooldown <= 0 {
+		cooldown = defaultBreakerCooldown
+	}
+
+	return &breaker{threshold: threshold, cooldown: cooldown}
+}
+
+// ready reports whether a message can be sent via the server without
+// claiming the half-open probe.
+func (b *breaker) ready(now time.Time) bool {
+	if b == nil {
+		return true
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	return b.openUntil.IsZero() || (!now.Before(b.openUntil) && !b.probing)
+}
+
+// allow reports whether a message can be sent via the server. If the
+// cooldown of an open circuit has elapsed, the caller's message is the
+// probe, which is indicated by the second return value, and every other
+// message is held off until it's done().
+func (b *breaker) allow(now time.Time) (bool, bool) {
+	if b == nil {
+		return true, false
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if b.openUntil.IsZero() {
+		return true, false
+	}
+	if now.Before(b.openUntil) || b.probing {
+		return false, false
+	}
+
+	b.probing = true
+	return true, true
+}
+
+// done records the result of a message that was allowed. probe is whether
+// the message was the half-open probe. Messages that were allowed before the
+// circuit opened may finish while the probe is in flight and don't end it.
+func (b *breaker) done(probe bool, err error, now time.Time) {
+	if b == nil {
+		return
+	}
+
+	b.mut.Lock()
+	defer b.mut.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+	if err == nil {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = now.Add(b.cooldown)
+	}
+}
+
+// release gives up a message that was allowed but wasn't sent, without
+// recording a result. If it was the probe, the next message can probe.
+func (b *breaker) release(probe bool) {
+	if b == nil || !probe {
+		return
+	}
+
+	b.mut.Lock()
+	b.probing = false
+	b.mut.Unlock()
+}
+
+// isRejection checks if an error is the server permanently rejecting a
+// recipient or the message, eg: 550 for an unknown mailbox at RCPT TO. It's
+// a failure of that message alone and shows that the server is up.
+func isRejection(err error) bool {
+	var tErr *textproto.Error
+	return errors.As(err, &tErr) && tErr.Code >= 550 && tErr.Code <= 559
+}
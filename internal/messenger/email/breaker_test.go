@@ -0,0 +1,82 @@
+package email
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestBreakerProbe(t *testing.T) {
+	var (
+		b   = newBreaker(1, time.Minute)
+		now = time.Now()
+	)
+
+	// A message allowed before the circuit opens.
+	if ok, probe := b.allow(now); !ok || probe {
+		t.Fatal("expected a closed circuit to allow messages")
+	}
+	b.done(false, errors.New("timeout"), now)
+
+	if ok, _ := b.allow(now); ok {
+		t.Fatal("expected the circuit to be open")
+	}
+
+	later := now.Add(time.Minute)
+	ok, probe := b.allow(later)
+	if !ok || !probe {
+		t.Fatal("expected a probe after the cooldown")
+	}
+
+	// Another message finishing while the probe is in flight doesn't end it.
+	b.done(false, errors.New("timeout"), later)
+	if ok, _ := b.allow(later.Add(time.Minute)); ok {
+		t.Fatal("expected messages to be held off while the probe is in flight")
+	}
+
+	b.done(true, nil, later)
+	if ok, probe := b.allow(later); !ok || probe {
+		t.Fatal("expected a successful probe to close the circuit")
+	}
+}
+
+func TestIsRejection(t *testing.T) {
+	for err, want := range map[error]bool{
+		&textproto.Error{Code: 550, Msg: "no such user"}:                    true,
+		fmt.Errorf("rcpt: %w", &textproto.Error{Code: 553, Msg: "invalid"}): true,
+		&textproto.Error{Code: 535, Msg: "authentication failed"}:           false,
+		&textproto.Error{Code: 421, Msg: "try again later"}:                 false,
+		errors.New("connection refused"):                                    false,
+	} {
+		if got := isRejection(err); got != want {
+			t.Errorf("%v: expected %v, got %v", err, want, got)
+		}
+	}
+}
+
+func TestNextServerSkipsOpenCircuits(t *testing.T) {
+	newServer := func(name string) *Server {
+		return &Server{Name: name, inflight: new(atomic.Int32), breaker: newBreaker(1, time.Minute)}
+	}
+
+	var (
+		a = newServer("a")
+		b = newServer("b")
+		e = &Emailer{servers: []*Server{a, b}}
+	)
+	a.breaker.done(false, errors.New("timeout"), time.Now())
+
+	for i := 0; i < 3; i++ {
+		if s, _ := e.nextServer(); s != b {
+			t.Fatalf("expected server b, got %v", s)
+		}
+	}
+
+	b.breaker.done(false, errors.New("timeout"), time.Now())
+	if s, _ := e.nextServer(); s != nil {
+		t.Fatalf("expected no server, got %v", s.Name)
+	}
+}
@@ -0,0 +1,306 @@
+package email
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// smtpStub is a minimal SMTP server that accepts every message and
+// records their raw DATA. If it has a username, it requires AUTH PLAIN
+// with the username and password.
+type smtpStub struct {
+	ln   net.Listener
+	msgs []string
+	mut  sync.Mutex
+
+	username string
+	password string
+}
+
+func newSMTPStub(t *testing.T) *smtpStub {
+	t.Helper()
+	return newAuthSMTPStub(t, "", "")
+}
+
+func newAuthSMTPStub(t *testing.T, username, password string) *smtpStub {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{ln: ln, username: username, password: password}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+
+	return s
+}
+
+func (s *smtpStub) serve(c net.Conn) {
+	defer c.Close()
+
+	var (
+		r = bufio.NewReader(c)
+		w = func(l string) { c.Write([]byte(l + "\r\n")) }
+	)
+	w("220 stub")
+	for {
+		l, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		switch cmd := strings.ToUpper(strings.TrimSpace(l)); {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			if s.username == "" {
+				w("250 stub")
+			} else {
+				w("250-stub")
+				w("250 AUTH PLAIN")
+			}
+		case strings.HasPrefix(cmd, "AUTH PLAIN"):
+			cred, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimSpace(l)[len("AUTH PLAIN"):]))
+			if string(cred) != "\x00"+s.username+"\x00"+s.password {
+				w("535 authentication failed")
+				continue
+			}
+			w("235 authenticated")
+		case cmd == "DATA":
+			w("354 go ahead")
+
+			var b strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				b.WriteString(l)
+			}
+			s.mut.Lock()
+			s.msgs = append(s.msgs, b.String())
+			s.mut.Unlock()
+			w("250 queued")
+		case cmd == "QUIT":
+			w("221 bye")
+			return
+		default:
+			w("250 ok")
+		}
+	}
+}
+
+func (s *smtpStub) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *smtpStub) messages() []string {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	return append([]string{}, s.msgs...)
+}
+
+// newDKIMKey returns a new RSA key and its PEM encoding.
+func newDKIMKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return key, string(b)
+}
+
+// verifyDKIM parses the DKIM-Signature header of a raw message and verifies
+// the signature with the public key.
+func verifyDKIM(t *testing.T, raw []byte, pub *rsa.PublicKey) map[string]string {
+	t.Helper()
+
+	raw = toCRLF(raw)
+	i := bytes.Index(raw, []byte("\r\n\r\n"))
+	if i < 0 {
+		t.Fatal("message has no body")
+	}
+	fields := dkimHeaderFields(raw[:i+2])
+	body := raw[i+4:]
+
+	var sigField string
+	for _, f := range fields {
+		if strings.HasPrefix(strings.ToLower(f), "dkim-signature:") {
+			sigField = f
+			break
+		}
+	}
+	if sigField == "" {
+		t.Fatal("no DKIM-Signature header")
+	}
+
+	tags := make(map[string]string)
+	_, val, _ := strings.Cut(sigField, ":")
+	for _, tag := range strings.Split(val, ";") {
+		k, v, ok := strings.Cut(strings.TrimSpace(tag), "=")
+		if !ok {
+			t.Fatalf("malformed DKIM-Signature tag %q", tag)
+		}
+		tags[k] = strings.Join(strings.Fields(v), "")
+	}
+	for _, k := range []string{"v", "a", "c", "d", "s", "t", "h", "bh", "b"} {
+		if tags[k] == "" {
+			t.Fatalf("DKIM-Signature has no %s= tag: %s", k, sigField)
+		}
+	}
+	if _, err := strconv.ParseInt(tags["t"], 10, 64); err != nil {
+		t.Fatalf("invalid t= tag: %v", err)
+	}
+
+	bh := sha256.Sum256(dkimRelaxedBody(body))
+	if got := base64.StdEncoding.EncodeToString(bh[:]); got != tags["bh"] {
+		t.Fatalf("body hash mismatch: expected %s, got %s", got, tags["bh"])
+	}
+
+	// Hash the signed headers bottom-up followed by the signature header with an empty b=.
+	h := sha256.New()
+	used := make(map[int]bool)
+	for _, name := range strings.Split(tags["h"], ":") {
+		for j := len(fields) - 1; j >= 0; j-- {
+			n, _, _ := strings.Cut(fields[j], ":")
+			if !used[j] && strings.EqualFold(strings.TrimSpace(n), name) {
+				used[j] = true
+				h.Write([]byte(dkimRelaxedHeader(fields[j])))
+				break
+			}
+		}
+	}
+	unsigned := strings.TrimRight(sigField[:strings.LastIndex(sigField, "b=")+2], "\r\n")
+	h.Write([]byte(strings.TrimSuffix(dkimRelaxedHeader(unsigned), "\r\n")))
+
+	sig, err := base64.StdEncoding.DecodeString(tags["b"])
+	if err != nil {
+		t.Fatalf("invalid b= tag: %v", err)
+	}
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, h.Sum(nil), sig); err != nil {
+		t.Fatalf("invalid DKIM signature: %v", err)
+	}
+
+	return tags
+}
+
+func TestDKIMSign(t *testing.T) {
+	key, pemKey := newDKIMKey(t)
+	d, err := newDKIMSigner(DKIMConf{Domain: "tenant.test", Selector: "lm", PrivateKey: pemKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw := "From: Tenant <news@tenant.test>\nTo: sub@example.com\nSubject:  Hello \n\tthere\n" +
+		"X-Custom: not signed\n\nHello  world \n\n\n"
+	signed, err := d.sign([]byte(raw))
+	if err != nil {
+		t.Fatalf("error signing: %v", err)
+	}
+
+	tags := verifyDKIM(t, signed, &key.PublicKey)
+	if tags["d"] != "tenant.test" || tags["s"] != "lm" || tags["a"] != "rsa-sha256" || tags["c"] != "relaxed/relaxed" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if tags["h"] != "from:subject:to" {
+		t.Errorf("unexpected signed headers: %s", tags["h"])
+	}
+
+	// Tampering with the body breaks the signature.
+	tampered := bytes.Replace(signed, []byte("Hello  world"), []byte("Hello there"), 1)
+	tb := sha256.Sum256(dkimRelaxedBody(tampered[bytes.Index(tampered, []byte("\r\n\r\n"))+4:]))
+	if base64.StdEncoding.EncodeToString(tb[:]) == tags["bh"] {
+		t.Error("expected the body hash to change with the body")
+	}
+
+	if _, err := d.sign([]byte("Subject: no from\r\n\r\nbody")); err == nil {
+		t.Error("expected an error for a message without a From header")
+	}
+}
+
+func TestNewDKIMSignerInvalid(t *testing.T) {
+	_, pemKey := newDKIMKey(t)
+	for _, c := range []DKIMConf{
+		{Domain: "tenant.test", PrivateKey: pemKey},
+		{Domain: "tenant.test", Selector: "lm", PrivateKey: "not a key"},
+	} {
+		if _, err := newDKIMSigner(c); err == nil {
+			t.Errorf("expected an error for %+v", c)
+		}
+	}
+}
+
+func TestPushDKIM(t *testing.T) {
+	key, pemKey := newDKIMKey(t)
+	stub := newSMTPStub(t)
+
+	te, _ := newCachingTenantEmailer(t)
+	emailer := func(dkim *DKIMConf) *Emailer {
+		e, err := te.createEmailerFromConfig(&TenantSMTPConfig{
+			TenantID: 2,
+			SMTP:     []SMTPConf{{Enabled: true, Host: "127.0.0.1", Port: stub.port(), TLSType: "none"}},
+			DKIM:     dkim,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { e.Close() })
+		return e
+	}
+
+	msg := models.Message{
+		From:        "Tenant <news@tenant.test>",
+		To:          []string{"sub@example.com"},
+		Subject:     "Hello",
+		ContentType: "plain",
+		Body:        []byte("Hello world"),
+	}
+
+	// A tenant with a key sends signed messages.
+	if err := emailer(&DKIMConf{Domain: "tenant.test", Selector: "lm", PrivateKey: pemKey}).Push(msg); err != nil {
+		t.Fatalf("error sending signed message: %v", err)
+	}
+
+	// A tenant without one sends them unsigned.
+	if err := emailer(nil).Push(msg); err != nil {
+		t.Fatalf("error sending unsigned message: %v", err)
+	}
+
+	// The stub records messages before accepting them.
+	msgs := stub.messages()
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 messages, got %d", len(msgs))
+	}
+
+	verifyDKIM(t, []byte(msgs[0]), &key.PublicKey)
+	if strings.Contains(strings.ToLower(msgs[1]), "dkim-signature:") {
+		t.Error("expected the message of the tenant without a key to be unsigned")
+	}
+}
@@ -1,12 +1,18 @@
 package email
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
-	"math/rand"
+	"net"
+	"net/mail"
 	"net/smtp"
 	"net/textproto"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/knadh/listmonk/models"
 	"github.com/knadh/smtppool/v2"
@@ -18,6 +24,10 @@ const (
 	hdrReturnPath = "Return-Path"
 	hdrBcc        = "Bcc"
 	hdrCc         = "Cc"
+
+	// connTimeout is the deadline for a message sent on a connection that's
+	// opened outside the pool, from dialing to QUIT.
+	connTimeout = time.Minute
 )
 
 // Server represents an SMTP server's credentials.
@@ -31,18 +41,54 @@ type Server struct {
 	TLSSkipVerify bool              `json:"tls_skip_verify"`
 	EmailHeaders  map[string]string `json:"email_headers"`
 
+	// Optional DKIM key with which messages sent via the server are signed.
+	DKIM *DKIMConf `json:"dkim,omitempty"`
+
+	// Weight is the server's relative share of messages when there are
+	// multiple servers. Defaults to 1.
+	Weight int `json:"weight"`
+
+	// After CircuitBreakerThreshold consecutive failures, the server is
+	// skipped for CircuitBreakerCooldown (30s if empty), after which a
+	// single message probes it. 0 disables the circuit breaker.
+	CircuitBreakerThreshold int           `json:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  time.Duration `json:"circuit_breaker_cooldown"`
+
 	// Rest of the options are embedded directly from the smtppool lib.
 	// The JSON tag is for config unmarshal to work.
 	//lint:ignore SA5008 ,squash is needed by koanf/mapstructure config unmarshal.
 	smtppool.Opt `json:",squash"`
 
 	pool *smtppool.Pool
+	dkim *dkimSigner
+
+	// DKIM signed messages are sent outside the pool, and this limits them
+	// to MaxConns connections at a time like the pool.
+	signedConns chan struct{}
+
+	// Current weight for the smooth weighted round-robin, guarded by
+	// the Emailer's mutex, and the number of messages being sent.
+	current  int
+	inflight *atomic.Int32
+
+	breaker *breaker
+}
+
+// ServerStatus is the result of verifying connectivity to an SMTP server.
+type ServerStatus struct {
+	Name  string `json:"name"`
+	Host  string `json:"host"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
 }
 
 // Emailer is the SMTP e-mail messenger.
 type Emailer struct {
 	servers []*Server
 	name    string
+
+	// Guards the round-robin state of the servers.
+	mut sync.Mutex
 }
 
 // New returns an SMTP e-mail Messenger backend with the given SMTP servers.
@@ -90,12 +136,25 @@ func New(name string, servers ...Server) (*Emailer, error) {
 			}
 		}
 
+		if s.DKIM != nil && s.DKIM.PrivateKey != "" {
+			d, err := newDKIMSigner(*s.DKIM)
+			if err != nil {
+				return nil, fmt.Errorf("error loading DKIM key for '%s': %v", s.Name, err)
+			}
+			s.dkim = d
+		}
+
 		pool, err := smtppool.New(s.Opt)
 		if err != nil {
 			return nil, err
 		}
 
 		s.pool = pool
+		if s.dkim != nil {
+			s.signedConns = make(chan struct{}, s.MaxConns)
+		}
+		s.inflight = new(atomic.Int32)
+		s.breaker = newBreaker(s.CircuitBreakerThreshold, s.CircuitBreakerCooldown)
 		e.servers = append(e.servers, &s)
 	}
 
@@ -108,18 +167,31 @@ func (e *Emailer) Name() string {
 }
 
 // Push pushes a message to the server.
-func (e *Emailer) Push(m models.Message) error {
-	// If there are more than one SMTP servers, send to a random
-	// one from the list.
-	var (
-		ln  = len(e.servers)
-		srv *Server
-	)
-	if ln > 1 {
-		srv = e.servers[rand.Intn(ln)]
-	} else {
-		srv = e.servers[0]
+func (e *Emailer) Push(m models.Message) (err error) {
+	// If there are more than one SMTP servers, pick one by their weights.
+	srv, probe := e.nextServer()
+	if srv == nil {
+		return ErrServersUnavailable
 	}
+	srv.inflight.Add(1)
+	defer srv.inflight.Add(-1)
+
+	// Record the result of the send on the server's circuit breaker. Messages
+	// that fail before they're sent, eg: attachments, say nothing about the server.
+	// Nor do recipients or messages that the server rejects.
+	sending := false
+	defer func() {
+		if !sending {
+			srv.breaker.release(probe)
+			return
+		}
+
+		bErr := err
+		if isRejection(err) {
+			bErr = nil
+		}
+		srv.breaker.done(probe, bErr, time.Now())
+	}()
 
 	// Are there attachments?
 	var files []smtppool.Attachment
@@ -129,9 +201,20 @@ func (e *Emailer) Push(m models.Message) error {
 			a := smtppool.Attachment{
 				Filename: f.Name,
 				Header:   f.Header,
-				Content:  make([]byte, len(f.Content)),
 			}
-			copy(a.Content, f.Content)
+
+			// Attachments that are loaded on demand are fresh for every
+			// message and needn't be copied.
+			if f.Loader != nil {
+				b, err := f.Loader()
+				if err != nil {
+					return fmt.Errorf("error loading attachment %s: %v", f.Name, err)
+				}
+				a.Content = b
+			} else {
+				a.Content = make([]byte, len(f.Content))
+				copy(a.Content, f.Content)
+			}
 			files = append(files, a)
 		}
 	}
@@ -189,18 +272,270 @@ func (e *Emailer) Push(m models.Message) error {
 		}
 	}
 
+	// The pool renders the MIME message itself while sending, so DKIM signed
+	// messages are rendered and signed here and sent on a connection of their own.
+	sending = true
+	if srv.dkim != nil {
+		return srv.sendSigned(em)
+	}
+
 	return srv.pool.Send(em)
 }
 
+// nextServer picks the server to send a message with using smooth weighted
+// round-robin. Servers that are already sending MaxConns messages are
+// skipped unless all the servers are busy. Servers with open circuits are
+// always skipped, and nil is returned if there are none left. The second
+// return value is whether the message is the server's circuit breaker probe.
+func (e *Emailer) nextServer() (*Server, bool) {
+	now := time.Now()
+	if len(e.servers) == 1 {
+		s := e.servers[0]
+		if ok, probe := s.breaker.allow(now); ok {
+			return s, probe
+		}
+		return nil, false
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	// Servers whose circuits turned out to be closed to the message after
+	// they were picked.
+	skip := make(map[*Server]bool)
+
+	pick := func(skipBusy bool) *Server {
+		var (
+			best  *Server
+			total int
+		)
+		for _, s := range e.servers {
+			if skip[s] || !s.breaker.ready(now) {
+				continue
+			}
+			if skipBusy && s.MaxConns > 0 && int(s.inflight.Load()) >= s.MaxConns {
+				continue
+			}
+
+			w := max(s.Weight, 1)
+			s.current += w
+			total += w
+			if best == nil || s.current > best.current {
+				best = s
+			}
+		}
+
+		if best != nil {
+			best.current -= total
+		}
+		return best
+	}
+
+	// A ready server's circuit may have opened, or its probe may have been
+	// claimed, by the time it's picked. Fall through to the next one.
+	for {
+		s := pick(true)
+		if s == nil {
+			s = pick(false)
+		}
+		if s == nil {
+			return nil, false
+		}
+
+		if ok, probe := s.breaker.allow(now); ok {
+			return s, probe
+		}
+		skip[s] = true
+	}
+}
+
+// sendSigned renders the e-mail, signs it with the server's DKIM key, and
+// sends it on a new connection outside the pool. Like the pool, it waits
+// upto PoolWaitTimeout for one of the server's MaxConns connections to free up.
+func (s *Server) sendSigned(em smtppool.Email) error {
+	raw, err := em.Bytes()
+	if err != nil {
+		return err
+	}
+
+	signed, err := s.dkim.sign(raw)
+	if err != nil {
+		return err
+	}
+
+	sender := em.Sender
+	if sender == "" {
+		sender = em.From
+	}
+	from, err := mail.ParseAddress(sender)
+	if err != nil {
+		return fmt.Errorf("invalid sender '%s': %v", sender, err)
+	}
+
+	var rcpts []string
+	for _, list := range [][]string{em.To, em.Cc, em.Bcc} {
+		for _, r := range list {
+			a, err := mail.ParseAddress(r)
+			if err != nil {
+				return fmt.Errorf("invalid recipient '%s': %v", r, err)
+			}
+			rcpts = append(rcpts, a.Address)
+		}
+	}
+
+	wait := s.PoolWaitTimeout
+	if wait < time.Second {
+		wait = time.Second * 2
+	}
+	t := time.NewTimer(wait)
+	select {
+	case s.signedConns <- struct{}{}:
+		t.Stop()
+		defer func() { <-s.signedConns }()
+	case <-t.C:
+		return fmt.Errorf("timed out waiting for a free connection to '%s'", s.Name)
+	}
+
+	cl, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	if err := cl.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, r := range rcpts {
+		if err := cl.Rcpt(r); err != nil {
+			return err
+		}
+	}
+
+	w, err := cl.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(signed); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	return cl.Quit()
+}
+
+// PushWithContext pushes a message to the server like Push unless the context
+// has already been cancelled or its deadline has expired. A send that's started
+// can't be cancelled. Returning early while it continues would have the caller
+// retry a message that may still be delivered and deliver it twice.
+func (e *Emailer) PushWithContext(ctx context.Context, m models.Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return e.Push(m)
+}
+
+// VerifyServers dials each SMTP server outside of the pool and runs
+// EHLO, STARTTLS and AUTH (as configured) on it, returning the status
+// of every server.
+func (e *Emailer) VerifyServers() []ServerStatus {
+	out := make([]ServerStatus, 0, len(e.servers))
+	for _, s := range e.servers {
+		st := ServerStatus{Name: s.Name, Host: s.Host}
+		if err := s.verify(); err != nil {
+			st.Error = err.Error()
+		} else {
+			st.OK = true
+		}
+		out = append(out, st)
+	}
+
+	return out
+}
+
+// verify opens a connection to the server and authenticates with it.
+func (s *Server) verify() error {
+	cl, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	return cl.Quit()
+}
+
+// dial opens a connection to the server outside the pool and runs EHLO,
+// STARTTLS and AUTH (as configured) on it. Everything on the connection has
+// to be done within connTimeout.
+func (s *Server) dial() (*smtp.Client, error) {
+	var (
+		addr    = net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+		timeout = s.PoolWaitTimeout
+	)
+	if timeout == 0 {
+		timeout = time.Second * 5
+	}
+
+	var (
+		conn net.Conn
+		err  error
+	)
+	if s.SSL == smtppool.SSLTLS {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: timeout}, "tcp", addr, s.TLSConfig)
+	} else {
+		conn, err = net.DialTimeout("tcp", addr, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.SetDeadline(time.Now().Add(connTimeout)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	cl, err := smtp.NewClient(conn, s.Host)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if s.HelloHostname != "" {
+		if err := cl.Hello(s.HelloHostname); err != nil {
+			cl.Close()
+			return nil, err
+		}
+	}
+
+	if s.SSL == smtppool.SSLSTARTTLS {
+		if err := cl.StartTLS(s.TLSConfig); err != nil {
+			cl.Close()
+			return nil, err
+		}
+	}
+
+	if s.Auth != nil {
+		if err := cl.Auth(s.Auth); err != nil {
+			cl.Close()
+			return nil, err
+		}
+	}
+
+	return cl, nil
+}
+
 // Flush flushes the message queue to the server.
 func (e *Emailer) Flush() error {
 	return nil
 }
 
-// Close closes the SMTP pools.
+// Close drains and closes the SMTP connection pools of all the servers.
 func (e *Emailer) Close() error {
 	for _, s := range e.servers {
-		s.pool.Close()
+		if s.pool != nil {
+			s.pool.Close()
+		}
 	}
 	return nil
 }
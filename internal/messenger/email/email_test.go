@@ -0,0 +1,128 @@
+package email
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/knadh/smtppool/v2"
+)
+
+func newWeightedServer(name string, weight, maxConns int) *Server {
+	return &Server{
+		Name:     name,
+		Weight:   weight,
+		Opt:      smtppool.Opt{MaxConns: maxConns},
+		inflight: new(atomic.Int32),
+		breaker:  newBreaker(0, 0),
+	}
+}
+
+func TestNextServerWeights(t *testing.T) {
+	var (
+		a = newWeightedServer("a", 1, 0)
+		b = newWeightedServer("b", 2, 0)
+		c = newWeightedServer("c", 3, 0)
+		e = &Emailer{servers: []*Server{a, b, c}}
+	)
+
+	counts := make(map[string]int)
+	for i := 0; i < 600; i++ {
+		s, _ := e.nextServer()
+		counts[s.Name]++
+	}
+	if counts["a"] != 100 || counts["b"] != 200 || counts["c"] != 300 {
+		t.Errorf("expected a 1:2:3 distribution, got %v", counts)
+	}
+
+	// The smooth round-robin interleaves servers instead of sending
+	// bursts to the heaviest one.
+	var seq string
+	for i := 0; i < 6; i++ {
+		s, _ := e.nextServer()
+		seq += s.Name
+	}
+	if seq != "cbacbc" {
+		t.Errorf("unexpected sequence: %s", seq)
+	}
+}
+
+func TestNextServerDefaultWeight(t *testing.T) {
+	e := &Emailer{servers: []*Server{newWeightedServer("a", 0, 0), newWeightedServer("b", 0, 0)}}
+
+	counts := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		s, _ := e.nextServer()
+		counts[s.Name]++
+	}
+	if counts["a"] != 5 || counts["b"] != 5 {
+		t.Errorf("expected an even distribution, got %v", counts)
+	}
+}
+
+func TestNextServerMaxConns(t *testing.T) {
+	var (
+		a = newWeightedServer("a", 10, 1)
+		b = newWeightedServer("b", 1, 0)
+		e = &Emailer{servers: []*Server{a, b}}
+	)
+
+	// The heavy server is busy with its only connection.
+	a.inflight.Add(1)
+	for i := 0; i < 5; i++ {
+		if s, _ := e.nextServer(); s != b {
+			t.Fatalf("expected the busy server to be skipped, got %s", s.Name)
+		}
+	}
+
+	// When all the servers are busy, they're picked by weight anyway.
+	b.MaxConns = 1
+	b.inflight.Add(1)
+	if s, _ := e.nextServer(); s == nil {
+		t.Fatal("expected a server when all of them are busy")
+	}
+}
+
+func TestPushWeighted(t *testing.T) {
+	stubs := []*smtpStub{newSMTPStub(t), newSMTPStub(t), newSMTPStub(t)}
+
+	var servers []Server
+	for i, s := range stubs {
+		servers = append(servers, Server{
+			Name:    string(rune('a' + i)),
+			TLSType: "none",
+			Weight:  i + 1,
+			Opt: smtppool.Opt{
+				Host:        "127.0.0.1",
+				Port:        s.port(),
+				MaxConns:    2,
+				IdleTimeout: time.Minute,
+			},
+		})
+	}
+	e, err := New(MessengerName, servers...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer e.Close()
+
+	msg := models.Message{
+		From:        "news@listmonk.test",
+		To:          []string{"sub@example.com"},
+		Subject:     "Hello",
+		ContentType: "plain",
+		Body:        []byte("Hello"),
+	}
+	for i := 0; i < 60; i++ {
+		if err := e.Push(msg); err != nil {
+			t.Fatalf("error sending message %d: %v", i, err)
+		}
+	}
+
+	for i, s := range stubs {
+		if got, want := len(s.messages()), (i+1)*10; got != want {
+			t.Errorf("server %d: expected %d messages, got %d", i, want, got)
+		}
+	}
+}
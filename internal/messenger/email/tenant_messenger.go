@@ -0,0 +1,56 @@
+package email
+
+import (
+	"errors"
+
+	"github.com/knadh/listmonk/models"
+)
+
+// TenantMessenger adapts a TenantEmailer to the campaign manager's Messenger
+// interface. Every message is sent with the SMTP configuration of the tenant
+// that it's for (models.Message.TenantID). Messages without a tenant go out
+// via the fallback emailer.
+type TenantMessenger struct {
+	te *TenantEmailer
+}
+
+// NewTenantMessenger returns a Messenger that sends messages through the
+// given TenantEmailer.
+func NewTenantMessenger(te *TenantEmailer) *TenantMessenger {
+	return &TenantMessenger{te: te}
+}
+
+// Name returns the messenger's name. It's the same as the regular e-mail
+// messenger so that campaigns don't have to pick a different messenger.
+func (tm *TenantMessenger) Name() string {
+	return MessengerName
+}
+
+// Push sends a message using its tenant's emailer.
+func (tm *TenantMessenger) Push(m models.Message) error {
+	if m.TenantID < 1 {
+		if tm.te.fallbackEmailer == nil {
+			return errors.New("message has no tenant ID and there's no fallback emailer")
+		}
+		return tm.te.fallbackEmailer.Push(m)
+	}
+
+	e, release, err := tm.te.acquireEmailer(m.TenantID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return e.Push(m)
+}
+
+// Flush is a no-op as the emailers send messages synchronously.
+func (tm *TenantMessenger) Flush() error {
+	return nil
+}
+
+// Close closes the TenantEmailer and its cached emailers.
+func (tm *TenantMessenger) Close() error {
+	tm.te.Close()
+	return nil
+}
@@ -3,12 +3,16 @@ package email
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
 	"time"
 
 	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/internal/utils"
+	"github.com/knadh/listmonk/models"
+	"github.com/knadh/smtppool/v2"
 )
 
 // TenantSMTPConfig represents SMTP configuration for a specific tenant
@@ -16,16 +20,44 @@ type TenantSMTPConfig struct {
 	TenantID int                    `json:"tenant_id"`
 	SMTP     []SMTPConf            `json:"smtp"`
 	Default  string                `json:"default"` // Default SMTP server name
+	DKIM     *DKIMConf             `json:"dkim,omitempty"` // Optional DKIM signing key
 	Metadata map[string]interface{} `json:"metadata,omitempty"`
 }
 
+// SMTPConf represents a single SMTP server entry in the 'smtp' settings JSON.
+type SMTPConf struct {
+	Name          string              `json:"name"`
+	UUID          string              `json:"uuid"`
+	Enabled       bool                `json:"enabled"`
+	Host          string              `json:"host"`
+	HelloHostname string              `json:"hello_hostname"`
+	Port          int                 `json:"port"`
+	AuthProtocol  string              `json:"auth_protocol"`
+	Username      string              `json:"username"`
+	Password      string              `json:"password,omitempty"`
+	EmailHeaders  []map[string]string `json:"email_headers"`
+	MaxConns      int                 `json:"max_conns"`
+	Weight        int                 `json:"weight"`
+	MaxMsgRetries int                 `json:"max_msg_retries"`
+	IdleTimeout   string              `json:"idle_timeout"`
+	WaitTimeout   string              `json:"wait_timeout"`
+	TLSType       string              `json:"tls_type"`
+	TLSSkipVerify bool                `json:"tls_skip_verify"`
+
+	CircuitBreakerThreshold int    `json:"circuit_breaker_threshold"`
+	CircuitBreakerCooldown  string `json:"circuit_breaker_cooldown"`
+}
+
 // TenantEmailer manages per-tenant SMTP configurations
 type TenantEmailer struct {
 	db     *sqlx.DB
 	logger *log.Logger
 
+	// App-level key that SMTP passwords in tenant_settings are encrypted with
+	secretKey string
+
 	// Cache of tenant-specific emailers
-	tenantEmailers map[int]*Emailer
+	tenantEmailers map[int]*cachedEmailer
 	mu             sync.RWMutex
 
 	// Global fallback emailer (for backward compatibility)
@@ -41,12 +73,35 @@ type TenantEmailer struct {
 	cacheMu     sync.RWMutex
 }
 
-// NewTenantEmailer creates a new tenant-aware emailer
-func NewTenantEmailer(db *sqlx.DB, fallbackEmailer *Emailer, logger *log.Logger) *TenantEmailer {
+// cachedEmailer is a tenant's cached emailer. It's reference counted so that
+// when it's evicted or replaced, its SMTP pools are closed only after the
+// messages that are being sent through it are done.
+type cachedEmailer struct {
+	e       *Emailer
+	refs    int
+	retired bool
+}
+
+// retire marks the emailer as evicted from the cache and returns true if
+// nothing is sending through it and it can be closed. It should be called
+// with TenantEmailer.mu held.
+func (ce *cachedEmailer) retire() bool {
+	if ce == nil {
+		return false
+	}
+
+	ce.retired = true
+	return ce.refs == 0
+}
+
+// NewTenantEmailer creates a new tenant-aware emailer. secretKey is used to
+// decrypt the SMTP passwords stored in tenant_settings.
+func NewTenantEmailer(db *sqlx.DB, fallbackEmailer *Emailer, secretKey string, logger *log.Logger) *TenantEmailer {
 	te := &TenantEmailer{
 		db:              db,
 		logger:          logger,
-		tenantEmailers:  make(map[int]*Emailer),
+		secretKey:       secretKey,
+		tenantEmailers:  make(map[int]*cachedEmailer),
 		fallbackEmailer: fallbackEmailer,
 		cacheEnabled:    true,
 		cacheExpiry:     time.Hour, // Cache SMTP config for 1 hour
@@ -60,26 +115,96 @@ func NewTenantEmailer(db *sqlx.DB, fallbackEmailer *Emailer, logger *log.Logger)
 	return te
 }
 
-// GetEmailerForTenant returns the emailer instance for a specific tenant
+// GetEmailerForTenant returns the emailer instance for a specific tenant.
+// The emailer is closed once it's evicted from the cache, so messages should
+// be sent with Send() or SendMessage(), which hold on to it while sending.
 func (te *TenantEmailer) GetEmailerForTenant(tenantID int) (*Emailer, error) {
-	if !te.cacheEnabled {
-		return te.loadTenantEmailer(tenantID)
+	emailer, release, err := te.acquireEmailer(tenantID)
+	if err != nil {
+		return nil, err
 	}
+	release()
 
-	te.mu.RLock()
-	emailer, exists := te.tenantEmailers[tenantID]
-	te.mu.RUnlock()
+	return emailer, nil
+}
+
+// acquireEmailer returns the emailer for a tenant along with a function that
+// has to be called once the caller is done sending with it. A cached emailer
+// isn't closed until all of its acquirers have released it.
+func (te *TenantEmailer) acquireEmailer(tenantID int) (*Emailer, func(), error) {
+	if te.cacheEnabled && te.isCacheValid(tenantID) {
+		te.mu.Lock()
+		ce, ok := te.tenantEmailers[tenantID]
+		if ok {
+			ce.refs++
+		}
+		te.mu.Unlock()
 
-	if exists && te.isCacheValid(tenantID) {
-		return emailer, nil
+		if ok {
+			return ce.e, func() { te.release(tenantID, ce) }, nil
+		}
 	}
 
 	// Cache miss or expired, load fresh configuration
-	return te.loadTenantEmailer(tenantID)
+	ce, err := te.loadTenantEmailer(tenantID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if ce == nil {
+		return te.fallbackEmailer, func() {}, nil
+	}
+
+	return ce.e, func() { te.release(tenantID, ce) }, nil
+}
+
+// release releases a reference to a cached emailer that was acquired with
+// acquireEmailer() and closes it if it was evicted and this was the last one.
+func (te *TenantEmailer) release(tenantID int, ce *cachedEmailer) {
+	if ce == nil {
+		return
+	}
+
+	te.mu.Lock()
+	ce.refs--
+	done := ce.retired && ce.refs == 0
+	te.mu.Unlock()
+
+	if done {
+		te.closeEmailer(tenantID, ce.e)
+	}
 }
 
-// loadTenantEmailer loads SMTP configuration for a tenant and creates an emailer
-func (te *TenantEmailer) loadTenantEmailer(tenantID int) (*Emailer, error) {
+// WarmCache pre-loads and caches the emailers of the given tenants so that
+// their first messages don't pay for loading the SMTP configuration. Tenants
+// with valid cached emailers are skipped. It's a no-op if caching is disabled.
+func (te *TenantEmailer) WarmCache(tenantIDs []int) {
+	if !te.cacheEnabled {
+		return
+	}
+
+	for _, id := range tenantIDs {
+		te.mu.RLock()
+		_, exists := te.tenantEmailers[id]
+		te.mu.RUnlock()
+
+		if exists && te.isCacheValid(id) {
+			continue
+		}
+
+		ce, err := te.loadTenantEmailer(id)
+		if err != nil {
+			te.logger.Printf("Error warming SMTP cache for tenant %d: %v", id, err)
+			continue
+		}
+		te.release(id, ce)
+	}
+}
+
+// loadTenantEmailer loads SMTP configuration for a tenant, creates an emailer
+// and caches it. The cached emailer is returned with a reference acquired for
+// the caller, which has to release() it. It's nil if the tenant has to use the
+// fallback emailer.
+func (te *TenantEmailer) loadTenantEmailer(tenantID int) (*cachedEmailer, error) {
 	config, err := te.loadTenantSMTPConfig(tenantID)
 	if err != nil {
 		te.logger.Printf("Error loading SMTP config for tenant %d: %v", tenantID, err)
@@ -87,7 +212,7 @@ func (te *TenantEmailer) loadTenantEmailer(tenantID int) (*Emailer, error) {
 		// Fall back to global configuration if available
 		if te.fallbackEmailer != nil {
 			te.logger.Printf("Using fallback SMTP for tenant %d", tenantID)
-			return te.fallbackEmailer, nil
+			return nil, nil
 		}
 		
 		return nil, fmt.Errorf("no SMTP configuration available for tenant %d: %v", tenantID, err)
@@ -100,34 +225,55 @@ func (te *TenantEmailer) loadTenantEmailer(tenantID int) (*Emailer, error) {
 		
 		// Fall back to global configuration
 		if te.fallbackEmailer != nil {
-			return te.fallbackEmailer, nil
+			return nil, nil
 		}
 		
 		return nil, err
 	}
 
-	// Cache the emailer
 	te.mu.Lock()
-	te.tenantEmailers[tenantID] = emailer
-	te.mu.Unlock()
+
+	// Another caller may have cached a fresh emailer for the tenant in the
+	// meantime. Use that and discard this one that nobody else has seen.
+	old := te.tenantEmailers[tenantID]
+	if old != nil && te.isCacheValid(tenantID) {
+		old.refs++
+		te.mu.Unlock()
+
+		te.closeEmailer(tenantID, emailer)
+		return old, nil
+	}
+
+	// Cache the emailer. The one it replaces, if any, is closed once the
+	// messages being sent through it are done.
+	ce := &cachedEmailer{e: emailer, refs: 1}
+	te.tenantEmailers[tenantID] = ce
 
 	te.cacheMu.Lock()
 	te.lastRefresh[tenantID] = time.Now()
 	te.cacheMu.Unlock()
 
+	closeOld := old.retire()
+	te.mu.Unlock()
+
+	if closeOld {
+		te.closeEmailer(tenantID, old.e)
+	}
+
 	te.logger.Printf("Created emailer for tenant %d with %d SMTP servers", tenantID, len(config.SMTP))
-	return emailer, nil
+	return ce, nil
 }
 
 // loadTenantSMTPConfig loads SMTP configuration from tenant_settings
 func (te *TenantEmailer) loadTenantSMTPConfig(tenantID int) (*TenantSMTPConfig, error) {
 	// Query tenant-specific SMTP settings
-	var smtpValue, defaultValue []byte
+	var smtpValue, defaultValue, dkimValue []byte
 	err := te.db.QueryRow(`
 		SELECT 
 			COALESCE((SELECT value FROM tenant_settings WHERE tenant_id = $1 AND key = 'smtp'), '[]'::jsonb) as smtp_value,
-			COALESCE((SELECT value FROM tenant_settings WHERE tenant_id = $1 AND key = 'smtp.default'), '""'::jsonb) as default_value
-	`, tenantID).Scan(&smtpValue, &defaultValue)
+			COALESCE((SELECT value FROM tenant_settings WHERE tenant_id = $1 AND key = 'smtp.default'), '""'::jsonb) as default_value,
+			COALESCE((SELECT value FROM tenant_settings WHERE tenant_id = $1 AND key = 'dkim'), 'null'::jsonb) as dkim_value
+	`, tenantID).Scan(&smtpValue, &defaultValue, &dkimValue)
 	
 	if err != nil {
 		return nil, fmt.Errorf("failed to query tenant SMTP settings: %v", err)
@@ -163,13 +309,46 @@ func (te *TenantEmailer) loadTenantSMTPConfig(tenantID int) (*TenantSMTPConfig,
 		return nil, fmt.Errorf("no SMTP configuration found for tenant %d", tenantID)
 	}
 
+	// Decrypt the stored passwords
+	for i := range smtpConfig {
+		pwd, err := te.decryptSecret(smtpConfig[i].Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt SMTP password for tenant %d: %v", tenantID, err)
+		}
+		smtpConfig[i].Password = pwd
+	}
+
+	// Optional DKIM key, with its private key stored encrypted.
+	var dkim *DKIMConf
+	if err := json.Unmarshal(dkimValue, &dkim); err != nil {
+		return nil, fmt.Errorf("failed to parse DKIM config for tenant %d: %v", tenantID, err)
+	}
+	if dkim != nil {
+		key, err := te.decryptSecret(dkim.PrivateKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt DKIM key for tenant %d: %v", tenantID, err)
+		}
+		dkim.PrivateKey = key
+
+		if key == "" {
+			dkim = nil
+		}
+	}
+
 	return &TenantSMTPConfig{
 		TenantID: tenantID,
 		SMTP:     smtpConfig,
 		Default:  defaultServer,
+		DKIM:     dkim,
 	}, nil
 }
 
+// decryptSecret decrypts a secret stored in tenant_settings. Plaintext values
+// stored before encryption was enabled are returned as-is.
+func (te *TenantEmailer) decryptSecret(val string) (string, error) {
+	return utils.DecryptSecret(te.secretKey, val)
+}
+
 // createEmailerFromConfig creates an Emailer instance from tenant SMTP configuration
 func (te *TenantEmailer) createEmailerFromConfig(config *TenantSMTPConfig) (*Emailer, error) {
 	if len(config.SMTP) == 0 {
@@ -191,21 +370,40 @@ func (te *TenantEmailer) createEmailerFromConfig(config *TenantSMTPConfig) (*Ema
 			continue
 		}
 
+		name := s.Name
+		if name == "" {
+			name = s.Host // Use host as name if not specified
+		}
+
 		srv := Server{
-			Name:            s.Host, // Use host as name if not specified
-			Host:            s.Host,
-			Port:            s.Port,
-			AuthProtocol:    s.AuthProtocol,
-			Username:        s.Username,
-			Password:        s.Password,
-			HelloHostname:   s.HelloHostname,
-			MaxConns:        s.MaxConns,
-			IdleTimeout:     s.IdleTimeout,
-			WaitTimeout:     s.WaitTimeout,
-			MaxMessageRetries: s.MaxMsgRetries,
-			TLSType:         s.TLSType,
-			TLSSkipVerify:   s.TLSSkipVerify,
-			EmailHeaders:    s.EmailHeaders,
+			Name:          name,
+			Username:      s.Username,
+			Password:      s.Password,
+			AuthProtocol:  s.AuthProtocol,
+			TLSType:       s.TLSType,
+			TLSSkipVerify: s.TLSSkipVerify,
+			EmailHeaders:  make(map[string]string),
+			DKIM:          config.DKIM,
+			Weight:        s.Weight,
+			Opt: smtppool.Opt{
+				Host:              s.Host,
+				Port:              s.Port,
+				HelloHostname:     s.HelloHostname,
+				MaxConns:          s.MaxConns,
+				MaxMessageRetries: s.MaxMsgRetries,
+			},
+			CircuitBreakerThreshold: s.CircuitBreakerThreshold,
+		}
+		for _, h := range s.EmailHeaders {
+			for k, v := range h {
+				srv.EmailHeaders[k] = v
+			}
+		}
+		if d, err := time.ParseDuration(s.IdleTimeout); err == nil {
+			srv.IdleTimeout = d
+		}
+		if d, err := time.ParseDuration(s.WaitTimeout); err == nil {
+			srv.PoolWaitTimeout = d
 		}
 
 		// Set defaults
@@ -221,8 +419,16 @@ func (te *TenantEmailer) createEmailerFromConfig(config *TenantSMTPConfig) (*Ema
 		if srv.IdleTimeout == 0 {
 			srv.IdleTimeout = time.Second * 15
 		}
-		if srv.WaitTimeout == 0 {
-			srv.WaitTimeout = time.Second * 5
+		if srv.PoolWaitTimeout == 0 {
+			srv.PoolWaitTimeout = time.Second * 5
+		}
+
+		// A tenant's unreachable SMTP server shouldn't tie up its workers.
+		if srv.CircuitBreakerThreshold == 0 {
+			srv.CircuitBreakerThreshold = 5
+		}
+		if d, err := time.ParseDuration(s.CircuitBreakerCooldown); err == nil {
+			srv.CircuitBreakerCooldown = d
 		}
 
 		servers = append(servers, srv)
@@ -232,13 +438,43 @@ func (te *TenantEmailer) createEmailerFromConfig(config *TenantSMTPConfig) (*Ema
 		return nil, fmt.Errorf("no enabled SMTP servers found for tenant %d", config.TenantID)
 	}
 
-	// Create the emailer
-	emailer := &Emailer{
-		servers: servers,
-		logger:  te.logger,
+	// Create the emailer with its SMTP connection pools.
+	return New(MessengerName, servers...)
+}
+
+// TestTenantSMTP verifies connectivity to each of the tenant's enabled SMTP
+// servers. If msg is not nil, it is also sent through every server that could
+// be verified. The emailer used for the test is not cached and is closed after.
+func (te *TenantEmailer) TestTenantSMTP(tenantID int, msg *models.Message) ([]ServerStatus, error) {
+	config, err := te.loadTenantSMTPConfig(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	emailer, err := te.createEmailerFromConfig(config)
+	if err != nil {
+		return nil, err
 	}
+	defer emailer.Close()
 
-	return emailer, nil
+	out := emailer.VerifyServers()
+	if msg == nil {
+		return out, nil
+	}
+
+	for i, st := range out {
+		if !st.OK {
+			continue
+		}
+
+		e := &Emailer{servers: []*Server{emailer.servers[i]}, name: emailer.name}
+		if err := e.Push(*msg); err != nil {
+			out[i].OK = false
+			out[i].Error = err.Error()
+		}
+	}
+
+	return out, nil
 }
 
 // isCacheValid checks if the cached emailer is still valid
@@ -269,9 +505,15 @@ func (te *TenantEmailer) cleanExpiredCache() {
 	te.mu.Lock()
 	te.cacheMu.Lock()
 
-	now := time.Now()
+	var (
+		now     = time.Now()
+		evicted = make(map[int]*Emailer)
+	)
 	for tenantID, lastRefresh := range te.lastRefresh {
 		if now.Sub(lastRefresh) > te.cacheExpiry {
+			if ce, ok := te.tenantEmailers[tenantID]; ok && ce.retire() {
+				evicted[tenantID] = ce.e
+			}
 			delete(te.tenantEmailers, tenantID)
 			delete(te.lastRefresh, tenantID)
 		}
@@ -279,34 +521,72 @@ func (te *TenantEmailer) cleanExpiredCache() {
 
 	te.cacheMu.Unlock()
 	te.mu.Unlock()
+
+	for tenantID, e := range evicted {
+		te.closeEmailer(tenantID, e)
+	}
+}
+
+// closeEmailer closes the SMTP connection pools of an evicted tenant emailer
+// that nothing is sending through.
+func (te *TenantEmailer) closeEmailer(tenantID int, e *Emailer) {
+	if e == nil || e == te.fallbackEmailer {
+		return
+	}
+
+	if err := e.Close(); err != nil {
+		te.logger.Printf("Error closing emailer for tenant %d: %v", tenantID, err)
+	}
 }
 
 // InvalidateCache forces a reload of SMTP configuration for a tenant
 func (te *TenantEmailer) InvalidateCache(tenantID int) {
 	te.mu.Lock()
+	ce := te.tenantEmailers[tenantID]
 	delete(te.tenantEmailers, tenantID)
+	closeIt := ce.retire()
 	te.mu.Unlock()
 
 	te.cacheMu.Lock()
 	delete(te.lastRefresh, tenantID)
 	te.cacheMu.Unlock()
 
+	if closeIt {
+		te.closeEmailer(tenantID, ce.e)
+	}
 	te.logger.Printf("Invalidated SMTP cache for tenant %d", tenantID)
 }
 
 // InvalidateAllCache clears all cached emailers
 func (te *TenantEmailer) InvalidateAllCache() {
 	te.mu.Lock()
-	te.tenantEmailers = make(map[int]*Emailer)
+	emailers := te.retireAll()
 	te.mu.Unlock()
 
 	te.cacheMu.Lock()
 	te.lastRefresh = make(map[int]time.Time)
 	te.cacheMu.Unlock()
 
+	for tenantID, e := range emailers {
+		te.closeEmailer(tenantID, e)
+	}
 	te.logger.Println("Invalidated all SMTP caches")
 }
 
+// retireAll evicts all the cached emailers and returns the ones that can be
+// closed right away. It should be called with te.mu held.
+func (te *TenantEmailer) retireAll() map[int]*Emailer {
+	out := make(map[int]*Emailer)
+	for tenantID, ce := range te.tenantEmailers {
+		if ce.retire() {
+			out[tenantID] = ce.e
+		}
+	}
+	te.tenantEmailers = make(map[int]*cachedEmailer)
+
+	return out
+}
+
 // GetCacheStats returns cache statistics
 func (te *TenantEmailer) GetCacheStats() map[string]interface{} {
 	te.mu.RLock()
@@ -349,15 +629,15 @@ func (te *TenantEmailer) SetCacheConfig(enabled bool, expiry, refreshInterval ti
 // Close shuts down the tenant emailer and cleans up resources
 func (te *TenantEmailer) Close() {
 	te.mu.Lock()
-	for tenantID, emailer := range te.tenantEmailers {
-		if emailer != nil {
-			// Close connections if the emailer has a close method
-			te.logger.Printf("Closing emailer for tenant %d", tenantID)
-		}
-	}
-	te.tenantEmailers = make(map[int]*Emailer)
+	emailers := te.retireAll()
 	te.mu.Unlock()
 
+	// Emailers that are still sending are closed when they're done.
+	for tenantID, e := range emailers {
+		te.logger.Printf("Closing emailer for tenant %d", tenantID)
+		te.closeEmailer(tenantID, e)
+	}
+
 	te.cacheMu.Lock()
 	te.lastRefresh = make(map[int]time.Time)
 	te.cacheMu.Unlock()
@@ -366,31 +646,28 @@ func (te *TenantEmailer) Close() {
 }
 
 // Send sends an email using the appropriate tenant's SMTP configuration
-func (te *TenantEmailer) Send(ctx context.Context, tenantID int, msg Message) error {
-	emailer, err := te.GetEmailerForTenant(tenantID)
-	if err != nil {
-		return fmt.Errorf("failed to get emailer for tenant %d: %v", tenantID, err)
+func (te *TenantEmailer) Send(ctx context.Context, tenantID int, msg models.Message) error {
+	return te.SendWithContext(ctx, tenantID, msg)
+}
+
+// SendMessage sends an email using the SMTP configuration of the tenant
+// that the message is for (models.Message.TenantID).
+func (te *TenantEmailer) SendMessage(ctx context.Context, msg models.Message) error {
+	if msg.TenantID < 1 {
+		return errors.New("message has no tenant ID")
 	}
 
-	return emailer.Send(msg)
+	return te.SendWithContext(ctx, msg.TenantID, msg)
 }
 
-// SendWithContext sends an email with context using tenant's SMTP configuration
-func (te *TenantEmailer) SendWithContext(ctx context.Context, tenantID int, msg Message) error {
-	emailer, err := te.GetEmailerForTenant(tenantID)
+// SendWithContext sends an email with context using tenant's SMTP configuration.
+// The context is only checked before the send starts (see PushWithContext).
+func (te *TenantEmailer) SendWithContext(ctx context.Context, tenantID int, msg models.Message) error {
+	emailer, release, err := te.acquireEmailer(tenantID)
 	if err != nil {
 		return fmt.Errorf("failed to get emailer for tenant %d: %v", tenantID, err)
 	}
+	defer release()
 
-	// Check if emailer supports context
-	type contextSender interface {
-		SendWithContext(context.Context, Message) error
-	}
-
-	if ctxSender, ok := emailer.(contextSender); ok {
-		return ctxSender.SendWithContext(ctx, msg)
-	}
-
-	// Fallback to regular Send
-	return emailer.Send(msg)
-}
\ No newline at end of file
+	return emailer.PushWithContext(ctx, msg)
+}
@@ -0,0 +1,264 @@
+package email
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+	"github.com/knadh/smtppool/v2"
+)
+
+// newTestEmailer returns an emailer with a server that's never dialed.
+func newTestEmailer(t *testing.T) *Emailer {
+	t.Helper()
+
+	e, err := New(MessengerName, Server{
+		Name: "test",
+		Opt: smtppool.Opt{
+			Host:        "127.0.0.1",
+			Port:        1,
+			MaxConns:    2,
+			IdleTimeout: time.Minute,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// isClosed returns true if the emailer's SMTP pool has been closed.
+func isClosed(e *Emailer) bool {
+	return errors.Is(e.servers[0].pool.Send(smtppool.Email{}), smtppool.ErrPoolClosed)
+}
+
+// newCachingTenantEmailer returns a TenantEmailer without a DB or the
+// refresh routine, with an emailer cached for each of the given tenants.
+func newCachingTenantEmailer(t *testing.T, tenantIDs ...int) (*TenantEmailer, map[int]*Emailer) {
+	t.Helper()
+
+	te := &TenantEmailer{
+		logger:         log.New(io.Discard, "", 0),
+		tenantEmailers: make(map[int]*cachedEmailer),
+		cacheEnabled:   true,
+		cacheExpiry:    time.Hour,
+		lastRefresh:    make(map[int]time.Time),
+	}
+
+	out := make(map[int]*Emailer)
+	for _, id := range tenantIDs {
+		e := newTestEmailer(t)
+		te.tenantEmailers[id] = &cachedEmailer{e: e}
+		te.lastRefresh[id] = time.Now()
+		out[id] = e
+	}
+	return te, out
+}
+
+func TestInvalidateCacheClosesEmailer(t *testing.T) {
+	te, es := newCachingTenantEmailer(t, 1, 2)
+
+	te.InvalidateCache(1)
+	if !isClosed(es[1]) {
+		t.Error("expected the invalidated emailer to be closed")
+	}
+	if isClosed(es[2]) {
+		t.Error("expected the other tenant's emailer to be open")
+	}
+	if _, ok := te.tenantEmailers[1]; ok {
+		t.Error("expected the emailer to be evicted")
+	}
+}
+
+func TestEvictedEmailerClosedOnRelease(t *testing.T) {
+	te, es := newCachingTenantEmailer(t, 1)
+
+	e, release, err := te.acquireEmailer(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e != es[1] {
+		t.Fatal("expected the cached emailer")
+	}
+
+	// The emailer is in use and can't be closed yet.
+	te.InvalidateCache(1)
+	if isClosed(es[1]) {
+		t.Fatal("expected the emailer in use to be open")
+	}
+
+	release()
+	if !isClosed(es[1]) {
+		t.Error("expected the emailer to be closed after its release")
+	}
+}
+
+func TestCleanExpiredCacheClosesEmailer(t *testing.T) {
+	te, es := newCachingTenantEmailer(t, 1, 2)
+	te.lastRefresh[1] = time.Now().Add(-2 * time.Hour)
+
+	te.cleanExpiredCache()
+	if !isClosed(es[1]) {
+		t.Error("expected the expired emailer to be closed")
+	}
+	if isClosed(es[2]) {
+		t.Error("expected the fresh emailer to be open")
+	}
+	if len(te.tenantEmailers) != 1 || len(te.lastRefresh) != 1 {
+		t.Errorf("expected one cached emailer, got %d (%d refreshes)", len(te.tenantEmailers), len(te.lastRefresh))
+	}
+}
+
+func TestTenantEmailerClose(t *testing.T) {
+	te, es := newCachingTenantEmailer(t, 1, 2)
+
+	_, release, err := te.acquireEmailer(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	te.Close()
+	if !isClosed(es[1]) {
+		t.Error("expected the idle emailer to be closed")
+	}
+	if isClosed(es[2]) {
+		t.Error("expected the emailer in use to be open")
+	}
+
+	release()
+	if !isClosed(es[2]) {
+		t.Error("expected the emailer to be closed after its release")
+	}
+}
+
+// settingsDB is a database connector that answers the tenant SMTP settings
+// query with the given smtp setting.
+type settingsDB struct{ smtp []byte }
+
+func (d *settingsDB) Connect(context.Context) (driver.Conn, error) { return &settingsConn{d}, nil }
+func (d *settingsDB) Driver() driver.Driver                        { return nil }
+
+type settingsConn struct{ db *settingsDB }
+
+func (c *settingsConn) Prepare(q string) (driver.Stmt, error) { return &settingsStmt{c.db}, nil }
+func (c *settingsConn) Close() error                          { return nil }
+func (c *settingsConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+type settingsStmt struct{ db *settingsDB }
+
+func (s *settingsStmt) Close() error  { return nil }
+func (s *settingsStmt) NumInput() int { return -1 }
+func (s *settingsStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("not supported")
+}
+
+func (s *settingsStmt) Query([]driver.Value) (driver.Rows, error) {
+	return &settingsRows{row: []driver.Value{s.db.smtp, []byte(`""`), []byte(`null`)}}, nil
+}
+
+type settingsRows struct{ row []driver.Value }
+
+func (r *settingsRows) Columns() []string {
+	return []string{"smtp_value", "default_value", "dkim_value"}
+}
+func (r *settingsRows) Close() error { return nil }
+func (r *settingsRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+// newStubTenantEmailer returns a TenantEmailer whose tenants have the SMTP
+// stub as their only server, authenticated with the given password.
+func newStubTenantEmailer(t *testing.T, s *smtpStub, password string) *TenantEmailer {
+	t.Helper()
+
+	b, err := json.Marshal([]SMTPConf{{
+		Name:         "stub",
+		Enabled:      true,
+		Host:         "127.0.0.1",
+		Port:         s.port(),
+		AuthProtocol: "plain",
+		Username:     "listmonk",
+		Password:     password,
+		MaxConns:     1,
+		TLSType:      "none",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &TenantEmailer{
+		db:     sqlx.NewDb(sql.OpenDB(&settingsDB{smtp: b}), "postgres"),
+		logger: log.New(io.Discard, "", 0),
+	}
+}
+
+func TestTestTenantSMTP(t *testing.T) {
+	s := newAuthSMTPStub(t, "listmonk", "secret")
+	te := newStubTenantEmailer(t, s, "secret")
+
+	// Verifying the servers doesn't send anything.
+	out, err := te.TestTenantSMTP(2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !out[0].OK {
+		t.Fatalf("expected the server to be verified, got %+v", out)
+	}
+	if n := len(s.messages()); n != 0 {
+		t.Fatalf("expected no messages, got %d", n)
+	}
+
+	msg := &models.Message{
+		From:        "news@listmonk.test",
+		To:          []string{"admin@example.com"},
+		Subject:     "Test",
+		ContentType: "plain",
+		Body:        []byte("Hello"),
+		TenantID:    2,
+	}
+	out, err = te.TestTenantSMTP(2, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || !out[0].OK {
+		t.Fatalf("expected the test message to be sent, got %+v", out)
+	}
+	if n := len(s.messages()); n != 1 {
+		t.Errorf("expected the test message, got %d messages", n)
+	}
+}
+
+func TestTestTenantSMTPAuthFailure(t *testing.T) {
+	s := newAuthSMTPStub(t, "listmonk", "secret")
+	te := newStubTenantEmailer(t, s, "wrong")
+
+	out, err := te.TestTenantSMTP(2, &models.Message{
+		From:        "news@listmonk.test",
+		To:          []string{"admin@example.com"},
+		Subject:     "Test",
+		ContentType: "plain",
+		Body:        []byte("Hello"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 1 || out[0].OK || out[0].Error == "" {
+		t.Fatalf("expected the server to fail authentication, got %+v", out)
+	}
+	if n := len(s.messages()); n != 0 {
+		t.Errorf("expected no messages, got %d", n)
+	}
+}
@@ -123,11 +123,22 @@ func (p *Postback) Push(m models.Message) error {
 		files := make([]attachment, 0, len(m.Attachments))
 		for _, f := range m.Attachments {
 			a := attachment{
-				Name:    f.Name,
-				Header:  f.Header,
-				Content: make([]byte, len(f.Content)),
+				Name:   f.Name,
+				Header: f.Header,
+			}
+
+			// Attachments that are loaded on demand are fresh for every
+			// message and needn't be copied.
+			if f.Loader != nil {
+				b, err := f.Loader()
+				if err != nil {
+					return fmt.Errorf("error loading attachment %s: %v", f.Name, err)
+				}
+				a.Content = b
+			} else {
+				a.Content = make([]byte, len(f.Content))
+				copy(a.Content, f.Content)
 			}
-			copy(a.Content, f.Content)
 			files = append(files, a)
 		}
 		pb.Attachments = files
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// tenantWindow is the request count of a tenant's user in the current window.
+type tenantWindow struct {
+	count int
+	start time.Time
+}
+
+// rateLimitKey identifies the requests of a user within a tenant.
+type rateLimitKey struct {
+	tenantID int
+	userID   int
+}
+
+// tenantRateLimiter counts the requests of every tenant's users in fixed windows.
+// Expired windows are swept periodically on requests.
+type tenantRateLimiter struct {
+	window    time.Duration
+	tenants   map[rateLimitKey]*tenantWindow
+	lastSweep time.Time
+	mut       sync.Mutex
+}
+
+// allow records a request of the tenant's user and returns true if it's
+// within the limit. Otherwise, it returns false along with the duration after
+// which the current window expires.
+func (r *tenantRateLimiter) allow(key rateLimitKey, limit int) (bool, time.Duration) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+
+	now := time.Now()
+	if now.Sub(r.lastSweep) >= r.window {
+		r.sweep(now)
+	}
+
+	w, ok := r.tenants[key]
+	if !ok || now.Sub(w.start) >= r.window {
+		w = &tenantWindow{start: now}
+		r.tenants[key] = w
+	}
+
+	if w.count >= limit {
+		return false, r.window - now.Sub(w.start)
+	}
+
+	w.count++
+	return true, 0
+}
+
+// sweep removes the expired windows. It should be called with the lock held.
+func (r *tenantRateLimiter) sweep(now time.Time) {
+	for k, w := range r.tenants {
+		if now.Sub(w.start) >= r.window {
+			delete(r.tenants, k)
+		}
+	}
+	r.lastSweep = now
+}
+
+// RateLimitPerTenant returns a middleware that limits the number of API
+// requests (/api/*) a tenant's users can make in every window so that one
+// tenant can't starve the others. The limit is taken from the tenant's
+// features (api_rate_limit) if it's set there, and from the given limit
+// otherwise. A limit <= 0 disables rate limiting. Requests over the limit get
+// a 429 with the Retry-After header set.
+//
+// Requests are counted per user of a tenant and unauthenticated requests
+// aren't counted, so nobody can exhaust another tenant's quota by sending
+// requests in its name. It should be registered after the tenant and the
+// auth middlewares.
+func RateLimitPerTenant(limit int, window time.Duration) echo.MiddlewareFunc {
+	if window <= 0 {
+		window = time.Minute
+	}
+
+	r := &tenantRateLimiter{
+		window:  window,
+		tenants: make(map[rateLimitKey]*tenantWindow),
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !strings.HasPrefix(c.Request().URL.Path, "/api/") {
+				return next(c)
+			}
+
+			tenant, err := GetTenant(c)
+			if err != nil {
+				return next(c)
+			}
+
+			sess := GetUserSession(c)
+			if sess == nil || sess.UserID < 1 {
+				return next(c)
+			}
+
+			lim := limit
+			if tenant.Features != nil && tenant.Features.APIRateLimit > 0 {
+				lim = tenant.Features.APIRateLimit
+			}
+			if lim <= 0 {
+				return next(c)
+			}
+
+			if ok, wait := r.allow(rateLimitKey{tenantID: tenant.ID, userID: sess.UserID}, lim); !ok {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				return echo.NewHTTPError(http.StatusTooManyRequests, "Too many requests. Please try again later.")
+			}
+
+			return next(c)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+type testUser struct {
+	ID         int
+	SuperAdmin bool
+}
+
+// rateLimitedRequest runs a request through the rate limiter as the given
+// tenant and user (none if userID is 0) and returns the response status.
+func rateLimitedRequest(mw echo.MiddlewareFunc, tenantID, userID int) int {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/lists", nil)
+	rec := httptest.NewRecorder()
+	c := e.NewContext(req, rec)
+
+	c.Set(TenantCtxKey, &models.TenantContext{ID: tenantID})
+	if userID > 0 {
+		c.Set("auth_user", testUser{ID: userID})
+	}
+
+	err := mw(func(c echo.Context) error {
+		return c.NoContent(http.StatusOK)
+	})(c)
+	if he, ok := err.(*echo.HTTPError); ok {
+		return he.Code
+	}
+	return rec.Code
+}
+
+func TestRateLimitPerTenant(t *testing.T) {
+	mw := RateLimitPerTenant(2, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if code := rateLimitedRequest(mw, 1, 10); code != http.StatusOK {
+			t.Fatalf("request %d: expected 200, got %d", i+1, code)
+		}
+	}
+	if code := rateLimitedRequest(mw, 1, 10); code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 over the limit, got %d", code)
+	}
+
+	// Another tenant's quota is unaffected.
+	if code := rateLimitedRequest(mw, 2, 20); code != http.StatusOK {
+		t.Fatalf("expected 200 for another tenant, got %d", code)
+	}
+}
+
+func TestRateLimitPerTenantUnauthenticated(t *testing.T) {
+	mw := RateLimitPerTenant(1, time.Minute)
+
+	// Unauthenticated requests in a tenant's name don't use up its quota.
+	for i := 0; i < 5; i++ {
+		rateLimitedRequest(mw, 1, 0)
+	}
+	if code := rateLimitedRequest(mw, 1, 10); code != http.StatusOK {
+		t.Fatalf("expected 200 for the tenant's user, got %d", code)
+	}
+
+	// Nor do another user's requests.
+	rateLimitedRequest(mw, 1, 11)
+	if code := rateLimitedRequest(mw, 1, 12); code != http.StatusOK {
+		t.Fatalf("expected 200 for another user of the tenant, got %d", code)
+	}
+}
+
+func TestRateLimitPerTenantFeatureLimit(t *testing.T) {
+	mw := RateLimitPerTenant(0, time.Minute)
+
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/lists", nil)
+
+	// The tenant's own limit applies even if the default is disabled.
+	var codes []int
+	for i := 0; i < 2; i++ {
+		c := e.NewContext(req, httptest.NewRecorder())
+		c.Set(TenantCtxKey, &models.TenantContext{ID: 3, Features: &models.TenantFeatures{APIRateLimit: 1}})
+		c.Set("auth_user", testUser{ID: 30})
+
+		err := mw(func(c echo.Context) error { return nil })(c)
+		if he, ok := err.(*echo.HTTPError); ok {
+			codes = append(codes, he.Code)
+		} else {
+			codes = append(codes, http.StatusOK)
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Fatalf("expected [200 429], got %v", codes)
+	}
+}
+
+func TestRateLimitPerTenantSweep(t *testing.T) {
+	r := &tenantRateLimiter{window: time.Millisecond * 50, tenants: make(map[rateLimitKey]*tenantWindow)}
+	for i := 1; i <= 100; i++ {
+		if ok, _ := r.allow(rateLimitKey{tenantID: i, userID: 1}, 1); !ok {
+			t.Fatalf("expected the first request of tenant %d to be allowed", i)
+		}
+	}
+
+	// Once the windows expire, they're swept on the next request.
+	time.Sleep(time.Millisecond * 60)
+	if ok, _ := r.allow(rateLimitKey{tenantID: 1, userID: 1}, 1); !ok {
+		t.Fatal("expected a request in a new window to be allowed")
+	}
+	if n := len(r.tenants); n != 1 {
+		t.Errorf("expected the expired windows to be swept, got %d windows", n)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 	"github.com/knadh/listmonk/models"
@@ -24,6 +25,18 @@ const (
 	
 	// TenantSubdomainKey is used for subdomain-based tenant resolution.
 	TenantSubdomainSuffix = ".listmonk.local" // Change this to your domain
+
+	// SessionTenantKey is the session variable that holds the tenant a user has switched to.
+	SessionTenantKey = "tenant_id"
+
+	// SessionImpersonateKey is the session variable that holds the tenant a
+	// super admin is impersonating and SessionImpersonateUntilKey, the Unix
+	// time at which the impersonation expires.
+	SessionImpersonateKey      = "impersonate_tenant_id"
+	SessionImpersonateUntilKey = "impersonate_until"
+
+	// sessionCtxKey is the key the auth middleware stores the cookie session on.
+	sessionCtxKey = "auth_session"
 )
 
 var (
@@ -32,6 +45,9 @@ var (
 	
 	// ErrTenantInactive is returned when a tenant is not active.
 	ErrTenantInactive = errors.New("tenant is inactive")
+
+	// ErrTenantSuspended is returned on write requests to a suspended tenant.
+	ErrTenantSuspended = errors.New("tenant is suspended")
 	
 	// ErrTenantAccessDenied is returned when a user doesn't have access to a tenant.
 	ErrTenantAccessDenied = errors.New("access denied to this tenant")
@@ -47,6 +63,10 @@ type TenantMiddleware struct {
 	db       *sqlx.DB
 	queries  *models.Queries
 	resolver TenantResolver
+	cache    *tenantCache
+
+	// exposeHeaders adds the X-Tenant-ID and X-Tenant-Slug headers to responses.
+	exposeHeaders bool
 }
 
 // NewTenantMiddleware creates a new tenant middleware instance.
@@ -54,12 +74,20 @@ func NewTenantMiddleware(db *sqlx.DB, queries *models.Queries) *TenantMiddleware
 	tm := &TenantMiddleware{
 		db:      db,
 		queries: queries,
+		cache:   newTenantCache(defaultTenantCacheTTL),
 	}
 	// Set self as default resolver
 	tm.resolver = tm
 	return tm
 }
 
+// SetExposeTenantHeaders sets whether the resolved tenant's ID and slug are
+// added to responses in the X-Tenant-ID and X-Tenant-Slug headers. This is off
+// by default so as to not disclose internal tenant IDs to clients.
+func (tm *TenantMiddleware) SetExposeTenantHeaders(on bool) {
+	tm.exposeHeaders = on
+}
+
 // Middleware returns the Echo middleware function.
 func (tm *TenantMiddleware) Middleware() echo.MiddlewareFunc {
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -73,23 +101,25 @@ func (tm *TenantMiddleware) Middleware() echo.MiddlewareFunc {
 				if err == ErrTenantInactive {
 					return echo.NewHTTPError(http.StatusForbidden, "Tenant is inactive")
 				}
+				if err == ErrTenantSuspended {
+					return echo.NewHTTPError(http.StatusForbidden, "Tenant is suspended and is read-only")
+				}
 				if err == ErrTenantAccessDenied {
 					return echo.NewHTTPError(http.StatusForbidden, "Access denied to this tenant")
 				}
 				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to resolve tenant")
 			}
 
-			// Store tenant context in Echo context
+			// Store tenant context in Echo context. The RLS tenant context is
+			// set per transaction with core.WithTenantTx() and not on the pooled
+			// connection so that it doesn't leak across requests.
 			c.Set(TenantCtxKey, tenant)
 
-			// Set tenant context in database session for RLS
-			if err := tm.SetDatabaseTenant(tenant.ID); err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "Failed to set tenant context")
-			}
-
 			// Add tenant info to response headers for debugging (optional)
-			c.Response().Header().Set("X-Tenant-ID", strconv.Itoa(tenant.ID))
-			c.Response().Header().Set("X-Tenant-Slug", tenant.Slug)
+			if tm.exposeHeaders {
+				c.Response().Header().Set("X-Tenant-ID", strconv.Itoa(tenant.ID))
+				c.Response().Header().Set("X-Tenant-Slug", tenant.Slug)
+			}
 
 			return next(c)
 		}
@@ -101,6 +131,16 @@ func (tm *TenantMiddleware) ResolveTenant(c echo.Context) (*models.TenantContext
 	var tenant *models.Tenant
 	var err error
 
+	// An unexpired impersonation by a super admin overrides all other strategies.
+	// The user is checked to still be a super admin on every request as the
+	// impersonation outlives the check when it was started.
+	if tenantID, ok := GetSessionImpersonation(c); ok && isSuperAdminSession(c) {
+		tenant, err = tm.GetTenantByID(tenantID)
+		if err == nil && tenant != nil {
+			return tm.newTenantContext(c, tenant, true)
+		}
+	}
+
 	// Strategy 1: Check subdomain
 	host := c.Request().Host
 	if strings.Contains(host, ".") {
@@ -141,7 +181,15 @@ func (tm *TenantMiddleware) ResolveTenant(c echo.Context) (*models.TenantContext
 		}
 	}
 
-	// Strategy 5: Get user's default tenant from session
+	// Strategy 5: Tenant the user has switched to in their session
+	if tenantID, ok := GetSessionTenantID(c); ok {
+		tenant, err = tm.GetTenantByID(tenantID)
+		if err == nil && tenant != nil {
+			return tm.buildTenantContext(c, tenant)
+		}
+	}
+
+	// Strategy 6: Get user's default tenant from session
 	if session := GetUserSession(c); session != nil && session.UserID > 0 {
 		tenant, err = tm.GetUserDefaultTenant(session.UserID)
 		if err == nil && tenant != nil {
@@ -149,7 +197,7 @@ func (tm *TenantMiddleware) ResolveTenant(c echo.Context) (*models.TenantContext
 		}
 	}
 
-	// Strategy 6: Fall back to default tenant (ID: 1) for backward compatibility
+	// Strategy 7: Fall back to default tenant (ID: 1) for backward compatibility
 	// Remove this in production for strict multi-tenancy
 	if tenant == nil {
 		tenant, err = tm.GetTenantByID(1)
@@ -163,14 +211,30 @@ func (tm *TenantMiddleware) ResolveTenant(c echo.Context) (*models.TenantContext
 
 // buildTenantContext creates a TenantContext from a Tenant model.
 func (tm *TenantMiddleware) buildTenantContext(c echo.Context, tenant *models.Tenant) (*models.TenantContext, error) {
-	// Check if tenant is active
+	return tm.newTenantContext(c, tenant, false)
+}
+
+// newTenantContext creates a TenantContext from a Tenant model. Impersonating
+// users aren't members of the tenant and are given the admin role in it.
+func (tm *TenantMiddleware) newTenantContext(c echo.Context, tenant *models.Tenant, impersonated bool) (*models.TenantContext, error) {
+	// Suspended tenants can still be read from, but not written to.
+	// Deleted (or any other non-active) tenants are always blocked.
+	readOnly := false
 	if !tenant.IsActive() {
-		return nil, ErrTenantInactive
+		if !tenant.IsSuspended() {
+			return nil, ErrTenantInactive
+		}
+		if !isReadRequest(c) {
+			return nil, ErrTenantSuspended
+		}
+		readOnly = true
 	}
 
 	// Get user's role in this tenant if authenticated
 	var userRole string = models.TenantUserRoleViewer // Default role
-	if session := GetUserSession(c); session != nil && session.UserID > 0 {
+	if impersonated {
+		userRole = models.TenantUserRoleAdmin
+	} else if session := GetUserSession(c); session != nil && session.UserID > 0 {
 		role, err := tm.GetUserTenantRole(session.UserID, tenant.ID)
 		if err != nil {
 			// User doesn't have access to this tenant
@@ -205,18 +269,44 @@ func (tm *TenantMiddleware) buildTenantContext(c echo.Context, tenant *models.Te
 		Settings: tenant.Settings,
 		Features: &features,
 		UserRole: userRole,
+		ReadOnly: readOnly,
+
+		Impersonated: impersonated,
 	}, nil
 }
 
-// SetDatabaseTenant sets the current tenant in the database session for RLS.
-func (tm *TenantMiddleware) SetDatabaseTenant(tenantID int) error {
-	query := fmt.Sprintf("SELECT set_config('app.current_tenant', '%d', false)", tenantID)
-	_, err := tm.db.Exec(query)
-	return err
+// isReadRequest checks if the request is a read-only one by its HTTP method.
+func isReadRequest(c echo.Context) bool {
+	switch c.Request().Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
 }
 
 // GetTenantByID retrieves a tenant by ID.
 func (tm *TenantMiddleware) GetTenantByID(id int) (*models.Tenant, error) {
+	return tm.cache.lookup(tenantIDCacheKey(id), func() (*models.Tenant, error) {
+		return tm.getTenantByID(id)
+	})
+}
+
+// GetTenantBySlug retrieves a tenant by slug.
+func (tm *TenantMiddleware) GetTenantBySlug(slug string) (*models.Tenant, error) {
+	return tm.cache.lookup(tenantCacheKey("slug", slug), func() (*models.Tenant, error) {
+		return tm.getTenantBySlug(slug)
+	})
+}
+
+// GetTenantByDomain retrieves a tenant by custom domain.
+func (tm *TenantMiddleware) GetTenantByDomain(domain string) (*models.Tenant, error) {
+	return tm.cache.lookup(tenantCacheKey("domain", domain), func() (*models.Tenant, error) {
+		return tm.getTenantByDomain(domain)
+	})
+}
+
+// getTenantByID fetches a tenant by ID from the DB.
+func (tm *TenantMiddleware) getTenantByID(id int) (*models.Tenant, error) {
 	var tenant models.Tenant
 	err := tm.db.Get(&tenant, `
 		SELECT * FROM tenants WHERE id = $1 AND status != 'deleted'
@@ -230,8 +320,8 @@ func (tm *TenantMiddleware) GetTenantByID(id int) (*models.Tenant, error) {
 	return &tenant, nil
 }
 
-// GetTenantBySlug retrieves a tenant by slug.
-func (tm *TenantMiddleware) GetTenantBySlug(slug string) (*models.Tenant, error) {
+// getTenantBySlug fetches a tenant by slug from the DB.
+func (tm *TenantMiddleware) getTenantBySlug(slug string) (*models.Tenant, error) {
 	var tenant models.Tenant
 	err := tm.db.Get(&tenant, `
 		SELECT * FROM tenants WHERE slug = $1 AND status != 'deleted'
@@ -245,8 +335,8 @@ func (tm *TenantMiddleware) GetTenantBySlug(slug string) (*models.Tenant, error)
 	return &tenant, nil
 }
 
-// GetTenantByDomain retrieves a tenant by custom domain.
-func (tm *TenantMiddleware) GetTenantByDomain(domain string) (*models.Tenant, error) {
+// getTenantByDomain fetches a tenant by custom domain from the DB.
+func (tm *TenantMiddleware) getTenantByDomain(domain string) (*models.Tenant, error) {
 	var tenant models.Tenant
 	err := tm.db.Get(&tenant, `
 		SELECT * FROM tenants WHERE domain = $1 AND status != 'deleted'
@@ -380,6 +470,11 @@ func extractUserSession(user interface{}) *UserSession {
 		}
 	}
 	
+	// Extract the platform super admin flag
+	if f := v.FieldByName("SuperAdmin"); f.IsValid() && f.Kind() == reflect.Bool {
+		session.SuperAdmin = f.Bool()
+	}
+
 	// Only return session if we got a valid user ID
 	if session.UserID > 0 {
 		return session
@@ -388,11 +483,84 @@ func extractUserSession(user interface{}) *UserSession {
 	return nil
 }
 
+// sessionGetter is implemented by the cookie session that the auth middleware
+// sets on the context. An interface is used instead of the concrete session
+// type to avoid depending on the auth package here.
+type sessionGetter interface {
+	Get(key string) (any, error)
+}
+
+// GetSessionTenantID returns the tenant ID that the user has switched to
+// in their cookie session, if any.
+func GetSessionTenantID(c echo.Context) (int, bool) {
+	sess, ok := c.Get(sessionCtxKey).(sessionGetter)
+	if !ok {
+		return 0, false
+	}
+
+	id := sessionInt(sess, SessionTenantKey)
+	return int(id), id > 0
+}
+
+// isSuperAdminSession checks if the authenticated user is a platform super admin.
+func isSuperAdminSession(c echo.Context) bool {
+	sess := GetUserSession(c)
+	return sess != nil && sess.SuperAdmin
+}
+
+// GetSessionImpersonation returns the tenant ID that a super admin is
+// impersonating in their cookie session, if the impersonation hasn't expired.
+func GetSessionImpersonation(c echo.Context) (int, bool) {
+	sess, ok := c.Get(sessionCtxKey).(sessionGetter)
+	if !ok {
+		return 0, false
+	}
+
+	id := sessionInt(sess, SessionImpersonateKey)
+	if id < 1 {
+		return 0, false
+	}
+
+	until := sessionInt(sess, SessionImpersonateUntilKey)
+	if until < time.Now().Unix() {
+		return 0, false
+	}
+
+	return int(id), true
+}
+
+// sessionInt returns an integer value from the session, or 0 if it's not set.
+func sessionInt(sess sessionGetter, key string) int64 {
+	val, err := sess.Get(key)
+	if err != nil || val == nil {
+		return 0
+	}
+
+	// Session stores may return numbers in different forms depending
+	// on how they serialize values.
+	var n int64
+	switch v := val.(type) {
+	case int:
+		n = int64(v)
+	case int64:
+		n = v
+	case float64:
+		n = int64(v)
+	case string:
+		n, _ = strconv.ParseInt(v, 10, 64)
+	case []byte:
+		n, _ = strconv.ParseInt(string(v), 10, 64)
+	}
+
+	return n
+}
+
 // UserSession represents a user's session data.
 type UserSession struct {
-	UserID   int    `json:"user_id"`
-	Username string `json:"username"`
-	Email    string `json:"email"`
+	UserID     int    `json:"user_id"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	SuperAdmin bool   `json:"super_admin"`
 }
 
 // GetTenant retrieves the tenant context from Echo context.
@@ -433,6 +601,28 @@ func RequireTenantRole(minRole string) echo.MiddlewareFunc {
 	}
 }
 
+// RequireFeature returns a middleware that requires the resolved tenant's plan
+// to have the named feature (eg: models.TenantFeatureAPIAccess) enabled and
+// responds with a 403 otherwise. Requests without a tenant context, that is,
+// when multi-tenancy is disabled, aren't gated.
+func RequireFeature(name string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			tenant, err := GetTenant(c)
+			if err != nil {
+				return next(c)
+			}
+
+			if !tenant.Features.Has(name) {
+				return echo.NewHTTPError(http.StatusForbidden,
+					fmt.Sprintf("The feature '%s' is not available on the tenant's plan", name))
+			}
+
+			return next(c)
+		}
+	}
+}
+
 // WithTenantContext adds tenant ID to the SQL context for queries.
 func WithTenantContext(ctx context.Context, tenantID int) context.Context {
 	return context.WithValue(ctx, "tenant_id", tenantID)
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/knadh/listmonk/models"
+)
+
+const (
+	// defaultTenantCacheTTL is the duration for which resolved tenants are cached.
+	defaultTenantCacheTTL = time.Minute
+
+	// maxTenantCacheItems is the maximum number of cached lookups.
+	maxTenantCacheItems = 10000
+)
+
+// tenantCache is an in-memory TTL cache of tenant lookups keyed by ID, slug,
+// or domain. Only tenants that are found are cached and not misses, as the
+// keys come from request hosts, headers, and params that anyone can send.
+// Expired entries are removed on access and swept periodically on writes.
+type tenantCache struct {
+	ttl       time.Duration
+	items     map[string]tenantCacheItem
+	lastSweep time.Time
+	mut       sync.RWMutex
+}
+
+type tenantCacheItem struct {
+	tenant *models.Tenant
+	expiry time.Time
+}
+
+func newTenantCache(ttl time.Duration) *tenantCache {
+	return &tenantCache{
+		ttl:   ttl,
+		items: make(map[string]tenantCacheItem),
+	}
+}
+
+// get returns the cached tenant for the key.
+func (tc *tenantCache) get(key string) (*models.Tenant, bool) {
+	tc.mut.RLock()
+	it, ok := tc.items[key]
+	tc.mut.RUnlock()
+
+	if !ok {
+		return nil, false
+	}
+
+	if time.Now().After(it.expiry) {
+		tc.mut.Lock()
+		if it, ok := tc.items[key]; ok && time.Now().After(it.expiry) {
+			delete(tc.items, key)
+		}
+		tc.mut.Unlock()
+		return nil, false
+	}
+
+	return it.tenant, true
+}
+
+// set caches the tenant against the key. Nothing is cached if the cache is
+// full even after removing the expired entries.
+func (tc *tenantCache) set(key string, t *models.Tenant) {
+	tc.mut.Lock()
+	defer tc.mut.Unlock()
+
+	if tc.ttl <= 0 {
+		return
+	}
+
+	now := time.Now()
+	if now.Sub(tc.lastSweep) >= tc.ttl || len(tc.items) >= maxTenantCacheItems {
+		tc.sweep(now)
+	}
+	if len(tc.items) >= maxTenantCacheItems {
+		return
+	}
+
+	tc.items[key] = tenantCacheItem{tenant: t, expiry: now.Add(tc.ttl)}
+}
+
+// sweep removes the expired entries. It should be called with the lock held.
+func (tc *tenantCache) sweep(now time.Time) {
+	for k, it := range tc.items {
+		if now.After(it.expiry) {
+			delete(tc.items, k)
+		}
+	}
+	tc.lastSweep = now
+}
+
+// setTTL changes the cache TTL and clears the cache. A TTL <= 0 disables caching.
+func (tc *tenantCache) setTTL(ttl time.Duration) {
+	tc.mut.Lock()
+	tc.ttl = ttl
+	tc.items = make(map[string]tenantCacheItem)
+	tc.mut.Unlock()
+}
+
+// invalidate removes all the entries of a tenant.
+func (tc *tenantCache) invalidate(tenantID int) {
+	tc.mut.Lock()
+	defer tc.mut.Unlock()
+
+	for k, it := range tc.items {
+		if it.tenant.ID == tenantID {
+			delete(tc.items, k)
+		}
+	}
+}
+
+// lookup returns the tenant for the key from the cache, or fetches it with fn
+// and caches it if it's found.
+func (tc *tenantCache) lookup(key string, fn func() (*models.Tenant, error)) (*models.Tenant, error) {
+	if t, ok := tc.get(key); ok {
+		return t, nil
+	}
+
+	t, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	tc.set(key, t)
+	return t, nil
+}
+
+// SetCacheTTL sets the duration for which tenant lookups are cached.
+// A TTL <= 0 disables caching.
+func (tm *TenantMiddleware) SetCacheTTL(ttl time.Duration) {
+	tm.cache.setTTL(ttl)
+}
+
+// InvalidateTenant removes a tenant from the lookup cache. It should be
+// called whenever a tenant is created, updated, or deleted.
+func (tm *TenantMiddleware) InvalidateTenant(tenantID int) {
+	tm.cache.invalidate(tenantID)
+}
+
+func tenantCacheKey(kind, val string) string {
+	return kind + ":" + val
+}
+
+func tenantIDCacheKey(id int) string {
+	return tenantCacheKey("id", strconv.Itoa(id))
+}
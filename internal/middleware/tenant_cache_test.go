@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+)
+
+// countingDB is a database connector with a single tenant that counts the
+// queries run on it.
+type countingDB struct {
+	settings string
+	queries  int
+	mut      sync.Mutex
+}
+
+func (d *countingDB) Connect(context.Context) (driver.Conn, error) { return &countingConn{d}, nil }
+func (d *countingDB) Driver() driver.Driver                        { return nil }
+
+func (d *countingDB) count() int {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+	return d.queries
+}
+
+type countingConn struct{ db *countingDB }
+
+func (c *countingConn) Prepare(q string) (driver.Stmt, error) { return &countingStmt{c.db}, nil }
+func (c *countingConn) Close() error                          { return nil }
+func (c *countingConn) Begin() (driver.Tx, error)             { return nil, errors.New("not supported") }
+
+type countingStmt struct{ db *countingDB }
+
+func (s *countingStmt) Close() error  { return nil }
+func (s *countingStmt) NumInput() int { return -1 }
+func (s *countingStmt) Exec([]driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (s *countingStmt) Query([]driver.Value) (driver.Rows, error) {
+	s.db.mut.Lock()
+	defer s.db.mut.Unlock()
+
+	s.db.queries++
+	return &countingRows{row: []driver.Value{int64(2), "acme", s.db.settings, "active"}}, nil
+}
+
+type countingRows struct{ row []driver.Value }
+
+func (r *countingRows) Columns() []string { return []string{"id", "slug", "settings", "status"} }
+func (r *countingRows) Close() error      { return nil }
+func (r *countingRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+func TestTenantCacheMissesNotCached(t *testing.T) {
+	c := newTenantCache(time.Minute)
+
+	calls := 0
+	miss := func() (*models.Tenant, error) {
+		calls++
+		return nil, ErrTenantNotFound
+	}
+	for i := 0; i < 3; i++ {
+		if _, err := c.lookup(tenantCacheKey("domain", "unknown.example.com"), miss); err != ErrTenantNotFound {
+			t.Fatalf("expected ErrTenantNotFound, got %v", err)
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("expected every miss to be looked up, got %d lookups", calls)
+	}
+	if len(c.items) != 0 {
+		t.Errorf("expected no cached entries, got %d", len(c.items))
+	}
+}
+
+func TestTenantCacheExpiry(t *testing.T) {
+	c := newTenantCache(time.Minute)
+	c.set(tenantIDCacheKey(1), &models.Tenant{ID: 1})
+
+	if tn, ok := c.get(tenantIDCacheKey(1)); !ok || tn.ID != 1 {
+		t.Fatal("expected cached tenant")
+	}
+
+	// Expire the entry.
+	it := c.items[tenantIDCacheKey(1)]
+	it.expiry = time.Now().Add(-time.Second)
+	c.items[tenantIDCacheKey(1)] = it
+
+	if _, ok := c.get(tenantIDCacheKey(1)); ok {
+		t.Fatal("expected expired entry to be a miss")
+	}
+	if _, ok := c.items[tenantIDCacheKey(1)]; ok {
+		t.Error("expected expired entry to be removed")
+	}
+}
+
+func TestTenantCacheSize(t *testing.T) {
+	c := newTenantCache(time.Minute)
+	for i := 0; i < maxTenantCacheItems+100; i++ {
+		c.set(tenantIDCacheKey(i), &models.Tenant{ID: i})
+	}
+
+	if len(c.items) > maxTenantCacheItems {
+		t.Errorf("expected at most %d entries, got %d", maxTenantCacheItems, len(c.items))
+	}
+
+	// Expired entries make room for new ones.
+	for k, it := range c.items {
+		it.expiry = time.Now().Add(-time.Second)
+		c.items[k] = it
+	}
+	c.set(tenantCacheKey("slug", strconv.Itoa(-1)), &models.Tenant{ID: -1})
+	if len(c.items) != 1 {
+		t.Errorf("expected expired entries to be swept, got %d entries", len(c.items))
+	}
+}
+
+func TestTenantLookupCached(t *testing.T) {
+	d := &countingDB{settings: `{"app.site_name": "Old"}`}
+	tm := NewTenantMiddleware(sqlx.NewDb(sql.OpenDB(d), "postgres"), nil)
+
+	for i := 0; i < 2; i++ {
+		tn, err := tm.GetTenantByID(2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(tn.Settings) != d.settings {
+			t.Fatalf("unexpected settings: %s", tn.Settings)
+		}
+	}
+	if n := d.count(); n != 1 {
+		t.Fatalf("expected the second lookup to be cached, got %d queries", n)
+	}
+
+	// The settings are updated and the tenant is invalidated, as the
+	// settings handler does.
+	d.mut.Lock()
+	d.settings = `{"app.site_name": "New"}`
+	d.mut.Unlock()
+	tm.InvalidateTenant(2)
+
+	tn, err := tm.GetTenantByID(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tn.Settings) != `{"app.site_name": "New"}` {
+		t.Errorf("expected the updated settings, got %s", tn.Settings)
+	}
+	if n := d.count(); n != 2 {
+		t.Errorf("expected the tenant to be fetched again, got %d queries", n)
+	}
+}
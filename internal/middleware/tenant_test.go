@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/knadh/listmonk/models"
+	"github.com/labstack/echo/v4"
+)
+
+// noDB is a database connector that fails every connection so that tenant
+// resolution only finds the tenants that are in the cache.
+type noDB struct{}
+
+func (noDB) Connect(context.Context) (driver.Conn, error) { return nil, errors.New("no database") }
+func (noDB) Driver() driver.Driver                        { return nil }
+
+type testSession map[string]any
+
+func (s testSession) Get(key string) (any, error) {
+	return s[key], nil
+}
+
+// impersonatingRequest resolves the tenant of a request by a user whose
+// session impersonates tenant 5.
+func impersonatingRequest(t *testing.T, superAdmin bool) (*models.TenantContext, error) {
+	t.Helper()
+
+	tm := NewTenantMiddleware(sqlx.NewDb(sql.OpenDB(noDB{}), "postgres"), nil)
+	tm.cache.set(tenantIDCacheKey(5), &models.Tenant{ID: 5, Slug: "five", Status: models.TenantStatusActive})
+
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/api/lists", nil), httptest.NewRecorder())
+	c.Set("auth_user", testUser{ID: 10, SuperAdmin: superAdmin})
+	c.Set(sessionCtxKey, testSession{
+		SessionImpersonateKey:      5,
+		SessionImpersonateUntilKey: time.Now().Add(time.Minute).Unix(),
+	})
+
+	return tm.ResolveTenant(c)
+}
+
+func TestResolveTenantImpersonation(t *testing.T) {
+	tc, err := impersonatingRequest(t, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tc.ID != 5 || !tc.Impersonated || tc.UserRole != models.TenantUserRoleAdmin {
+		t.Errorf("expected admin impersonation of tenant 5, got %+v", tc)
+	}
+}
+
+func TestResolveTenantImpersonationDenied(t *testing.T) {
+	// A user who's no longer a super admin doesn't get to keep impersonating.
+	tc, err := impersonatingRequest(t, false)
+	if err == nil && tc.Impersonated {
+		t.Fatalf("expected impersonation to be denied, got %+v", tc)
+	}
+}
+
+func TestGetSessionImpersonationExpiry(t *testing.T) {
+	e := echo.New()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder())
+	c.Set(sessionCtxKey, testSession{
+		SessionImpersonateKey:      5,
+		SessionImpersonateUntilKey: time.Now().Add(-time.Second).Unix(),
+	})
+
+	if _, ok := GetSessionImpersonation(c); ok {
+		t.Error("expected expired impersonation to be ignored")
+	}
+}
@@ -1,7 +1,12 @@
 package utils
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
 	"net/mail"
 	"net/url"
 	"path"
@@ -51,3 +56,78 @@ func SanitizeURI(u string) string {
 
 	return path.Clean(p.Path)
 }
+
+// secretPrefix marks values encrypted with EncryptSecret().
+const secretPrefix = "enc:"
+
+// EncryptSecret encrypts a secret (eg: a password) with AES-GCM using a key
+// derived from the given app-level secret key. The result is prefixed with
+// "enc:" so that it can be told apart from plaintext values. If the key is
+// empty, or the value is empty or already encrypted, it's returned as-is.
+func EncryptSecret(key, val string) (string, error) {
+	if key == "" || val == "" || IsEncryptedSecret(val) {
+		return val, nil
+	}
+
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	b := gcm.Seal(nonce, nonce, []byte(val), nil)
+	return secretPrefix + base64.StdEncoding.EncodeToString(b), nil
+}
+
+// DecryptSecret decrypts a secret encrypted with EncryptSecret(). Values that
+// aren't encrypted (eg: plaintext values stored before encryption was enabled)
+// are returned as-is.
+func DecryptSecret(key, val string) (string, error) {
+	if !IsEncryptedSecret(val) {
+		return val, nil
+	}
+	if key == "" {
+		return "", errors.New("secret key is required to decrypt the value")
+	}
+
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(val, secretPrefix))
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := newSecretCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	if len(b) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted value")
+	}
+
+	out, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", err
+	}
+
+	return string(out), nil
+}
+
+// IsEncryptedSecret checks whether a value was encrypted with EncryptSecret().
+func IsEncryptedSecret(val string) bool {
+	return strings.HasPrefix(val, secretPrefix)
+}
+
+// newSecretCipher returns an AES-GCM cipher with a 256 bit key derived from the secret key.
+func newSecretCipher(key string) (cipher.AEAD, error) {
+	k := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(k[:])
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
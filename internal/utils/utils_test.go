@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEncryptSecret(t *testing.T) {
+	enc, err := EncryptSecret("key", "password")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !IsEncryptedSecret(enc) || strings.Contains(enc, "password") {
+		t.Fatalf("expected an encrypted value, got %q", enc)
+	}
+
+	// Encrypting an encrypted value is a no-op.
+	if again, _ := EncryptSecret("key", enc); again != enc {
+		t.Errorf("expected encrypted value to be returned as-is, got %q", again)
+	}
+
+	dec, err := DecryptSecret("key", enc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if dec != "password" {
+		t.Errorf("expected %q, got %q", "password", dec)
+	}
+
+	if _, err := DecryptSecret("wrong", enc); err == nil {
+		t.Error("expected error decrypting with the wrong key")
+	}
+	if _, err := DecryptSecret("", enc); err == nil {
+		t.Error("expected error decrypting without a key")
+	}
+}
+
+func TestEncryptSecretPlaintext(t *testing.T) {
+	// Without a key, values are stored as-is.
+	if enc, _ := EncryptSecret("", "password"); enc != "password" {
+		t.Errorf("expected plaintext value without a key, got %q", enc)
+	}
+
+	// Plaintext values stored before encryption was enabled are read as-is.
+	if dec, err := DecryptSecret("key", "password"); err != nil || dec != "password" {
+		t.Errorf("expected plaintext value, got %q (%v)", dec, err)
+	}
+}
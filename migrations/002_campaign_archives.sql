@@ -0,0 +1,18 @@
+-- Rendered content of sent campaigns for the public archive.
+-- Requires 001_add_multitenancy.sql.
+
+CREATE TABLE IF NOT EXISTS campaign_archives (
+    campaign_id     INTEGER PRIMARY KEY REFERENCES campaigns(id) ON DELETE CASCADE,
+    tenant_id       INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    body            TEXT NOT NULL,
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    updated_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_campaign_archives_tenant_id ON campaign_archives(tenant_id);
+
+ALTER TABLE campaign_archives ENABLE ROW LEVEL SECURITY;
+
+-- Campaign archives RLS
+CREATE POLICY tenant_isolation_campaign_archives ON campaign_archives
+    FOR ALL 
+    USING (tenant_id = COALESCE(NULLIF(current_setting('app.current_tenant', true), '')::integer, -1));
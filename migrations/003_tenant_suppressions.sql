@@ -0,0 +1,19 @@
+-- Per-tenant suppression list of e-mail addresses (eg: complaint addresses)
+-- that campaign messages are never sent to.
+-- Requires 001_add_multitenancy.sql.
+
+CREATE TABLE IF NOT EXISTS tenant_suppressions (
+    tenant_id       INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    email           TEXT NOT NULL,
+    reason          TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, email)
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_suppressions_email ON tenant_suppressions(tenant_id, LOWER(email));
+
+ALTER TABLE tenant_suppressions ENABLE ROW LEVEL SECURITY;
+
+-- Tenant suppressions RLS
+CREATE POLICY tenant_isolation_tenant_suppressions ON tenant_suppressions
+    FOR ALL 
+    USING (tenant_id = COALESCE(NULLIF(current_setting('app.current_tenant', true), '')::integer, -1));
@@ -0,0 +1,6 @@
+-- Optional subscriber query expression (the same kind as the subscriber
+-- search/segment queries) that further filters a campaign's subscribers
+-- when it's being sent.
+-- Requires 001_add_multitenancy.sql.
+
+ALTER TABLE campaigns ADD COLUMN IF NOT EXISTS subscriber_query TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,20 @@
+-- Audit log of actions taken on tenants by staff, eg: super admins
+-- impersonating a tenant.
+-- Requires 001_add_multitenancy.sql.
+
+CREATE TABLE IF NOT EXISTS tenant_audit_log (
+    id              BIGSERIAL PRIMARY KEY,
+    tenant_id       INTEGER NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id         INTEGER NULL REFERENCES users(id) ON DELETE SET NULL,
+    action          TEXT NOT NULL,
+    meta            JSONB NOT NULL DEFAULT '{}',
+    created_at      TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_tenant_audit_log_tenant ON tenant_audit_log(tenant_id, created_at);
+
+ALTER TABLE tenant_audit_log ENABLE ROW LEVEL SECURITY;
+
+-- Tenant audit log RLS
+CREATE POLICY tenant_isolation_tenant_audit_log ON tenant_audit_log
+    FOR ALL 
+    USING (tenant_id = COALESCE(NULLIF(current_setting('app.current_tenant', true), '')::integer, -1));
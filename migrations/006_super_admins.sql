@@ -0,0 +1,7 @@
+-- Platform super admins, who can manage and impersonate all tenants. This is
+-- separate from the per-tenant super admin role (ID 1) that every tenant's
+-- admins have. Grant it explicitly, eg:
+--   UPDATE users SET is_super_admin = true WHERE username = 'admin' AND tenant_id = 1;
+-- Requires 001_add_multitenancy.sql.
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS is_super_admin BOOLEAN NOT NULL DEFAULT false;
@@ -241,6 +241,10 @@ type Campaign struct {
 	ArchiveTemplateID null.Int        `db:"archive_template_id" json:"archive_template_id"`
 	ArchiveMeta       json.RawMessage `db:"archive_meta" json:"archive_meta"`
 
+	// SubscriberQuery is an optional subscriber query expression that
+	// further filters the campaign's list subscribers when it's sent.
+	SubscriberQuery string `db:"subscriber_query" json:"subscriber_query"`
+
 	// TemplateBody is joined in from templates by the next-campaigns query.
 	TemplateBody        string             `db:"template_body" json:"-"`
 	ArchiveTemplateBody string             `db:"archive_template_body" json:"-"`
@@ -362,6 +366,10 @@ type Message struct {
 
 	// Messenger is the messenger backend to use: email|postback.
 	Messenger string
+
+	// TenantID is the ID of the tenant that the message is sent for, so that
+	// tenant aware messengers can route it. It's 0 for single-tenant messages.
+	TenantID int
 }
 
 // Attachment represents a file or blob attachment that can be
@@ -370,6 +378,19 @@ type Attachment struct {
 	Name    string
 	Header  textproto.MIMEHeader
 	Content []byte
+
+	// Loader, if set, loads the content on demand instead of it being held
+	// in Content, eg: to stream large campaign attachments from the media
+	// store per message instead of holding them for the campaign's duration.
+	Loader func() ([]byte, error) `json:"-"`
+}
+
+// Bytes returns the attachment's content, loading it with Loader if it's set.
+func (a Attachment) Bytes() ([]byte, error) {
+	if a.Loader != nil {
+		return a.Loader()
+	}
+	return a.Content, nil
 }
 
 // TxMessage represents an e-mail campaign.
@@ -438,6 +459,23 @@ func (subs Subscribers) LoadLists(stmt *sqlx.Stmt) error {
 		return err
 	}
 
+	return subs.attachLists(sl)
+}
+
+// LoadTenantLists is LoadLists for the tenant scoped lists query that
+// takes the tenant's ID as its first argument.
+func (subs Subscribers) LoadTenantLists(stmt *sqlx.Stmt, tenantID int) error {
+	var sl []subLists
+	err := stmt.Select(&sl, tenantID, pq.Array(subs.GetIDs()))
+	if err != nil {
+		return err
+	}
+
+	return subs.attachLists(sl)
+}
+
+// attachLists attaches lazy loaded lists to the subscribers they belong to.
+func (subs Subscribers) attachLists(sl []subLists) error {
 	if len(subs) != len(sl) {
 		return errors.New("campaign stats count does not match")
 	}
@@ -77,16 +77,17 @@ type Queries struct {
 	DeleteCampaignViews        *sqlx.Stmt `query:"delete-campaign-views"`
 	DeleteCampaignLinkClicks   *sqlx.Stmt `query:"delete-campaign-link-clicks"`
 
-	NextCampaigns            *sqlx.Stmt `query:"next-campaigns"`
-	GetRunningCampaign       *sqlx.Stmt `query:"get-running-campaign"`
-	NextCampaignSubscribers  *sqlx.Stmt `query:"next-campaign-subscribers"`
-	GetOneCampaignSubscriber *sqlx.Stmt `query:"get-one-campaign-subscriber"`
-	UpdateCampaign           *sqlx.Stmt `query:"update-campaign"`
-	UpdateCampaignStatus     *sqlx.Stmt `query:"update-campaign-status"`
-	UpdateCampaignCounts     *sqlx.Stmt `query:"update-campaign-counts"`
-	UpdateCampaignArchive    *sqlx.Stmt `query:"update-campaign-archive"`
-	RegisterCampaignView     *sqlx.Stmt `query:"register-campaign-view"`
-	DeleteCampaign           *sqlx.Stmt `query:"delete-campaign"`
+	NextCampaigns             *sqlx.Stmt `query:"next-campaigns"`
+	GetRunningCampaign        *sqlx.Stmt `query:"get-running-campaign"`
+	NextCampaignSubscribers   *sqlx.Stmt `query:"next-campaign-subscribers"`
+	NextCampaignSubscriberIDs string     `query:"next-campaign-subscriber-ids"`
+	GetOneCampaignSubscriber  *sqlx.Stmt `query:"get-one-campaign-subscriber"`
+	UpdateCampaign            *sqlx.Stmt `query:"update-campaign"`
+	UpdateCampaignStatus      *sqlx.Stmt `query:"update-campaign-status"`
+	UpdateCampaignCounts      *sqlx.Stmt `query:"update-campaign-counts"`
+	UpdateCampaignArchive     *sqlx.Stmt `query:"update-campaign-archive"`
+	RegisterCampaignView      *sqlx.Stmt `query:"register-campaign-view"`
+	DeleteCampaign            *sqlx.Stmt `query:"delete-campaign"`
 
 	InsertMedia *sqlx.Stmt `query:"insert-media"`
 	GetMedia    *sqlx.Stmt `query:"get-media"`
@@ -130,6 +131,20 @@ type Queries struct {
 	DeleteRole            *sqlx.Stmt `query:"delete-role"`
 	UpsertListPermissions *sqlx.Stmt `query:"upsert-list-permissions"`
 	DeleteListPermission  *sqlx.Stmt `query:"delete-list-permission"`
+
+	QueryTenants         string     `query:"query-tenants"`
+	InsertTenantAuditLog *sqlx.Stmt `query:"insert-tenant-audit-log"`
+
+	// Tenant queries of the campaign manager's store.
+	GetActiveTenantIDs    *sqlx.Stmt `query:"get-active-tenant-ids"`
+	GetTenantFeatures     *sqlx.Stmt `query:"get-tenant-features"`
+	SaveCampaignArchive   *sqlx.Stmt `query:"save-campaign-archive"`
+	GetTenantUsage        *sqlx.Stmt `query:"get-tenant-usage"`
+	RecordTenantBounce    *sqlx.Stmt `query:"record-tenant-bounce"`
+	GetTenantSuppressions *sqlx.Stmt `query:"get-tenant-suppressions"`
+	GetTenantAdminEmails  *sqlx.Stmt `query:"get-tenant-admin-emails"`
+	GetCampaignLinks      *sqlx.Stmt `query:"get-campaign-links"`
+	PauseTenantCampaigns  *sqlx.Stmt `query:"pause-tenant-campaigns"`
 }
 
 // compileSubscriberQueryTpl takes an arbitrary WHERE expressions
@@ -24,6 +24,14 @@ const (
 	TenantUserRoleViewer = "viewer"
 )
 
+// TenantFeature represents the name (json key) of an on/off feature in TenantFeatures.
+const (
+	TenantFeatureCustomDomain      = "custom_domain"
+	TenantFeatureAPIAccess         = "api_access"
+	TenantFeatureWebhooks          = "webhooks_enabled"
+	TenantFeatureAdvancedAnalytics = "advanced_analytics"
+)
+
 // Tenant represents a tenant/organization in the multi-tenant system.
 type Tenant struct {
 	ID           int         `db:"id" json:"id"`
@@ -45,6 +53,10 @@ type Tenant struct {
 	CampaignCount   int `db:"campaign_count" json:"campaign_count,omitempty"`
 	ListCount       int `db:"list_count" json:"list_count,omitempty"`
 	UserCount       int `db:"user_count" json:"user_count,omitempty"`
+
+	// Pseudofield for getting the total number of tenants
+	// in paginated queries.
+	Total int `db:"total" json:"-"`
 }
 
 // TenantUser represents the relationship between a user and a tenant.
@@ -78,10 +90,27 @@ type TenantFeatures struct {
 	MaxLists             int  `json:"max_lists"`
 	MaxTemplates         int  `json:"max_templates"`
 	MaxUsers             int  `json:"max_users"`
+	MaxStorageBytes      int64 `json:"max_storage_bytes"`
+	APIRateLimit         int  `json:"api_rate_limit"` // API requests per rate limit window
+	MaxConcurrency       int  `json:"max_concurrency"` // Campaign workers for the plan's tier
 	CustomDomain         bool `json:"custom_domain"`
 	APIAccess            bool `json:"api_access"`
 	WebhooksEnabled      bool `json:"webhooks_enabled"`
 	AdvancedAnalytics    bool `json:"advanced_analytics"`
+
+	// Disables the sprig template functions that generate random or
+	// cryptographic data, do network lookups, or handle file paths.
+	RestrictTemplateFuncs bool `json:"restrict_template_funcs"`
+}
+
+// TenantCampaignAnalytics holds the aggregated performance of a tenant's campaign.
+type TenantCampaignAnalytics struct {
+	CampaignID   int `db:"campaign_id" json:"campaign_id"`
+	Sent         int `db:"sent" json:"sent"`
+	Views        int `db:"views" json:"views"`
+	Clicks       int `db:"clicks" json:"clicks"`
+	Bounces      int `db:"bounces" json:"bounces"`
+	Unsubscribes int `db:"unsubscribes" json:"unsubscribes"`
 }
 
 // TenantContext holds the current tenant information for a request.
@@ -94,6 +123,11 @@ type TenantContext struct {
 	Settings types.JSONText `json:"settings"`
 	Features *TenantFeatures `json:"features"`
 	UserRole string         `json:"user_role"` // Role of current user in this tenant
+	ReadOnly bool           `json:"read_only"` // Tenant is suspended and only allows reads
+
+	// Impersonated is set when a super admin is acting within the tenant
+	// temporarily without being a member of it.
+	Impersonated bool `json:"impersonated"`
 }
 
 // Scan implements the sql.Scanner interface for TenantFeatures.
@@ -110,11 +144,38 @@ func (tf TenantFeatures) Value() (driver.Value, error) {
         return json.Marshal(tf)
 }
 
+// Has checks if the named on/off feature (eg: TenantFeatureAPIAccess) is
+// enabled. Unknown features are considered disabled.
+func (tf *TenantFeatures) Has(name string) bool {
+	if tf == nil {
+		return false
+	}
+
+	switch name {
+	case TenantFeatureCustomDomain:
+		return tf.CustomDomain
+	case TenantFeatureAPIAccess:
+		return tf.APIAccess
+	case TenantFeatureWebhooks:
+		return tf.WebhooksEnabled
+	case TenantFeatureAdvancedAnalytics:
+		return tf.AdvancedAnalytics
+	}
+
+	return false
+}
+
 // IsActive checks if the tenant is active.
 func (t *Tenant) IsActive() bool {
 	return t.Status == TenantStatusActive
 }
 
+// IsSuspended checks if the tenant is suspended. Suspended tenants have
+// read-only access.
+func (t *Tenant) IsSuspended() bool {
+	return t.Status == TenantStatusSuspended
+}
+
 // CanAddSubscriber checks if the tenant can add more subscribers.
 func (t *Tenant) CanAddSubscriber(currentCount int, features *TenantFeatures) bool {
 	if features == nil || features.MaxSubscribers == 0 {
@@ -541,7 +541,7 @@ counts AS (
 camp AS (
     INSERT INTO campaigns (tenant_id, uuid, type, name, subject, from_email, body, altbody,
         content_type, send_at, headers, tags, messenger, template_id, to_send,
-        max_subscriber_id, archive, archive_slug, archive_template_id, archive_meta, body_source)
+        max_subscriber_id, archive, archive_slug, archive_template_id, archive_meta, body_source, subscriber_query)
         SELECT $1, $2, $3, $4, $5, $6,
             -- body
             COALESCE(NULLIF($7, ''), (SELECT body FROM tpl), ''),
@@ -556,7 +556,8 @@ camp AS (
             $18,
             $19,
             -- body_source
-            COALESCE($21, (SELECT body_source FROM tpl))
+            COALESCE($21, (SELECT body_source FROM tpl)),
+            COALESCE($22, '')
         RETURNING id
 ),
 med AS (
@@ -805,7 +806,8 @@ SELECT COUNT(%s) AS "count", url
 -- name: get-running-campaign
 -- Returns the metadata for a running campaign that is required by next-campaign-subscribers to retrieve
 -- a batch of campaign subscribers for processing.
-SELECT campaigns.id AS campaign_id, campaigns.type as campaign_type, last_subscriber_id, max_subscriber_id, lists.id AS list_id
+SELECT campaigns.id AS campaign_id, campaigns.type as campaign_type, last_subscriber_id, max_subscriber_id,
+    campaigns.subscriber_query, lists.id AS list_id
     FROM campaigns
     LEFT JOIN campaign_lists ON (campaign_lists.campaign_id = campaigns.id)
     LEFT JOIN lists ON (lists.tenant_id = $1 AND lists.id = campaign_lists.list_id)
@@ -823,6 +825,9 @@ SELECT campaigns.id AS campaign_id, campaigns.type as campaign_type, last_subscr
 -- the query planner works as expected. The difference is staggering. ~15 seconds on a subscribers table with 15m
 -- rows and a subscriber_lists table with 70 million rows when fetching subscribers for a campaign with a single list,
 -- vs. a few million seconds using this current approach.
+--
+-- $8 is the optional list of subscriber IDs that match the campaign's subscriber query
+-- expression, from next-campaign-subscriber-ids. It's NULL for campaigns without one.
 WITH campLists AS (
     SELECT lists.id AS list_id, optin FROM lists
     LEFT JOIN campaign_lists ON campaign_lists.list_id = lists.id
@@ -843,6 +848,7 @@ subs AS (
             AND s.id <= $5
              -- Subscriber should not be blacklisted.
             AND s.tenant_id = $1 AND s.status != 'blocklisted'
+            AND ($8::INT[] IS NULL OR s.id = ANY($8::INT[]))
             AND (
                 -- If it's an optin campaign and the list is double-optin, only pick unconfirmed subscribers.
                 ($3 = 'optin' AND sl.status = 'unconfirmed' AND campLists.optin = 'double')
@@ -867,6 +873,39 @@ u AS (
 )
 SELECT * FROM subs;
 
+-- name: next-campaign-subscriber-ids
+-- raw: true
+-- Replica of the subscriber selection in next-campaign-subscribers that returns the IDs
+-- of the next batch of subscribers that also match a campaign's subscriber query
+-- expression (%query%). The expression is arbitrary SQL, so this is only run in read-only
+-- transactions and doesn't update the campaign. The IDs are then passed to
+-- next-campaign-subscribers as $8.
+WITH campLists AS (
+    SELECT lists.id AS list_id, optin FROM lists
+    LEFT JOIN campaign_lists ON campaign_lists.list_id = lists.id
+    WHERE lists.tenant_id = $1 AND campaign_lists.campaign_id = $2
+)
+SELECT DISTINCT subscribers.id
+    FROM subscriber_lists sl
+    JOIN campLists ON sl.list_id = campLists.list_id
+    JOIN subscribers ON subscribers.id = sl.subscriber_id
+    WHERE
+        sl.list_id = ANY($6::INT[])
+        AND subscribers.id > $4
+        AND subscribers.id <= $5
+        AND subscribers.tenant_id = $1 AND subscribers.status != 'blocklisted'
+        AND (%query%)
+        AND (
+            ($3 = 'optin' AND sl.status = 'unconfirmed' AND campLists.optin = 'double')
+            OR (
+                $3 != 'optin' AND (
+                    (campLists.optin = 'double' AND sl.status = 'confirmed') OR
+                    (campLists.optin != 'double' AND sl.status != 'unsubscribed')
+                )
+            )
+        )
+    ORDER BY subscribers.id LIMIT $7;
+
 -- name: delete-campaign-views
 DELETE FROM campaign_views cv USING campaigns c 
 WHERE cv.campaign_id = c.id AND c.tenant_id = $1 AND cv.created_at < $2;
@@ -911,6 +950,7 @@ WITH camp AS (
         archive_template_id=(CASE WHEN $8::content_type = 'visual' THEN NULL ELSE $17::INT END),
         archive_meta=$18,
         body_source=$20,
+        subscriber_query=COALESCE($21, ''),
         updated_at=NOW()
     WHERE tenant_id = $1 AND id = $2 RETURNING id
 ),
@@ -942,6 +982,8 @@ UPDATE campaigns SET
 WHERE tenant_id = $1 AND id=$2;
 
 -- name: update-campaign-status
+-- $4 is the optional list of statuses that the campaign has to be in to be
+-- updated so that a concurrent status change isn't overwritten.
 UPDATE campaigns SET
     status=(
         CASE
@@ -950,7 +992,8 @@ UPDATE campaigns SET
         END
     ),
     updated_at=NOW()
-WHERE tenant_id = $1 AND id = $2;
+WHERE tenant_id = $1 AND id = $2
+    AND ($4::campaign_status[] IS NULL OR status = ANY($4::campaign_status[]));
 
 -- name: update-campaign-archive
 UPDATE campaigns SET
@@ -1354,3 +1397,83 @@ UPDATE roles SET name=$3, permissions=$4 WHERE tenant_id = $1 AND id=$2 and pare
 
 -- name: delete-role
 DELETE FROM roles WHERE tenant_id = $1 AND id=$2;
+
+-- tenants
+-- name: query-tenants
+-- raw: true
+-- Fetches a page of tenants with their resource counts. The total number of
+-- matching tenants is repeated on every row for pagination. $3 is a
+-- case-insensitive substring of the name, slug, or domain, and $4 is a status.
+-- Deleted tenants are only returned when they're asked for. %order% is
+-- replaced with the sort order.
+SELECT t.*, COUNT(*) OVER () AS total,
+    (SELECT COUNT(*) FROM subscribers WHERE tenant_id = t.id) AS subscriber_count,
+    (SELECT COUNT(*) FROM campaigns WHERE tenant_id = t.id) AS campaign_count,
+    (SELECT COUNT(*) FROM lists WHERE tenant_id = t.id) AS list_count,
+    (SELECT COUNT(*) FROM user_tenants WHERE tenant_id = t.id) AS user_count
+FROM tenants t
+WHERE (CASE WHEN $4 != '' THEN t.status = $4 ELSE t.status != 'deleted' END)
+    AND ($3 = '' OR t.name ILIKE $3 OR t.slug ILIKE $3 OR t.domain ILIKE $3)
+ORDER BY %order% OFFSET $1 LIMIT (CASE WHEN $2 < 1 THEN NULL ELSE $2 END);
+
+-- name: insert-tenant-audit-log
+-- Records an action taken on a tenant by a user, eg: a super admin impersonating it.
+INSERT INTO tenant_audit_log (tenant_id, user_id, action, meta) VALUES($1, $2, $3, $4);
+
+-- name: get-active-tenant-ids
+-- Returns the IDs of active tenants that have running or scheduled campaigns to process.
+SELECT DISTINCT t.id FROM tenants t
+    JOIN campaigns c ON (c.tenant_id = t.id)
+    WHERE t.status = 'active' AND c.status IN ('running', 'scheduled')
+    ORDER BY t.id;
+
+-- name: get-tenant-features
+SELECT features FROM tenants WHERE id = $1;
+
+-- name: save-campaign-archive
+-- Saves the rendered content of a tenant's campaign for the archive.
+INSERT INTO campaign_archives (campaign_id, tenant_id, body)
+    SELECT id, tenant_id, $3 FROM campaigns WHERE tenant_id = $1 AND id = $2
+    ON CONFLICT (campaign_id) DO UPDATE SET body=EXCLUDED.body, updated_at=NOW();
+
+-- name: get-tenant-usage
+-- Returns a tenant's usage that's counted against its plan's limits.
+SELECT
+    (SELECT COUNT(*) FROM campaigns WHERE tenant_id = $1 AND created_at >= DATE_TRUNC('month', CURRENT_DATE)) AS campaigns_this_month,
+    (SELECT COUNT(*) FROM subscribers WHERE tenant_id = $1) AS subscribers;
+
+-- name: record-tenant-bounce
+-- Records a bounce for a tenant's subscriber and returns the number of bounces
+-- of the same type recorded for the subscriber including this one. Nothing is
+-- returned if the subscriber doesn't exist in the tenant.
+WITH b AS (
+    INSERT INTO bounces (tenant_id, subscriber_id, campaign_id, type, source, meta)
+    SELECT $1, id, (SELECT id FROM campaigns WHERE tenant_id = $1 AND $3 != '' AND uuid = $3::UUID), $4, $5, $6
+    FROM subscribers WHERE tenant_id = $1 AND id = $2
+    RETURNING subscriber_id
+)
+SELECT (SELECT COUNT(*) FROM bounces WHERE tenant_id = $1 AND subscriber_id = b.subscriber_id AND type = $4) + 1 FROM b;
+
+-- name: get-tenant-suppressions
+SELECT email FROM tenant_suppressions WHERE tenant_id = $1;
+
+-- name: get-tenant-admin-emails
+-- Returns the e-mails of a tenant's enabled owners and admins.
+SELECT u.email FROM users u
+    JOIN user_tenants ut ON (ut.user_id = u.id)
+    WHERE ut.tenant_id = $1 AND ut.role IN ('owner', 'admin')
+        AND u.type = 'user' AND u.status = 'enabled'
+    ORDER BY u.id;
+
+-- name: get-campaign-links
+-- Returns the URL to UUID mappings of a tenant's tracked links that appear in
+-- the given campaigns' bodies.
+SELECT DISTINCT l.url, l.uuid FROM links l
+    JOIN campaigns c ON (c.tenant_id = l.tenant_id)
+    WHERE l.tenant_id = $1 AND c.uuid = ANY($2::UUID[])
+        AND (STRPOS(c.body, l.url) > 0 OR STRPOS(COALESCE(c.altbody, ''), l.url) > 0);
+
+-- name: pause-tenant-campaigns
+-- Pauses all of a tenant's running campaigns and returns their IDs.
+UPDATE campaigns SET status='paused', updated_at=NOW()
+    WHERE tenant_id = $1 AND status = 'running' RETURNING id;
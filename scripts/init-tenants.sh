@@ -239,6 +239,14 @@ assign_super_admin() {
     else
         log_warn "Failed to assign super admin to default tenant"
     fi
+
+    # Flag the user as a platform super admin who can manage all tenants
+    # (migrations/006_super_admins.sql).
+    if execute_sql "UPDATE users SET is_super_admin = true WHERE id = $user_id;" "Flag super admin"; then
+        log_info "User (ID: $user_id) flagged as super admin"
+    else
+        log_warn "Failed to flag super admin"
+    fi
 }
 
 # Function to set up tenant-specific settings